@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/anyulbade/payment-method-health-monitor/internal/database"
 	"github.com/anyulbade/payment-method-health-monitor/internal/handler"
 	"github.com/anyulbade/payment-method-health-monitor/internal/middleware"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
 	"github.com/anyulbade/payment-method-health-monitor/internal/service"
 )
@@ -60,8 +62,11 @@ func main() {
 	healthHandler := handler.NewHealthHandler(pool)
 	router.GET("/health", healthHandler.Health)
 
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
 	handler.SetupSwagger(router)
-	setupAPIRoutes(router, pool)
+	setupAPIRoutes(workerCtx, router, pool, cfg)
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -83,6 +88,7 @@ func main() {
 	<-quit
 
 	log.Info().Msg("shutting down server")
+	stopWorkers()
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
 
@@ -93,7 +99,30 @@ func main() {
 	log.Info().Msg("server exited")
 }
 
-func setupAPIRoutes(router *gin.Engine, pool *pgxpool.Pool) {
+// setupAPIRoutes wires the API and starts the background workers, which stop
+// when workerCtx is cancelled.
+func setupAPIRoutes(workerCtx context.Context, router *gin.Engine, pool *pgxpool.Pool, cfg *config.Config) {
+	healthWeights, err := service.ParseHealthWeights(cfg.HealthScoreWeights)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid HEALTH_SCORE_WEIGHTS")
+	}
+	detectionInterval, err := time.ParseDuration(cfg.InsightDetectionInterval)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid INSIGHT_DETECTION_INTERVAL")
+	}
+	webhookInterval, err := time.ParseDuration(cfg.WebhookPollInterval)
+	if err != nil || webhookInterval <= 0 {
+		log.Fatal().Err(err).Msg("invalid WEBHOOK_POLL_INTERVAL")
+	}
+	webhookAttempts, err := strconv.Atoi(cfg.WebhookMaxAttempts)
+	if err != nil || webhookAttempts < 1 {
+		log.Fatal().Err(err).Msg("invalid WEBHOOK_MAX_ATTEMPTS")
+	}
+	notificationInterval, err := time.ParseDuration(cfg.NotificationPollInterval)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid NOTIFICATION_POLL_INTERVAL")
+	}
+
 	txnRepo := repository.NewTransactionRepository(pool)
 	pmRepo := repository.NewPaymentMethodRepository(pool)
 	metricsRepo := repository.NewMetricsRepository(pool)
@@ -101,32 +130,174 @@ func setupAPIRoutes(router *gin.Engine, pool *pgxpool.Pool) {
 	trendRepo := repository.NewTrendRepository(pool)
 	roiRepo := repository.NewROIRepository(pool)
 	marketGapRepo := repository.NewMarketGapRepository(pool)
+	benchmarkRepo := repository.NewBenchmarkRepository(pool)
+	healthScoreRepo := repository.NewHealthScoreRepository(pool)
+	fxRepo := repository.NewFxRepository(pool)
+	ruleRepo := repository.NewDetectionRuleRepository(pool)
+	insightStateRepo := repository.NewInsightStateRepository(pool)
+	webhookRepo := repository.NewWebhookRepository(pool)
+	notificationRepo := repository.NewNotificationRepository(pool)
+	pendingRepo := repository.NewPendingRepository(pool)
+	amountBandRepo := repository.NewAmountBandRepository(pool)
+	evidenceRepo := repository.NewEvidenceRepository(pool)
+	customRuleRepo := repository.NewCustomRuleRepository(pool)
+	eventRepo := repository.NewEventRepository(pool)
+	feedbackRepo := repository.NewInsightFeedbackRepository(pool)
 
 	txnService := service.NewTransactionService(txnRepo, pmRepo)
-	metricsService := service.NewMetricsService(metricsRepo)
-	insightService := service.NewInsightService(insightRepo)
 	trendService := service.NewTrendService(trendRepo)
 	roiService := service.NewROIService(roiRepo)
+	healthScoreService := service.NewHealthScoreService(healthScoreRepo, trendService, roiService, healthWeights)
+	metricsService := service.NewMetricsService(metricsRepo, healthScoreService)
+	detectors := service.NewDetectorRegistry()
+	if err := service.RegisterBuiltinDetectors(detectors, insightRepo, pendingRepo, amountBandRepo, trendService, customRuleRepo, metricsRepo); err != nil {
+		log.Fatal().Err(err).Msg("failed to register insight detectors")
+	}
+	for _, t := range cfg.DisabledDetectors {
+		if err := detectors.SetEnabled(t, false); err != nil {
+			log.Fatal().Err(err).Msg("invalid DISABLED_DETECTORS")
+		}
+	}
+	ruleService := service.NewDetectionRuleService(ruleRepo, pmRepo, detectors)
+	insightService := service.NewInsightService(detectors, ruleService, insightStateRepo)
+	eventService := service.NewEventService(eventRepo, pmRepo)
+	insightService.SetEventService(eventService)
+	webhookService := service.NewWebhookService(webhookRepo, &http.Client{Timeout: 10 * time.Second}, webhookAttempts)
+	insightService.AddNotifier(webhookService)
+	notificationService := service.NewNotificationService(notificationRepo, map[string]service.ChannelSender{
+		model.ChannelKindEmail: service.SMTPSender{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		},
+		model.ChannelKindChatWebhook: service.ChatWebhookSender{Client: &http.Client{Timeout: 10 * time.Second}},
+		model.ChannelKindLog:         service.LogSender{},
+	})
+	insightService.AddNotifier(notificationService)
 	marketGapService := service.NewMarketGapService(marketGapRepo)
-	reportService := service.NewReportService(metricsService, insightService)
+	benchmarkService := service.NewBenchmarkService(benchmarkRepo)
+	reportService := service.NewReportService(metricsService, insightService, eventService)
+	currencyService := service.NewCurrencyService(fxRepo)
+	pendingService := service.NewPendingService(pendingRepo, ruleService)
+	amountBandService := service.NewAmountBandService(amountBandRepo, ruleService)
+	evidenceService := service.NewEvidenceService(detectors, insightStateRepo, pmRepo, evidenceRepo)
+	customRuleService := service.NewCustomRuleService(customRuleRepo)
+	feedbackService := service.NewInsightFeedbackService(feedbackRepo, ruleService, detectors, cfg.AdaptiveThresholds)
 
 	txnHandler := handler.NewTransactionHandler(txnService)
-	metricsHandler := handler.NewMetricsHandler(metricsService)
+	metricsHandler := handler.NewMetricsHandler(metricsService, currencyService)
 	insightHandler := handler.NewInsightHandler(insightService)
-	trendHandler := handler.NewTrendHandler(trendService)
-	roiHandler := handler.NewROIHandler(roiService)
+	trendHandler := handler.NewTrendHandler(trendService, currencyService, eventService)
+	roiHandler := handler.NewROIHandler(roiService, currencyService)
 	marketGapHandler := handler.NewMarketGapHandler(marketGapService)
-	reportHandler := handler.NewReportHandler(reportService)
+	benchmarkHandler := handler.NewBenchmarkHandler(benchmarkService)
+	healthScoreHandler := handler.NewHealthScoreHandler(healthScoreService)
+	reportHandler := handler.NewReportHandler(reportService, currencyService)
+	ruleHandler := handler.NewDetectionRuleHandler(ruleService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	pendingHandler := handler.NewPendingHandler(pendingService)
+	amountBandHandler := handler.NewAmountBandHandler(amountBandService)
+	evidenceHandler := handler.NewEvidenceHandler(evidenceService)
+	customRuleHandler := handler.NewCustomRuleHandler(customRuleService)
+	eventHandler := handler.NewEventHandler(eventService)
+	feedbackHandler := handler.NewInsightFeedbackHandler(feedbackService)
 
 	api := router.Group("/api/v1")
 	{
 		api.POST("/transactions", txnHandler.Create)
 		api.POST("/transactions/batch", txnHandler.CreateBatch)
 		api.GET("/metrics", metricsHandler.GetMetrics)
+		api.GET("/metrics/compare", metricsHandler.CompareMetrics)
 		api.GET("/insights", insightHandler.GetInsights)
+		api.GET("/insights/detectors", insightHandler.ListDetectors)
+		api.GET("/insights/tracked", insightHandler.ListTracked)
+		api.GET("/insights/precision", feedbackHandler.GetPrecision)
+		api.POST("/insights/backtest", insightHandler.Backtest)
+		api.GET("/insights/:id", insightHandler.GetTracked)
+		api.GET("/insights/:id/evidence", evidenceHandler.GetEvidence)
+		api.POST("/insights/:id/state", insightHandler.TransitionState)
+		api.PUT("/insights/:id/assignee", insightHandler.SetAssignee)
+		api.POST("/insights/:id/notes", insightHandler.AddNote)
+		api.POST("/insights/:id/feedback", feedbackHandler.AddFeedback)
+		api.GET("/insights/:id/feedback", feedbackHandler.ListFeedback)
 		api.GET("/trends", trendHandler.GetTrends)
+		api.POST("/events", eventHandler.CreateEvent)
+		api.GET("/events", eventHandler.ListEvents)
+		api.DELETE("/events/:id", eventHandler.DeleteEvent)
 		api.GET("/roi", roiHandler.GetROI)
 		api.GET("/market-gaps", marketGapHandler.GetMarketGaps)
+		api.GET("/benchmarks", benchmarkHandler.GetBenchmarks)
+		api.GET("/health-scores", healthScoreHandler.GetHealthScores)
+		api.GET("/pending/aging", pendingHandler.GetAging)
+		api.GET("/amount-bands", amountBandHandler.GetAmountBands)
 		api.GET("/reports/health", reportHandler.GetReport)
+		api.GET("/admin/detection-rules", ruleHandler.ListRules)
+		api.PUT("/admin/detection-rules", ruleHandler.SaveRule)
+		api.GET("/admin/detection-rules/effective", ruleHandler.GetEffective)
+		api.GET("/admin/detection-rules/:id", ruleHandler.GetRule)
+		api.POST("/admin/insight-rules", customRuleHandler.CreateRule)
+		api.GET("/admin/insight-rules", customRuleHandler.ListRules)
+		api.GET("/admin/insight-rules/:id", customRuleHandler.GetRule)
+		api.PUT("/admin/insight-rules/:id", customRuleHandler.UpdateRule)
+		api.DELETE("/admin/insight-rules/:id", customRuleHandler.DeleteRule)
+		api.GET("/admin/threshold-suggestions", feedbackHandler.ListSuggestions)
+		api.POST("/admin/threshold-suggestions/:id/accept", feedbackHandler.AcceptSuggestion)
+		api.POST("/webhooks", webhookHandler.CreateSubscription)
+		api.GET("/webhooks", webhookHandler.ListSubscriptions)
+		api.DELETE("/webhooks/:id", webhookHandler.DeleteSubscription)
+		api.GET("/webhooks/deliveries", webhookHandler.ListDeliveries)
+		api.POST("/webhooks/deliveries/:id/redeliver", webhookHandler.Redeliver)
+		api.POST("/notifications/channels", notificationHandler.CreateChannel)
+		api.GET("/notifications/channels", notificationHandler.ListChannels)
+		api.DELETE("/notifications/channels/:id", notificationHandler.DeleteChannel)
+		api.POST("/notifications/routes", notificationHandler.CreateRoute)
+		api.GET("/notifications/routes", notificationHandler.ListRoutes)
+		api.DELETE("/notifications/routes/:id", notificationHandler.DeleteRoute)
+		api.GET("/notifications/log", notificationHandler.ListLog)
+	}
+
+	if detectionInterval > 0 {
+		go runEvery(workerCtx, detectionInterval, "insight detection", func(ctx context.Context) error {
+			_, err := insightService.DetectInsights(ctx, model.AnalyticsFilter{}, "", "")
+			return err
+		})
+	}
+	go runEvery(workerCtx, webhookInterval, "webhook delivery", func(ctx context.Context) error {
+		// Drain full batches before waiting for the next tick.
+		for {
+			n, err := webhookService.DeliverDue(ctx)
+			if err != nil || n == 0 {
+				return err
+			}
+		}
+	})
+	if notificationInterval > 0 {
+		go runEvery(workerCtx, notificationInterval, "notification delivery", func(ctx context.Context) error {
+			for {
+				n, err := notificationService.DeliverDue(ctx)
+				if err != nil || n == 0 {
+					return err
+				}
+			}
+		})
+	}
+}
+
+// runEvery calls fn every interval until ctx is cancelled, logging failures.
+func runEvery(ctx context.Context, interval time.Duration, name string, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Str("worker", name).Msg("background job failed")
+			}
+		}
 	}
 }
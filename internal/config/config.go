@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 )
 
 type Config struct {
@@ -15,6 +16,21 @@ type Config struct {
 	DBSSLMode   string
 	AutoMigrate bool
 	GinMode     string
+
+	HealthScoreWeights string
+	DisabledDetectors  []string
+	AdaptiveThresholds bool
+
+	InsightDetectionInterval string
+	WebhookPollInterval      string
+	WebhookMaxAttempts       string
+
+	NotificationPollInterval string
+	SMTPHost                 string
+	SMTPPort                 string
+	SMTPUsername             string
+	SMTPPassword             string
+	SMTPFrom                 string
 }
 
 func Load() *Config {
@@ -28,6 +44,21 @@ func Load() *Config {
 		DBSSLMode:   getEnv("DB_SSLMODE", "disable"),
 		AutoMigrate: getEnv("AUTO_MIGRATE", "false") == "true",
 		GinMode:     getEnv("GIN_MODE", "debug"),
+
+		HealthScoreWeights: getEnv("HEALTH_SCORE_WEIGHTS", ""),
+		DisabledDetectors:  getEnvList("DISABLED_DETECTORS"),
+		AdaptiveThresholds: getEnv("ADAPTIVE_THRESHOLDS", "false") == "true",
+
+		InsightDetectionInterval: getEnv("INSIGHT_DETECTION_INTERVAL", "15m"),
+		WebhookPollInterval:      getEnv("WEBHOOK_POLL_INTERVAL", "10s"),
+		WebhookMaxAttempts:       getEnv("WEBHOOK_MAX_ATTEMPTS", "8"),
+
+		NotificationPollInterval: getEnv("NOTIFICATION_POLL_INTERVAL", "30s"),
+		SMTPHost:                 getEnv("SMTP_HOST", ""),
+		SMTPPort:                 getEnv("SMTP_PORT", "25"),
+		SMTPUsername:             getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:             getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:                 getEnv("SMTP_FROM", "pmhm@localhost"),
 	}
 }
 
@@ -42,3 +73,13 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -46,7 +46,7 @@ func TestMigrations_ApplyAndRollback(t *testing.T) {
 	require.NoError(t, err, "migrations should apply cleanly")
 
 	// Verify tables exist
-	tables := []string{"countries", "payment_methods", "payment_method_countries", "transactions", "integration_costs", "country_payment_catalog"}
+	tables := []string{"countries", "payment_methods", "payment_method_countries", "transactions", "integration_costs", "country_payment_catalog", "fx_rates", "detection_rules", "detection_rule_versions", "insights", "insight_transitions", "insight_notes", "webhook_subscriptions", "webhook_deliveries", "notification_channels", "notification_routes", "notification_queue", "notification_log", "custom_insight_rules", "events", "insight_feedback"}
 	for _, table := range tables {
 		var exists bool
 		err := pool.QueryRow(context.Background(),
@@ -63,6 +63,18 @@ func TestMigrations_ApplyAndRollback(t *testing.T) {
 	err = RunMigrations(dbURL)
 	require.NoError(t, err, "re-apply should succeed")
 
+	// Detectors run on their registered defaults; no rules are seeded
+	var seeded []string
+	rows, err := pool.Query(context.Background(), "SELECT rule_set FROM detection_rules ORDER BY rule_set")
+	require.NoError(t, err)
+	for rows.Next() {
+		var ruleSet string
+		require.NoError(t, rows.Scan(&ruleSet))
+		seeded = append(seeded, ruleSet)
+	}
+	require.NoError(t, rows.Err())
+	assert.Empty(t, seeded)
+
 	// Verify CHECK constraints
 	t.Run("country code constraint", func(t *testing.T) {
 		_, err := pool.Exec(context.Background(),
@@ -111,6 +111,13 @@ func SeedData(ctx context.Context, pool *pgxpool.Pool) error {
 		if err != nil {
 			return fmt.Errorf("insert country %s: %w", c.Code, err)
 		}
+
+		_, err = tx.Exec(ctx,
+			"INSERT INTO fx_rates (currency, rate_to_usd, effective_from) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+			c.Currency, c.FxRate, "2000-01-01")
+		if err != nil {
+			return fmt.Errorf("insert fx rate %s: %w", c.Currency, err)
+		}
 	}
 	log.Info().Int("count", len(countries)).Msg("inserted countries")
 
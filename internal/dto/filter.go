@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+// ParseFilter reads the shared analytics filter params. country,
+// payment_method and type take comma-separated lists and may be repeated.
+func ParseFilter(c *gin.Context) model.AnalyticsFilter {
+	return model.AnalyticsFilter{
+		Countries:      ParseList(c, "country"),
+		PaymentMethods: ParseList(c, "payment_method"),
+		Types:          ParseList(c, "type"),
+		DateFrom:       c.Query("date_from"),
+		DateTo:         c.Query("date_to"),
+		Expr:           strings.TrimSpace(c.Query("filter")),
+	}
+}
+
+// ParseList reads a comma-separated, repeatable query param.
+func ParseList(c *gin.Context, param string) []string {
+	var values []string
+	for _, raw := range c.QueryArray(param) {
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
@@ -16,3 +16,121 @@ type CreateTransactionRequest struct {
 type BatchTransactionRequest struct {
 	Transactions []CreateTransactionRequest `json:"transactions" binding:"required,min=1,max=500,dive"`
 }
+
+type UpsertDetectionRuleRequest struct {
+	RuleSet           string             `json:"rule_set" binding:"required"`
+	CountryCode       string             `json:"country_code" binding:"omitempty,len=2"`
+	PaymentMethodType string             `json:"payment_method_type" binding:"omitempty,oneof=CARD CASH BANK_TRANSFER WALLET BNPL"`
+	PaymentMethodCode string             `json:"payment_method_code" binding:"omitempty,max=50"`
+	Params            map[string]float64 `json:"params" binding:"required,min=1"`
+	ChangedBy         string             `json:"changed_by" binding:"required,max=100"`
+	Note              string             `json:"note" binding:"max=500"`
+}
+
+// InsightBacktestRequest replays a detector over a date range. Params, when
+// set, are tried as the rule for the given scope instead of the stored one.
+type InsightBacktestRequest struct {
+	RuleSet           string             `json:"rule_set" binding:"required"`
+	DateFrom          string             `json:"date_from" binding:"required"`
+	DateTo            string             `json:"date_to" binding:"required"`
+	CountryCode       string             `json:"country_code" binding:"omitempty,len=2"`
+	PaymentMethodType string             `json:"payment_method_type" binding:"omitempty,oneof=CARD CASH BANK_TRANSFER WALLET BNPL"`
+	PaymentMethodCode string             `json:"payment_method_code" binding:"omitempty,max=50"`
+	Params            map[string]float64 `json:"params"`
+}
+
+type InsightTransitionRequest struct {
+	State        string     `json:"state" binding:"required,oneof=OPEN ACKNOWLEDGED SNOOZED RESOLVED"`
+	SnoozedUntil *time.Time `json:"snoozed_until"`
+	Actor        string     `json:"actor" binding:"required,max=100"`
+	Note         string     `json:"note" binding:"max=2000"`
+}
+
+type InsightAssigneeRequest struct {
+	Assignee string `json:"assignee" binding:"max=100"`
+}
+
+type InsightNoteRequest struct {
+	Author string `json:"author" binding:"required,max=100"`
+	Note   string `json:"note" binding:"required,max=2000"`
+}
+
+// InsightFeedbackRequest labels an insight. FALSE_POSITIVE and DUPLICATE
+// need a reason.
+type InsightFeedbackRequest struct {
+	Verdict string `json:"verdict" binding:"required,oneof=USEFUL FALSE_POSITIVE DUPLICATE"`
+	Reason  string `json:"reason" binding:"max=1000"`
+	Author  string `json:"author" binding:"required,max=100"`
+}
+
+type AcceptSuggestionRequest struct {
+	AcceptedBy string `json:"accepted_by" binding:"required,max=100"`
+}
+
+type CreateWebhookRequest struct {
+	URL          string   `json:"url" binding:"required,url,max=2000"`
+	Secret       string   `json:"secret" binding:"omitempty,min=16,max=128"`
+	Description  string   `json:"description" binding:"max=200"`
+	EventTypes   []string `json:"event_types"`
+	InsightTypes []string `json:"insight_types"`
+	Severities   []string `json:"severities" binding:"dive,oneof=HIGH MEDIUM LOW"`
+	Countries    []string `json:"countries" binding:"dive,len=2"`
+}
+
+type CreateNotificationChannelRequest struct {
+	Name             string   `json:"name" binding:"required,max=100"`
+	Kind             string   `json:"kind" binding:"required,oneof=EMAIL CHAT_WEBHOOK LOG"`
+	Recipients       []string `json:"recipients" binding:"dive,email"`
+	WebhookURL       string   `json:"webhook_url" binding:"omitempty,url,max=2000"`
+	SubjectTemplate  string   `json:"subject_template" binding:"max=1000"`
+	BodyTemplate     string   `json:"body_template" binding:"max=10000"`
+	RateLimitPerHour int      `json:"rate_limit_per_hour" binding:"min=0"`
+}
+
+type CreateNotificationRouteRequest struct {
+	Name           string   `json:"name" binding:"required,max=100"`
+	Priority       int      `json:"priority"`
+	EventTypes     []string `json:"event_types"`
+	InsightTypes   []string `json:"insight_types"`
+	Severities     []string `json:"severities" binding:"dive,oneof=HIGH MEDIUM LOW"`
+	Countries      []string `json:"countries" binding:"dive,len=2"`
+	ChannelID      string   `json:"channel_id" binding:"required,uuid"`
+	Delivery       string   `json:"delivery" binding:"omitempty,oneof=IMMEDIATE DAILY_DIGEST WEEKLY_DIGEST"`
+	StopProcessing bool     `json:"stop_processing"`
+}
+
+type CustomRuleTierRequest struct {
+	Severity  string  `json:"severity" binding:"required,oneof=HIGH MEDIUM LOW"`
+	Threshold float64 `json:"threshold"`
+}
+
+// CustomInsightRuleRequest creates or replaces a custom insight rule. Tiers
+// are checked in order, so list the most severe first. Enabled defaults to
+// true.
+type CustomInsightRuleRequest struct {
+	Name                string                  `json:"name" binding:"required,max=100"`
+	Expression          string                  `json:"expression" binding:"required,max=500"`
+	ScoreField          string                  `json:"score_field" binding:"required"`
+	SeverityTiers       []CustomRuleTierRequest `json:"severity_tiers" binding:"max=3,dive"`
+	DefaultSeverity     string                  `json:"default_severity" binding:"omitempty,oneof=HIGH MEDIUM LOW"`
+	Inverted            bool                    `json:"inverted"`
+	LookbackDays        int                     `json:"lookback_days" binding:"min=0,max=730"`
+	DescriptionTemplate string                  `json:"description_template" binding:"max=1000"`
+	RecommendedAction   string                  `json:"recommended_action" binding:"max=1000"`
+	Enabled             *bool                   `json:"enabled"`
+	UpdatedBy           string                  `json:"updated_by" binding:"required,max=100"`
+}
+
+// CreateEventRequest adds an event to the timeline. Leave the scope fields
+// empty to apply the event to every method, country or provider.
+type CreateEventRequest struct {
+	Kind              string    `json:"kind" binding:"required,oneof=MAINTENANCE OUTAGE PRICING_CHANGE LAUNCH CAMPAIGN"`
+	Title             string    `json:"title" binding:"required,max=200"`
+	Description       string    `json:"description" binding:"max=2000"`
+	PaymentMethodCode string    `json:"payment_method_code" binding:"max=50"`
+	CountryCode       string    `json:"country_code" binding:"omitempty,len=2"`
+	Provider          string    `json:"provider" binding:"max=100"`
+	StartsAt          time.Time `json:"starts_at" binding:"required"`
+	EndsAt            time.Time `json:"ends_at" binding:"required"`
+	CreatedBy         string    `json:"created_by" binding:"required,max=100"`
+}
@@ -0,0 +1,106 @@
+package filterexpr
+
+import "fmt"
+
+// Row holds the field values a Program evaluates against. Fields missing from
+// the row read as zero or the empty string.
+type Row map[string]Literal
+
+// Num and Str build row values.
+func Num(v float64) Literal { return Literal{Num: v} }
+func Str(v string) Literal  { return Literal{Str: v, IsString: true} }
+
+// Program is an expression checked against a field whitelist and evaluated in
+// memory. The language has no function calls or variables beyond the listed
+// fields, so evaluation is bounded by the expression length.
+type Program struct {
+	root Node
+}
+
+// NewProgram parses expr and checks every comparison against fields, so a
+// Program never fails at evaluation time.
+func NewProgram(expr string, fields map[string]Kind) (*Program, error) {
+	n, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNode(n, fields); err != nil {
+		return nil, err
+	}
+	return &Program{root: n}, nil
+}
+
+// Eval reports whether row satisfies the expression.
+func (p *Program) Eval(row Row) bool {
+	return evalNode(p.root, row)
+}
+
+func checkNode(n Node, fields map[string]Kind) error {
+	switch n := n.(type) {
+	case *Logical:
+		if err := checkNode(n.Left, fields); err != nil {
+			return err
+		}
+		return checkNode(n.Right, fields)
+	case *Not:
+		return checkNode(n.X, fields)
+	case *Comparison:
+		kind, ok := fields[n.Field]
+		if !ok {
+			return &Error{Pos: n.At, Msg: fmt.Sprintf("unknown field %q, use one of: %s", n.Field, fieldList(fields))}
+		}
+		return checkComparison(n, kind)
+	}
+	return &Error{Msg: "unsupported expression"}
+}
+
+func evalNode(n Node, row Row) bool {
+	switch n := n.(type) {
+	case *Logical:
+		if n.Op == "and" {
+			return evalNode(n.Left, row) && evalNode(n.Right, row)
+		}
+		return evalNode(n.Left, row) || evalNode(n.Right, row)
+	case *Not:
+		return !evalNode(n.X, row)
+	case *Comparison:
+		v := row[n.Field]
+		if n.Op == "in" {
+			for _, want := range n.Values {
+				if compare(v, "=", want) {
+					return true
+				}
+			}
+			return false
+		}
+		return compare(v, n.Op, n.Values[0])
+	}
+	return false
+}
+
+func compare(v Literal, op string, want Literal) bool {
+	if want.IsString {
+		switch op {
+		case "=":
+			return v.Str == want.Str
+		case "!=":
+			return v.Str != want.Str
+		}
+		return false
+	}
+	switch op {
+	case "<":
+		return v.Num < want.Num
+	case "<=":
+		return v.Num <= want.Num
+	case ">":
+		return v.Num > want.Num
+	case ">=":
+		return v.Num >= want.Num
+	case "=":
+		return v.Num == want.Num
+	case "!=":
+		return v.Num != want.Num
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+// Package filterexpr parses the boolean filter language accepted by the
+// analytics endpoints, e.g. "approval_rate < 80 and transaction_count >= 20",
+// and compiles it to a parameterized SQL condition. Field names are resolved
+// against a whitelist, and literals are always bound, never interpolated.
+// Programs evaluate the same language in memory over rows of field values.
+package filterexpr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxLength bounds the size of an expression.
+const MaxLength = 500
+
+type Kind int
+
+const (
+	Number Kind = iota
+	String
+)
+
+// Field maps an expression field name to the SQL column it filters on.
+type Field struct {
+	Column string
+	Kind   Kind
+}
+
+// Error is returned for any malformed expression or unknown field; handlers
+// report it as a 400.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Pos, e.Msg)
+}
+
+// Node is an expression tree node.
+type Node interface {
+	pos() int
+}
+
+// Logical is an "and" or "or" of two operands.
+type Logical struct {
+	Op          string
+	Left, Right Node
+	At          int
+}
+
+type Not struct {
+	X  Node
+	At int
+}
+
+// Comparison compares a field against one literal, or against a list of
+// literals when Op is "in".
+type Comparison struct {
+	Field  string
+	Op     string
+	Values []Literal
+	At     int
+}
+
+type Literal struct {
+	Num      float64
+	Str      string
+	IsString bool
+}
+
+func (n *Logical) pos() int    { return n.At }
+func (n *Not) pos() int        { return n.At }
+func (n *Comparison) pos() int { return n.At }
+
+// Parse builds the expression tree. Keywords are case-insensitive; "&&",
+// "||", "!" and "==" are accepted as aliases of and, or, not and "=".
+func Parse(expr string) (Node, error) {
+	if len(expr) > MaxLength {
+		return nil, &Error{Pos: MaxLength, Msg: fmt.Sprintf("expression longer than %d characters", MaxLength)}
+	}
+	toks, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return n, nil
+}
+
+// Compile parses expr and renders it as a SQL condition over fields. bind
+// registers a literal and returns its placeholder.
+func Compile(expr string, fields map[string]Field, bind func(any) string) (string, error) {
+	n, err := Parse(expr)
+	if err != nil {
+		return "", err
+	}
+	return compileNode(n, fields, bind)
+}
+
+func compileNode(n Node, fields map[string]Field, bind func(any) string) (string, error) {
+	switch n := n.(type) {
+	case *Logical:
+		l, err := compileNode(n.Left, fields, bind)
+		if err != nil {
+			return "", err
+		}
+		r, err := compileNode(n.Right, fields, bind)
+		if err != nil {
+			return "", err
+		}
+		return "(" + l + " " + strings.ToUpper(n.Op) + " " + r + ")", nil
+	case *Not:
+		x, err := compileNode(n.X, fields, bind)
+		if err != nil {
+			return "", err
+		}
+		return "(NOT " + x + ")", nil
+	case *Comparison:
+		f, ok := fields[n.Field]
+		if !ok {
+			return "", &Error{Pos: n.At, Msg: fmt.Sprintf("unknown field %q, use one of: %s", n.Field, fieldList(fields))}
+		}
+		if err := checkComparison(n, f.Kind); err != nil {
+			return "", err
+		}
+		args := make([]string, len(n.Values))
+		for i, v := range n.Values {
+			// Explicit casts keep the placeholder type independent of the
+			// column, so 80.5 compares fine against an integer count.
+			if v.IsString {
+				args[i] = bind(v.Str) + "::text"
+			} else {
+				args[i] = bind(v.Num) + "::numeric"
+			}
+		}
+		if n.Op == "in" {
+			return f.Column + " IN (" + strings.Join(args, ", ") + ")", nil
+		}
+		op := n.Op
+		if op == "!=" {
+			op = "<>"
+		}
+		return f.Column + " " + op + " " + args[0], nil
+	}
+	return "", &Error{Msg: "unsupported expression"}
+}
+
+// checkComparison rejects literals of the wrong kind and ordering operators
+// on text fields.
+func checkComparison(n *Comparison, kind Kind) error {
+	for _, v := range n.Values {
+		if v.IsString != (kind == String) {
+			return &Error{Pos: n.At, Msg: fmt.Sprintf("field %q compares against a %s", n.Field, kindName(kind))}
+		}
+	}
+	if kind == String && n.Op != "in" && n.Op != "=" && n.Op != "!=" {
+		return &Error{Pos: n.At, Msg: fmt.Sprintf("operator %s is not supported on text field %q", n.Op, n.Field)}
+	}
+	return nil
+}
+
+func fieldList[V any](fields map[string]V) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func kindName(k Kind) string {
+	if k == String {
+		return "quoted string"
+	}
+	return "number"
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		t := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "or", Left: left, Right: right, At: t.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		t := p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "and", Left: left, Right: right, At: t.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Node, error) {
+	if p.peek().kind == tokNot {
+		t := p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x, At: t.pos}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tokRParen {
+			return nil, &Error{Pos: c.pos, Msg: "expected )"}
+		}
+		return n, nil
+	case tokIdent:
+		return p.parseComparison(t)
+	case tokEOF:
+		return nil, &Error{Pos: t.pos, Msg: "unexpected end of expression"}
+	}
+	return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected a field name, got %q", t.text)}
+}
+
+func (p *parser) parseComparison(field token) (Node, error) {
+	op := p.next()
+	switch op.kind {
+	case tokOp:
+		v, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &Comparison{Field: field.text, Op: op.text, Values: []Literal{v}, At: field.pos}, nil
+	case tokIn:
+		if t := p.next(); t.kind != tokLParen {
+			return nil, &Error{Pos: t.pos, Msg: "expected ( after in"}
+		}
+		var values []Literal
+		for {
+			v, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			t := p.next()
+			if t.kind == tokRParen {
+				break
+			}
+			if t.kind != tokComma {
+				return nil, &Error{Pos: t.pos, Msg: "expected , or )"}
+			}
+		}
+		return &Comparison{Field: field.text, Op: "in", Values: values, At: field.pos}, nil
+	}
+	return nil, &Error{Pos: op.pos, Msg: fmt.Sprintf("expected a comparison operator after %q", field.text)}
+}
+
+func (p *parser) parseLiteral() (Literal, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return Literal{}, &Error{Pos: t.pos, Msg: fmt.Sprintf("invalid number %q", t.text)}
+		}
+		return Literal{Num: f}, nil
+	case tokString:
+		return Literal{Str: t.text, IsString: true}, nil
+	}
+	return Literal{}, &Error{Pos: t.pos, Msg: "expected a number or quoted string"}
+}
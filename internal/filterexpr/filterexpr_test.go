@@ -0,0 +1,146 @@
+package filterexpr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFields = map[string]Field{
+	"approval_rate":     {Column: "approval_rate", Kind: Number},
+	"transaction_count": {Column: "transaction_count", Kind: Number},
+	"country_code":      {Column: "country_code", Kind: String},
+}
+
+func compileForTest(t *testing.T, expr string) (string, []any, error) {
+	t.Helper()
+	var args []any
+	sql, err := Compile(expr, testFields, func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	})
+	return sql, args, err
+}
+
+func TestCompile(t *testing.T) {
+	cases := []struct {
+		expr string
+		sql  string
+		args []any
+	}{
+		{"approval_rate<80", "approval_rate < $1::numeric", []any{80.0}},
+		{"approval_rate < 80 and transaction_count >= 20",
+			"(approval_rate < $1::numeric AND transaction_count >= $2::numeric)", []any{80.0, 20.0}},
+		{"approval_rate < 80 or transaction_count > 5 and country_code = 'BR'",
+			"(approval_rate < $1::numeric OR (transaction_count > $2::numeric AND country_code = $3::text))",
+			[]any{80.0, 5.0, "BR"}},
+		{"not (approval_rate >= 90.5)", "(NOT approval_rate >= $1::numeric)", []any{90.5}},
+		{"country_code in ('BR', \"MX\")", "country_code IN ($1::text, $2::text)", []any{"BR", "MX"}},
+		{"approval_rate == 50 && country_code != 'AR'",
+			"(approval_rate = $1::numeric AND country_code <> $2::text)", []any{50.0, "AR"}},
+		{"transaction_count > -1 || !(country_code = 'it''s')",
+			"(transaction_count > $1::numeric OR (NOT country_code = $2::text))", []any{-1.0, "it's"}},
+		{"APPROVAL_RATE < 1 AND transaction_count < 2", "", nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			sql, args, err := compileForTest(t, tc.expr)
+			if tc.sql == "" {
+				// Field names are case-sensitive.
+				var ferr *Error
+				require.True(t, errors.As(err, &ferr), "expected *Error, got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.sql, sql)
+			assert.Equal(t, tc.args, args)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []string{
+		"",
+		"approval_rate",
+		"approval_rate <",
+		"approval_rate < 'high'",
+		"country_code = 10",
+		"country_code < 'BR'",
+		"unknown_field = 1",
+		"approval_rate < 80 and",
+		"(approval_rate < 80",
+		"approval_rate < 80)",
+		"country_code = 'BR",
+		"approval_rate < 80; DROP TABLE transactions",
+		"approval_rate & 1",
+		"country_code in 'BR'",
+		"approval_rate < 1.2.3",
+	}
+
+	for _, expr := range cases {
+		t.Run(expr, func(t *testing.T) {
+			_, _, err := compileForTest(t, expr)
+			var ferr *Error
+			assert.True(t, errors.As(err, &ferr), "expected *Error, got %v", err)
+		})
+	}
+}
+
+func TestParseRejectsLongExpressions(t *testing.T) {
+	long := "approval_rate < 1"
+	for len(long) <= MaxLength {
+		long += " or approval_rate < 1"
+	}
+	_, err := Parse(long)
+	var ferr *Error
+	assert.True(t, errors.As(err, &ferr))
+}
+
+var testKinds = map[string]Kind{
+	"type":          String,
+	"approval_rate": Number,
+	"tpv_usd":       Number,
+}
+
+func TestProgramEval(t *testing.T) {
+	row := Row{"type": Str("WALLET"), "approval_rate": Num(82.5), "tpv_usd": Num(25000)}
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{`type == "WALLET" && approval_rate < 85 && tpv_usd > 10000`, true},
+		{`type == "WALLET" && approval_rate < 80`, false},
+		{`type in ('CARD', 'WALLET') and not tpv_usd <= 25000`, false},
+		{`type != 'CARD' or approval_rate > 99`, true},
+		{`approval_rate >= 82.5 and approval_rate <= 82.5 and approval_rate = 82.5`, true},
+		{`!(type = 'WALLET') || tpv_usd != 25000`, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			p, err := NewProgram(tc.expr, testKinds)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, p.Eval(row))
+		})
+	}
+}
+
+func TestNewProgramErrors(t *testing.T) {
+	for _, expr := range []string{
+		"country_code = 'BR'",
+		"type = 1",
+		"type < 'WALLET'",
+		"approval_rate in ('high')",
+		"approval_rate <",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := NewProgram(expr, testKinds)
+			var ferr *Error
+			assert.True(t, errors.As(err, &ferr), "expected *Error, got %v", err)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package filterexpr
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokAnd
+	tokOr
+	tokNot
+	tokIn
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var keywords = map[string]tokenKind{
+	"and": tokAnd,
+	"or":  tokOr,
+	"not": tokNot,
+	"in":  tokIn,
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			toks = append(toks, token{tokRParen, ")", i})
+			i++
+		case c == ',':
+			toks = append(toks, token{tokComma, ",", i})
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(src) || src[i+1] != c {
+				return nil, &Error{Pos: i, Msg: fmt.Sprintf("unexpected %q", string(c))}
+			}
+			kind := tokAnd
+			if c == '|' {
+				kind = tokOr
+			}
+			toks = append(toks, token{kind, src[i : i+2], i})
+			i += 2
+		case c == '<' || c == '>' || c == '=' || c == '!':
+			start := i
+			i++
+			if i < len(src) && src[i] == '=' {
+				i++
+			}
+			op := src[start:i]
+			switch op {
+			case "!":
+				toks = append(toks, token{tokNot, op, start})
+			case "==":
+				toks = append(toks, token{tokOp, "=", start})
+			default:
+				toks = append(toks, token{tokOp, op, start})
+			}
+		case c == '\'' || c == '"':
+			start := i
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(src) {
+				if src[i] == c {
+					// A doubled quote is an escaped quote.
+					if i+1 < len(src) && src[i+1] == c {
+						sb.WriteByte(c)
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if !closed {
+				return nil, &Error{Pos: start, Msg: "unterminated string"}
+			}
+			toks = append(toks, token{tokString, sb.String(), start})
+		case isDigit(c) || c == '.' || (c == '-' && i+1 < len(src) && (isDigit(src[i+1]) || src[i+1] == '.')):
+			start := i
+			i++
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			toks = append(toks, token{tokNumber, src[start:i], start})
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && (isIdentStart(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			word := src[start:i]
+			if kind, ok := keywords[strings.ToLower(word)]; ok {
+				toks = append(toks, token{kind, word, start})
+			} else {
+				toks = append(toks, token{tokIdent, word, start})
+			}
+		default:
+			return nil, &Error{Pos: i, Msg: fmt.Sprintf("unexpected character %q", string(c))}
+		}
+	}
+	return append(toks, token{tokEOF, "", len(src)}), nil
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+type AmountBandHandler struct {
+	svc *service.AmountBandService
+}
+
+func NewAmountBandHandler(svc *service.AmountBandService) *AmountBandHandler {
+	return &AmountBandHandler{svc: svc}
+}
+
+func (h *AmountBandHandler) GetAmountBands(c *gin.Context) {
+	f := dto.ParseFilter(c)
+	p := dto.ParsePagination(c)
+	if rejectFilterExpr(c, f) {
+		return
+	}
+
+	profiles, err := h.svc.GetAmountBands(c.Request.Context(), f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute amount bands: " + err.Error()})
+		return
+	}
+
+	totalItems := len(profiles)
+	start := p.Offset
+	end := start + p.PageSize
+	if start > totalItems {
+		start = totalItems
+	}
+	if end > totalItems {
+		end = totalItems
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"amount_bands": profiles[start:end],
+		"pagination":   dto.NewPagination(p.Page, p.PageSize, totalItems),
+	})
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+type BenchmarkHandler struct {
+	svc *service.BenchmarkService
+}
+
+func NewBenchmarkHandler(svc *service.BenchmarkService) *BenchmarkHandler {
+	return &BenchmarkHandler{svc: svc}
+}
+
+func (h *BenchmarkHandler) GetBenchmarks(c *gin.Context) {
+	f := dto.ParseFilter(c)
+	if rejectFilterExpr(c, f) {
+		return
+	}
+	dateFrom := f.DateFrom
+	dateTo := f.DateTo
+	growthDays, err := strconv.Atoi(c.DefaultQuery("growth_days", "30"))
+	if err != nil || growthDays < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: growth_days must be a positive integer"})
+		return
+	}
+	p := dto.ParsePagination(c)
+
+	if dateFrom != "" {
+		if _, err := parseDate(dateFrom); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date_from format"})
+			return
+		}
+	}
+	if dateTo != "" {
+		if _, err := parseDate(dateTo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date_to format"})
+			return
+		}
+	}
+
+	results, err := h.svc.GetBenchmarks(c.Request.Context(), f, growthDays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute benchmarks: " + err.Error()})
+		return
+	}
+
+	totalItems := len(results)
+	start := p.Offset
+	end := start + p.PageSize
+	if start > totalItems {
+		start = totalItems
+	}
+	if end > totalItems {
+		end = totalItems
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       results[start:end],
+		"pagination": dto.NewPagination(p.Page, p.PageSize, totalItems),
+	})
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBenchmarkHandler_RejectsBadGrowthDays(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// Validation fails before the service is used.
+	r.GET("/benchmarks", NewBenchmarkHandler(nil).GetBenchmarks)
+
+	for _, v := range []string{"abc", "0", "-7", "1.5"} {
+		t.Run(v, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/benchmarks?growth_days="+v, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "growth_days")
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+// resolveCurrency reads the currency query param. It writes a 400 and returns
+// ok=false for unknown currencies, or for LOCAL when allowLocal is false or
+// the query is not scoped to a single country.
+func resolveCurrency(c *gin.Context, svc *service.CurrencyService, country string, allowLocal bool) (conv *service.FxConverter, local bool, ok bool) {
+	currency := service.NormalizeCurrency(c.Query("currency"))
+
+	var err error
+	if currency == service.CurrencyLocal {
+		if !allowLocal {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "currency=LOCAL is not supported on this endpoint"})
+			return nil, false, false
+		}
+		local = true
+		conv, err = svc.LocalConverter(c.Request.Context(), country)
+	} else {
+		conv, err = svc.Converter(c.Request.Context(), currency)
+	}
+
+	if errors.Is(err, service.ErrUnknownCurrency) || errors.Is(err, service.ErrLocalCurrencyCountry) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load fx rates: " + err.Error()})
+		return nil, false, false
+	}
+	return conv, local, true
+}
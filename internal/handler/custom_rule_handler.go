@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+type CustomRuleHandler struct {
+	svc *service.CustomRuleService
+}
+
+func NewCustomRuleHandler(svc *service.CustomRuleService) *CustomRuleHandler {
+	return &CustomRuleHandler{svc: svc}
+}
+
+func customRuleFromRequest(req dto.CustomInsightRuleRequest) model.CustomInsightRule {
+	rule := model.CustomInsightRule{
+		Name:                req.Name,
+		Expression:          req.Expression,
+		ScoreField:          req.ScoreField,
+		DefaultSeverity:     req.DefaultSeverity,
+		Inverted:            req.Inverted,
+		LookbackDays:        req.LookbackDays,
+		DescriptionTemplate: req.DescriptionTemplate,
+		RecommendedAction:   req.RecommendedAction,
+		Enabled:             req.Enabled == nil || *req.Enabled,
+		UpdatedBy:           req.UpdatedBy,
+	}
+	for _, t := range req.SeverityTiers {
+		rule.SeverityTiers = append(rule.SeverityTiers, model.CustomRuleTier{Severity: t.Severity, Threshold: t.Threshold})
+	}
+	return rule
+}
+
+// writeCustomRuleSaveError reports validation errors, unknown ids and
+// duplicate names; it returns false when err is nil.
+func writeCustomRuleSaveError(c *gin.Context, name string, err error) bool {
+	if err == nil {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, service.ErrInvalidCustomRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "insight rule not found"})
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		c.JSON(http.StatusConflict, gin.H{"error": "an insight rule named " + name + " already exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save insight rule: " + err.Error()})
+	}
+	return true
+}
+
+func (h *CustomRuleHandler) CreateRule(c *gin.Context) {
+	var req dto.CustomInsightRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	rule, err := h.svc.CreateRule(c.Request.Context(), customRuleFromRequest(req))
+	if writeCustomRuleSaveError(c, req.Name, err) {
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": rule})
+}
+
+func (h *CustomRuleHandler) UpdateRule(c *gin.Context) {
+	var req dto.CustomInsightRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	rule := customRuleFromRequest(req)
+	rule.ID = c.Param("id")
+	saved, err := h.svc.UpdateRule(c.Request.Context(), rule)
+	if writeCustomRuleSaveError(c, req.Name, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": saved})
+}
+
+func (h *CustomRuleHandler) ListRules(c *gin.Context) {
+	rules, err := h.svc.ListRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list insight rules: " + err.Error()})
+		return
+	}
+	if rules == nil {
+		rules = []model.CustomInsightRule{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+func (h *CustomRuleHandler) GetRule(c *gin.Context) {
+	rule, err := h.svc.GetRule(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "insight rule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get insight rule: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+func (h *CustomRuleHandler) DeleteRule(c *gin.Context) {
+	err := h.svc.DeleteRule(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "insight rule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete insight rule: " + err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
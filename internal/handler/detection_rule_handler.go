@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+type DetectionRuleHandler struct {
+	svc *service.DetectionRuleService
+}
+
+func NewDetectionRuleHandler(svc *service.DetectionRuleService) *DetectionRuleHandler {
+	return &DetectionRuleHandler{svc: svc}
+}
+
+func (h *DetectionRuleHandler) ListRules(c *gin.Context) {
+	rules, err := h.svc.ListRules(c.Request.Context(), c.Query("rule_set"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list detection rules: " + err.Error()})
+		return
+	}
+	if rules == nil {
+		rules = []model.DetectionRule{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+func (h *DetectionRuleHandler) GetRule(c *gin.Context) {
+	rule, versions, err := h.svc.GetRule(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "detection rule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get detection rule: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rule, "versions": versions})
+}
+
+func (h *DetectionRuleHandler) GetEffective(c *gin.Context) {
+	ruleSet := c.Query("rule_set")
+	if ruleSet == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rule_set is required"})
+		return
+	}
+
+	effective, err := h.svc.Effective(c.Request.Context(), ruleSet,
+		c.Query("country"), c.Query("type"), c.Query("payment_method"))
+	if errors.Is(err, service.ErrInvalidDetectionRule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve detection rules: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": effective})
+}
+
+func (h *DetectionRuleHandler) SaveRule(c *gin.Context) {
+	var req dto.UpsertDetectionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	rule, err := h.svc.SaveRule(c.Request.Context(), model.DetectionRule{
+		RuleSet:           req.RuleSet,
+		CountryCode:       req.CountryCode,
+		PaymentMethodType: req.PaymentMethodType,
+		PaymentMethodCode: req.PaymentMethodCode,
+		Params:            req.Params,
+		UpdatedBy:         req.ChangedBy,
+	}, req.Note)
+	if errors.Is(err, service.ErrInvalidDetectionRule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save detection rule: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+type EventHandler struct {
+	svc *service.EventService
+}
+
+func NewEventHandler(svc *service.EventService) *EventHandler {
+	return &EventHandler{svc: svc}
+}
+
+func (h *EventHandler) CreateEvent(c *gin.Context) {
+	var req dto.CreateEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	event, err := h.svc.CreateEvent(c.Request.Context(), model.Event{
+		Kind:              req.Kind,
+		Title:             req.Title,
+		Description:       req.Description,
+		PaymentMethodCode: req.PaymentMethodCode,
+		CountryCode:       req.CountryCode,
+		Provider:          req.Provider,
+		StartsAt:          req.StartsAt,
+		EndsAt:            req.EndsAt,
+		CreatedBy:         req.CreatedBy,
+	})
+	if errors.Is(err, service.ErrInvalidEvent) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create event: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": event})
+}
+
+// ListEvents returns the events overlapping date_from..date_to, filtered by
+// kind, country and payment_method.
+func (h *EventHandler) ListEvents(c *gin.Context) {
+	f := dto.ParseFilter(c)
+	if rejectFilterExpr(c, f) {
+		return
+	}
+	q := repository.EventQuery{
+		Kinds:          dto.ParseList(c, "kind"),
+		Countries:      f.Countries,
+		PaymentMethods: f.PaymentMethods,
+	}
+	var ok bool
+	if q.From, ok = parseEventTime(f.DateFrom); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date_from format"})
+		return
+	}
+	if q.To, ok = parseEventTime(f.DateTo); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date_to format"})
+		return
+	}
+
+	events, err := h.svc.ListEvents(c.Request.Context(), q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list events: " + err.Error()})
+		return
+	}
+	if events == nil {
+		events = []model.Event{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": events})
+}
+
+// parseEventTime accepts RFC3339 or YYYY-MM-DD; empty is the zero time.
+func parseEventTime(v string) (time.Time, bool) {
+	if v == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, true
+	}
+	t, err := time.Parse("2006-01-02", v)
+	return t, err == nil
+}
+
+func (h *EventHandler) DeleteEvent(c *gin.Context) {
+	err := h.svc.DeleteEvent(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete event: " + err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
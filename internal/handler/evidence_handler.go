@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+const maxEvidenceSample = 100
+
+type EvidenceHandler struct {
+	svc *service.EvidenceService
+}
+
+func NewEvidenceHandler(svc *service.EvidenceService) *EvidenceHandler {
+	return &EvidenceHandler{svc: svc}
+}
+
+// GetEvidence returns the window, peers, weekly series and a transaction
+// sample behind a tracked insight.
+func (h *EvidenceHandler) GetEvidence(c *gin.Context) {
+	sampleSize, err := strconv.Atoi(c.DefaultQuery("sample_size", "20"))
+	if err != nil || sampleSize < 0 || sampleSize > maxEvidenceSample {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: sample_size must be between 0 and 100"})
+		return
+	}
+
+	evidence, err := h.svc.GetEvidence(c.Request.Context(), c.Param("id"), sampleSize)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "insight not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get insight evidence: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": evidence})
+}
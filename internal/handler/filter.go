@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/filterexpr"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+// writeQueryError reports a malformed filter expression as a 400 and any
+// other failure as a 500 prefixed with "failed to <action>".
+func writeQueryError(c *gin.Context, action string, err error) {
+	var exprErr *filterexpr.Error
+	if errors.As(err, &exprErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": exprErr.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to " + action + ": " + err.Error()})
+}
+
+// rejectFilterExpr answers 400 when ?filter= reaches an endpoint whose
+// queries cannot apply it, rather than returning unfiltered rows that look
+// filtered. It reports whether the request was rejected.
+func rejectFilterExpr(c *gin.Context, f model.AnalyticsFilter) bool {
+	if f.Expr == "" {
+		return false
+	}
+	writeQueryError(c, "apply filter", &filterexpr.Error{Msg: "filter expressions are not supported on " + c.FullPath()})
+	return true
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejectFilterExpr(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// The filter is rejected before any service is used.
+	r.GET("/insights", NewInsightHandler(nil).GetInsights)
+	r.GET("/benchmarks", NewBenchmarkHandler(nil).GetBenchmarks)
+	r.GET("/market-gaps", NewMarketGapHandler(nil).GetMarketGaps)
+	r.GET("/health-scores", NewHealthScoreHandler(nil).GetHealthScores)
+	r.GET("/amount-bands", NewAmountBandHandler(nil).GetAmountBands)
+	r.GET("/pending", NewPendingHandler(nil).GetAging)
+
+	filter := url.QueryEscape("approval_rate < 50")
+	for _, path := range []string{"/insights", "/benchmarks", "/market-gaps", "/health-scores", "/amount-bands", "/pending"} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+"?filter="+filter, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "not supported on "+path)
+		})
+	}
+}
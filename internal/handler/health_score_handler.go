@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+type HealthScoreHandler struct {
+	svc *service.HealthScoreService
+}
+
+func NewHealthScoreHandler(svc *service.HealthScoreService) *HealthScoreHandler {
+	return &HealthScoreHandler{svc: svc}
+}
+
+func (h *HealthScoreHandler) GetHealthScores(c *gin.Context) {
+	f := dto.ParseFilter(c)
+	if rejectFilterExpr(c, f) {
+		return
+	}
+	period := c.DefaultQuery("period", "MOM")
+	periodsBack, _ := strconv.Atoi(c.DefaultQuery("periods_back", "6"))
+	p := dto.ParsePagination(c)
+
+	if period != "WOW" && period != "MOM" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be WOW or MOM"})
+		return
+	}
+
+	results, err := h.svc.GetHistory(c.Request.Context(), f, period, periodsBack)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute health scores: " + err.Error()})
+		return
+	}
+
+	totalItems := len(results)
+	start := p.Offset
+	end := start + p.PageSize
+	if start > totalItems {
+		start = totalItems
+	}
+	if end > totalItems {
+		end = totalItems
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       results[start:end],
+		"pagination": dto.NewPagination(p.Page, p.PageSize, totalItems),
+	})
+}
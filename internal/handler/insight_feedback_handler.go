@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+type InsightFeedbackHandler struct {
+	svc *service.InsightFeedbackService
+}
+
+func NewInsightFeedbackHandler(svc *service.InsightFeedbackService) *InsightFeedbackHandler {
+	return &InsightFeedbackHandler{svc: svc}
+}
+
+func (h *InsightFeedbackHandler) AddFeedback(c *gin.Context) {
+	var req dto.InsightFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	feedback, err := h.svc.AddFeedback(c.Request.Context(), model.InsightFeedback{
+		InsightID: c.Param("id"),
+		Verdict:   req.Verdict,
+		Reason:    req.Reason,
+		Author:    req.Author,
+	})
+	switch {
+	case errors.Is(err, service.ErrInvalidFeedback):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "insight not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add feedback: " + err.Error()})
+	default:
+		c.JSON(http.StatusCreated, gin.H{"data": feedback})
+	}
+}
+
+func (h *InsightFeedbackHandler) ListFeedback(c *gin.Context) {
+	feedback, err := h.svc.ListFeedback(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list feedback: " + err.Error()})
+		return
+	}
+	if feedback == nil {
+		feedback = []model.InsightFeedback{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": feedback})
+}
+
+// GetPrecision reports precision per detector and threshold setting,
+// optionally for the insight_type list only.
+func (h *InsightFeedbackHandler) GetPrecision(c *gin.Context) {
+	stats, err := h.svc.PrecisionStats(c.Request.Context(), dto.ParseList(c, "insight_type"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute precision: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": stats})
+}
+
+func (h *InsightFeedbackHandler) ListSuggestions(c *gin.Context) {
+	suggestions, err := h.svc.Suggestions(c.Request.Context())
+	if errors.Is(err, service.ErrAdaptiveThresholdsDisabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute threshold suggestions: " + err.Error()})
+		return
+	}
+	if suggestions == nil {
+		suggestions = []service.ThresholdSuggestion{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": suggestions})
+}
+
+// AcceptSuggestion saves a current suggestion as the global detection rule.
+func (h *InsightFeedbackHandler) AcceptSuggestion(c *gin.Context) {
+	var req dto.AcceptSuggestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	rule, err := h.svc.AcceptSuggestion(c.Request.Context(), c.Param("id"), req.AcceptedBy)
+	switch {
+	case errors.Is(err, service.ErrAdaptiveThresholdsDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUnknownSuggestion):
+		c.JSON(http.StatusNotFound, gin.H{"error": "threshold suggestion not found or no longer applies"})
+	case errors.Is(err, service.ErrInvalidDetectionRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to accept threshold suggestion: " + err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"data": rule})
+	}
+}
@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 
 	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
 	"github.com/anyulbade/payment-method-health-monitor/internal/service"
 )
 
@@ -18,16 +22,33 @@ func NewInsightHandler(svc *service.InsightService) *InsightHandler {
 }
 
 func (h *InsightHandler) GetInsights(c *gin.Context) {
-	country := c.Query("country")
+	f := dto.ParseFilter(c)
+	if rejectFilterExpr(c, f) {
+		return
+	}
 	insightType := c.Query("insight_type")
 	severity := c.Query("severity")
+	sortBy := c.Query("sort")
 	p := dto.ParsePagination(c)
 
-	insights, err := h.svc.DetectInsights(c.Request.Context(), country, insightType, severity)
+	if sortBy != "" && sortBy != "impact" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort: must be impact"})
+		return
+	}
+
+	insights, err := h.svc.DetectInsights(c.Request.Context(), f, insightType, severity)
+	if errors.Is(err, service.ErrUnknownInsightType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to detect insights: " + err.Error()})
 		return
 	}
+	insights = service.FilterByState(insights, dto.ParseList(c, "state"))
+	if sortBy == "impact" {
+		service.SortByImpact(insights)
+	}
 
 	totalItems := len(insights)
 	start := p.Offset
@@ -44,3 +65,165 @@ func (h *InsightHandler) GetInsights(c *gin.Context) {
 		"pagination": dto.NewPagination(p.Page, p.PageSize, totalItems),
 	})
 }
+
+func (h *InsightHandler) ListDetectors(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": h.svc.Detectors()})
+}
+
+// ListTracked lists persisted insights, including resolved ones, without
+// running detection.
+func (h *InsightHandler) ListTracked(c *gin.Context) {
+	f := dto.ParseFilter(c)
+	if rejectFilterExpr(c, f) {
+		return
+	}
+	p := dto.ParsePagination(c)
+
+	tracked, err := h.svc.ListTracked(c.Request.Context(), repository.InsightScope{
+		States:             dto.ParseList(c, "state"),
+		Types:              dto.ParseList(c, "insight_type"),
+		Countries:          f.Countries,
+		PaymentMethods:     f.PaymentMethods,
+		PaymentMethodTypes: f.Types,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list insights: " + err.Error()})
+		return
+	}
+
+	totalItems := len(tracked)
+	start := p.Offset
+	end := start + p.PageSize
+	if start > totalItems {
+		start = totalItems
+	}
+	if end > totalItems {
+		end = totalItems
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       tracked[start:end],
+		"pagination": dto.NewPagination(p.Page, p.PageSize, totalItems),
+	})
+}
+
+func (h *InsightHandler) GetTracked(c *gin.Context) {
+	detail, err := h.svc.GetTracked(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "insight not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get insight: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": detail})
+}
+
+func (h *InsightHandler) TransitionState(c *gin.Context) {
+	var req dto.InsightTransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	tracked, err := h.svc.TransitionInsight(c.Request.Context(), c.Param("id"), req.State, req.SnoozedUntil, req.Actor, req.Note)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "insight not found"})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidInsightTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update insight: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tracked})
+}
+
+func (h *InsightHandler) SetAssignee(c *gin.Context) {
+	var req dto.InsightAssigneeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	tracked, err := h.svc.AssignInsight(c.Request.Context(), c.Param("id"), req.Assignee)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "insight not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assign insight: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tracked})
+}
+
+func (h *InsightHandler) AddNote(c *gin.Context) {
+	var req dto.InsightNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	note, err := h.svc.AddInsightNote(c.Request.Context(), c.Param("id"), req.Author, req.Note)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "insight not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add note: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": note})
+}
+
+// Backtest replays one detector weekly over a past date range without
+// persisting anything.
+func (h *InsightHandler) Backtest(c *gin.Context) {
+	var req dto.InsightBacktestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+	from, err := parseDate(req.DateFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date_from format"})
+		return
+	}
+	to, err := parseDate(req.DateTo)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date_to format"})
+		return
+	}
+
+	f := dto.ParseFilter(c)
+	if rejectFilterExpr(c, f) {
+		return
+	}
+	bt := service.BacktestRequest{RuleSet: req.RuleSet, From: from, To: to, Filter: f}
+	if len(req.Params) > 0 {
+		bt.Proposed = &model.DetectionRule{
+			RuleSet:           req.RuleSet,
+			CountryCode:       req.CountryCode,
+			PaymentMethodType: req.PaymentMethodType,
+			PaymentMethodCode: req.PaymentMethodCode,
+			Params:            req.Params,
+		}
+	}
+
+	result, err := h.svc.Backtest(c.Request.Context(), bt)
+	if errors.Is(err, service.ErrUnknownInsightType) || errors.Is(err, service.ErrInvalidBacktest) ||
+		errors.Is(err, service.ErrInvalidDetectionRule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to backtest insights: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+func TestInsightHandler_BacktestCustomRuleActivityStatus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	router := setupFullRouter(t)
+	pool := getTestPool(t)
+	defer pool.Close()
+	ctx := context.Background()
+
+	// A method whose only transactions are in early November 2025: ACTIVE at
+	// the November evaluations, INACTIVE today.
+	_, err := pool.Exec(ctx, `INSERT INTO payment_methods (code, name, type) VALUES ('BT_TEST', 'Backtest Card', 'CARD')`)
+	require.NoError(t, err)
+	for i := 0; i < 12; i++ {
+		_, err := pool.Exec(ctx, `
+			INSERT INTO transactions (payment_method_code, country_code, currency, amount, amount_usd, status, transaction_date)
+			VALUES ('BT_TEST', 'MX', 'MXN', 100, 5, 'APPROVED', $1)`,
+			time.Date(2025, 11, 3, i, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+	}
+
+	body := `{"name":"active methods","expression":"activity_status = 'ACTIVE'","score_field":"transaction_count","updated_by":"test"}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/insight-rules", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	body = `{"rule_set":"custom_rule","date_from":"2025-11-10","date_to":"2025-11-24"}`
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/v1/insights/backtest?payment_method=BT_TEST", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Data service.BacktestResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data.Evaluations, 3)
+	for _, e := range resp.Data.Evaluations {
+		assert.Equal(t, 1, e.Firing, "activity_status as of %s", e.AsOf)
+	}
+	assert.Equal(t, 1, resp.Data.TotalAlerts)
+}
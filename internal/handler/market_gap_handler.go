@@ -18,11 +18,14 @@ func NewMarketGapHandler(svc *service.MarketGapService) *MarketGapHandler {
 }
 
 func (h *MarketGapHandler) GetMarketGaps(c *gin.Context) {
-	country := c.Query("country")
+	f := dto.ParseFilter(c)
+	if rejectFilterExpr(c, f) {
+		return
+	}
 	onlyEssential := c.Query("only_essential") == "true"
 	p := dto.ParsePagination(c)
 
-	gaps, coverage, err := h.svc.GetMarketGaps(c.Request.Context(), country, onlyEssential)
+	gaps, coverage, err := h.svc.GetMarketGaps(c.Request.Context(), f, onlyEssential)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to detect market gaps: " + err.Error()})
 		return
@@ -11,23 +11,32 @@ import (
 )
 
 type MetricsHandler struct {
-	svc *service.MetricsService
+	svc         *service.MetricsService
+	currencySvc *service.CurrencyService
 }
 
-func NewMetricsHandler(svc *service.MetricsService) *MetricsHandler {
-	return &MetricsHandler{svc: svc}
+func NewMetricsHandler(svc *service.MetricsService, currencySvc *service.CurrencyService) *MetricsHandler {
+	return &MetricsHandler{svc: svc, currencySvc: currencySvc}
 }
 
 func (h *MetricsHandler) GetMetrics(c *gin.Context) {
-	country := c.Query("country")
-	pmType := c.Query("type")
-	dateFrom := c.Query("date_from")
-	dateTo := c.Query("date_to")
+	f := dto.ParseFilter(c)
+	dateFrom := f.DateFrom
+	dateTo := f.DateTo
 	sortBy := c.DefaultQuery("sort_by", "tpv_usd")
 	order := c.DefaultQuery("order", "desc")
 
 	p := dto.ParsePagination(c)
 
+	withHealth := false
+	for _, inc := range dto.ParseList(c, "include") {
+		if inc != "health_score" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: include supports health_score"})
+			return
+		}
+		withHealth = true
+	}
+
 	// Validate date formats
 	if dateFrom != "" {
 		if _, err := time.Parse(time.RFC3339, dateFrom); err != nil {
@@ -52,18 +61,144 @@ func (h *MetricsHandler) GetMetrics(c *gin.Context) {
 		return
 	}
 
+	conv, local, ok := resolveCurrency(c, h.currencySvc, f.SingleCountry(), true)
+	if !ok {
+		return
+	}
+
 	results, summary, totalItems, err := h.svc.GetMetrics(
-		c.Request.Context(), country, pmType, dateFrom, dateTo, sortBy, order,
-		p.PageSize, p.Offset,
+		c.Request.Context(), f, sortBy, order,
+		p.PageSize, p.Offset, withHealth,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute metrics: " + err.Error()})
+		writeQueryError(c, "compute metrics", err)
 		return
 	}
+	service.ConvertMetrics(results, &summary, conv, local, dateTo)
 
 	c.JSON(http.StatusOK, gin.H{
+		"currency":   conv.Code(),
 		"data":       results,
 		"summary":    summary,
 		"pagination": dto.NewPagination(p.Page, p.PageSize, totalItems),
 	})
 }
+
+func (h *MetricsHandler) CompareMetrics(c *gin.Context) {
+	f := dto.ParseFilter(c)
+	preset := c.Query("preset")
+	sortBy := c.DefaultQuery("sort_by", "tpv_usd")
+	rankBy := c.DefaultQuery("rank_by", "abs_delta")
+	order := c.DefaultQuery("order", "desc")
+	p := dto.ParsePagination(c)
+
+	if c.Query("date_from") == "" || c.Query("date_to") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date_from and date_to are required"})
+		return
+	}
+	current, ok := parseDateWindow(c, "date_from", "date_to")
+	if !ok {
+		return
+	}
+
+	hasExplicit := c.Query("compare_from") != "" || c.Query("compare_to") != ""
+	if hasExplicit && preset != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "use either preset or compare_from/compare_to, not both"})
+		return
+	}
+
+	var previous service.DateWindow
+	if hasExplicit {
+		if c.Query("compare_from") == "" || c.Query("compare_to") == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "compare_from and compare_to must be provided together"})
+			return
+		}
+		previous, ok = parseDateWindow(c, "compare_from", "compare_to")
+		if !ok {
+			return
+		}
+	} else {
+		if preset == "" {
+			preset = service.ComparePresetPreviousPeriod
+		}
+		var err error
+		previous, err = service.PreviousWindow(current, preset)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid preset, use: previous_period, same_period_last_year"})
+			return
+		}
+	}
+
+	if _, ok := service.ComparableMetrics[sortBy]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort_by metric"})
+		return
+	}
+	if rankBy != "abs_delta" && rankBy != "pct_delta" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rank_by must be abs_delta or pct_delta"})
+		return
+	}
+
+	results, err := h.svc.CompareMetrics(c.Request.Context(), f, current, previous, sortBy, rankBy, order)
+	if err != nil {
+		writeQueryError(c, "compare metrics", err)
+		return
+	}
+
+	totalItems := len(results)
+	start := p.Offset
+	end := start + p.PageSize
+	if start > totalItems {
+		start = totalItems
+	}
+	if end > totalItems {
+		end = totalItems
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"current_window":  current,
+		"previous_window": previous,
+		"data":            results[start:end],
+		"pagination":      dto.NewPagination(p.Page, p.PageSize, totalItems),
+	})
+}
+
+// parseDateWindow reads a from/to pair of RFC3339 or YYYY-MM-DD query params
+// as a half-open window, writing a 400 response and returning false when
+// either is invalid. A date-only to covers that whole day.
+func parseDateWindow(c *gin.Context, fromParam, toParam string) (service.DateWindow, bool) {
+	from, err := parseDate(c.Query(fromParam))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + fromParam + " format"})
+		return service.DateWindow{}, false
+	}
+	to, err := parseWindowEnd(c.Query(toParam))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + toParam + " format"})
+		return service.DateWindow{}, false
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fromParam + " must be before " + toParam})
+		return service.DateWindow{}, false
+	}
+	return service.DateWindow{From: from, To: to}, true
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// parseWindowEnd parses the exclusive end of a window. A YYYY-MM-DD value
+// ends at the following midnight.
+func parseWindowEnd(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+func TestParseDateWindow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		from, to string
+		want     service.DateWindow
+		wantCode int
+	}{
+		{
+			name: "date-only to covers the whole day",
+			from: "2026-02-01", to: "2026-02-28",
+			want: service.DateWindow{
+				From: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "single day",
+			from: "2026-02-01", to: "2026-02-01",
+			want: service.DateWindow{
+				From: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "RFC3339 to is the exclusive end",
+			from: "2026-02-01T00:00:00Z", to: "2026-02-01T12:00:00Z",
+			want: service.DateWindow{
+				From: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{name: "empty window", from: "2026-02-01T00:00:00Z", to: "2026-02-01T00:00:00Z", wantCode: http.StatusBadRequest},
+		{name: "bad to", from: "2026-02-01", to: "February", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/?date_from="+tt.from+"&date_to="+tt.to, nil)
+
+			got, ok := parseDateWindow(c, "date_from", "date_to")
+			if tt.wantCode != 0 {
+				assert.False(t, ok)
+				assert.Equal(t, tt.wantCode, w.Code)
+				return
+			}
+			require.True(t, ok, w.Body.String())
+			assert.True(t, tt.want.From.Equal(got.From), "from %s", got.From)
+			assert.True(t, tt.want.To.Equal(got.To), "to %s", got.To)
+		})
+	}
+}
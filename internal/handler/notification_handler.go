@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+type NotificationHandler struct {
+	svc *service.NotificationService
+}
+
+func NewNotificationHandler(svc *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{svc: svc}
+}
+
+func (h *NotificationHandler) CreateChannel(c *gin.Context) {
+	var req dto.CreateNotificationChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	ch, err := h.svc.CreateChannel(c.Request.Context(), model.NotificationChannel{
+		Name:             req.Name,
+		Kind:             req.Kind,
+		Recipients:       req.Recipients,
+		WebhookURL:       req.WebhookURL,
+		SubjectTemplate:  req.SubjectTemplate,
+		BodyTemplate:     req.BodyTemplate,
+		RateLimitPerHour: req.RateLimitPerHour,
+	})
+	if errors.Is(err, service.ErrInvalidNotificationConfig) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"error": "a channel named " + req.Name + " already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create channel: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": ch})
+}
+
+func (h *NotificationHandler) ListChannels(c *gin.Context) {
+	channels, err := h.svc.ListChannels(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list channels: " + err.Error()})
+		return
+	}
+	if channels == nil {
+		channels = []model.NotificationChannel{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": channels})
+}
+
+func (h *NotificationHandler) DeleteChannel(c *gin.Context) {
+	err := h.svc.DeleteChannel(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete channel: " + err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *NotificationHandler) CreateRoute(c *gin.Context) {
+	var req dto.CreateNotificationRouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	rt, err := h.svc.CreateRoute(c.Request.Context(), model.NotificationRoute{
+		Name:           req.Name,
+		Priority:       req.Priority,
+		EventTypes:     req.EventTypes,
+		InsightTypes:   req.InsightTypes,
+		Severities:     req.Severities,
+		Countries:      req.Countries,
+		ChannelID:      req.ChannelID,
+		Delivery:       req.Delivery,
+		StopProcessing: req.StopProcessing,
+	})
+	if errors.Is(err, service.ErrInvalidNotificationConfig) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create route: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": rt})
+}
+
+// ListRoutes returns routes in evaluation order.
+func (h *NotificationHandler) ListRoutes(c *gin.Context) {
+	routes, err := h.svc.ListRoutes(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list routes: " + err.Error()})
+		return
+	}
+	if routes == nil {
+		routes = []model.NotificationRoute{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": routes})
+}
+
+func (h *NotificationHandler) DeleteRoute(c *gin.Context) {
+	err := h.svc.DeleteRoute(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "route not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete route: " + err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListLog serves sent and failed messages, newest first.
+func (h *NotificationHandler) ListLog(c *gin.Context) {
+	p := dto.ParsePagination(c)
+
+	entries, err := h.svc.ListLog(c.Request.Context(), c.Query("channel_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list notification log: " + err.Error()})
+		return
+	}
+
+	totalItems := len(entries)
+	start := p.Offset
+	end := start + p.PageSize
+	if start > totalItems {
+		start = totalItems
+	}
+	if end > totalItems {
+		end = totalItems
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       entries[start:end],
+		"pagination": dto.NewPagination(p.Page, p.PageSize, totalItems),
+	})
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+type PendingHandler struct {
+	svc *service.PendingService
+}
+
+func NewPendingHandler(svc *service.PendingService) *PendingHandler {
+	return &PendingHandler{svc: svc}
+}
+
+func (h *PendingHandler) GetAging(c *gin.Context) {
+	f := dto.ParseFilter(c)
+	p := dto.ParsePagination(c)
+	if rejectFilterExpr(c, f) {
+		return
+	}
+
+	aging, err := h.svc.GetAging(c.Request.Context(), f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute pending aging: " + err.Error()})
+		return
+	}
+
+	totalItems := len(aging)
+	start := p.Offset
+	end := start + p.PageSize
+	if start > totalItems {
+		start = totalItems
+	}
+	if end > totalItems {
+		end = totalItems
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"aging":      aging[start:end],
+		"pagination": dto.NewPagination(p.Page, p.PageSize, totalItems),
+	})
+}
@@ -6,26 +6,36 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
 	"github.com/anyulbade/payment-method-health-monitor/internal/service"
 )
 
 type ReportHandler struct {
-	svc *service.ReportService
+	svc         *service.ReportService
+	currencySvc *service.CurrencyService
 }
 
-func NewReportHandler(svc *service.ReportService) *ReportHandler {
-	return &ReportHandler{svc: svc}
+func NewReportHandler(svc *service.ReportService, currencySvc *service.CurrencyService) *ReportHandler {
+	return &ReportHandler{svc: svc, currencySvc: currencySvc}
 }
 
 func (h *ReportHandler) GetReport(c *gin.Context) {
-	country := c.Query("country")
-	dateFrom := c.Query("date_from")
-	dateTo := c.Query("date_to")
+	f := dto.ParseFilter(c)
 	format := c.Query("format")
+	sortBy := c.Query("sort")
+	if sortBy != "" && sortBy != "impact" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort: must be impact"})
+		return
+	}
+
+	conv, local, ok := resolveCurrency(c, h.currencySvc, f.SingleCountry(), true)
+	if !ok {
+		return
+	}
 
-	data, err := h.svc.GenerateReport(c.Request.Context(), country, dateFrom, dateTo)
+	data, err := h.svc.GenerateReport(c.Request.Context(), f, conv, local, sortBy == "impact")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate report: " + err.Error()})
+		writeQueryError(c, "generate report", err)
 		return
 	}
 
@@ -10,24 +10,29 @@ import (
 )
 
 type ROIHandler struct {
-	svc *service.ROIService
+	svc         *service.ROIService
+	currencySvc *service.CurrencyService
 }
 
-func NewROIHandler(svc *service.ROIService) *ROIHandler {
-	return &ROIHandler{svc: svc}
+func NewROIHandler(svc *service.ROIService, currencySvc *service.CurrencyService) *ROIHandler {
+	return &ROIHandler{svc: svc, currencySvc: currencySvc}
 }
 
 func (h *ROIHandler) GetROI(c *gin.Context) {
-	country := c.Query("country")
-	dateFrom := c.Query("date_from")
-	dateTo := c.Query("date_to")
+	f := dto.ParseFilter(c)
 	p := dto.ParsePagination(c)
 
-	results, err := h.svc.GetROI(c.Request.Context(), country, dateFrom, dateTo)
+	conv, _, ok := resolveCurrency(c, h.currencySvc, f.SingleCountry(), false)
+	if !ok {
+		return
+	}
+
+	results, err := h.svc.GetROI(c.Request.Context(), f)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute ROI: " + err.Error()})
+		writeQueryError(c, "compute ROI", err)
 		return
 	}
+	service.ConvertROI(results, conv, f.DateTo)
 
 	totalItems := len(results)
 	start := p.Offset
@@ -40,6 +45,7 @@ func (h *ROIHandler) GetROI(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
+		"currency":   conv.Code(),
 		"data":       results[start:end],
 		"pagination": dto.NewPagination(p.Page, p.PageSize, totalItems),
 	})
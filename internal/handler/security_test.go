@@ -16,6 +16,7 @@ import (
 
 	"github.com/anyulbade/payment-method-health-monitor/internal/database"
 	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
 	"github.com/anyulbade/payment-method-health-monitor/internal/service"
 )
@@ -47,14 +48,49 @@ func setupFullRouter(t *testing.T) *gin.Engine {
 	pmRepo := repository.NewPaymentMethodRepository(pool)
 	metricsRepo := repository.NewMetricsRepository(pool)
 	insightRepo := repository.NewInsightRepository(pool)
+	trendRepo := repository.NewTrendRepository(pool)
+	roiRepo := repository.NewROIRepository(pool)
+	healthScoreRepo := repository.NewHealthScoreRepository(pool)
+	pendingRepo := repository.NewPendingRepository(pool)
+	amountBandRepo := repository.NewAmountBandRepository(pool)
+	customRuleRepo := repository.NewCustomRuleRepository(pool)
 
 	txnService := service.NewTransactionService(txnRepo, pmRepo)
-	metricsService := service.NewMetricsService(metricsRepo)
-	insightService := service.NewInsightService(insightRepo)
+	trendService := service.NewTrendService(trendRepo)
+	healthScoreService := service.NewHealthScoreService(healthScoreRepo,
+		trendService, service.NewROIService(roiRepo), service.DefaultHealthWeights)
+	metricsService := service.NewMetricsService(metricsRepo, healthScoreService)
+	detectors := service.NewDetectorRegistry()
+	if err := service.RegisterBuiltinDetectors(detectors, insightRepo, pendingRepo, amountBandRepo, trendService, customRuleRepo, metricsRepo); err != nil {
+		t.Fatalf("register detectors: %v", err)
+	}
+	ruleService := service.NewDetectionRuleService(repository.NewDetectionRuleRepository(pool), pmRepo, detectors)
+	insightStateRepo := repository.NewInsightStateRepository(pool)
+	insightService := service.NewInsightService(detectors, ruleService, insightStateRepo)
+	eventService := service.NewEventService(repository.NewEventRepository(pool), pmRepo)
+	insightService.SetEventService(eventService)
+	webhookService := service.NewWebhookService(repository.NewWebhookRepository(pool), http.DefaultClient, 3)
+	insightService.AddNotifier(webhookService)
+	notificationService := service.NewNotificationService(repository.NewNotificationRepository(pool),
+		map[string]service.ChannelSender{model.ChannelKindLog: service.LogSender{}})
+	insightService.AddNotifier(notificationService)
+	currencyService := service.NewCurrencyService(repository.NewFxRepository(pool))
 
 	txnHandler := NewTransactionHandler(txnService)
-	metricsHandler := NewMetricsHandler(metricsService)
+	metricsHandler := NewMetricsHandler(metricsService, currencyService)
 	insightHandler := NewInsightHandler(insightService)
+	healthScoreHandler := NewHealthScoreHandler(healthScoreService)
+	ruleHandler := NewDetectionRuleHandler(ruleService)
+	webhookHandler := NewWebhookHandler(webhookService)
+	notificationHandler := NewNotificationHandler(notificationService)
+	pendingHandler := NewPendingHandler(service.NewPendingService(pendingRepo, ruleService))
+	amountBandHandler := NewAmountBandHandler(service.NewAmountBandService(amountBandRepo, ruleService))
+	evidenceHandler := NewEvidenceHandler(service.NewEvidenceService(detectors, insightStateRepo, pmRepo,
+		repository.NewEvidenceRepository(pool)))
+	customRuleHandler := NewCustomRuleHandler(service.NewCustomRuleService(customRuleRepo))
+	eventHandler := NewEventHandler(eventService)
+	feedbackHandler := NewInsightFeedbackHandler(service.NewInsightFeedbackService(
+		repository.NewInsightFeedbackRepository(pool), ruleService, detectors, true))
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -62,7 +98,30 @@ func setupFullRouter(t *testing.T) *gin.Engine {
 	api.POST("/transactions", txnHandler.Create)
 	api.POST("/transactions/batch", txnHandler.CreateBatch)
 	api.GET("/metrics", metricsHandler.GetMetrics)
+	api.GET("/metrics/compare", metricsHandler.CompareMetrics)
 	api.GET("/insights", insightHandler.GetInsights)
+	api.GET("/insights/tracked", insightHandler.ListTracked)
+	api.GET("/insights/precision", feedbackHandler.GetPrecision)
+	api.POST("/insights/backtest", insightHandler.Backtest)
+	api.GET("/insights/:id", insightHandler.GetTracked)
+	api.GET("/insights/:id/evidence", evidenceHandler.GetEvidence)
+	api.POST("/insights/:id/state", insightHandler.TransitionState)
+	api.POST("/insights/:id/feedback", feedbackHandler.AddFeedback)
+	api.GET("/insights/:id/feedback", feedbackHandler.ListFeedback)
+	api.GET("/health-scores", healthScoreHandler.GetHealthScores)
+	api.GET("/pending/aging", pendingHandler.GetAging)
+	api.GET("/amount-bands", amountBandHandler.GetAmountBands)
+	api.GET("/admin/detection-rules", ruleHandler.ListRules)
+	api.PUT("/admin/detection-rules", ruleHandler.SaveRule)
+	api.GET("/admin/detection-rules/effective", ruleHandler.GetEffective)
+	api.GET("/admin/detection-rules/:id", ruleHandler.GetRule)
+	api.POST("/admin/insight-rules", customRuleHandler.CreateRule)
+	api.GET("/admin/insight-rules/:id", customRuleHandler.GetRule)
+	api.GET("/events", eventHandler.ListEvents)
+	api.POST("/events", eventHandler.CreateEvent)
+	api.GET("/webhooks/deliveries", webhookHandler.ListDeliveries)
+	api.POST("/webhooks/deliveries/:id/redeliver", webhookHandler.Redeliver)
+	api.GET("/notifications/log", notificationHandler.ListLog)
 
 	return router
 }
@@ -80,8 +139,32 @@ func TestSQLInjection(t *testing.T) {
 		{"country param", "/api/v1/metrics?country=MX'%3B+DROP+TABLE+transactions%3B+--"},
 		{"country with OR", "/api/v1/metrics?country=MX'+OR+'1'%3D'1"},
 		{"date injection", "/api/v1/metrics?date_from=2026-01-01'+UNION+SELECT+*+FROM+pg_catalog.pg_tables+--"},
+		{"insight type", "/api/v1/insights?insight_type=zombie'%3B+DROP+TABLE+transactions%3B+--"},
 		{"insight country", "/api/v1/insights?country=CO'%3B+DROP+TABLE+transactions%3B+--"},
+		{"health score method", "/api/v1/health-scores?payment_method=PIX'+OR+'1'%3D'1"},
+		{"pending aging method", "/api/v1/pending/aging?payment_method=OXXO'+OR+'1'%3D'1"},
+		{"amount bands type", "/api/v1/amount-bands?type=BNPL'%3B+DROP+TABLE+transactions%3B+--"},
 		{"type injection", "/api/v1/metrics?type=CARD'+OR+'1'%3D'1"},
+		{"compare country", "/api/v1/metrics/compare?date_from=2026-01-01&date_to=2026-01-31&country=MX'+OR+'1'%3D'1"},
+		{"country list injection", "/api/v1/metrics?country=MX,BR'%3B+DROP+TABLE+transactions%3B+--"},
+		{"filter statement injection", "/api/v1/metrics?filter=approval_rate<80%3B+DROP+TABLE+transactions"},
+		{"filter field injection", "/api/v1/metrics?filter=1%3D1+or+approval_rate<80"},
+		{"filter literal injection", "/api/v1/metrics?filter=country_code%3D'MX''+OR+''1''%3D''1'"},
+		{"currency injection", "/api/v1/metrics?currency=BRL'+OR+'1'%3D'1"},
+		{"rule set injection", "/api/v1/admin/detection-rules?rule_set=zombie'+OR+'1'%3D'1"},
+		{"rule id injection", "/api/v1/admin/detection-rules/1'+OR+'1'%3D'1"},
+		{"effective scope injection", "/api/v1/admin/detection-rules/effective?rule_set=zombie&country=MX'+OR+'1'%3D'1"},
+		{"tracked insight state", "/api/v1/insights/tracked?state=OPEN'+OR+'1'%3D'1"},
+		{"tracked insight id", "/api/v1/insights/abc'%3B+DROP+TABLE+insights%3B+--"},
+		{"insight evidence id", "/api/v1/insights/abc'%3B+DROP+TABLE+insights%3B+--/evidence"},
+		{"insight rule id injection", "/api/v1/admin/insight-rules/1'+OR+'1'%3D'1"},
+		{"insight feedback id", "/api/v1/insights/abc'%3B+DROP+TABLE+insight_feedback%3B+--/feedback"},
+		{"precision insight_type", "/api/v1/insights/precision?insight_type=zombie'+OR+'1'%3D'1"},
+		{"event kind injection", "/api/v1/events?kind=MAINTENANCE'+OR+'1'%3D'1"},
+		{"event country injection", "/api/v1/events?country=AR'%3B+DROP+TABLE+events%3B+--"},
+		{"webhook delivery subscription", "/api/v1/webhooks/deliveries?subscription_id=x'+OR+'1'%3D'1"},
+		{"notification log channel", "/api/v1/notifications/log?channel_id=x'+OR+'1'%3D'1"},
+		{"compare sort_by", "/api/v1/metrics/compare?date_from=2026-01-01&date_to=2026-01-31&sort_by=tpv_usd%3B+DROP+TABLE+transactions"},
 	}
 
 	for _, tc := range injections {
@@ -115,6 +198,28 @@ func TestSQLInjection(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
+
+	t.Run("proposed rule scope injection in backtest", func(t *testing.T) {
+		body := `{"rule_set":"performance_alert","date_from":"2026-01-05","date_to":"2026-01-19",` +
+			`"payment_method_code":"PIX' OR '1'='1","params":{"min_gap_pp":5}}`
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/insights/backtest", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("sql in custom insight rule expression", func(t *testing.T) {
+		body := `{"name":"injection","score_field":"approval_rate","updated_by":"test",` +
+			`"expression":"approval_rate < 80; DROP TABLE transactions"}`
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/admin/insight-rules", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 }
 
 func TestMalformedJSON(t *testing.T) {
@@ -11,16 +11,19 @@ import (
 )
 
 type TrendHandler struct {
-	svc *service.TrendService
+	svc         *service.TrendService
+	currencySvc *service.CurrencyService
+	eventSvc    *service.EventService
 }
 
-func NewTrendHandler(svc *service.TrendService) *TrendHandler {
-	return &TrendHandler{svc: svc}
+// NewTrendHandler serves trends; with a non-nil eventSvc each series is
+// annotated with the timeline events over its periods.
+func NewTrendHandler(svc *service.TrendService, currencySvc *service.CurrencyService, eventSvc *service.EventService) *TrendHandler {
+	return &TrendHandler{svc: svc, currencySvc: currencySvc, eventSvc: eventSvc}
 }
 
 func (h *TrendHandler) GetTrends(c *gin.Context) {
-	country := c.Query("country")
-	paymentMethod := c.Query("payment_method")
+	f := dto.ParseFilter(c)
 	period := c.DefaultQuery("period", "MOM")
 	metric := c.DefaultQuery("metric", "tpv_usd")
 	periodsBack, _ := strconv.Atoi(c.DefaultQuery("periods_back", "6"))
@@ -40,9 +43,14 @@ func (h *TrendHandler) GetTrends(c *gin.Context) {
 		return
 	}
 
-	results, err := h.svc.GetTrends(c.Request.Context(), country, paymentMethod, period, metric, periodsBack)
+	conv, _, ok := resolveCurrency(c, h.currencySvc, f.SingleCountry(), false)
+	if !ok {
+		return
+	}
+
+	results, err := h.svc.GetTrendsInCurrency(c.Request.Context(), f, period, metric, periodsBack, conv)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute trends: " + err.Error()})
+		writeQueryError(c, "compute trends", err)
 		return
 	}
 
@@ -56,8 +64,17 @@ func (h *TrendHandler) GetTrends(c *gin.Context) {
 		end = totalItems
 	}
 
+	page := results[start:end]
+	if h.eventSvc != nil {
+		if err := h.eventSvc.AnnotateTrends(c.Request.Context(), f, page, period); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load events: " + err.Error()})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"data":       results[start:end],
+		"currency":   conv.Code(),
+		"data":       page,
 		"pagination": dto.NewPagination(p.Page, p.PageSize, totalItems),
 	})
 }
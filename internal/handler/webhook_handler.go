@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/dto"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/service"
+)
+
+type WebhookHandler struct {
+	svc *service.WebhookService
+}
+
+func NewWebhookHandler(svc *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{svc: svc}
+}
+
+func (h *WebhookHandler) CreateSubscription(c *gin.Context) {
+	var req dto.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed: " + err.Error()})
+		return
+	}
+
+	sub, secret, err := h.svc.CreateSubscription(c.Request.Context(), model.WebhookSubscription{
+		URL:          req.URL,
+		Secret:       req.Secret,
+		Description:  req.Description,
+		EventTypes:   req.EventTypes,
+		InsightTypes: req.InsightTypes,
+		Severities:   req.Severities,
+		Countries:    req.Countries,
+	})
+	if errors.Is(err, service.ErrInvalidWebhook) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create webhook: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": sub, "secret": secret})
+}
+
+func (h *WebhookHandler) ListSubscriptions(c *gin.Context) {
+	subs, err := h.svc.ListSubscriptions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list webhooks: " + err.Error()})
+		return
+	}
+	if subs == nil {
+		subs = []model.WebhookSubscription{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": subs})
+}
+
+func (h *WebhookHandler) DeleteSubscription(c *gin.Context) {
+	err := h.svc.DeleteSubscription(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete webhook: " + err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries serves the outbox; status=DEAD is the dead-letter view.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", model.WebhookDeliveryPending, model.WebhookDeliveryDelivered, model.WebhookDeliveryDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be PENDING, DELIVERED or DEAD"})
+		return
+	}
+	p := dto.ParsePagination(c)
+
+	deliveries, err := h.svc.ListDeliveries(c.Request.Context(), status, c.Query("subscription_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list deliveries: " + err.Error()})
+		return
+	}
+
+	totalItems := len(deliveries)
+	start := p.Offset
+	end := start + p.PageSize
+	if start > totalItems {
+		start = totalItems
+	}
+	if end > totalItems {
+		end = totalItems
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       deliveries[start:end],
+		"pagination": dto.NewPagination(p.Page, p.PageSize, totalItems),
+	})
+}
+
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	err := h.svc.Redeliver(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+		return
+	}
+	if errors.Is(err, service.ErrDeliveryPending) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to redeliver: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": "queued"})
+}
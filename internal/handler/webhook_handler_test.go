@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookHandler_Redeliver(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	router := setupFullRouter(t)
+	pool := getTestPool(t)
+	defer pool.Close()
+	ctx := context.Background()
+
+	var deliveryID string
+	err := pool.QueryRow(ctx, `
+		WITH sub AS (
+			INSERT INTO webhook_subscriptions (url, secret) VALUES ('https://example.com/hook', 'secret')
+			RETURNING id
+		)
+		INSERT INTO webhook_deliveries (subscription_id, event_type, insight_id, payload)
+		SELECT id, 'insight.opened', 'test-insight', '{}' FROM sub
+		RETURNING id::text`).Scan(&deliveryID)
+	require.NoError(t, err)
+
+	redeliver := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/webhooks/deliveries/"+deliveryID+"/redeliver", nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("pending delivery is not re-queued", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, redeliver().Code)
+	})
+
+	t.Run("dead delivery is re-queued", func(t *testing.T) {
+		_, err := pool.Exec(ctx, `UPDATE webhook_deliveries SET status = 'DEAD', attempts = 8 WHERE id::text = $1`, deliveryID)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, redeliver().Code)
+
+		var status string
+		var attempts int
+		require.NoError(t, pool.QueryRow(ctx,
+			`SELECT status, attempts FROM webhook_deliveries WHERE id::text = $1`, deliveryID).Scan(&status, &attempts))
+		assert.Equal(t, "PENDING", status)
+		assert.Zero(t, attempts)
+	})
+
+	t.Run("unknown delivery", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/webhooks/deliveries/00000000-0000-0000-0000-000000000000/redeliver", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
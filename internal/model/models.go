@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -59,3 +60,307 @@ type CountryPaymentCatalog struct {
 	IsEssential       bool    `json:"is_essential"`
 	Source            string  `json:"source,omitempty"`
 }
+
+// AnalyticsFilter is the row filter shared by the analytics endpoints. Empty
+// slices and strings leave that dimension unrestricted.
+type AnalyticsFilter struct {
+	Countries      []string
+	PaymentMethods []string
+	Types          []string
+	DateFrom       string
+	DateTo         string
+	Expr           string
+}
+
+// SingleCountry returns the country when the filter selects exactly one.
+func (f AnalyticsFilter) SingleCountry() string {
+	if len(f.Countries) == 1 {
+		return f.Countries[0]
+	}
+	return ""
+}
+
+// Matches reports whether a row passes the country, payment method and type
+// lists. Empty arguments are not checked, for rows that lack that dimension.
+func (f AnalyticsFilter) Matches(country, paymentMethod, pmType string) bool {
+	return listAllows(f.Countries, country) &&
+		listAllows(f.PaymentMethods, paymentMethod) &&
+		listAllows(f.Types, pmType)
+}
+
+func listAllows(list []string, v string) bool {
+	if len(list) == 0 || v == "" {
+		return true
+	}
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectionRule overrides detector parameters for a scope. Empty scope fields
+// match anything, so a rule with none set is the global rule of its set.
+type DetectionRule struct {
+	ID                string             `json:"id"`
+	RuleSet           string             `json:"rule_set"`
+	CountryCode       string             `json:"country_code,omitempty"`
+	PaymentMethodType string             `json:"payment_method_type,omitempty"`
+	PaymentMethodCode string             `json:"payment_method_code,omitempty"`
+	Params            map[string]float64 `json:"params"`
+	Version           int                `json:"version"`
+	UpdatedBy         string             `json:"updated_by"`
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
+}
+
+// Specificity orders rules from global to method scoped.
+func (r DetectionRule) Specificity() int {
+	s := 0
+	if r.CountryCode != "" {
+		s++
+	}
+	if r.PaymentMethodType != "" {
+		s += 2
+	}
+	if r.PaymentMethodCode != "" {
+		s += 4
+	}
+	return s
+}
+
+// Matches reports whether the rule applies to the given row.
+func (r DetectionRule) Matches(country, pmType, paymentMethod string) bool {
+	return (r.CountryCode == "" || r.CountryCode == country) &&
+		(r.PaymentMethodType == "" || r.PaymentMethodType == pmType) &&
+		(r.PaymentMethodCode == "" || r.PaymentMethodCode == paymentMethod)
+}
+
+type DetectionRuleVersion struct {
+	Version   int                `json:"version"`
+	Params    map[string]float64 `json:"params"`
+	ChangedBy string             `json:"changed_by"`
+	Note      string             `json:"note"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+const (
+	InsightStateOpen         = "OPEN"
+	InsightStateAcknowledged = "ACKNOWLEDGED"
+	InsightStateSnoozed      = "SNOOZED"
+	InsightStateResolved     = "RESOLVED"
+	InsightStateAutoResolved = "AUTO_RESOLVED"
+)
+
+// TrackedInsight is the persisted lifecycle of an insight, keyed by its
+// stable insight_id. Snapshot holds the insight as last detected.
+type TrackedInsight struct {
+	InsightID         string          `json:"insight_id"`
+	Type              string          `json:"type"`
+	PaymentMethodCode string          `json:"payment_method_code"`
+	CountryCode       string          `json:"country_code"`
+	Severity          string          `json:"severity"`
+	State             string          `json:"state"`
+	SnoozedUntil      *time.Time      `json:"snoozed_until,omitempty"`
+	Assignee          string          `json:"assignee"`
+	FirstSeen         time.Time       `json:"first_seen"`
+	LastSeen          time.Time       `json:"last_seen"`
+	ResolvedAt        *time.Time      `json:"resolved_at,omitempty"`
+	Snapshot          json.RawMessage `json:"snapshot"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+type InsightTransition struct {
+	InsightID string    `json:"insight_id"`
+	FromState string    `json:"from_state"`
+	ToState   string    `json:"to_state"`
+	Actor     string    `json:"actor"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type InsightNote struct {
+	Author    string    `json:"author"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	InsightEventOpened          = "insight.opened"
+	InsightEventResolved        = "insight.resolved"
+	InsightEventStateChanged    = "insight.state_changed"
+	InsightEventSeverityChanged = "insight.severity_changed"
+)
+
+const (
+	WebhookDeliveryPending   = "PENDING"
+	WebhookDeliveryDelivered = "DELIVERED"
+	WebhookDeliveryDead      = "DEAD"
+)
+
+// WebhookSubscription receives insight events. Empty filter lists match
+// everything.
+type WebhookSubscription struct {
+	ID           string    `json:"id"`
+	URL          string    `json:"url"`
+	Secret       string    `json:"-"`
+	Description  string    `json:"description"`
+	EventTypes   []string  `json:"event_types"`
+	InsightTypes []string  `json:"insight_types"`
+	Severities   []string  `json:"severities"`
+	Countries    []string  `json:"countries"`
+	Active       bool      `json:"active"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventType      string          `json:"event_type"`
+	InsightID      string          `json:"insight_id"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code"`
+	LastError      string          `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+const (
+	ChannelKindEmail       = "EMAIL"
+	ChannelKindChatWebhook = "CHAT_WEBHOOK"
+	ChannelKindLog         = "LOG"
+
+	RouteDeliveryImmediate    = "IMMEDIATE"
+	RouteDeliveryDailyDigest  = "DAILY_DIGEST"
+	RouteDeliveryWeeklyDigest = "WEEKLY_DIGEST"
+)
+
+// NotificationChannel is a destination with its own templates and an hourly
+// message limit (0 means unlimited).
+type NotificationChannel struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Kind             string    `json:"kind"`
+	Recipients       []string  `json:"recipients"`
+	WebhookURL       string    `json:"webhook_url,omitempty"`
+	SubjectTemplate  string    `json:"subject_template"`
+	BodyTemplate     string    `json:"body_template"`
+	RateLimitPerHour int       `json:"rate_limit_per_hour"`
+	Active           bool      `json:"active"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// NotificationRoute sends matching insight events to a channel. Routes are
+// evaluated by ascending priority; StopProcessing ends evaluation on a match.
+type NotificationRoute struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	Priority       int       `json:"priority"`
+	EventTypes     []string  `json:"event_types"`
+	InsightTypes   []string  `json:"insight_types"`
+	Severities     []string  `json:"severities"`
+	Countries      []string  `json:"countries"`
+	ChannelID      string    `json:"channel_id"`
+	Delivery       string    `json:"delivery"`
+	StopProcessing bool      `json:"stop_processing"`
+	Active         bool      `json:"active"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type NotificationLogEntry struct {
+	ID        string    `json:"id"`
+	ChannelID string    `json:"channel_id"`
+	RouteID   string    `json:"route_id"`
+	ItemCount int       `json:"item_count"`
+	Subject   string    `json:"subject"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CustomRuleTier raises a matching row to Severity when its score field
+// exceeds Threshold (falls below it, for inverted rules).
+type CustomRuleTier struct {
+	Severity  string  `json:"severity"`
+	Threshold float64 `json:"threshold"`
+}
+
+// CustomInsightRule is an analyst-defined insight: every per-method metric row
+// over the last LookbackDays that satisfies Expression becomes an insight.
+type CustomInsightRule struct {
+	ID                  string           `json:"id"`
+	Name                string           `json:"name"`
+	Expression          string           `json:"expression"`
+	ScoreField          string           `json:"score_field"`
+	SeverityTiers       []CustomRuleTier `json:"severity_tiers"`
+	DefaultSeverity     string           `json:"default_severity"`
+	Inverted            bool             `json:"inverted"`
+	LookbackDays        int              `json:"lookback_days"`
+	DescriptionTemplate string           `json:"description_template"`
+	RecommendedAction   string           `json:"recommended_action"`
+	Enabled             bool             `json:"enabled"`
+	UpdatedBy           string           `json:"updated_by"`
+	CreatedAt           time.Time        `json:"created_at"`
+	UpdatedAt           time.Time        `json:"updated_at"`
+}
+
+const (
+	EventKindMaintenance   = "MAINTENANCE"
+	EventKindOutage        = "OUTAGE"
+	EventKindPricingChange = "PRICING_CHANGE"
+	EventKindLaunch        = "LAUNCH"
+	EventKindCampaign      = "CAMPAIGN"
+)
+
+// Event is something that happened to a payment method, country or provider
+// over a time range. Empty scope fields match anything, so an event with none
+// set applies everywhere.
+type Event struct {
+	ID                string    `json:"id"`
+	Kind              string    `json:"kind"`
+	Title             string    `json:"title"`
+	Description       string    `json:"description"`
+	PaymentMethodCode string    `json:"payment_method_code,omitempty"`
+	CountryCode       string    `json:"country_code,omitempty"`
+	Provider          string    `json:"provider,omitempty"`
+	StartsAt          time.Time `json:"starts_at"`
+	EndsAt            time.Time `json:"ends_at"`
+	CreatedBy         string    `json:"created_by"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// Matches reports whether the event applies to a method-country whose
+// method belongs to provider.
+func (e Event) Matches(country, paymentMethod, provider string) bool {
+	return (e.CountryCode == "" || e.CountryCode == country) &&
+		(e.PaymentMethodCode == "" || e.PaymentMethodCode == paymentMethod) &&
+		(e.Provider == "" || e.Provider == provider)
+}
+
+// Overlaps reports whether the event's range intersects [from, to].
+func (e Event) Overlaps(from, to time.Time) bool {
+	return !e.StartsAt.After(to) && !e.EndsAt.Before(from)
+}
+
+const (
+	FeedbackUseful        = "USEFUL"
+	FeedbackFalsePositive = "FALSE_POSITIVE"
+	FeedbackDuplicate     = "DUPLICATE"
+)
+
+// InsightFeedback is an analyst's verdict on a tracked insight. InsightType
+// and Thresholds are copied from the insight when the feedback is given.
+type InsightFeedback struct {
+	ID          string             `json:"id"`
+	InsightID   string             `json:"insight_id"`
+	InsightType string             `json:"insight_type"`
+	Thresholds  map[string]float64 `json:"thresholds"`
+	Verdict     string             `json:"verdict"`
+	Reason      string             `json:"reason"`
+	Author      string             `json:"author"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+type AmountBandRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAmountBandRepository(pool *pgxpool.Pool) *AmountBandRepository {
+	return &AmountBandRepository{pool: pool}
+}
+
+// AmountBandRow is one equal-count band of a method-country's transactions
+// ordered by local amount. Band runs from 1 (smallest amounts) to the band
+// count.
+type AmountBandRow struct {
+	PaymentMethodCode string
+	PaymentMethodName string
+	PaymentMethodType string
+	CountryCode       string
+	Currency          string
+	Band              int
+	MinAmount         float64
+	MaxAmount         float64
+	TransactionCount  int
+	ApprovedCount     int
+	VolumeUSD         float64
+	ApprovedTpvUSD    float64
+}
+
+// GetAmountBands splits each method-country-currency's transactions between
+// since and before into bands of roughly equal size by local amount.
+func (r *AmountBandRepository) GetAmountBands(ctx context.Context, f model.AnalyticsFilter, since, before time.Time, bands int) ([]AmountBandRow, error) {
+	var b queryBuilder
+	where := and(
+		"t.transaction_date >= "+b.bind(since),
+		"t.transaction_date < "+b.bind(before),
+		b.anyOf("t.country_code", f.Countries),
+		b.anyOf("t.payment_method_code", f.PaymentMethods),
+		b.anyOf("pm.type", f.Types),
+	)
+
+	query := fmt.Sprintf(`
+		WITH banded AS (
+			SELECT t.payment_method_code, pm.name, pm.type, t.country_code, t.currency,
+				t.amount, t.amount_usd, t.status,
+				NTILE(%s::int) OVER (
+					PARTITION BY t.payment_method_code, t.country_code, t.currency
+					ORDER BY t.amount
+				) as band
+			FROM transactions t
+			JOIN payment_methods pm ON pm.code = t.payment_method_code
+			WHERE %s
+		)
+		SELECT payment_method_code, name, type, country_code, currency, band,
+			MIN(amount)::float, MAX(amount)::float,
+			COUNT(*) as txn_count,
+			COUNT(*) FILTER (WHERE status = 'APPROVED') as approved_count,
+			SUM(amount_usd)::float as volume_usd,
+			COALESCE(SUM(amount_usd) FILTER (WHERE status = 'APPROVED'), 0)::float as approved_tpv_usd
+		FROM banded
+		GROUP BY payment_method_code, name, type, country_code, currency, band
+		ORDER BY payment_method_code, country_code, currency, band
+	`, b.bind(bands), where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query amount bands: %w", err)
+	}
+	defer rows.Close()
+
+	var results []AmountBandRow
+	for rows.Next() {
+		var a AmountBandRow
+		if err := rows.Scan(&a.PaymentMethodCode, &a.PaymentMethodName, &a.PaymentMethodType, &a.CountryCode,
+			&a.Currency, &a.Band, &a.MinAmount, &a.MaxAmount, &a.TransactionCount, &a.ApprovedCount,
+			&a.VolumeUSD, &a.ApprovedTpvUSD); err != nil {
+			return nil, fmt.Errorf("scan amount band: %w", err)
+		}
+		results = append(results, a)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type BenchmarkRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewBenchmarkRepository(pool *pgxpool.Pool) *BenchmarkRepository {
+	return &BenchmarkRepository{pool: pool}
+}
+
+type BenchmarkRow struct {
+	PaymentMethodCode  string
+	PaymentMethodName  string
+	PaymentMethodType  string
+	CountryCode        string
+	TotalCount         int
+	ApprovedCount      int
+	ApprovedTPV        float64
+	AvgTicketUSD       float64
+	MonthsInRange      float64
+	RecentTPV          float64
+	PriorTPV           float64
+	MonthlyFixedCost   float64
+	PerTransactionCost float64
+	PercentageFee      float64
+}
+
+// GetBenchmarkData returns one row per (payment_method, country) across every
+// country, since peer groups span the whole region. Growth compares approved
+// TPV in the last growthDays before date_to (or now) against the growthDays
+// before that.
+func (r *BenchmarkRepository) GetBenchmarkData(ctx context.Context, dateFrom, dateTo string, growthDays int) ([]BenchmarkRow, error) {
+	var b queryBuilder
+	from := b.bind(dateFrom)
+	to := b.bind(dateTo)
+	days := b.bind(growthDays)
+	where := b.dateRange("t.transaction_date", dateFrom, dateTo)
+
+	query := fmt.Sprintf(`
+		WITH txn_agg AS (
+			SELECT t.payment_method_code, t.country_code,
+				COUNT(*) AS total_count,
+				COUNT(*) FILTER (WHERE t.status = 'APPROVED') AS approved_count,
+				COALESCE(SUM(t.amount_usd) FILTER (WHERE t.status = 'APPROVED'), 0) AS approved_tpv,
+				COALESCE(AVG(t.amount_usd), 0) AS avg_ticket,
+				GREATEST(
+					EXTRACT(EPOCH FROM (
+						COALESCE(NULLIF(%[2]s::text, '')::timestamptz, MAX(t.transaction_date)) -
+						COALESCE(NULLIF(%[1]s::text, '')::timestamptz, MIN(t.transaction_date))
+					)) / (30*86400),
+					1
+				) AS months_in_range
+			FROM transactions t
+			WHERE %[4]s
+			GROUP BY t.payment_method_code, t.country_code
+		),
+		anchor AS (
+			SELECT COALESCE(NULLIF(%[2]s::text, '')::timestamptz, NOW()) AS at
+		),
+		growth AS (
+			SELECT t.payment_method_code, t.country_code,
+				COALESCE(SUM(t.amount_usd) FILTER (
+					WHERE t.transaction_date > an.at - make_interval(days => %[3]s::int)
+				), 0) AS recent_tpv,
+				COALESCE(SUM(t.amount_usd) FILTER (
+					WHERE t.transaction_date <= an.at - make_interval(days => %[3]s::int)
+				), 0) AS prior_tpv
+			FROM transactions t
+			CROSS JOIN anchor an
+			WHERE t.status = 'APPROVED'
+				AND t.transaction_date > an.at - make_interval(days => %[3]s::int * 2)
+				AND t.transaction_date <= an.at
+			GROUP BY t.payment_method_code, t.country_code
+		)
+		SELECT a.payment_method_code, pm.name, pm.type, a.country_code,
+			a.total_count, a.approved_count, a.approved_tpv, a.avg_ticket, a.months_in_range,
+			COALESCE(g.recent_tpv, 0), COALESCE(g.prior_tpv, 0),
+			COALESCE(ic.monthly_fixed_cost_usd, 0),
+			COALESCE(ic.per_transaction_cost_usd, 0),
+			COALESCE(ic.percentage_fee, 0)
+		FROM txn_agg a
+		JOIN payment_methods pm ON pm.code = a.payment_method_code
+		LEFT JOIN growth g ON g.payment_method_code = a.payment_method_code
+			AND g.country_code = a.country_code
+		LEFT JOIN integration_costs ic ON ic.payment_method_code = a.payment_method_code
+			AND ic.country_code = a.country_code AND ic.effective_to IS NULL
+		ORDER BY a.country_code, a.payment_method_code
+	`, from, to, days, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query benchmarks: %w", err)
+	}
+	defer rows.Close()
+
+	var results []BenchmarkRow
+	for rows.Next() {
+		var b BenchmarkRow
+		if err := rows.Scan(&b.PaymentMethodCode, &b.PaymentMethodName, &b.PaymentMethodType, &b.CountryCode,
+			&b.TotalCount, &b.ApprovedCount, &b.ApprovedTPV, &b.AvgTicketUSD, &b.MonthsInRange,
+			&b.RecentTPV, &b.PriorTPV,
+			&b.MonthlyFixedCost, &b.PerTransactionCost, &b.PercentageFee); err != nil {
+			return nil, fmt.Errorf("scan benchmark: %w", err)
+		}
+		results = append(results, b)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+type CustomRuleRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewCustomRuleRepository(pool *pgxpool.Pool) *CustomRuleRepository {
+	return &CustomRuleRepository{pool: pool}
+}
+
+const customRuleColumns = `id::text, name, expression, score_field, severity_tiers, default_severity, inverted,
+	lookback_days, description_template, recommended_action, enabled, updated_by, created_at, updated_at`
+
+func scanCustomRule(row pgx.Row) (*model.CustomInsightRule, error) {
+	r := &model.CustomInsightRule{}
+	err := row.Scan(&r.ID, &r.Name, &r.Expression, &r.ScoreField, &r.SeverityTiers, &r.DefaultSeverity, &r.Inverted,
+		&r.LookbackDays, &r.DescriptionTemplate, &r.RecommendedAction, &r.Enabled, &r.UpdatedBy, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CustomRuleRepository) CreateRule(ctx context.Context, rule model.CustomInsightRule) (*model.CustomInsightRule, error) {
+	saved, err := scanCustomRule(r.pool.QueryRow(ctx, `
+		INSERT INTO custom_insight_rules (name, expression, score_field, severity_tiers, default_severity, inverted,
+			lookback_days, description_template, recommended_action, enabled, updated_by)
+		VALUES ($1, $2, $3, $4::jsonb, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING `+customRuleColumns,
+		rule.Name, rule.Expression, rule.ScoreField, rule.SeverityTiers, rule.DefaultSeverity, rule.Inverted,
+		rule.LookbackDays, rule.DescriptionTemplate, rule.RecommendedAction, rule.Enabled, rule.UpdatedBy))
+	if err != nil {
+		return nil, fmt.Errorf("insert custom insight rule: %w", err)
+	}
+	return saved, nil
+}
+
+// UpdateRule replaces every editable field of the rule. It returns
+// pgx.ErrNoRows when no rule has the given id.
+func (r *CustomRuleRepository) UpdateRule(ctx context.Context, rule model.CustomInsightRule) (*model.CustomInsightRule, error) {
+	saved, err := scanCustomRule(r.pool.QueryRow(ctx, `
+		UPDATE custom_insight_rules SET
+			name = $2,
+			expression = $3,
+			score_field = $4,
+			severity_tiers = $5::jsonb,
+			default_severity = $6,
+			inverted = $7,
+			lookback_days = $8,
+			description_template = $9,
+			recommended_action = $10,
+			enabled = $11,
+			updated_by = $12,
+			updated_at = NOW()
+		WHERE id::text = $1
+		RETURNING `+customRuleColumns,
+		rule.ID, rule.Name, rule.Expression, rule.ScoreField, rule.SeverityTiers, rule.DefaultSeverity, rule.Inverted,
+		rule.LookbackDays, rule.DescriptionTemplate, rule.RecommendedAction, rule.Enabled, rule.UpdatedBy))
+	if err != nil {
+		return nil, fmt.Errorf("update custom insight rule: %w", err)
+	}
+	return saved, nil
+}
+
+// ListRules returns every rule by name, or only the enabled ones.
+func (r *CustomRuleRepository) ListRules(ctx context.Context, enabledOnly bool) ([]model.CustomInsightRule, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+customRuleColumns+`
+		FROM custom_insight_rules
+		WHERE enabled OR NOT $1
+		ORDER BY name
+	`, enabledOnly)
+	if err != nil {
+		return nil, fmt.Errorf("query custom insight rules: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.CustomInsightRule
+	for rows.Next() {
+		rule, err := scanCustomRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan custom insight rule: %w", err)
+		}
+		results = append(results, *rule)
+	}
+	return results, rows.Err()
+}
+
+// GetRule returns pgx.ErrNoRows when no rule has the given id.
+func (r *CustomRuleRepository) GetRule(ctx context.Context, id string) (*model.CustomInsightRule, error) {
+	return scanCustomRule(r.pool.QueryRow(ctx,
+		`SELECT `+customRuleColumns+` FROM custom_insight_rules WHERE id::text = $1`, id))
+}
+
+// DeleteRule returns pgx.ErrNoRows when nothing matched.
+func (r *CustomRuleRepository) DeleteRule(ctx context.Context, id string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM custom_insight_rules WHERE id::text = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete custom insight rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
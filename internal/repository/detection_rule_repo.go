@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+type DetectionRuleRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewDetectionRuleRepository(pool *pgxpool.Pool) *DetectionRuleRepository {
+	return &DetectionRuleRepository{pool: pool}
+}
+
+// Activity thresholds applied when no activity_status rule sets them. The
+// service registers the same values as the rule set's defaults.
+const (
+	DefaultActiveMinTxns      = 10
+	DefaultLowActivityMinTxns = 1
+)
+
+// activityParam resolves an activity_status threshold in SQL for the row
+// identified by the country, type and method expressions.
+func activityParam(key string, fallback int, country, pmType, method string) string {
+	return fmt.Sprintf("COALESCE(detection_param('activity_status', '%s', %s, %s, %s), %d)",
+		key, country, pmType, method, fallback)
+}
+
+const detectionRuleColumns = `id::text, rule_set, COALESCE(country_code, ''), COALESCE(payment_method_type, ''),
+	COALESCE(payment_method_code, ''), params, version, updated_by, created_at, updated_at`
+
+func scanDetectionRule(row pgx.Row) (*model.DetectionRule, error) {
+	r := &model.DetectionRule{}
+	err := row.Scan(&r.ID, &r.RuleSet, &r.CountryCode, &r.PaymentMethodType,
+		&r.PaymentMethodCode, &r.Params, &r.Version, &r.UpdatedBy, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ListRules returns the rules of ruleSet (all sets when empty), global rules
+// first.
+func (r *DetectionRuleRepository) ListRules(ctx context.Context, ruleSet string) ([]model.DetectionRule, error) {
+	var b queryBuilder
+	where := "TRUE"
+	if ruleSet != "" {
+		where = "rule_set = " + b.bind(ruleSet)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM detection_rules
+		WHERE %s
+		ORDER BY rule_set,
+			(payment_method_code IS NOT NULL)::int * 4
+				+ (payment_method_type IS NOT NULL)::int * 2
+				+ (country_code IS NOT NULL)::int,
+			country_code NULLS FIRST, payment_method_type NULLS FIRST, payment_method_code NULLS FIRST
+	`, detectionRuleColumns, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query detection rules: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.DetectionRule
+	for rows.Next() {
+		rule, err := scanDetectionRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan detection rule: %w", err)
+		}
+		results = append(results, *rule)
+	}
+	return results, rows.Err()
+}
+
+// GetRule returns pgx.ErrNoRows when no rule has the given id.
+func (r *DetectionRuleRepository) GetRule(ctx context.Context, id string) (*model.DetectionRule, error) {
+	return scanDetectionRule(r.pool.QueryRow(ctx,
+		`SELECT `+detectionRuleColumns+` FROM detection_rules WHERE id::text = $1`, id))
+}
+
+func (r *DetectionRuleRepository) ListVersions(ctx context.Context, ruleID string) ([]model.DetectionRuleVersion, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT version, params, changed_by, note, created_at
+		FROM detection_rule_versions
+		WHERE rule_id::text = $1
+		ORDER BY version DESC
+	`, ruleID)
+	if err != nil {
+		return nil, fmt.Errorf("query detection rule versions: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.DetectionRuleVersion
+	for rows.Next() {
+		var v model.DetectionRuleVersion
+		if err := rows.Scan(&v.Version, &v.Params, &v.ChangedBy, &v.Note, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan detection rule version: %w", err)
+		}
+		results = append(results, v)
+	}
+	return results, rows.Err()
+}
+
+// UpsertRule replaces the params of the rule with the same scope, or creates
+// it, and records the new version in detection_rule_versions.
+func (r *DetectionRuleRepository) UpsertRule(ctx context.Context, rule model.DetectionRule, note string) (*model.DetectionRule, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	saved, err := scanDetectionRule(tx.QueryRow(ctx, `
+		INSERT INTO detection_rules (rule_set, country_code, payment_method_type, payment_method_code, params, updated_by)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), $5::jsonb, $6)
+		ON CONFLICT ON CONSTRAINT uq_detection_rule_scope DO UPDATE SET
+			params = EXCLUDED.params,
+			version = detection_rules.version + 1,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW()
+		RETURNING `+detectionRuleColumns,
+		rule.RuleSet, rule.CountryCode, rule.PaymentMethodType, rule.PaymentMethodCode, rule.Params, rule.UpdatedBy))
+	if err != nil {
+		return nil, fmt.Errorf("upsert detection rule: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO detection_rule_versions (rule_id, version, params, changed_by, note)
+		VALUES ($1::uuid, $2, $3::jsonb, $4, $5)
+	`, saved.ID, saved.Version, saved.Params, saved.UpdatedBy, note)
+	if err != nil {
+		return nil, fmt.Errorf("insert detection rule version: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit detection rule: %w", err)
+	}
+	return saved, nil
+}
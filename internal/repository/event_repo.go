@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+type EventRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewEventRepository(pool *pgxpool.Pool) *EventRepository {
+	return &EventRepository{pool: pool}
+}
+
+// EventQuery selects events. Empty lists match everything, and an event
+// without a country or method matches any country or method filter. Zero
+// From or To leaves that side of the range open.
+type EventQuery struct {
+	Kinds          []string
+	Countries      []string
+	PaymentMethods []string
+	From           time.Time
+	To             time.Time
+}
+
+const eventColumns = `id::text, kind, title, description, COALESCE(payment_method_code, ''), COALESCE(country_code, ''),
+	COALESCE(provider, ''), starts_at, ends_at, created_by, created_at`
+
+func scanEvent(row pgx.Row) (*model.Event, error) {
+	e := &model.Event{}
+	err := row.Scan(&e.ID, &e.Kind, &e.Title, &e.Description, &e.PaymentMethodCode, &e.CountryCode,
+		&e.Provider, &e.StartsAt, &e.EndsAt, &e.CreatedBy, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *EventRepository) CreateEvent(ctx context.Context, e model.Event) (*model.Event, error) {
+	saved, err := scanEvent(r.pool.QueryRow(ctx, `
+		INSERT INTO events (kind, title, description, payment_method_code, country_code, provider,
+			starts_at, ends_at, created_by)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9)
+		RETURNING `+eventColumns,
+		e.Kind, e.Title, e.Description, e.PaymentMethodCode, e.CountryCode, e.Provider,
+		e.StartsAt, e.EndsAt, e.CreatedBy))
+	if err != nil {
+		return nil, fmt.Errorf("insert event: %w", err)
+	}
+	return saved, nil
+}
+
+// ListEvents returns the events overlapping the query range, by start time.
+func (r *EventRepository) ListEvents(ctx context.Context, q EventQuery) ([]model.Event, error) {
+	var b queryBuilder
+	var conds []string
+	if !q.From.IsZero() {
+		conds = append(conds, "ends_at >= "+b.bind(q.From))
+	}
+	if !q.To.IsZero() {
+		conds = append(conds, "starts_at <= "+b.bind(q.To))
+	}
+	if len(q.Countries) > 0 {
+		conds = append(conds, "(country_code IS NULL OR "+b.anyOf("country_code", q.Countries)+")")
+	}
+	if len(q.PaymentMethods) > 0 {
+		conds = append(conds, "(payment_method_code IS NULL OR "+b.anyOf("payment_method_code", q.PaymentMethods)+")")
+	}
+	conds = append(conds, b.anyOf("kind", q.Kinds))
+
+	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM events
+		WHERE %s
+		ORDER BY starts_at, id
+	`, eventColumns, and(conds...)), b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query events: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+		results = append(results, *e)
+	}
+	return results, rows.Err()
+}
+
+// DeleteEvent returns pgx.ErrNoRows when nothing matched.
+func (r *EventRepository) DeleteEvent(ctx context.Context, id string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM events WHERE id::text = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete event: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+// EvidenceRepository reads the raw data behind an insight. A zero from
+// means all history.
+type EvidenceRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewEvidenceRepository(pool *pgxpool.Pool) *EvidenceRepository {
+	return &EvidenceRepository{pool: pool}
+}
+
+func evidenceWindow(b *queryBuilder, from, to time.Time) string {
+	cond := "t.transaction_date < " + b.bind(to)
+	if from.IsZero() {
+		return cond
+	}
+	return and("t.transaction_date >= "+b.bind(from), cond)
+}
+
+// GetPeerMethods lists the other methods with transactions in the country
+// during the window, limited to pmType unless it is empty.
+func (r *EvidenceRepository) GetPeerMethods(ctx context.Context, paymentMethod, country, pmType string, from, to time.Time) ([]string, error) {
+	var b queryBuilder
+	where := and(
+		"t.country_code = "+b.bind(country),
+		"t.payment_method_code <> "+b.bind(paymentMethod),
+		evidenceWindow(&b, from, to),
+	)
+	if pmType != "" {
+		where = and(where, "pm.type = "+b.bind(pmType))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT DISTINCT t.payment_method_code
+		FROM transactions t
+		JOIN payment_methods pm ON pm.code = t.payment_method_code
+		WHERE %s
+		ORDER BY t.payment_method_code
+	`, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query peer methods: %w", err)
+	}
+	defer rows.Close()
+
+	peers := []string{}
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, fmt.Errorf("scan peer method: %w", err)
+		}
+		peers = append(peers, code)
+	}
+	return peers, rows.Err()
+}
+
+// EvidenceWeek is one UTC week (starting Monday) of a method and its peers
+// in a country.
+type EvidenceWeek struct {
+	Week                 time.Time
+	TransactionCount     int
+	ApprovedCount        int
+	TpvUSD               float64
+	PeerTransactionCount int
+	PeerApprovedCount    int
+	PeerTpvUSD           float64
+}
+
+// GetWeeklyEvidence returns weekly counts and approved TPV for the method
+// and, summed, for the peers. Weeks where neither had traffic are omitted.
+func (r *EvidenceRepository) GetWeeklyEvidence(ctx context.Context, paymentMethod, country string, peers []string, from, to time.Time) ([]EvidenceWeek, error) {
+	var b queryBuilder
+	method := b.bind(paymentMethod)
+	peerList := b.bind(nonNil(peers))
+	where := and(
+		"t.country_code = "+b.bind(country),
+		fmt.Sprintf("(t.payment_method_code = %s OR t.payment_method_code = ANY(%s::text[]))", method, peerList),
+		evidenceWindow(&b, from, to),
+	)
+
+	query := fmt.Sprintf(`
+		SELECT DATE_TRUNC('week', t.transaction_date AT TIME ZONE 'UTC') as week,
+			COUNT(*) FILTER (WHERE t.payment_method_code = %[1]s) as txn_count,
+			COUNT(*) FILTER (WHERE t.payment_method_code = %[1]s AND t.status = 'APPROVED') as approved_count,
+			COALESCE(SUM(t.amount_usd) FILTER (WHERE t.payment_method_code = %[1]s AND t.status = 'APPROVED'), 0)::float as tpv_usd,
+			COUNT(*) FILTER (WHERE t.payment_method_code <> %[1]s) as peer_txn_count,
+			COUNT(*) FILTER (WHERE t.payment_method_code <> %[1]s AND t.status = 'APPROVED') as peer_approved_count,
+			COALESCE(SUM(t.amount_usd) FILTER (WHERE t.payment_method_code <> %[1]s AND t.status = 'APPROVED'), 0)::float as peer_tpv_usd
+		FROM transactions t
+		WHERE %[2]s
+		GROUP BY week
+		ORDER BY week
+	`, method, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query weekly evidence: %w", err)
+	}
+	defer rows.Close()
+
+	var results []EvidenceWeek
+	for rows.Next() {
+		var w EvidenceWeek
+		if err := rows.Scan(&w.Week, &w.TransactionCount, &w.ApprovedCount, &w.TpvUSD,
+			&w.PeerTransactionCount, &w.PeerApprovedCount, &w.PeerTpvUSD); err != nil {
+			return nil, fmt.Errorf("scan weekly evidence: %w", err)
+		}
+		results = append(results, w)
+	}
+	return results, rows.Err()
+}
+
+// SampleTransactions returns the method's most recent transactions in the
+// country during the window, limited to statuses unless it is empty.
+func (r *EvidenceRepository) SampleTransactions(ctx context.Context, paymentMethod, country string, statuses []string, from, to time.Time, limit int) ([]model.Transaction, error) {
+	var b queryBuilder
+	where := and(
+		"t.payment_method_code = "+b.bind(paymentMethod),
+		"t.country_code = "+b.bind(country),
+		b.anyOf("t.status", statuses),
+		evidenceWindow(&b, from, to),
+	)
+
+	query := fmt.Sprintf(`
+		SELECT t.id, t.payment_method_code, t.country_code, t.currency, t.amount::float, t.amount_usd::float,
+			t.status, COALESCE(t.merchant_id, ''), COALESCE(t.customer_id, ''), t.transaction_date, t.created_at
+		FROM transactions t
+		WHERE %s
+		ORDER BY t.transaction_date DESC
+		LIMIT %s
+	`, where, b.bind(limit))
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query evidence sample: %w", err)
+	}
+	defer rows.Close()
+
+	sample := []model.Transaction{}
+	for rows.Next() {
+		var t model.Transaction
+		if err := rows.Scan(&t.ID, &t.PaymentMethodCode, &t.CountryCode, &t.Currency, &t.Amount, &t.AmountUSD,
+			&t.Status, &t.MerchantID, &t.CustomerID, &t.TransactionDate, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan evidence sample: %w", err)
+		}
+		sample = append(sample, t)
+	}
+	return sample, rows.Err()
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type FxRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewFxRepository(pool *pgxpool.Pool) *FxRepository {
+	return &FxRepository{pool: pool}
+}
+
+type FxRate struct {
+	Currency      string
+	RateToUSD     float64
+	EffectiveFrom time.Time
+	EffectiveTo   *time.Time
+}
+
+// ListRates returns the rate history of a currency, oldest first.
+func (r *FxRepository) ListRates(ctx context.Context, currency string) ([]FxRate, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT currency, rate_to_usd, effective_from, effective_to
+		FROM fx_rates
+		WHERE currency = $1
+		ORDER BY effective_from
+	`, currency)
+	if err != nil {
+		return nil, fmt.Errorf("query fx rates: %w", err)
+	}
+	defer rows.Close()
+
+	var results []FxRate
+	for rows.Next() {
+		var f FxRate
+		if err := rows.Scan(&f.Currency, &f.RateToUSD, &f.EffectiveFrom, &f.EffectiveTo); err != nil {
+			return nil, fmt.Errorf("scan fx rate: %w", err)
+		}
+		results = append(results, f)
+	}
+	return results, nil
+}
+
+func (r *FxRepository) GetCountryCurrency(ctx context.Context, countryCode string) (string, error) {
+	var currency string
+	err := r.pool.QueryRow(ctx,
+		`SELECT currency FROM countries WHERE code = $1`, countryCode).Scan(&currency)
+	return currency, err
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+type HealthScoreRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewHealthScoreRepository(pool *pgxpool.Pool) *HealthScoreRepository {
+	return &HealthScoreRepository{pool: pool}
+}
+
+type HealthScoreBucket struct {
+	Period             string
+	PaymentMethodCode  string
+	PaymentMethodName  string
+	CountryCode        string
+	TotalCount         int
+	ApprovedCount      int
+	RefundedCount      int
+	TpvUSD             float64
+	TxnCount90d        int
+	ActiveMinTxns      float64
+	LowActivityMinTxns float64
+	MonthlyFixedCost   float64
+	PerTransactionCost float64
+	PercentageFee      float64
+}
+
+// GetScoreInputs returns per-period aggregates with the cost terms effective
+// at the start of each period, the 90-day activity count as of its end and
+// the activity_status thresholds from detection_rules.
+func (r *HealthScoreRepository) GetScoreInputs(ctx context.Context, f model.AnalyticsFilter, period string, periodsBack int) ([]HealthScoreBucket, error) {
+	truncFunc := "month"
+	if period == "WOW" {
+		truncFunc = "week"
+	}
+
+	var b queryBuilder
+	interval := b.bind(fmt.Sprintf("%d %ss", periodsBack, truncFunc))
+	where := and(
+		fmt.Sprintf("t.transaction_date >= DATE_TRUNC('%s', NOW()) - %s::interval", truncFunc, interval),
+		b.anyOf("t.country_code", f.Countries),
+		b.anyOf("t.payment_method_code", f.PaymentMethods),
+		b.anyOf("tpm.type", f.Types),
+	)
+
+	query := fmt.Sprintf(`
+		WITH buckets AS (
+			SELECT
+				DATE_TRUNC('%[1]s', t.transaction_date) AS period_start,
+				t.payment_method_code,
+				t.country_code,
+				COUNT(*) AS total_count,
+				COUNT(*) FILTER (WHERE t.status = 'APPROVED') AS approved_count,
+				COUNT(*) FILTER (WHERE t.status = 'REFUNDED') AS refunded_count,
+				COALESCE(SUM(t.amount_usd) FILTER (WHERE t.status = 'APPROVED'), 0) AS tpv_usd
+			FROM transactions t
+			JOIN payment_methods tpm ON tpm.code = t.payment_method_code
+			WHERE %[2]s
+			GROUP BY DATE_TRUNC('%[1]s', t.transaction_date), t.payment_method_code, t.country_code
+		)
+		SELECT
+			b.period_start::text,
+			b.payment_method_code,
+			pm.name,
+			b.country_code,
+			b.total_count,
+			b.approved_count,
+			b.refunded_count,
+			b.tpv_usd,
+			(
+				SELECT COUNT(*) FROM transactions t90
+				WHERE t90.payment_method_code = b.payment_method_code
+					AND t90.country_code = b.country_code
+					AND t90.transaction_date < b.period_start + INTERVAL '1 %[1]s'
+					AND t90.transaction_date >= b.period_start + INTERVAL '1 %[1]s' - INTERVAL '90 days'
+			) AS txn_count_90d,
+			%[3]s::float,
+			%[4]s::float,
+			COALESCE(ic.monthly_fixed_cost_usd, 0),
+			COALESCE(ic.per_transaction_cost_usd, 0),
+			COALESCE(ic.percentage_fee, 0)
+		FROM buckets b
+		JOIN payment_methods pm ON pm.code = b.payment_method_code
+		LEFT JOIN integration_costs ic ON ic.payment_method_code = b.payment_method_code
+			AND ic.country_code = b.country_code
+			AND ic.effective_from <= b.period_start::date
+			AND (ic.effective_to IS NULL OR ic.effective_to > b.period_start::date)
+		ORDER BY b.payment_method_code, b.country_code, b.period_start
+	`, truncFunc, where,
+		activityParam("active_min_txns", DefaultActiveMinTxns, "b.country_code", "pm.type", "b.payment_method_code"),
+		activityParam("low_activity_min_txns", DefaultLowActivityMinTxns, "b.country_code", "pm.type", "b.payment_method_code"))
+
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query health score inputs: %w", err)
+	}
+	defer rows.Close()
+
+	var results []HealthScoreBucket
+	for rows.Next() {
+		var b HealthScoreBucket
+		if err := rows.Scan(&b.Period, &b.PaymentMethodCode, &b.PaymentMethodName, &b.CountryCode,
+			&b.TotalCount, &b.ApprovedCount, &b.RefundedCount, &b.TpvUSD, &b.TxnCount90d,
+			&b.ActiveMinTxns, &b.LowActivityMinTxns, &b.MonthlyFixedCost, &b.PerTransactionCost, &b.PercentageFee); err != nil {
+			return nil, fmt.Errorf("scan health score input: %w", err)
+		}
+		results = append(results, b)
+	}
+	return results, nil
+}
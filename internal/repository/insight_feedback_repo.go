@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+type InsightFeedbackRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewInsightFeedbackRepository(pool *pgxpool.Pool) *InsightFeedbackRepository {
+	return &InsightFeedbackRepository{pool: pool}
+}
+
+const feedbackColumns = `id::text, insight_id, insight_type, thresholds, verdict, reason, author, created_at`
+
+func scanFeedback(row pgx.Row) (*model.InsightFeedback, error) {
+	f := &model.InsightFeedback{}
+	err := row.Scan(&f.ID, &f.InsightID, &f.InsightType, &f.Thresholds, &f.Verdict, &f.Reason, &f.Author, &f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// AddFeedback stores a verdict with the insight's type and thresholds as last
+// detected. It returns pgx.ErrNoRows when the insight is not tracked.
+func (r *InsightFeedbackRepository) AddFeedback(ctx context.Context, f model.InsightFeedback) (*model.InsightFeedback, error) {
+	return scanFeedback(r.pool.QueryRow(ctx, `
+		INSERT INTO insight_feedback (insight_id, insight_type, thresholds, verdict, reason, author)
+		SELECT insight_id, type, COALESCE(snapshot->'thresholds', '{}'::jsonb), $2, $3, $4
+		FROM insights WHERE insight_id = $1
+		RETURNING `+feedbackColumns,
+		f.InsightID, f.Verdict, f.Reason, f.Author))
+}
+
+// ListFeedback returns an insight's feedback, oldest first.
+func (r *InsightFeedbackRepository) ListFeedback(ctx context.Context, insightID string) ([]model.InsightFeedback, error) {
+	return r.queryFeedback(ctx, `
+		SELECT `+feedbackColumns+`
+		FROM insight_feedback
+		WHERE insight_id = $1
+		ORDER BY created_at, id
+	`, insightID)
+}
+
+// LatestVerdicts returns the most recent feedback on each insight of the
+// given types (all types when empty), so re-labelling an insight replaces
+// its earlier verdict.
+func (r *InsightFeedbackRepository) LatestVerdicts(ctx context.Context, types []string) ([]model.InsightFeedback, error) {
+	var b queryBuilder
+	return r.queryFeedback(ctx, fmt.Sprintf(`
+		SELECT DISTINCT ON (insight_id) %s
+		FROM insight_feedback
+		WHERE %s
+		ORDER BY insight_id, created_at DESC, id DESC
+	`, feedbackColumns, b.anyOf("insight_type", types)), b.args...)
+}
+
+func (r *InsightFeedbackRepository) queryFeedback(ctx context.Context, query string, args ...any) ([]model.InsightFeedback, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query insight feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.InsightFeedback
+	for rows.Next() {
+		f, err := scanFeedback(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan insight feedback: %w", err)
+		}
+		results = append(results, *f)
+	}
+	return results, rows.Err()
+}
@@ -3,8 +3,11 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 )
 
 type InsightRepository struct {
@@ -26,12 +29,23 @@ type ZombieCandidate struct {
 	MonthlyCostUSD    float64
 }
 
-func (r *InsightRepository) GetZombieCandidates(ctx context.Context, country string) ([]ZombieCandidate, error) {
-	query := `
+// GetZombieCandidates evaluates the integrations active at asOf against the
+// transactions before it.
+func (r *InsightRepository) GetZombieCandidates(ctx context.Context, f model.AnalyticsFilter, asOf time.Time) ([]ZombieCandidate, error) {
+	var b queryBuilder
+	ref := b.bind(asOf)
+	where := and(
+		b.anyOf("ic.country_code", f.Countries),
+		b.anyOf("ic.payment_method_code", f.PaymentMethods),
+		b.anyOf("pm.type", f.Types),
+	)
+
+	query := fmt.Sprintf(`
 		WITH txn_90d AS (
 			SELECT payment_method_code, country_code, COUNT(*) as cnt
 			FROM transactions
-			WHERE transaction_date >= NOW() - INTERVAL '90 days'
+			WHERE transaction_date >= %[1]s::timestamptz - INTERVAL '90 days'
+				AND transaction_date < %[1]s
 			GROUP BY payment_method_code, country_code
 		),
 		historical AS (
@@ -42,6 +56,7 @@ func (r *InsightRepository) GetZombieCandidates(ctx context.Context, country str
 				) as monthly_avg,
 				EXTRACT(EPOCH FROM (MAX(transaction_date) - MIN(transaction_date))) / (30*86400) as months_active
 			FROM transactions
+			WHERE transaction_date < %[1]s
 			GROUP BY payment_method_code, country_code
 		)
 		SELECT ic.payment_method_code, pm.name, pm.type, ic.country_code,
@@ -53,10 +68,11 @@ func (r *InsightRepository) GetZombieCandidates(ctx context.Context, country str
 		JOIN payment_methods pm ON pm.code = ic.payment_method_code
 		LEFT JOIN txn_90d t90 ON t90.payment_method_code = ic.payment_method_code AND t90.country_code = ic.country_code
 		LEFT JOIN historical h ON h.payment_method_code = ic.payment_method_code AND h.country_code = ic.country_code
-		WHERE ic.effective_to IS NULL
-			AND ($1 = '' OR ic.country_code = $1)
-	`
-	rows, err := r.pool.Query(ctx, query, country)
+		WHERE ic.effective_from <= %[1]s::date
+			AND (ic.effective_to IS NULL OR ic.effective_to > %[1]s::date)
+			AND %[2]s
+	`, ref, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
 	if err != nil {
 		return nil, fmt.Errorf("query zombie candidates: %w", err)
 	}
@@ -74,45 +90,101 @@ func (r *InsightRepository) GetZombieCandidates(ctx context.Context, country str
 	return results, nil
 }
 
+// HiddenGemCandidate carries a method's revenue and volume shares within its
+// country and within its type in the country, its approved TPV in the two
+// momentum windows, and the integration costs active at detection.
 type HiddenGemCandidate struct {
-	PaymentMethodCode   string
-	PaymentMethodName   string
-	CountryCode         string
-	ApprovalRate        float64
-	RevenueContribution float64
-	VolumeShare         float64
-	TpvUSD              float64
-	TransactionCount    int
-}
-
-func (r *InsightRepository) GetHiddenGemCandidates(ctx context.Context, country string) ([]HiddenGemCandidate, error) {
-	query := `
+	PaymentMethodCode       string
+	PaymentMethodName       string
+	PaymentMethodType       string
+	CountryCode             string
+	ApprovalRate            float64
+	RevenueContribution     float64
+	VolumeShare             float64
+	TypeRevenueContribution float64
+	TypeVolumeShare         float64
+	TpvUSD                  float64
+	TransactionCount        int
+	ActiveMonths            float64
+	RecentTpvUSD            float64
+	PriorTpvUSD             float64
+	// HasCost is false when no integration cost row was active, in which
+	// case the cost fields are zero rather than known to be free.
+	HasCost               bool
+	MonthlyFixedCostUSD   float64
+	PerTransactionCostUSD float64
+	PercentageFee         float64
+}
+
+// activeMonths is the span of a group's transactions in months, at least one.
+const activeMonths = `GREATEST(EXTRACT(EPOCH FROM MAX(%[1]stransaction_date) - MIN(%[1]stransaction_date)) / 2629800, 1)::float`
+
+// GetHiddenGemCandidates computes shares before asOf within each country and
+// within each type in the country, so methods are only compared with the
+// market they compete in. Method and type filters only drop rows. Recent TPV
+// covers the momentumDays before asOf and prior TPV the momentumDays before
+// that; methods without an active cost row have zero costs.
+func (r *InsightRepository) GetHiddenGemCandidates(ctx context.Context, f model.AnalyticsFilter, asOf time.Time, momentumDays int) ([]HiddenGemCandidate, error) {
+	var b queryBuilder
+	ref := b.bind(asOf)
+	days := b.bind(momentumDays)
+	countryWhere := and(
+		b.anyOf("t.country_code", f.Countries),
+		"t.transaction_date < "+ref,
+	)
+	where := and(
+		b.anyOf("s.payment_method_code", f.PaymentMethods),
+		b.anyOf("s.payment_method_type", f.Types),
+	)
+
+	query := fmt.Sprintf(`
 		WITH txn_agg AS (
-			SELECT payment_method_code, country_code,
+			SELECT t.payment_method_code, pm.name as payment_method_name, pm.type as payment_method_type, t.country_code,
 				COUNT(*) as txn_count,
-				COALESCE(SUM(amount_usd) FILTER (WHERE status = 'APPROVED'), 0) as tpv_usd,
-				CASE WHEN COUNT(*) > 0
-					THEN COUNT(*) FILTER (WHERE status = 'APPROVED')::float / COUNT(*)::float * 100
-					ELSE 0
-				END as approval_rate
-			FROM transactions
-			WHERE ($1 = '' OR country_code = $1)
-			GROUP BY payment_method_code, country_code
+				COALESCE(SUM(t.amount_usd) FILTER (WHERE t.status = 'APPROVED'), 0) as tpv_usd,
+				COUNT(*) FILTER (WHERE t.status = 'APPROVED')::float / COUNT(*)::float * 100 as approval_rate,
+				%[1]s as active_months,
+				COALESCE(SUM(t.amount_usd) FILTER (WHERE t.status = 'APPROVED'
+					AND t.transaction_date >= %[2]s::timestamptz - make_interval(days => %[3]s)), 0) as recent_tpv_usd,
+				COALESCE(SUM(t.amount_usd) FILTER (WHERE t.status = 'APPROVED'
+					AND t.transaction_date >= %[2]s::timestamptz - make_interval(days => 2 * %[3]s)
+					AND t.transaction_date < %[2]s::timestamptz - make_interval(days => %[3]s)), 0) as prior_tpv_usd
+			FROM transactions t
+			JOIN payment_methods pm ON pm.code = t.payment_method_code
+			WHERE %[4]s
+			GROUP BY t.payment_method_code, pm.name, pm.type, t.country_code
 		),
-		totals AS (
-			SELECT SUM(tpv_usd) as total_tpv, SUM(txn_count) as total_txns FROM txn_agg
+		shares AS (
+			SELECT a.*,
+				SUM(a.tpv_usd) OVER (PARTITION BY a.country_code) as country_tpv,
+				SUM(a.txn_count) OVER (PARTITION BY a.country_code)::float as country_txns,
+				SUM(a.tpv_usd) OVER (PARTITION BY a.country_code, a.payment_method_type) as type_tpv,
+				SUM(a.txn_count) OVER (PARTITION BY a.country_code, a.payment_method_type)::float as type_txns
+			FROM txn_agg a
 		)
-		SELECT a.payment_method_code, pm.name, a.country_code,
-			a.approval_rate,
-			CASE WHEN t.total_tpv > 0 THEN a.tpv_usd / t.total_tpv * 100 ELSE 0 END as revenue_contribution,
-			CASE WHEN t.total_txns > 0 THEN a.txn_count::float / t.total_txns::float * 100 ELSE 0 END as volume_share,
-			a.tpv_usd,
-			a.txn_count
-		FROM txn_agg a
-		JOIN payment_methods pm ON pm.code = a.payment_method_code
-		CROSS JOIN totals t
-	`
-	rows, err := r.pool.Query(ctx, query, country)
+		SELECT s.payment_method_code, s.payment_method_name, s.payment_method_type, s.country_code,
+			s.approval_rate,
+			CASE WHEN s.country_tpv > 0 THEN s.tpv_usd / s.country_tpv * 100 ELSE 0 END as revenue_contribution,
+			s.txn_count / s.country_txns * 100 as volume_share,
+			CASE WHEN s.type_tpv > 0 THEN s.tpv_usd / s.type_tpv * 100 ELSE 0 END as type_revenue_contribution,
+			s.txn_count / s.type_txns * 100 as type_volume_share,
+			s.tpv_usd,
+			s.txn_count,
+			s.active_months,
+			s.recent_tpv_usd,
+			s.prior_tpv_usd,
+			ic.payment_method_code IS NOT NULL as has_cost,
+			COALESCE(ic.monthly_fixed_cost_usd, 0) as monthly_fixed_cost_usd,
+			COALESCE(ic.per_transaction_cost_usd, 0) as per_transaction_cost_usd,
+			COALESCE(ic.percentage_fee, 0) as percentage_fee
+		FROM shares s
+		LEFT JOIN integration_costs ic ON ic.payment_method_code = s.payment_method_code
+			AND ic.country_code = s.country_code
+			AND ic.effective_from <= %[2]s::date
+			AND (ic.effective_to IS NULL OR ic.effective_to > %[2]s::date)
+		WHERE %[5]s
+	`, fmt.Sprintf(activeMonths, "t."), ref, days, countryWhere, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
 	if err != nil {
 		return nil, fmt.Errorf("query hidden gems: %w", err)
 	}
@@ -121,8 +193,10 @@ func (r *InsightRepository) GetHiddenGemCandidates(ctx context.Context, country
 	var results []HiddenGemCandidate
 	for rows.Next() {
 		var h HiddenGemCandidate
-		if err := rows.Scan(&h.PaymentMethodCode, &h.PaymentMethodName, &h.CountryCode,
-			&h.ApprovalRate, &h.RevenueContribution, &h.VolumeShare, &h.TpvUSD, &h.TransactionCount); err != nil {
+		if err := rows.Scan(&h.PaymentMethodCode, &h.PaymentMethodName, &h.PaymentMethodType, &h.CountryCode,
+			&h.ApprovalRate, &h.RevenueContribution, &h.VolumeShare, &h.TypeRevenueContribution, &h.TypeVolumeShare,
+			&h.TpvUSD, &h.TransactionCount, &h.ActiveMonths, &h.RecentTpvUSD, &h.PriorTpvUSD,
+			&h.HasCost, &h.MonthlyFixedCostUSD, &h.PerTransactionCostUSD, &h.PercentageFee); err != nil {
 			return nil, fmt.Errorf("scan hidden gem: %w", err)
 		}
 		results = append(results, h)
@@ -130,44 +204,78 @@ func (r *InsightRepository) GetHiddenGemCandidates(ctx context.Context, country
 	return results, nil
 }
 
+// PerformanceAlertCandidate pairs a method's counts with those of its peers:
+// every other method of the same type in the country. PeerApprovalRate pools
+// the peers' transactions, so each peer weighs by its volume; it is 0 when
+// the method has no peers.
 type PerformanceAlertCandidate struct {
-	PaymentMethodCode      string
-	PaymentMethodName      string
-	PaymentMethodType      string
-	CountryCode            string
-	ApprovalRate           float64
-	CountryTypeAvgApproval float64
-	TransactionCount       int
+	PaymentMethodCode    string
+	PaymentMethodName    string
+	PaymentMethodType    string
+	CountryCode          string
+	ApprovalRate         float64
+	TransactionCount     int
+	ApprovedCount        int
+	PeerApprovalRate     float64
+	PeerTransactionCount int
+	PeerApprovedCount    int
+	PeerMethods          int
+	VolumeUSD            float64
+	ActiveMonths         float64
 }
 
-func (r *InsightRepository) GetPerformanceAlertCandidates(ctx context.Context, country string) ([]PerformanceAlertCandidate, error) {
-	query := `
+// GetPerformanceAlertCandidates compares each method with the other methods
+// of its type in the country, over the filtered countries before asOf; method
+// and type filters only drop rows.
+func (r *InsightRepository) GetPerformanceAlertCandidates(ctx context.Context, f model.AnalyticsFilter, asOf time.Time) ([]PerformanceAlertCandidate, error) {
+	var b queryBuilder
+	countryWhere := and(
+		b.anyOf("t.country_code", f.Countries),
+		"t.transaction_date < "+b.bind(asOf),
+	)
+	where := and(
+		b.anyOf("ms.payment_method_code", f.PaymentMethods),
+		b.anyOf("ms.pm_type", f.Types),
+	)
+
+	query := fmt.Sprintf(`
 		WITH method_stats AS (
 			SELECT t.payment_method_code, t.country_code, pm.type as pm_type,
 				COUNT(*) as txn_count,
-				CASE WHEN COUNT(*) > 0
-					THEN COUNT(*) FILTER (WHERE t.status = 'APPROVED')::float / COUNT(*)::float * 100
-					ELSE 0
-				END as approval_rate
+				COUNT(*) FILTER (WHERE t.status = 'APPROVED') as approved_count,
+				COALESCE(SUM(t.amount_usd), 0)::float as volume_usd,
+				%s as active_months
 			FROM transactions t
 			JOIN payment_methods pm ON pm.code = t.payment_method_code
-			WHERE ($1 = '' OR t.country_code = $1)
+			WHERE %s
 			GROUP BY t.payment_method_code, t.country_code, pm.type
 		),
-		type_avgs AS (
-			SELECT country_code, pm_type, AVG(approval_rate) as avg_approval
-			FROM method_stats
-			GROUP BY country_code, pm_type
+		with_peers AS (
+			SELECT ms.*,
+				(SUM(ms.txn_count) OVER w)::bigint - ms.txn_count as peer_txns,
+				(SUM(ms.approved_count) OVER w)::bigint - ms.approved_count as peer_approved,
+				COUNT(*) OVER w - 1 as peer_methods
+			FROM method_stats ms
+			WINDOW w AS (PARTITION BY ms.country_code, ms.pm_type)
 		)
 		SELECT ms.payment_method_code, pm.name, ms.pm_type, ms.country_code,
-			ms.approval_rate,
-			ta.avg_approval as country_type_avg,
-			ms.txn_count
-		FROM method_stats ms
+			ms.approved_count::float / ms.txn_count::float * 100 as approval_rate,
+			ms.txn_count,
+			ms.approved_count,
+			CASE WHEN ms.peer_txns > 0
+				THEN ms.peer_approved::float / ms.peer_txns::float * 100
+				ELSE 0
+			END as peer_approval_rate,
+			ms.peer_txns,
+			ms.peer_approved,
+			ms.peer_methods,
+			ms.volume_usd,
+			ms.active_months
+		FROM with_peers ms
 		JOIN payment_methods pm ON pm.code = ms.payment_method_code
-		JOIN type_avgs ta ON ta.country_code = ms.country_code AND ta.pm_type = ms.pm_type
-	`
-	rows, err := r.pool.Query(ctx, query, country)
+		WHERE %s
+	`, fmt.Sprintf(activeMonths, "t."), countryWhere, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
 	if err != nil {
 		return nil, fmt.Errorf("query perf alerts: %w", err)
 	}
@@ -177,10 +285,398 @@ func (r *InsightRepository) GetPerformanceAlertCandidates(ctx context.Context, c
 	for rows.Next() {
 		var p PerformanceAlertCandidate
 		if err := rows.Scan(&p.PaymentMethodCode, &p.PaymentMethodName, &p.PaymentMethodType,
-			&p.CountryCode, &p.ApprovalRate, &p.CountryTypeAvgApproval, &p.TransactionCount); err != nil {
+			&p.CountryCode, &p.ApprovalRate, &p.TransactionCount, &p.ApprovedCount,
+			&p.PeerApprovalRate, &p.PeerTransactionCount, &p.PeerApprovedCount, &p.PeerMethods,
+			&p.VolumeUSD, &p.ActiveMonths); err != nil {
 			return nil, fmt.Errorf("scan perf alert: %w", err)
 		}
 		results = append(results, p)
 	}
 	return results, nil
 }
+
+// HourlyApprovalPoint is one hour of traffic for a method in a country.
+type HourlyApprovalPoint struct {
+	PaymentMethodCode string
+	PaymentMethodName string
+	PaymentMethodType string
+	CountryCode       string
+	Hour              time.Time
+	TransactionCount  int
+	ApprovedCount     int
+	VolumeUSD         float64
+}
+
+// GetHourlyApprovalSeries returns hourly counts from since up to before,
+// ordered by method, country and hour. Hours without traffic are absent.
+func (r *InsightRepository) GetHourlyApprovalSeries(ctx context.Context, f model.AnalyticsFilter, since, before time.Time) ([]HourlyApprovalPoint, error) {
+	var b queryBuilder
+	where := and(
+		"t.transaction_date >= "+b.bind(since),
+		"t.transaction_date < "+b.bind(before),
+		b.anyOf("t.country_code", f.Countries),
+		b.anyOf("t.payment_method_code", f.PaymentMethods),
+		b.anyOf("pm.type", f.Types),
+	)
+
+	query := fmt.Sprintf(`
+		SELECT t.payment_method_code, pm.name, pm.type, t.country_code,
+			DATE_TRUNC('hour', t.transaction_date) as hour,
+			COUNT(*) as txn_count,
+			COUNT(*) FILTER (WHERE t.status = 'APPROVED') as approved_count,
+			COALESCE(SUM(t.amount_usd), 0)::float as volume_usd
+		FROM transactions t
+		JOIN payment_methods pm ON pm.code = t.payment_method_code
+		WHERE %s
+		GROUP BY t.payment_method_code, pm.name, pm.type, t.country_code, hour
+		ORDER BY t.payment_method_code, t.country_code, hour
+	`, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query hourly approval series: %w", err)
+	}
+	defer rows.Close()
+
+	var results []HourlyApprovalPoint
+	for rows.Next() {
+		var p HourlyApprovalPoint
+		if err := rows.Scan(&p.PaymentMethodCode, &p.PaymentMethodName, &p.PaymentMethodType, &p.CountryCode,
+			&p.Hour, &p.TransactionCount, &p.ApprovedCount, &p.VolumeUSD); err != nil {
+			return nil, fmt.Errorf("scan hourly approval: %w", err)
+		}
+		results = append(results, p)
+	}
+	return results, rows.Err()
+}
+
+// HourOfWeekVolume is a method-country's traffic in one UTC hour-of-week slot
+// (Sunday 00:00 is slot 0) summed over a history window.
+type HourOfWeekVolume struct {
+	PaymentMethodCode string
+	PaymentMethodName string
+	PaymentMethodType string
+	CountryCode       string
+	HourOfWeek        int
+	TransactionCount  int
+	ApprovedTpvUSD    float64
+	SeriesStart       time.Time
+}
+
+// GetHourOfWeekVolumes sums traffic per hour-of-week slot between since and
+// before. SeriesStart is the method-country's first transaction in the window.
+func (r *InsightRepository) GetHourOfWeekVolumes(ctx context.Context, f model.AnalyticsFilter, since, before time.Time) ([]HourOfWeekVolume, error) {
+	var b queryBuilder
+	where := and(
+		"t.transaction_date >= "+b.bind(since),
+		"t.transaction_date < "+b.bind(before),
+		b.anyOf("t.country_code", f.Countries),
+		b.anyOf("t.payment_method_code", f.PaymentMethods),
+		b.anyOf("pm.type", f.Types),
+	)
+
+	query := fmt.Sprintf(`
+		SELECT t.payment_method_code, pm.name, pm.type, t.country_code,
+			(EXTRACT(DOW FROM t.transaction_date AT TIME ZONE 'UTC')::int * 24
+				+ EXTRACT(HOUR FROM t.transaction_date AT TIME ZONE 'UTC')::int) as hour_of_week,
+			COUNT(*) as txn_count,
+			COALESCE(SUM(t.amount_usd) FILTER (WHERE t.status = 'APPROVED'), 0)::float as approved_tpv,
+			MIN(MIN(t.transaction_date)) OVER (PARTITION BY t.payment_method_code, t.country_code) as series_start
+		FROM transactions t
+		JOIN payment_methods pm ON pm.code = t.payment_method_code
+		WHERE %s
+		GROUP BY t.payment_method_code, pm.name, pm.type, t.country_code, hour_of_week
+		ORDER BY t.payment_method_code, t.country_code, hour_of_week
+	`, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query hour-of-week volumes: %w", err)
+	}
+	defer rows.Close()
+
+	var results []HourOfWeekVolume
+	for rows.Next() {
+		var v HourOfWeekVolume
+		if err := rows.Scan(&v.PaymentMethodCode, &v.PaymentMethodName, &v.PaymentMethodType, &v.CountryCode,
+			&v.HourOfWeek, &v.TransactionCount, &v.ApprovedTpvUSD, &v.SeriesStart); err != nil {
+			return nil, fmt.Errorf("scan hour-of-week volume: %w", err)
+		}
+		results = append(results, v)
+	}
+	return results, rows.Err()
+}
+
+// RecentActivity is a method-country's latest transaction and its traffic
+// in a recent window.
+type RecentActivity struct {
+	PaymentMethodCode string
+	CountryCode       string
+	LastTransactionAt time.Time
+	RecentCount       int
+	RecentTpvUSD      float64
+}
+
+// GetRecentActivity returns, per method-country with traffic between since
+// and before, the last transaction time and the count and approved TPV from
+// recentSince on.
+func (r *InsightRepository) GetRecentActivity(ctx context.Context, f model.AnalyticsFilter, since, recentSince, before time.Time) ([]RecentActivity, error) {
+	var b queryBuilder
+	recent := b.bind(recentSince)
+	where := and(
+		"t.transaction_date >= "+b.bind(since),
+		"t.transaction_date < "+b.bind(before),
+		b.anyOf("t.country_code", f.Countries),
+		b.anyOf("t.payment_method_code", f.PaymentMethods),
+		b.anyOf("pm.type", f.Types),
+	)
+
+	query := fmt.Sprintf(`
+		SELECT t.payment_method_code, t.country_code,
+			MAX(t.transaction_date) as last_txn_at,
+			COUNT(*) FILTER (WHERE t.transaction_date >= %[1]s) as recent_count,
+			COALESCE(SUM(t.amount_usd) FILTER (WHERE t.transaction_date >= %[1]s AND t.status = 'APPROVED'), 0)::float as recent_tpv
+		FROM transactions t
+		JOIN payment_methods pm ON pm.code = t.payment_method_code
+		WHERE %[2]s
+		GROUP BY t.payment_method_code, t.country_code
+	`, recent, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query recent activity: %w", err)
+	}
+	defer rows.Close()
+
+	var results []RecentActivity
+	for rows.Next() {
+		var a RecentActivity
+		if err := rows.Scan(&a.PaymentMethodCode, &a.CountryCode, &a.LastTransactionAt, &a.RecentCount, &a.RecentTpvUSD); err != nil {
+			return nil, fmt.Errorf("scan recent activity: %w", err)
+		}
+		results = append(results, a)
+	}
+	return results, rows.Err()
+}
+
+// MethodTPV is a method's approved TPV in a country.
+type MethodTPV struct {
+	PaymentMethodCode string
+	PaymentMethodName string
+	PaymentMethodType string
+	CountryCode       string
+	TpvUSD            float64
+}
+
+// GetMethodTPV returns approved TPV from since up to before per method and
+// country. Only the country filter applies so shares stay relative to the
+// whole market; callers drop rows for method and type filters.
+func (r *InsightRepository) GetMethodTPV(ctx context.Context, f model.AnalyticsFilter, since, before time.Time) ([]MethodTPV, error) {
+	var b queryBuilder
+	where := and(
+		"t.transaction_date >= "+b.bind(since),
+		"t.transaction_date < "+b.bind(before),
+		"t.status = 'APPROVED'",
+		b.anyOf("t.country_code", f.Countries),
+	)
+
+	query := fmt.Sprintf(`
+		SELECT t.payment_method_code, pm.name, pm.type, t.country_code,
+			SUM(t.amount_usd)::float as tpv_usd
+		FROM transactions t
+		JOIN payment_methods pm ON pm.code = t.payment_method_code
+		WHERE %s
+		GROUP BY t.payment_method_code, pm.name, pm.type, t.country_code
+		ORDER BY t.country_code, tpv_usd DESC
+	`, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query method tpv: %w", err)
+	}
+	defer rows.Close()
+
+	var results []MethodTPV
+	for rows.Next() {
+		var m MethodTPV
+		if err := rows.Scan(&m.PaymentMethodCode, &m.PaymentMethodName, &m.PaymentMethodType, &m.CountryCode, &m.TpvUSD); err != nil {
+			return nil, fmt.Errorf("scan method tpv: %w", err)
+		}
+		results = append(results, m)
+	}
+	return results, rows.Err()
+}
+
+// CatalogAlternative is a catalog method with no transactions in the given
+// window. PaymentMethodType is empty when the method is not in
+// payment_methods.
+type CatalogAlternative struct {
+	CountryCode       string
+	PaymentMethodCode string
+	PaymentMethodType string
+	MarketSharePct    float64
+	IsEssential       bool
+}
+
+// GetCatalogAlternatives lists inactive catalog methods, largest market share
+// first.
+func (r *InsightRepository) GetCatalogAlternatives(ctx context.Context, f model.AnalyticsFilter, since, before time.Time) ([]CatalogAlternative, error) {
+	var b queryBuilder
+	sinceArg, beforeArg := b.bind(since), b.bind(before)
+	where := b.anyOf("cpc.country_code", f.Countries)
+
+	query := fmt.Sprintf(`
+		SELECT cpc.country_code, cpc.payment_method_code, COALESCE(pm.type, ''),
+			COALESCE(cpc.market_share_pct, 0)::float, cpc.is_essential
+		FROM country_payment_catalog cpc
+		LEFT JOIN payment_methods pm ON pm.code = cpc.payment_method_code
+		WHERE NOT EXISTS (
+			SELECT 1 FROM transactions t
+			WHERE t.payment_method_code = cpc.payment_method_code
+				AND t.country_code = cpc.country_code
+				AND t.transaction_date >= %s
+				AND t.transaction_date < %s
+		)
+		AND %s
+		ORDER BY cpc.country_code, COALESCE(cpc.market_share_pct, 0) DESC, cpc.payment_method_code
+	`, sinceArg, beforeArg, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query catalog alternatives: %w", err)
+	}
+	defer rows.Close()
+
+	var results []CatalogAlternative
+	for rows.Next() {
+		var a CatalogAlternative
+		if err := rows.Scan(&a.CountryCode, &a.PaymentMethodCode, &a.PaymentMethodType, &a.MarketSharePct, &a.IsEssential); err != nil {
+			return nil, fmt.Errorf("scan catalog alternative: %w", err)
+		}
+		results = append(results, a)
+	}
+	return results, rows.Err()
+}
+
+// MethodLaunch is when a method went live in a country: the earliest
+// effective_from of its integration.
+type MethodLaunch struct {
+	PaymentMethodCode string
+	PaymentMethodName string
+	PaymentMethodType string
+	CountryCode       string
+	LaunchedAt        time.Time
+}
+
+// GetLaunchesBetween returns methods whose first integration became
+// effective between from and to.
+func (r *InsightRepository) GetLaunchesBetween(ctx context.Context, f model.AnalyticsFilter, from, to time.Time) ([]MethodLaunch, error) {
+	var b queryBuilder
+	where := and(
+		b.anyOf("ic.country_code", f.Countries),
+		b.anyOf("ic.payment_method_code", f.PaymentMethods),
+		b.anyOf("pm.type", f.Types),
+	)
+	fromArg, toArg := b.bind(from), b.bind(to)
+
+	query := fmt.Sprintf(`
+		SELECT ic.payment_method_code, pm.name, pm.type, ic.country_code,
+			MIN(ic.effective_from)::timestamptz as launched_at
+		FROM integration_costs ic
+		JOIN payment_methods pm ON pm.code = ic.payment_method_code
+		WHERE %s
+		GROUP BY ic.payment_method_code, pm.name, pm.type, ic.country_code
+		HAVING MIN(ic.effective_from) >= %s::date AND MIN(ic.effective_from) <= %s::date
+		ORDER BY ic.country_code, launched_at
+	`, where, fromArg, toArg)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query launches: %w", err)
+	}
+	defer rows.Close()
+
+	var results []MethodLaunch
+	for rows.Next() {
+		var l MethodLaunch
+		if err := rows.Scan(&l.PaymentMethodCode, &l.PaymentMethodName, &l.PaymentMethodType, &l.CountryCode, &l.LaunchedAt); err != nil {
+			return nil, fmt.Errorf("scan launch: %w", err)
+		}
+		results = append(results, l)
+	}
+	return results, rows.Err()
+}
+
+// WeeklyMethodTPV is a method's approved TPV in one week relative to a
+// reference time; week 0 starts at the reference, week -1 ends at it.
+type WeeklyMethodTPV struct {
+	PaymentMethodCode string
+	PaymentMethodType string
+	Week              int
+	TpvUSD            float64
+}
+
+// GetWeeklyTPVAround returns approved TPV per method of a country for each
+// week from weeksBefore weeks before ref until weeksAfter weeks after it.
+func (r *InsightRepository) GetWeeklyTPVAround(ctx context.Context, country string, ref time.Time, weeksBefore, weeksAfter int) ([]WeeklyMethodTPV, error) {
+	week := 7 * 24 * time.Hour
+	var b queryBuilder
+	refArg := b.bind(ref)
+	where := and(
+		"t.country_code = "+b.bind(country),
+		"t.status = 'APPROVED'",
+		"t.transaction_date >= "+b.bind(ref.Add(-time.Duration(weeksBefore)*week)),
+		"t.transaction_date < "+b.bind(ref.Add(time.Duration(weeksAfter)*week)),
+	)
+
+	query := fmt.Sprintf(`
+		SELECT t.payment_method_code, pm.type,
+			FLOOR(EXTRACT(EPOCH FROM (t.transaction_date - %s::timestamptz)) / 604800)::int as week,
+			SUM(t.amount_usd)::float as tpv_usd
+		FROM transactions t
+		JOIN payment_methods pm ON pm.code = t.payment_method_code
+		WHERE %s
+		GROUP BY t.payment_method_code, pm.type, week
+		ORDER BY t.payment_method_code, week
+	`, refArg, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query weekly tpv: %w", err)
+	}
+	defer rows.Close()
+
+	var results []WeeklyMethodTPV
+	for rows.Next() {
+		var w WeeklyMethodTPV
+		if err := rows.Scan(&w.PaymentMethodCode, &w.PaymentMethodType, &w.Week, &w.TpvUSD); err != nil {
+			return nil, fmt.Errorf("scan weekly tpv: %w", err)
+		}
+		results = append(results, w)
+	}
+	return results, rows.Err()
+}
+
+// CustomerOverlap counts a launched method's identified customers and how
+// many of them had paid with other methods in the country before launch.
+type CustomerOverlap struct {
+	Customers   int
+	Overlapping int
+}
+
+func (r *InsightRepository) GetCustomerOverlap(ctx context.Context, paymentMethod, country string, preFrom, launch, postTo time.Time) (CustomerOverlap, error) {
+	var o CustomerOverlap
+	err := r.pool.QueryRow(ctx, `
+		WITH adopters AS (
+			SELECT DISTINCT customer_id FROM transactions
+			WHERE payment_method_code = $1 AND country_code = $2
+				AND customer_id IS NOT NULL AND customer_id <> ''
+				AND transaction_date >= $4 AND transaction_date < $5
+		)
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE EXISTS (
+				SELECT 1 FROM transactions t
+				WHERE t.customer_id = a.customer_id AND t.country_code = $2
+					AND t.payment_method_code <> $1
+					AND t.transaction_date >= $3 AND t.transaction_date < $4
+			))
+		FROM adopters a
+	`, paymentMethod, country, preFrom, launch, postTo).Scan(&o.Customers, &o.Overlapping)
+	if err != nil {
+		return o, fmt.Errorf("query customer overlap: %w", err)
+	}
+	return o, nil
+}
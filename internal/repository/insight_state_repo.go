@@ -0,0 +1,402 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+// InsightStateRepository persists insight lifecycles.
+type InsightStateRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewInsightStateRepository(pool *pgxpool.Pool) *InsightStateRepository {
+	return &InsightStateRepository{pool: pool}
+}
+
+// InsightScope selects tracked insights. Empty lists match everything;
+// PaymentMethodTypes matches through the insight's payment method.
+type InsightScope struct {
+	States             []string
+	Types              []string
+	Countries          []string
+	PaymentMethods     []string
+	PaymentMethodTypes []string
+}
+
+func (s InsightScope) where(b *queryBuilder) string {
+	typeWhere := "TRUE"
+	if len(s.PaymentMethodTypes) > 0 {
+		typeWhere = fmt.Sprintf(`EXISTS (SELECT 1 FROM payment_methods pm
+			WHERE pm.code = i.payment_method_code AND %s)`, b.anyOf("pm.type", s.PaymentMethodTypes))
+	}
+	return and(
+		b.anyOf("i.state", s.States),
+		b.anyOf("i.type", s.Types),
+		b.anyOf("i.country_code", s.Countries),
+		b.anyOf("i.payment_method_code", s.PaymentMethods),
+		typeWhere,
+	)
+}
+
+const trackedInsightColumns = `i.insight_id, i.type, i.payment_method_code, i.country_code, i.severity, i.state,
+	i.snoozed_until, i.assignee, i.first_seen, i.last_seen, i.resolved_at, i.snapshot, i.updated_at`
+
+func scanTrackedInsight(row pgx.Row) (*model.TrackedInsight, error) {
+	t := &model.TrackedInsight{}
+	err := row.Scan(&t.InsightID, &t.Type, &t.PaymentMethodCode, &t.CountryCode, &t.Severity, &t.State,
+		&t.SnoozedUntil, &t.Assignee, &t.FirstSeen, &t.LastSeen, &t.ResolvedAt, &t.Snapshot, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *InsightStateRepository) queryTracked(ctx context.Context, query string, args ...any) ([]model.TrackedInsight, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query insights: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.TrackedInsight
+	for rows.Next() {
+		t, err := scanTrackedInsight(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan insight: %w", err)
+		}
+		results = append(results, *t)
+	}
+	return results, rows.Err()
+}
+
+func (r *InsightStateRepository) GetByIDs(ctx context.Context, ids []string) (map[string]model.TrackedInsight, error) {
+	found, err := r.queryTracked(ctx,
+		`SELECT `+trackedInsightColumns+` FROM insights i WHERE i.insight_id = ANY($1::text[])`, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]model.TrackedInsight, len(found))
+	for _, t := range found {
+		byID[t.InsightID] = t
+	}
+	return byID, nil
+}
+
+// Get returns pgx.ErrNoRows when the insight has never been detected.
+func (r *InsightStateRepository) Get(ctx context.Context, id string) (*model.TrackedInsight, error) {
+	return scanTrackedInsight(r.pool.QueryRow(ctx,
+		`SELECT `+trackedInsightColumns+` FROM insights i WHERE i.insight_id = $1`, id))
+}
+
+// List returns tracked insights in scope, most recently seen first.
+func (r *InsightStateRepository) List(ctx context.Context, scope InsightScope) ([]model.TrackedInsight, error) {
+	var b queryBuilder
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM insights i
+		WHERE %s
+		ORDER BY i.last_seen DESC, i.insight_id
+	`, trackedInsightColumns, scope.where(&b))
+	return r.queryTracked(ctx, query, b.args...)
+}
+
+// SyncPlan derives the rows and transitions a detection run writes from the
+// current state of its insights, keyed by insight ID.
+type SyncPlan func(existing map[string]model.TrackedInsight) ([]model.TrackedInsight, []model.InsightTransition, error)
+
+// AutoResolved is an insight Sync auto-resolved, as stored after the change.
+type AutoResolved struct {
+	Insight    model.TrackedInsight
+	Transition model.InsightTransition
+}
+
+// Sync writes a detection run in one transaction. It locks ids, so
+// concurrent manual transitions and runs are applied in order even for
+// insights no run has stored yet, lets plan derive the writes from their
+// locked state and stores them. When
+// resolveScope is not nil it then auto-resolves every unresolved insight in
+// that scope that the run did not produce or hold back. publish runs last in
+// the same transaction, so outbox rows commit with the changes they
+// describe.
+func (r *InsightStateRepository) Sync(ctx context.Context, ids []string, plan SyncPlan, resolveScope *InsightScope, held []string, now time.Time, publish func(tx pgx.Tx, resolved []AutoResolved) error) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockInsightIDs(ctx, tx, ids); err != nil {
+		return err
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT `+trackedInsightColumns+`
+		FROM insights i
+		WHERE i.insight_id = ANY($1::text[])
+		ORDER BY i.insight_id
+		FOR UPDATE`, ids)
+	if err != nil {
+		return fmt.Errorf("lock insights: %w", err)
+	}
+	existing := make(map[string]model.TrackedInsight, len(ids))
+	for rows.Next() {
+		t, err := scanTrackedInsight(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("scan insight: %w", err)
+		}
+		existing[t.InsightID] = *t
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("lock insights: %w", err)
+	}
+
+	detected, transitions, err := plan(existing)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range detected {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO insights (insight_id, type, payment_method_code, country_code, severity, state,
+				snoozed_until, first_seen, last_seen, resolved_at, snapshot, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			ON CONFLICT (insight_id) DO UPDATE SET
+				severity = EXCLUDED.severity,
+				state = EXCLUDED.state,
+				snoozed_until = EXCLUDED.snoozed_until,
+				first_seen = EXCLUDED.first_seen,
+				last_seen = EXCLUDED.last_seen,
+				resolved_at = EXCLUDED.resolved_at,
+				snapshot = EXCLUDED.snapshot,
+				updated_at = EXCLUDED.updated_at
+		`, t.InsightID, t.Type, t.PaymentMethodCode, t.CountryCode, t.Severity, t.State,
+			t.SnoozedUntil, t.FirstSeen, t.LastSeen, t.ResolvedAt, t.Snapshot, now)
+		if err != nil {
+			return fmt.Errorf("upsert insight %s: %w", t.InsightID, err)
+		}
+	}
+
+	for _, tr := range transitions {
+		if err := insertTransition(ctx, tx, tr); err != nil {
+			return err
+		}
+	}
+
+	var resolved []AutoResolved
+	if resolveScope != nil {
+		keep := append(append([]string{}, ids...), held...)
+		if resolved, err = autoResolve(ctx, tx, *resolveScope, keep, now); err != nil {
+			return err
+		}
+	}
+	for _, a := range resolved {
+		if err := insertTransition(ctx, tx, a.Transition); err != nil {
+			return err
+		}
+	}
+
+	if err := publish(tx, resolved); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit insight sync: %w", err)
+	}
+	return nil
+}
+
+// autoResolve resolves the unresolved insights in scope other than keep.
+func autoResolve(ctx context.Context, tx pgx.Tx, scope InsightScope, keep []string, now time.Time) ([]AutoResolved, error) {
+	var b queryBuilder
+	nowArg := b.bind(now)
+	query := fmt.Sprintf(`
+		WITH target AS (
+			SELECT i.insight_id, i.state
+			FROM insights i
+			WHERE i.state IN ('OPEN', 'ACKNOWLEDGED', 'SNOOZED')
+				AND NOT (i.insight_id = ANY(%s::text[]))
+				AND %s
+			FOR UPDATE OF i
+		)
+		UPDATE insights i SET
+			state = 'AUTO_RESOLVED',
+			snoozed_until = NULL,
+			resolved_at = %s,
+			updated_at = %s
+		FROM target t
+		WHERE i.insight_id = t.insight_id
+		RETURNING t.state, %s
+	`, b.bind(keep), scope.where(&b), nowArg, nowArg, trackedInsightColumns)
+	rows, err := tx.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("auto-resolve insights: %w", err)
+	}
+	defer rows.Close()
+
+	var resolved []AutoResolved
+	for rows.Next() {
+		var a AutoResolved
+		t := &a.Insight
+		if err := rows.Scan(&a.Transition.FromState, &t.InsightID, &t.Type, &t.PaymentMethodCode, &t.CountryCode,
+			&t.Severity, &t.State, &t.SnoozedUntil, &t.Assignee, &t.FirstSeen, &t.LastSeen, &t.ResolvedAt,
+			&t.Snapshot, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan auto-resolved insight: %w", err)
+		}
+		a.Transition.InsightID = t.InsightID
+		a.Transition.ToState = model.InsightStateAutoResolved
+		a.Transition.Actor = "system"
+		a.Transition.Note = "detector stopped firing"
+		a.Transition.CreatedAt = now
+		resolved = append(resolved, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("auto-resolve insights: %w", err)
+	}
+	return resolved, nil
+}
+
+// SetState moves an insight from one state to another, records the
+// transition and calls publish with the updated insight in the same
+// transaction. It returns pgx.ErrNoRows when the insight is not in state
+// from (or does not exist).
+func (r *InsightStateRepository) SetState(ctx context.Context, tr model.InsightTransition, snoozedUntil *time.Time, publish func(tx pgx.Tx, t *model.TrackedInsight) error) (*model.TrackedInsight, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := scanTrackedInsight(tx.QueryRow(ctx, `
+		UPDATE insights i SET
+			state = $3,
+			snoozed_until = $4,
+			resolved_at = CASE WHEN $3 IN ('RESOLVED', 'AUTO_RESOLVED') THEN $5 ELSE NULL END,
+			updated_at = $5
+		WHERE i.insight_id = $1 AND i.state = $2
+		RETURNING `+trackedInsightColumns,
+		tr.InsightID, tr.FromState, tr.ToState, snoozedUntil, tr.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+	if err := insertTransition(ctx, tx, tr); err != nil {
+		return nil, err
+	}
+	if err := publish(tx, t); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit insight state: %w", err)
+	}
+	return t, nil
+}
+
+func (r *InsightStateRepository) SetAssignee(ctx context.Context, id, assignee string) (*model.TrackedInsight, error) {
+	return scanTrackedInsight(r.pool.QueryRow(ctx, `
+		UPDATE insights i SET assignee = $2, updated_at = NOW()
+		WHERE i.insight_id = $1
+		RETURNING `+trackedInsightColumns, id, assignee))
+}
+
+// AddNote returns pgx.ErrNoRows when the insight does not exist.
+func (r *InsightStateRepository) AddNote(ctx context.Context, id string, note model.InsightNote) (*model.InsightNote, error) {
+	saved := &model.InsightNote{}
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO insight_notes (insight_id, author, note)
+		SELECT insight_id, $2, $3 FROM insights WHERE insight_id = $1
+		RETURNING author, note, created_at
+	`, id, note.Author, note.Note).Scan(&saved.Author, &saved.Note, &saved.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (r *InsightStateRepository) ListTransitions(ctx context.Context, id string) ([]model.InsightTransition, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT insight_id, from_state, to_state, actor, note, created_at
+		FROM insight_transitions
+		WHERE insight_id = $1
+		ORDER BY created_at, id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query insight transitions: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.InsightTransition
+	for rows.Next() {
+		var tr model.InsightTransition
+		if err := rows.Scan(&tr.InsightID, &tr.FromState, &tr.ToState, &tr.Actor, &tr.Note, &tr.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan insight transition: %w", err)
+		}
+		results = append(results, tr)
+	}
+	return results, rows.Err()
+}
+
+func (r *InsightStateRepository) ListNotes(ctx context.Context, id string) ([]model.InsightNote, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT author, note, created_at
+		FROM insight_notes
+		WHERE insight_id = $1
+		ORDER BY created_at, id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query insight notes: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.InsightNote
+	for rows.Next() {
+		var n model.InsightNote
+		if err := rows.Scan(&n.Author, &n.Note, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan insight note: %w", err)
+		}
+		results = append(results, n)
+	}
+	return results, rows.Err()
+}
+
+// lockInsightIDs takes a transaction-scoped advisory lock per insight ID.
+// Row locks only cover insights already stored, so without it two runs
+// detecting the same new insight would both record it as first detected.
+// The locks are taken in ID order so overlapping runs cannot deadlock.
+func lockInsightIDs(ctx context.Context, tx pgx.Tx, ids []string) error {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	batch := &pgx.Batch{}
+	for i, id := range sorted {
+		if i > 0 && id == sorted[i-1] {
+			continue
+		}
+		batch.Queue(`SELECT pg_advisory_xact_lock(hashtextextended('insight:' || $1::text, 0))`, id)
+	}
+	results := tx.SendBatch(ctx, batch)
+	defer results.Close()
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := results.Exec(); err != nil {
+			return fmt.Errorf("lock insight ids: %w", err)
+		}
+	}
+	return nil
+}
+
+func insertTransition(ctx context.Context, tx pgx.Tx, tr model.InsightTransition) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO insight_transitions (insight_id, from_state, to_state, actor, note, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, tr.InsightID, tr.FromState, tr.ToState, tr.Actor, tr.Note, tr.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert insight transition: %w", err)
+	}
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 )
 
 type MarketGapRepository struct {
@@ -29,8 +31,18 @@ type CountryCoverage struct {
 	ActiveMethods int
 }
 
-func (r *MarketGapRepository) GetGaps(ctx context.Context, country string, onlyEssential bool) ([]MarketGap, error) {
-	query := `
+func (r *MarketGapRepository) GetGaps(ctx context.Context, f model.AnalyticsFilter, onlyEssential bool) ([]MarketGap, error) {
+	var b queryBuilder
+	where := and(
+		b.anyOf("cpc.country_code", f.Countries),
+		b.anyOf("cpc.payment_method_code", f.PaymentMethods),
+		b.catalogType(f.Types),
+	)
+	if onlyEssential {
+		where = and(where, "cpc.is_essential = true")
+	}
+
+	query := fmt.Sprintf(`
 		SELECT cpc.country_code, cpc.payment_method_code,
 			COALESCE(cpc.market_share_pct, 0), cpc.is_essential, COALESCE(cpc.source, '')
 		FROM country_payment_catalog cpc
@@ -40,11 +52,10 @@ func (r *MarketGapRepository) GetGaps(ctx context.Context, country string, onlyE
 				AND t.country_code = cpc.country_code
 				AND t.transaction_date >= NOW() - INTERVAL '90 days'
 		)
-		AND ($1 = '' OR cpc.country_code = $1)
-		AND ($2 = false OR cpc.is_essential = true)
+		AND %s
 		ORDER BY cpc.country_code, COALESCE(cpc.market_share_pct, 0) DESC
-	`
-	rows, err := r.pool.Query(ctx, query, country, onlyEssential)
+	`, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
 	if err != nil {
 		return nil, fmt.Errorf("query gaps: %w", err)
 	}
@@ -61,8 +72,14 @@ func (r *MarketGapRepository) GetGaps(ctx context.Context, country string, onlyE
 	return results, nil
 }
 
-func (r *MarketGapRepository) GetCoverage(ctx context.Context, country string) ([]CountryCoverage, error) {
-	query := `
+func (r *MarketGapRepository) GetCoverage(ctx context.Context, f model.AnalyticsFilter) ([]CountryCoverage, error) {
+	var b queryBuilder
+	where := and(
+		b.anyOf("cpc.country_code", f.Countries),
+		b.catalogType(f.Types),
+	)
+
+	query := fmt.Sprintf(`
 		SELECT cpc.country_code,
 			COUNT(DISTINCT cpc.payment_method_code) as total_catalog,
 			COUNT(DISTINCT t.payment_method_code) as active_methods
@@ -70,10 +87,10 @@ func (r *MarketGapRepository) GetCoverage(ctx context.Context, country string) (
 		LEFT JOIN transactions t ON t.payment_method_code = cpc.payment_method_code
 			AND t.country_code = cpc.country_code
 			AND t.transaction_date >= NOW() - INTERVAL '90 days'
-		WHERE ($1 = '' OR cpc.country_code = $1)
+		WHERE %s
 		GROUP BY cpc.country_code
-	`
-	rows, err := r.pool.Query(ctx, query, country)
+	`, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
 	if err != nil {
 		return nil, fmt.Errorf("query coverage: %w", err)
 	}
@@ -89,3 +106,14 @@ func (r *MarketGapRepository) GetCoverage(ctx context.Context, country string) (
 	}
 	return results, nil
 }
+
+// catalogType restricts catalog rows to payment methods of the given types.
+// The catalog references methods by code only, so the type is looked up.
+func (b *queryBuilder) catalogType(types []string) string {
+	if len(types) == 0 {
+		return "TRUE"
+	}
+	return fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM payment_methods pm
+			WHERE pm.code = cpc.payment_method_code AND %s)`, b.anyOf("pm.type", types))
+}
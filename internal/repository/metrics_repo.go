@@ -3,8 +3,13 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/filterexpr"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 )
 
 type MetricRow struct {
@@ -15,6 +20,7 @@ type MetricRow struct {
 	TransactionCount     int
 	ApprovedCount        int
 	DeclinedCount        int
+	RefundedCount        int
 	TpvUSD               float64
 	ApprovalRate         float64
 	AvgTransactionValue  float64
@@ -22,6 +28,11 @@ type MetricRow struct {
 	MonthlyCostUSD       float64
 	CostEfficiencyRatio  float64
 	ActivityStatus       string
+
+	// Same aggregates over the original amount column; only meaningful when
+	// every row shares a currency (single-country queries).
+	TpvLocal                 float64
+	AvgTransactionValueLocal float64
 }
 
 type MetricsRepository struct {
@@ -32,90 +43,171 @@ func NewMetricsRepository(pool *pgxpool.Pool) *MetricsRepository {
 	return &MetricsRepository{pool: pool}
 }
 
-func (r *MetricsRepository) GetMetrics(ctx context.Context, country, pmType, dateFrom, dateTo, sortBy, order string, limit, offset int) ([]MetricRow, int, error) {
-	baseQuery := `
-		WITH txn_agg AS (
-			SELECT
-				t.payment_method_code,
-				t.country_code,
-				COUNT(*) AS transaction_count,
-				COUNT(*) FILTER (WHERE t.status = 'APPROVED') AS approved_count,
-				COUNT(*) FILTER (WHERE t.status = 'DECLINED') AS declined_count,
-				COALESCE(SUM(t.amount_usd) FILTER (WHERE t.status = 'APPROVED'), 0) AS tpv_usd,
-				CASE WHEN COUNT(*) > 0
-					THEN ROUND(COUNT(*) FILTER (WHERE t.status = 'APPROVED')::numeric / COUNT(*)::numeric * 100, 2)
-					ELSE 0
-				END AS approval_rate,
-				CASE WHEN COUNT(*) > 0
-					THEN ROUND(AVG(t.amount_usd)::numeric, 2)
-					ELSE 0
-				END AS avg_transaction_value
-			FROM transactions t
-			WHERE ($1 = '' OR t.country_code = $1)
-				AND ($3 = '' OR t.transaction_date >= $3::timestamptz)
-				AND ($4 = '' OR t.transaction_date <= $4::timestamptz)
-			GROUP BY t.payment_method_code, t.country_code
-		),
-		total_tpv AS (
-			SELECT COALESCE(SUM(tpv_usd), 0) AS total FROM txn_agg
-		),
-		txn_90d AS (
-			SELECT
-				t.payment_method_code,
-				t.country_code,
-				COUNT(*) AS txn_count_90d
-			FROM transactions t
-			WHERE t.transaction_date >= NOW() - INTERVAL '90 days'
-				AND ($1 = '' OR t.country_code = $1)
-			GROUP BY t.payment_method_code, t.country_code
-		)
+// metricFilterFields are the columns a metrics filter expression can use,
+// named after the MetricResult JSON fields.
+var metricFilterFields = map[string]filterexpr.Field{
+	"payment_method_code":       {Column: "payment_method_code", Kind: filterexpr.String},
+	"payment_method_type":       {Column: "payment_method_type", Kind: filterexpr.String},
+	"country_code":              {Column: "country_code", Kind: filterexpr.String},
+	"activity_status":           {Column: "activity_status", Kind: filterexpr.String},
+	"transaction_count":         {Column: "transaction_count", Kind: filterexpr.Number},
+	"approved_count":            {Column: "approved_count", Kind: filterexpr.Number},
+	"declined_count":            {Column: "declined_count", Kind: filterexpr.Number},
+	"refunded_count":            {Column: "refunded_count", Kind: filterexpr.Number},
+	"tpv_usd":                   {Column: "tpv_usd", Kind: filterexpr.Number},
+	"approval_rate":             {Column: "approval_rate", Kind: filterexpr.Number},
+	"avg_transaction_value_usd": {Column: "avg_transaction_value", Kind: filterexpr.Number},
+	"revenue_contribution_pct":  {Column: "revenue_contribution_pct", Kind: filterexpr.Number},
+	"monthly_cost_usd":          {Column: "monthly_cost_usd", Kind: filterexpr.Number},
+	"cost_efficiency_ratio":     {Column: "cost_efficiency_ratio", Kind: filterexpr.Number},
+}
+
+// metricsQuery builds the per (payment_method, country) metrics query. Country
+// and payment method filters narrow the transactions (and so the revenue
+// contribution denominator); type and the filter expression only drop rows.
+// activity_status counts the 90 days up to DateTo, or up to now without one,
+// so a past window is classified as it stood then.
+func metricsQuery(b *queryBuilder, f model.AnalyticsFilter) (string, error) {
+	asOf := "NOW()"
+	if f.DateTo != "" {
+		asOf = b.bind(f.DateTo) + "::timestamptz"
+	}
+	return buildMetricsQuery(b, f,
+		b.dateRange("t.transaction_date", f.DateFrom, f.DateTo),
+		fmt.Sprintf("t.transaction_date >= %[1]s - INTERVAL '90 days' AND t.transaction_date <= %[1]s", asOf))
+}
+
+// windowMetricsQuery is metricsQuery over the half-open window [from, to)
+// instead of f's dates. activity_status counts the 90 days before to.
+func windowMetricsQuery(b *queryBuilder, f model.AnalyticsFilter, from, to time.Time) (string, error) {
+	start, end := b.bind(from)+"::timestamptz", b.bind(to)+"::timestamptz"
+	return buildMetricsQuery(b, f,
+		fmt.Sprintf("t.transaction_date >= %s AND t.transaction_date < %s", start, end),
+		fmt.Sprintf("t.transaction_date >= %[1]s - INTERVAL '90 days' AND t.transaction_date < %[1]s", end))
+}
+
+// buildMetricsQuery assembles the metrics query from the conditions picking
+// the period's transactions and the ones behind activity_status.
+func buildMetricsQuery(b *queryBuilder, f model.AnalyticsFilter, period, activityPeriod string) (string, error) {
+	txnWhere := and(
+		b.anyOf("t.country_code", f.Countries),
+		b.anyOf("t.payment_method_code", f.PaymentMethods),
+		period,
+	)
+	activityWhere := and(
+		activityPeriod,
+		b.anyOf("t.country_code", f.Countries),
+		b.anyOf("t.payment_method_code", f.PaymentMethods),
+	)
+	typeWhere := b.anyOf("pm.type", f.Types)
+	exprWhere, err := b.expr(f.Expr, metricFilterFields)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+	SELECT * FROM (
+	WITH txn_agg AS (
 		SELECT
-			a.payment_method_code,
-			pm.name AS payment_method_name,
-			pm.type AS payment_method_type,
-			a.country_code,
-			a.transaction_count,
-			a.approved_count,
-			a.declined_count,
-			a.tpv_usd,
-			a.approval_rate,
-			a.avg_transaction_value,
-			CASE WHEN tt.total > 0
-				THEN ROUND(a.tpv_usd / tt.total * 100, 2)
+			t.payment_method_code,
+			t.country_code,
+			COUNT(*) AS transaction_count,
+			COUNT(*) FILTER (WHERE t.status = 'APPROVED') AS approved_count,
+			COUNT(*) FILTER (WHERE t.status = 'DECLINED') AS declined_count,
+			COUNT(*) FILTER (WHERE t.status = 'REFUNDED') AS refunded_count,
+			COALESCE(SUM(t.amount_usd) FILTER (WHERE t.status = 'APPROVED'), 0) AS tpv_usd,
+			CASE WHEN COUNT(*) > 0
+				THEN ROUND(COUNT(*) FILTER (WHERE t.status = 'APPROVED')::numeric / COUNT(*)::numeric * 100, 2)
+				ELSE 0
+			END AS approval_rate,
+			CASE WHEN COUNT(*) > 0
+				THEN ROUND(AVG(t.amount_usd)::numeric, 2)
 				ELSE 0
-			END AS revenue_contribution_pct,
-			COALESCE(ic.monthly_fixed_cost_usd, 0) AS monthly_cost_usd,
-			CASE WHEN a.tpv_usd > 0
-				THEN ROUND(COALESCE(ic.monthly_fixed_cost_usd, 0)::numeric / a.tpv_usd::numeric * 100, 2)
+			END AS avg_transaction_value,
+			COALESCE(SUM(t.amount) FILTER (WHERE t.status = 'APPROVED'), 0) AS tpv_local,
+			CASE WHEN COUNT(*) > 0
+				THEN ROUND(AVG(t.amount)::numeric, 2)
 				ELSE 0
-			END AS cost_efficiency_ratio,
-			CASE
-				WHEN COALESCE(t90.txn_count_90d, 0) >= 10 THEN 'ACTIVE'
-				WHEN COALESCE(t90.txn_count_90d, 0) >= 1 THEN 'LOW_ACTIVITY'
-				ELSE 'INACTIVE'
-			END AS activity_status
-		FROM txn_agg a
-		JOIN payment_methods pm ON pm.code = a.payment_method_code
-		CROSS JOIN total_tpv tt
-		LEFT JOIN integration_costs ic ON ic.payment_method_code = a.payment_method_code
-			AND ic.country_code = a.country_code
-			AND ic.effective_to IS NULL
-		LEFT JOIN txn_90d t90 ON t90.payment_method_code = a.payment_method_code
-			AND t90.country_code = a.country_code
-		WHERE ($2 = '' OR pm.type = $2)
-	`
+			END AS avg_transaction_value_local
+		FROM transactions t
+		WHERE %s
+		GROUP BY t.payment_method_code, t.country_code
+	),
+	total_tpv AS (
+		SELECT COALESCE(SUM(tpv_usd), 0) AS total FROM txn_agg
+	),
+	txn_90d AS (
+		SELECT
+			t.payment_method_code,
+			t.country_code,
+			COUNT(*) AS txn_count_90d
+		FROM transactions t
+		WHERE %s
+		GROUP BY t.payment_method_code, t.country_code
+	)
+	SELECT
+		a.payment_method_code,
+		pm.name AS payment_method_name,
+		pm.type AS payment_method_type,
+		a.country_code,
+		a.transaction_count,
+		a.approved_count,
+		a.declined_count,
+		a.refunded_count,
+		a.tpv_usd,
+		a.approval_rate,
+		a.avg_transaction_value,
+		CASE WHEN tt.total > 0
+			THEN ROUND(a.tpv_usd / tt.total * 100, 2)
+			ELSE 0
+		END AS revenue_contribution_pct,
+		COALESCE(ic.monthly_fixed_cost_usd, 0) AS monthly_cost_usd,
+		CASE WHEN a.tpv_usd > 0
+			THEN ROUND(COALESCE(ic.monthly_fixed_cost_usd, 0)::numeric / a.tpv_usd::numeric * 100, 2)
+			ELSE 0
+		END AS cost_efficiency_ratio,
+		CASE
+			WHEN COALESCE(t90.txn_count_90d, 0) >= %s THEN 'ACTIVE'
+			WHEN COALESCE(t90.txn_count_90d, 0) >= %s THEN 'LOW_ACTIVITY'
+			ELSE 'INACTIVE'
+		END AS activity_status,
+		a.tpv_local,
+		a.avg_transaction_value_local
+	FROM txn_agg a
+	JOIN payment_methods pm ON pm.code = a.payment_method_code
+	CROSS JOIN total_tpv tt
+	LEFT JOIN integration_costs ic ON ic.payment_method_code = a.payment_method_code
+		AND ic.country_code = a.country_code
+		AND ic.effective_to IS NULL
+	LEFT JOIN txn_90d t90 ON t90.payment_method_code = a.payment_method_code
+		AND t90.country_code = a.country_code
+	WHERE %s
+	) m
+	WHERE %s
+`, txnWhere, activityWhere,
+		activityParam("active_min_txns", DefaultActiveMinTxns, "a.country_code", "pm.type", "a.payment_method_code"),
+		activityParam("low_activity_min_txns", DefaultLowActivityMinTxns, "a.country_code", "pm.type", "a.payment_method_code"),
+		typeWhere, exprWhere), nil
+}
+
+func (r *MetricsRepository) GetMetrics(ctx context.Context, f model.AnalyticsFilter, sortBy, order string, limit, offset int) ([]MetricRow, int, error) {
+	var b queryBuilder
+	baseQuery, err := metricsQuery(&b, f)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	validSorts := map[string]string{
-		"transaction_count":    "a.transaction_count",
-		"tpv_usd":             "a.tpv_usd",
-		"approval_rate":       "a.approval_rate",
+		"transaction_count":    "transaction_count",
+		"tpv_usd":              "tpv_usd",
+		"approval_rate":        "approval_rate",
 		"revenue_contribution": "revenue_contribution_pct",
-		"payment_method_code": "a.payment_method_code",
+		"payment_method_code":  "payment_method_code",
 	}
 
 	sortCol, ok := validSorts[sortBy]
 	if !ok {
-		sortCol = "a.tpv_usd"
+		sortCol = "tpv_usd"
 	}
 
 	orderDir := "DESC"
@@ -126,35 +218,81 @@ func (r *MetricsRepository) GetMetrics(ctx context.Context, country, pmType, dat
 	// Count query
 	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM (%s) sub`, baseQuery)
 	var totalItems int
-	err := r.pool.QueryRow(ctx, countQuery, country, pmType, dateFrom, dateTo).Scan(&totalItems)
+	err = r.pool.QueryRow(ctx, countQuery, b.args...).Scan(&totalItems)
 	if err != nil {
 		return nil, 0, fmt.Errorf("count metrics: %w", err)
 	}
 
 	// Data query
-	dataQuery := fmt.Sprintf(`%s ORDER BY %s %s LIMIT $5 OFFSET $6`, baseQuery, sortCol, orderDir)
+	dataQuery := fmt.Sprintf(`%s ORDER BY %s %s LIMIT %s OFFSET %s`, baseQuery, sortCol, orderDir, b.bind(limit), b.bind(offset))
 
-	rows, err := r.pool.Query(ctx, dataQuery, country, pmType, dateFrom, dateTo, limit, offset)
+	rows, err := r.pool.Query(ctx, dataQuery, b.args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("query metrics: %w", err)
 	}
 	defer rows.Close()
 
+	results, err := scanMetricRows(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return results, totalItems, nil
+}
+
+// ListMetrics returns every (payment_method, country) row for the window,
+// unpaginated, for callers that post-process the full set.
+func (r *MetricsRepository) ListMetrics(ctx context.Context, f model.AnalyticsFilter) ([]MetricRow, error) {
+	var b queryBuilder
+	baseQuery, err := metricsQuery(&b, f)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`%s ORDER BY payment_method_code, country_code`, baseQuery)
+
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query metrics: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMetricRows(rows)
+}
+
+// ListMetricsInWindow returns every metrics row of the half-open window
+// [from, to), ignoring the filter's dates.
+func (r *MetricsRepository) ListMetricsInWindow(ctx context.Context, f model.AnalyticsFilter, from, to time.Time) ([]MetricRow, error) {
+	var b queryBuilder
+	baseQuery, err := windowMetricsQuery(&b, f, from, to)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`%s ORDER BY payment_method_code, country_code`, baseQuery)
+
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query metrics: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMetricRows(rows)
+}
+
+func scanMetricRows(rows pgx.Rows) ([]MetricRow, error) {
 	var results []MetricRow
 	for rows.Next() {
 		var m MetricRow
 		err := rows.Scan(
 			&m.PaymentMethodCode, &m.PaymentMethodName, &m.PaymentMethodType,
 			&m.CountryCode, &m.TransactionCount, &m.ApprovedCount, &m.DeclinedCount,
-			&m.TpvUSD, &m.ApprovalRate, &m.AvgTransactionValue,
+			&m.RefundedCount, &m.TpvUSD, &m.ApprovalRate, &m.AvgTransactionValue,
 			&m.RevenueContribution, &m.MonthlyCostUSD, &m.CostEfficiencyRatio,
-			&m.ActivityStatus,
+			&m.ActivityStatus, &m.TpvLocal, &m.AvgTransactionValueLocal,
 		)
 		if err != nil {
-			return nil, 0, fmt.Errorf("scan metric row: %w", err)
+			return nil, fmt.Errorf("scan metric row: %w", err)
 		}
 		results = append(results, m)
 	}
-
-	return results, totalItems, nil
+	return results, rows.Err()
 }
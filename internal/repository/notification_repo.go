@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+type NotificationRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewNotificationRepository(pool *pgxpool.Pool) *NotificationRepository {
+	return &NotificationRepository{pool: pool}
+}
+
+const notificationChannelColumns = `id::text, name, kind, recipients, webhook_url, subject_template,
+	body_template, rate_limit_per_hour, active, created_at`
+
+func scanNotificationChannel(row pgx.Row) (*model.NotificationChannel, error) {
+	ch := &model.NotificationChannel{}
+	err := row.Scan(&ch.ID, &ch.Name, &ch.Kind, &ch.Recipients, &ch.WebhookURL, &ch.SubjectTemplate,
+		&ch.BodyTemplate, &ch.RateLimitPerHour, &ch.Active, &ch.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (r *NotificationRepository) CreateChannel(ctx context.Context, ch model.NotificationChannel) (*model.NotificationChannel, error) {
+	saved, err := scanNotificationChannel(r.pool.QueryRow(ctx, `
+		INSERT INTO notification_channels (name, kind, recipients, webhook_url, subject_template, body_template,
+			rate_limit_per_hour, active)
+		VALUES ($1, $2, $3::text[], $4, $5, $6, $7, $8)
+		RETURNING `+notificationChannelColumns,
+		ch.Name, ch.Kind, nonNil(ch.Recipients), ch.WebhookURL, ch.SubjectTemplate, ch.BodyTemplate,
+		ch.RateLimitPerHour, ch.Active))
+	if err != nil {
+		return nil, fmt.Errorf("insert notification channel: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *NotificationRepository) ListChannels(ctx context.Context) ([]model.NotificationChannel, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+notificationChannelColumns+` FROM notification_channels ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("query notification channels: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.NotificationChannel
+	for rows.Next() {
+		ch, err := scanNotificationChannel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan notification channel: %w", err)
+		}
+		results = append(results, *ch)
+	}
+	return results, rows.Err()
+}
+
+func (r *NotificationRepository) ChannelExists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM notification_channels WHERE id::text = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+// DeleteChannel also removes the channel's routes and queued items. It
+// returns pgx.ErrNoRows when nothing matched.
+func (r *NotificationRepository) DeleteChannel(ctx context.Context, id string) error {
+	return r.deleteByID(ctx, "notification_channels", id)
+}
+
+const notificationRouteColumns = `id::text, name, priority, event_types, insight_types, severities, countries,
+	channel_id::text, delivery, stop_processing, active, created_at`
+
+func scanNotificationRoute(row pgx.Row) (*model.NotificationRoute, error) {
+	rt := &model.NotificationRoute{}
+	err := row.Scan(&rt.ID, &rt.Name, &rt.Priority, &rt.EventTypes, &rt.InsightTypes, &rt.Severities,
+		&rt.Countries, &rt.ChannelID, &rt.Delivery, &rt.StopProcessing, &rt.Active, &rt.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+func (r *NotificationRepository) CreateRoute(ctx context.Context, rt model.NotificationRoute) (*model.NotificationRoute, error) {
+	saved, err := scanNotificationRoute(r.pool.QueryRow(ctx, `
+		INSERT INTO notification_routes (name, priority, event_types, insight_types, severities, countries,
+			channel_id, delivery, stop_processing, active)
+		VALUES ($1, $2, $3::text[], $4::text[], $5::text[], $6::text[], $7::uuid, $8, $9, $10)
+		RETURNING `+notificationRouteColumns,
+		rt.Name, rt.Priority, nonNil(rt.EventTypes), nonNil(rt.InsightTypes), nonNil(rt.Severities),
+		nonNil(rt.Countries), rt.ChannelID, rt.Delivery, rt.StopProcessing, rt.Active))
+	if err != nil {
+		return nil, fmt.Errorf("insert notification route: %w", err)
+	}
+	return saved, nil
+}
+
+// ListRoutes returns routes in evaluation order.
+func (r *NotificationRepository) ListRoutes(ctx context.Context) ([]model.NotificationRoute, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+notificationRouteColumns+` FROM notification_routes ORDER BY priority, created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("query notification routes: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.NotificationRoute
+	for rows.Next() {
+		rt, err := scanNotificationRoute(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan notification route: %w", err)
+		}
+		results = append(results, *rt)
+	}
+	return results, rows.Err()
+}
+
+func (r *NotificationRepository) DeleteRoute(ctx context.Context, id string) error {
+	return r.deleteByID(ctx, "notification_routes", id)
+}
+
+func (r *NotificationRepository) deleteByID(ctx context.Context, table, id string) error {
+	tag, err := r.pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE id::text = $1`, table), id)
+	if err != nil {
+		return fmt.Errorf("delete from %s: %w", table, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// QueuedNotification is one routed event.
+type QueuedNotification struct {
+	ID        string
+	RouteID   string
+	ChannelID string
+	InsightID string
+	Event     []byte
+	Digest    bool
+	Attempts  int
+	DueAt     time.Time
+}
+
+// Enqueue writes queue items in tx, the transaction recording their events.
+func (r *NotificationRepository) Enqueue(ctx context.Context, tx pgx.Tx, items []QueuedNotification) error {
+	if len(items) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for _, it := range items {
+		batch.Queue(`
+			INSERT INTO notification_queue (route_id, channel_id, insight_id, event, digest, due_at)
+			VALUES ($1::uuid, $2::uuid, $3, $4::jsonb, $5, $6)
+		`, it.RouteID, it.ChannelID, it.InsightID, string(it.Event), it.Digest, it.DueAt)
+	}
+	results := tx.SendBatch(ctx, batch)
+	defer results.Close()
+	for range items {
+		if _, err := results.Exec(); err != nil {
+			return fmt.Errorf("enqueue notification: %w", err)
+		}
+	}
+	return nil
+}
+
+// ClaimDue leases up to limit pending items whose due time has passed, plus
+// every other due digest item of the digest routes among them, so a digest
+// is never split across claims.
+func (r *NotificationRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]QueuedNotification, error) {
+	rows, err := r.pool.Query(ctx, `
+		WITH due AS (
+			SELECT id, route_id, digest FROM notification_queue
+			WHERE status = 'PENDING' AND due_at <= NOW()
+			ORDER BY due_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		),
+		digest_rest AS (
+			SELECT id FROM notification_queue
+			WHERE status = 'PENDING' AND due_at <= NOW() AND digest
+				AND route_id IN (SELECT route_id FROM due WHERE digest)
+			FOR UPDATE SKIP LOCKED
+		),
+		claimed AS (
+			SELECT id FROM due
+			UNION
+			SELECT id FROM digest_rest
+		)
+		UPDATE notification_queue q SET due_at = NOW() + $2::interval
+		FROM claimed
+		WHERE q.id = claimed.id
+		RETURNING q.id::text, q.route_id::text, q.channel_id::text, q.insight_id, q.event, q.digest, q.attempts, q.due_at
+	`, limit, fmt.Sprintf("%d seconds", int(lease.Seconds())))
+	if err != nil {
+		return nil, fmt.Errorf("claim notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var results []QueuedNotification
+	for rows.Next() {
+		var q QueuedNotification
+		if err := rows.Scan(&q.ID, &q.RouteID, &q.ChannelID, &q.InsightID, &q.Event, &q.Digest, &q.Attempts, &q.DueAt); err != nil {
+			return nil, fmt.Errorf("scan notification: %w", err)
+		}
+		results = append(results, q)
+	}
+	return results, rows.Err()
+}
+
+// MarkSent closes queue items and logs the message they were sent in.
+func (r *NotificationRepository) MarkSent(ctx context.Context, ids []string, entry model.NotificationLogEntry) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE notification_queue SET status = 'SENT', sent_at = NOW(), attempts = attempts + 1, last_error = ''
+		WHERE id::text = ANY($1::text[])
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("mark notifications sent: %w", err)
+	}
+	if err := insertNotificationLog(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// MarkFailed records a failed attempt: items go back to PENDING at retryAt,
+// or to FAILED when retryAt is nil.
+func (r *NotificationRepository) MarkFailed(ctx context.Context, ids []string, entry model.NotificationLogEntry, retryAt *time.Time) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE notification_queue SET
+			status = CASE WHEN $2::timestamptz IS NULL THEN 'FAILED' ELSE 'PENDING' END,
+			due_at = COALESCE($2::timestamptz, due_at),
+			attempts = attempts + 1,
+			last_error = $3
+		WHERE id::text = ANY($1::text[])
+	`, ids, retryAt, entry.Error)
+	if err != nil {
+		return fmt.Errorf("mark notifications failed: %w", err)
+	}
+	if err := insertNotificationLog(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Defer pushes items back without counting an attempt, e.g. when their
+// channel is over its rate limit.
+func (r *NotificationRepository) Defer(ctx context.Context, ids []string, until time.Time) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE notification_queue SET due_at = $2 WHERE id::text = ANY($1::text[])`, ids, until)
+	if err != nil {
+		return fmt.Errorf("defer notifications: %w", err)
+	}
+	return nil
+}
+
+// CountSent returns how many messages a channel sent since the given time.
+func (r *NotificationRepository) CountSent(ctx context.Context, channelID string, since time.Time) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM notification_log
+		WHERE channel_id::text = $1 AND status = 'SENT' AND created_at >= $2
+	`, channelID, since).Scan(&n)
+	return n, err
+}
+
+// ListLog returns the most recent sends, optionally for one channel.
+func (r *NotificationRepository) ListLog(ctx context.Context, channelID string, limit int) ([]model.NotificationLogEntry, error) {
+	var b queryBuilder
+	where := "TRUE"
+	if channelID != "" {
+		where = "channel_id::text = " + b.bind(channelID)
+	}
+	query := fmt.Sprintf(`
+		SELECT id::text, channel_id::text, route_id::text, item_count, subject, status, error, created_at
+		FROM notification_log
+		WHERE %s
+		ORDER BY created_at DESC, id
+		LIMIT %s
+	`, where, b.bind(limit))
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query notification log: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.NotificationLogEntry
+	for rows.Next() {
+		var e model.NotificationLogEntry
+		if err := rows.Scan(&e.ID, &e.ChannelID, &e.RouteID, &e.ItemCount, &e.Subject, &e.Status, &e.Error, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan notification log: %w", err)
+		}
+		results = append(results, e)
+	}
+	return results, rows.Err()
+}
+
+func insertNotificationLog(ctx context.Context, tx pgx.Tx, e model.NotificationLogEntry) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO notification_log (channel_id, route_id, item_count, subject, status, error)
+		VALUES ($1::uuid, $2::uuid, $3, $4, $5, $6)
+	`, e.ChannelID, e.RouteID, e.ItemCount, e.Subject, e.Status, e.Error)
+	if err != nil {
+		return fmt.Errorf("insert notification log: %w", err)
+	}
+	return nil
+}
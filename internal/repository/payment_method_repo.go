@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
@@ -58,3 +59,22 @@ func (r *PaymentMethodRepository) GetFxRate(ctx context.Context, countryCode str
 		Scan(&fxRate, &currency)
 	return fxRate, currency, err
 }
+
+// Providers maps every payment method code to its provider ("" when unset).
+func (r *PaymentMethodRepository) Providers(ctx context.Context) (map[string]string, error) {
+	rows, err := r.pool.Query(ctx, `SELECT code, COALESCE(provider, '') FROM payment_methods`)
+	if err != nil {
+		return nil, fmt.Errorf("query providers: %w", err)
+	}
+	defer rows.Close()
+
+	providers := make(map[string]string)
+	for rows.Next() {
+		var code, provider string
+		if err := rows.Scan(&code, &provider); err != nil {
+			return nil, fmt.Errorf("scan provider: %w", err)
+		}
+		providers[code] = provider
+	}
+	return providers, rows.Err()
+}
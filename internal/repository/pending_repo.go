@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+type PendingRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPendingRepository(pool *pgxpool.Pool) *PendingRepository {
+	return &PendingRepository{pool: pool}
+}
+
+// PendingAge is a method-country's PENDING transactions that have been
+// pending for AgeHours whole hours.
+type PendingAge struct {
+	PaymentMethodCode string
+	PaymentMethodName string
+	PaymentMethodType string
+	CountryCode       string
+	AgeHours          int
+	TransactionCount  int
+	AmountUSD         float64
+}
+
+// GetPendingAges counts PENDING transactions per method, country and whole
+// hours of age at asOf. Transactions dated after asOf are ignored.
+func (r *PendingRepository) GetPendingAges(ctx context.Context, f model.AnalyticsFilter, asOf time.Time) ([]PendingAge, error) {
+	var b queryBuilder
+	ref := b.bind(asOf)
+	where := and(
+		"t.status = 'PENDING'",
+		"t.transaction_date <= "+ref,
+		b.anyOf("t.country_code", f.Countries),
+		b.anyOf("t.payment_method_code", f.PaymentMethods),
+		b.anyOf("pm.type", f.Types),
+	)
+
+	query := fmt.Sprintf(`
+		SELECT t.payment_method_code, pm.name, pm.type, t.country_code,
+			FLOOR(EXTRACT(EPOCH FROM (%s::timestamptz - t.transaction_date)) / 3600)::int as age_hours,
+			COUNT(*) as txn_count,
+			SUM(t.amount_usd)::float as amount_usd
+		FROM transactions t
+		JOIN payment_methods pm ON pm.code = t.payment_method_code
+		WHERE %s
+		GROUP BY t.payment_method_code, pm.name, pm.type, t.country_code, age_hours
+		ORDER BY t.payment_method_code, t.country_code, age_hours
+	`, ref, where)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query pending ages: %w", err)
+	}
+	defer rows.Close()
+
+	var results []PendingAge
+	for rows.Next() {
+		var p PendingAge
+		if err := rows.Scan(&p.PaymentMethodCode, &p.PaymentMethodName, &p.PaymentMethodType, &p.CountryCode,
+			&p.AgeHours, &p.TransactionCount, &p.AmountUSD); err != nil {
+			return nil, fmt.Errorf("scan pending age: %w", err)
+		}
+		results = append(results, p)
+	}
+	return results, rows.Err()
+}
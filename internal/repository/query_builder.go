@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/filterexpr"
+)
+
+// queryBuilder collects bind args so SQL fragments can reference them without
+// hand-numbered $N placeholders.
+type queryBuilder struct {
+	args []any
+}
+
+// bind registers a value and returns its placeholder.
+func (b *queryBuilder) bind(v any) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// anyOf matches col against values; an empty list matches everything.
+func (b *queryBuilder) anyOf(col string, values []string) string {
+	if len(values) == 0 {
+		return "TRUE"
+	}
+	return fmt.Sprintf("%s = ANY(%s::text[])", col, b.bind(values))
+}
+
+// dateRange bounds col by the optional RFC3339 or YYYY-MM-DD limits.
+func (b *queryBuilder) dateRange(col, from, to string) string {
+	var conds []string
+	if from != "" {
+		conds = append(conds, fmt.Sprintf("%s >= %s::timestamptz", col, b.bind(from)))
+	}
+	if to != "" {
+		conds = append(conds, fmt.Sprintf("%s <= %s::timestamptz", col, b.bind(to)))
+	}
+	return and(conds...)
+}
+
+// expr compiles a filter expression over fields; an empty one matches
+// everything. Malformed expressions return a *filterexpr.Error.
+func (b *queryBuilder) expr(expr string, fields map[string]filterexpr.Field) (string, error) {
+	if expr == "" {
+		return "TRUE", nil
+	}
+	return filterexpr.Compile(expr, fields, b.bind)
+}
+
+func and(conds ...string) string {
+	var kept []string
+	for _, c := range conds {
+		if c != "" && c != "TRUE" {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(kept, " AND ")
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/filterexpr"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 )
 
 type ROIRepository struct {
@@ -28,8 +31,40 @@ type ROIRow struct {
 	MonthsInRange         float64
 }
 
-func (r *ROIRepository) GetROIData(ctx context.Context, country, dateFrom, dateTo string) ([]ROIRow, error) {
-	query := `
+// roiFilterFields are the columns a ROI filter expression can use.
+var roiFilterFields = map[string]filterexpr.Field{
+	"payment_method_code":      {Column: "payment_method_code", Kind: filterexpr.String},
+	"payment_method_type":      {Column: "payment_method_type", Kind: filterexpr.String},
+	"country_code":             {Column: "country_code", Kind: filterexpr.String},
+	"approved_tpv_usd":         {Column: "approved_tpv", Kind: filterexpr.Number},
+	"approved_count":           {Column: "approved_count", Kind: filterexpr.Number},
+	"transaction_count":        {Column: "total_count", Kind: filterexpr.Number},
+	"monthly_fixed_cost_usd":   {Column: "monthly_fixed_cost_usd", Kind: filterexpr.Number},
+	"per_transaction_cost_usd": {Column: "per_transaction_cost_usd", Kind: filterexpr.Number},
+	"percentage_fee":           {Column: "percentage_fee", Kind: filterexpr.Number},
+}
+
+func (r *ROIRepository) GetROIData(ctx context.Context, f model.AnalyticsFilter) ([]ROIRow, error) {
+	var b queryBuilder
+	dateFrom := b.bind(f.DateFrom)
+	dateTo := b.bind(f.DateTo)
+	txnWhere := and(
+		b.anyOf("t.country_code", f.Countries),
+		b.anyOf("t.payment_method_code", f.PaymentMethods),
+		b.dateRange("t.transaction_date", f.DateFrom, f.DateTo),
+	)
+	typeWhere := b.anyOf("pm.type", f.Types)
+	exprWhere, err := b.expr(f.Expr, roiFilterFields)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT payment_method_code, payment_method_name, country_code,
+			approved_tpv, approved_count, total_count,
+			monthly_fixed_cost_usd, per_transaction_cost_usd, percentage_fee,
+			months_in_range
+		FROM (
 		WITH txn_agg AS (
 			SELECT t.payment_method_code, t.country_code,
 				COALESCE(SUM(t.amount_usd) FILTER (WHERE t.status = 'APPROVED'), 0) AS approved_tpv,
@@ -37,29 +72,31 @@ func (r *ROIRepository) GetROIData(ctx context.Context, country, dateFrom, dateT
 				COUNT(*) AS total_count,
 				GREATEST(
 					EXTRACT(EPOCH FROM (
-						COALESCE(NULLIF($3, '')::timestamptz, MAX(t.transaction_date)) -
-						COALESCE(NULLIF($2, '')::timestamptz, MIN(t.transaction_date))
+						COALESCE(NULLIF(%[1]s::text, '')::timestamptz, MAX(t.transaction_date)) -
+						COALESCE(NULLIF(%[2]s::text, '')::timestamptz, MIN(t.transaction_date))
 					)) / (30*86400),
 					1
 				) AS months_in_range
 			FROM transactions t
-			WHERE ($1 = '' OR t.country_code = $1)
-				AND ($2 = '' OR t.transaction_date >= $2::timestamptz)
-				AND ($3 = '' OR t.transaction_date <= $3::timestamptz)
+			WHERE %[3]s
 			GROUP BY t.payment_method_code, t.country_code
 		)
-		SELECT a.payment_method_code, pm.name, a.country_code,
+		SELECT a.payment_method_code, pm.name AS payment_method_name,
+			pm.type AS payment_method_type, a.country_code,
 			a.approved_tpv, a.approved_count, a.total_count,
-			COALESCE(ic.monthly_fixed_cost_usd, 0),
-			COALESCE(ic.per_transaction_cost_usd, 0),
-			COALESCE(ic.percentage_fee, 0),
+			COALESCE(ic.monthly_fixed_cost_usd, 0) AS monthly_fixed_cost_usd,
+			COALESCE(ic.per_transaction_cost_usd, 0) AS per_transaction_cost_usd,
+			COALESCE(ic.percentage_fee, 0) AS percentage_fee,
 			a.months_in_range
 		FROM txn_agg a
 		JOIN payment_methods pm ON pm.code = a.payment_method_code
 		LEFT JOIN integration_costs ic ON ic.payment_method_code = a.payment_method_code
 			AND ic.country_code = a.country_code AND ic.effective_to IS NULL
-	`
-	rows, err := r.pool.Query(ctx, query, country, dateFrom, dateTo)
+		WHERE %[4]s
+		) r
+		WHERE %[5]s
+	`, dateTo, dateFrom, txnWhere, typeWhere, exprWhere)
+	rows, err := r.pool.Query(ctx, query, b.args...)
 	if err != nil {
 		return nil, fmt.Errorf("query ROI: %w", err)
 	}
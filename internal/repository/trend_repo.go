@@ -3,8 +3,12 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/filterexpr"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 )
 
 type TrendRepository struct {
@@ -19,6 +23,7 @@ type TrendBucket struct {
 	Period              string
 	PaymentMethodCode   string
 	PaymentMethodName   string
+	PaymentMethodType   string
 	CountryCode         string
 	TransactionCount    int
 	TpvUSD              float64
@@ -26,19 +31,67 @@ type TrendBucket struct {
 	AvgTransactionValue float64
 }
 
-func (r *TrendRepository) GetTrends(ctx context.Context, country, paymentMethod, period string, periodsBack int) ([]TrendBucket, error) {
+// trendFilterFields are the columns a trend filter expression can use. They
+// aggregate the whole requested window per (payment_method, country), so the
+// expression keeps or drops entire series rather than single buckets.
+var trendFilterFields = map[string]filterexpr.Field{
+	"payment_method_code":   {Column: "payment_method_code", Kind: filterexpr.String},
+	"country_code":          {Column: "country_code", Kind: filterexpr.String},
+	"transaction_count":     {Column: "transaction_count", Kind: filterexpr.Number},
+	"tpv_usd":               {Column: "tpv_usd", Kind: filterexpr.Number},
+	"approval_rate":         {Column: "approval_rate", Kind: filterexpr.Number},
+	"avg_transaction_value": {Column: "avg_transaction_value", Kind: filterexpr.Number},
+}
+
+// GetTrends buckets the periodsBack periods before the one containing asOf,
+// and that period up to asOf.
+func (r *TrendRepository) GetTrends(ctx context.Context, f model.AnalyticsFilter, period string, periodsBack int, asOf time.Time) ([]TrendBucket, error) {
 	truncFunc := "month"
 	if period == "WOW" {
 		truncFunc = "week"
 	}
 
-	intervalStr := fmt.Sprintf("%d %ss", periodsBack, truncFunc)
+	var b queryBuilder
+	ref := b.bind(asOf)
+	interval := b.bind(fmt.Sprintf("%d %ss", periodsBack, truncFunc))
+	where := and(
+		fmt.Sprintf("t.transaction_date >= DATE_TRUNC('%s', %s::timestamptz) - %s::interval", truncFunc, ref, interval),
+		"t.transaction_date < "+ref,
+		b.anyOf("t.country_code", f.Countries),
+		b.anyOf("t.payment_method_code", f.PaymentMethods),
+		b.anyOf("pm.type", f.Types),
+	)
+	exprWhere, err := b.expr(f.Expr, trendFilterFields)
+	if err != nil {
+		return nil, err
+	}
 
 	query := fmt.Sprintf(`
+		WITH windowed AS (
+			SELECT t.*, pm.name AS payment_method_name, pm.type AS payment_method_type
+			FROM transactions t
+			JOIN payment_methods pm ON pm.code = t.payment_method_code
+			WHERE %[2]s
+		),
+		series AS (
+			SELECT * FROM (
+				SELECT
+					payment_method_code,
+					country_code,
+					COUNT(*) AS transaction_count,
+					COALESCE(SUM(amount_usd) FILTER (WHERE status = 'APPROVED'), 0) AS tpv_usd,
+					ROUND(COUNT(*) FILTER (WHERE status = 'APPROVED')::numeric / COUNT(*)::numeric * 100, 2) AS approval_rate,
+					ROUND(AVG(amount_usd)::numeric, 2) AS avg_transaction_value
+				FROM windowed
+				GROUP BY payment_method_code, country_code
+			) s
+			WHERE %[3]s
+		)
 		SELECT
-			DATE_TRUNC('%s', t.transaction_date)::text AS period,
+			DATE_TRUNC('%[1]s', t.transaction_date)::text AS period,
 			t.payment_method_code,
-			pm.name,
+			t.payment_method_name,
+			t.payment_method_type,
 			t.country_code,
 			COUNT(*) AS txn_count,
 			COALESCE(SUM(t.amount_usd) FILTER (WHERE t.status = 'APPROVED'), 0) AS tpv_usd,
@@ -50,16 +103,14 @@ func (r *TrendRepository) GetTrends(ctx context.Context, country, paymentMethod,
 				THEN ROUND(AVG(t.amount_usd)::numeric, 2)
 				ELSE 0
 			END AS avg_txn_value
-		FROM transactions t
-		JOIN payment_methods pm ON pm.code = t.payment_method_code
-		WHERE t.transaction_date >= DATE_TRUNC('%s', NOW()) - $1::interval
-			AND ($2 = '' OR t.country_code = $2)
-			AND ($3 = '' OR t.payment_method_code = $3)
-		GROUP BY DATE_TRUNC('%s', t.transaction_date), t.payment_method_code, pm.name, t.country_code
+		FROM windowed t
+		JOIN series s ON s.payment_method_code = t.payment_method_code
+			AND s.country_code = t.country_code
+		GROUP BY DATE_TRUNC('%[1]s', t.transaction_date), t.payment_method_code, t.payment_method_name, t.payment_method_type, t.country_code
 		ORDER BY period ASC, t.payment_method_code, t.country_code
-	`, truncFunc, truncFunc, truncFunc)
+	`, truncFunc, where, exprWhere)
 
-	rows, err := r.pool.Query(ctx, query, intervalStr, country, paymentMethod)
+	rows, err := r.pool.Query(ctx, query, b.args...)
 	if err != nil {
 		return nil, fmt.Errorf("query trends: %w", err)
 	}
@@ -69,7 +120,7 @@ func (r *TrendRepository) GetTrends(ctx context.Context, country, paymentMethod,
 	for rows.Next() {
 		var b TrendBucket
 		if err := rows.Scan(&b.Period, &b.PaymentMethodCode, &b.PaymentMethodName,
-			&b.CountryCode, &b.TransactionCount, &b.TpvUSD, &b.ApprovalRate, &b.AvgTransactionValue); err != nil {
+			&b.PaymentMethodType, &b.CountryCode, &b.TransactionCount, &b.TpvUSD, &b.ApprovalRate, &b.AvgTransactionValue); err != nil {
 			return nil, fmt.Errorf("scan trend: %w", err)
 		}
 		results = append(results, b)
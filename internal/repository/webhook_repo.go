@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+type WebhookRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhookRepository(pool *pgxpool.Pool) *WebhookRepository {
+	return &WebhookRepository{pool: pool}
+}
+
+const webhookSubscriptionColumns = `id::text, url, secret, description, event_types, insight_types,
+	severities, countries, active, created_at`
+
+func scanWebhookSubscription(row pgx.Row) (*model.WebhookSubscription, error) {
+	s := &model.WebhookSubscription{}
+	err := row.Scan(&s.ID, &s.URL, &s.Secret, &s.Description, &s.EventTypes, &s.InsightTypes,
+		&s.Severities, &s.Countries, &s.Active, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, sub model.WebhookSubscription) (*model.WebhookSubscription, error) {
+	saved, err := scanWebhookSubscription(r.pool.QueryRow(ctx, `
+		INSERT INTO webhook_subscriptions (url, secret, description, event_types, insight_types, severities, countries, active)
+		VALUES ($1, $2, $3, $4::text[], $5::text[], $6::text[], $7::text[], $8)
+		RETURNING `+webhookSubscriptionColumns,
+		sub.URL, sub.Secret, sub.Description, nonNil(sub.EventTypes), nonNil(sub.InsightTypes),
+		nonNil(sub.Severities), nonNil(sub.Countries), sub.Active))
+	if err != nil {
+		return nil, fmt.Errorf("insert webhook subscription: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.WebhookSubscription
+	for rows.Next() {
+		s, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook subscription: %w", err)
+		}
+		results = append(results, *s)
+	}
+	return results, rows.Err()
+}
+
+// DeleteSubscription removes the subscription and its deliveries. It returns
+// pgx.ErrNoRows when nothing matched.
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id::text = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// WebhookEvent is an event to fan out to matching subscriptions.
+type WebhookEvent struct {
+	EventType   string
+	InsightID   string
+	InsightType string
+	Severity    string
+	CountryCode string
+	Payload     []byte
+}
+
+// Enqueue writes one outbox row per active subscription whose filters match
+// each event in tx, the transaction recording the events, and returns how
+// many rows it wrote.
+func (r *WebhookRepository) Enqueue(ctx context.Context, tx pgx.Tx, events []WebhookEvent) (int, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, e := range events {
+		batch.Queue(`
+			INSERT INTO webhook_deliveries (subscription_id, event_type, insight_id, payload)
+			SELECT s.id, $1, $2, $6::jsonb
+			FROM webhook_subscriptions s
+			WHERE s.active
+				AND (cardinality(s.event_types) = 0 OR $1 = ANY(s.event_types))
+				AND (cardinality(s.insight_types) = 0 OR $3 = ANY(s.insight_types))
+				AND (cardinality(s.severities) = 0 OR $4 = ANY(s.severities))
+				AND (cardinality(s.countries) = 0 OR $5 = ANY(s.countries))
+		`, e.EventType, e.InsightID, e.InsightType, e.Severity, e.CountryCode, string(e.Payload))
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	defer results.Close()
+
+	total := 0
+	for range events {
+		tag, err := results.Exec()
+		if err != nil {
+			return total, fmt.Errorf("enqueue webhook deliveries: %w", err)
+		}
+		total += int(tag.RowsAffected())
+	}
+	return total, nil
+}
+
+// DueDelivery is a claimed delivery with what is needed to send it.
+type DueDelivery struct {
+	model.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// ClaimDue leases up to limit pending deliveries whose next attempt is due by
+// pushing next_attempt_at out by lease, so concurrent workers skip them.
+func (r *WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]DueDelivery, error) {
+	rows, err := r.pool.Query(ctx, `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'PENDING' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET next_attempt_at = NOW() + $2::interval
+		FROM due, webhook_subscriptions s
+		WHERE d.id = due.id AND s.id = d.subscription_id
+		RETURNING d.id::text, d.subscription_id::text, d.event_type, d.insight_id, d.payload, d.attempts, s.url, s.secret
+	`, limit, fmt.Sprintf("%d seconds", int(lease.Seconds())))
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var results []DueDelivery
+	for rows.Next() {
+		var d DueDelivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventType, &d.InsightID, &d.Payload, &d.Attempts,
+			&d.URL, &d.Secret); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
+// RecordAttempt stores the outcome of one delivery attempt. nextAttempt is
+// ignored unless status is PENDING.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, id, status string, attempts, statusCode int, lastErr string, nextAttempt time.Time) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE webhook_deliveries SET
+			status = $2,
+			attempts = $3,
+			last_status_code = $4,
+			last_error = $5,
+			next_attempt_at = CASE WHEN $2 = 'PENDING' THEN $6 ELSE next_attempt_at END,
+			delivered_at = CASE WHEN $2 = 'DELIVERED' THEN NOW() ELSE NULL END
+		WHERE id::text = $1
+	`, id, status, attempts, statusCode, lastErr, nextAttempt)
+	if err != nil {
+		return fmt.Errorf("record webhook attempt: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns deliveries newest first, optionally by status and
+// subscription.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, status, subscriptionID string, limit int) ([]model.WebhookDelivery, error) {
+	var b queryBuilder
+	var conds []string
+	if status != "" {
+		conds = append(conds, "status = "+b.bind(status))
+	}
+	if subscriptionID != "" {
+		conds = append(conds, "subscription_id::text = "+b.bind(subscriptionID))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id::text, subscription_id::text, event_type, insight_id, payload, status, attempts,
+			next_attempt_at, last_status_code, last_error, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE %s
+		ORDER BY created_at DESC, id
+		LIMIT %s
+	`, and(conds...), b.bind(limit))
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.WebhookDelivery
+	for rows.Next() {
+		var d model.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventType, &d.InsightID, &d.Payload, &d.Status,
+			&d.Attempts, &d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
+// Redeliver puts a delivered or failed delivery back in the queue with a
+// fresh attempt budget. A PENDING delivery is queued or leased to a worker
+// already, so it is left alone and Redeliver returns false. It returns
+// pgx.ErrNoRows when no delivery has the id.
+func (r *WebhookRepository) Redeliver(ctx context.Context, id string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE webhook_deliveries SET
+			status = 'PENDING', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+		WHERE id::text = $1 AND status <> 'PENDING'
+	`, id)
+	if err != nil {
+		return false, fmt.Errorf("redeliver webhook: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		return true, nil
+	}
+	var exists bool
+	if err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM webhook_deliveries WHERE id::text = $1)`, id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("redeliver webhook: %w", err)
+	}
+	if !exists {
+		return false, pgx.ErrNoRows
+	}
+	return false, nil
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+type AmountBand struct {
+	Band               int     `json:"band"`
+	MinAmount          float64 `json:"min_amount"`
+	MaxAmount          float64 `json:"max_amount"`
+	TransactionCount   int     `json:"transaction_count"`
+	ApprovedCount      int     `json:"approved_count"`
+	ApprovalRate       float64 `json:"approval_rate"`
+	OthersApprovalRate float64 `json:"others_approval_rate"`
+	VolumeUSD          float64 `json:"volume_usd"`
+	ApprovedTpvUSD     float64 `json:"approved_tpv_usd"`
+}
+
+// AmountBandProfile is a method-country's approval rate by ticket size in
+// local currency. ProbableLimit is set when approval collapses above it.
+type AmountBandProfile struct {
+	PaymentMethodCode  string       `json:"payment_method_code"`
+	PaymentMethodName  string       `json:"payment_method_name"`
+	PaymentMethodType  string       `json:"payment_method_type"`
+	CountryCode        string       `json:"country_code"`
+	Currency           string       `json:"currency"`
+	TransactionCount   int          `json:"transaction_count"`
+	ApprovalRate       float64      `json:"approval_rate"`
+	Bands              []AmountBand `json:"bands"`
+	ProbableLimit      *float64     `json:"probable_limit"`
+	ApprovalBelowLimit float64      `json:"approval_rate_below_limit,omitempty"`
+	ApprovalAboveLimit float64      `json:"approval_rate_above_limit,omitempty"`
+	LimitDropPP        float64      `json:"limit_drop_pp,omitempty"`
+	TxnsAboveLimit     int          `json:"transactions_above_limit,omitempty"`
+	VolumeAboveLimit   float64      `json:"volume_above_limit_usd,omitempty"`
+}
+
+type AmountBandService struct {
+	repo  *repository.AmountBandRepository
+	rules *DetectionRuleService
+}
+
+func NewAmountBandService(repo *repository.AmountBandRepository, rules *DetectionRuleService) *AmountBandService {
+	return &AmountBandService{repo: repo, rules: rules}
+}
+
+// GetAmountBands profiles each method-country with the ticket_size_mismatch
+// rules: lookback_days and band_count from the global rules, the limit
+// thresholds per row.
+func (s *AmountBandService) GetAmountBands(ctx context.Context, f model.AnalyticsFilter) ([]AmountBandProfile, error) {
+	paramsFor, err := s.rules.ParamsFunc(ctx, InsightTypeTicketSizeMismatch)
+	if err != nil {
+		return nil, err
+	}
+	global := paramsFor("", "", "")
+	now := time.Now().UTC()
+	since := now.AddDate(0, 0, -int(global.Get("lookback_days")))
+	rows, err := s.repo.GetAmountBands(ctx, f, since, now, int(global.Get("band_count")))
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := []AmountBandProfile{}
+	for _, series := range splitAmountBands(rows) {
+		first := series[0]
+		profiles = append(profiles, profileAmountBands(series,
+			paramsFor(first.CountryCode, first.PaymentMethodType, first.PaymentMethodCode)))
+	}
+	return profiles, nil
+}
+
+// splitAmountBands groups rows ordered by method, country and currency.
+func splitAmountBands(rows []repository.AmountBandRow) [][]repository.AmountBandRow {
+	var out [][]repository.AmountBandRow
+	start := 0
+	for i := 1; i <= len(rows); i++ {
+		if i == len(rows) ||
+			rows[i].PaymentMethodCode != rows[start].PaymentMethodCode ||
+			rows[i].CountryCode != rows[start].CountryCode ||
+			rows[i].Currency != rows[start].Currency {
+			if i > start {
+				out = append(out, rows[start:i])
+			}
+			start = i
+		}
+	}
+	return out
+}
+
+// profileAmountBands computes band approval rates and looks for a limit with
+// min_band_txns and min_drop_pp from params.
+func profileAmountBands(series []repository.AmountBandRow, params DetectorParams) AmountBandProfile {
+	first := series[0]
+	p := AmountBandProfile{
+		PaymentMethodCode: first.PaymentMethodCode,
+		PaymentMethodName: first.PaymentMethodName,
+		PaymentMethodType: first.PaymentMethodType,
+		CountryCode:       first.CountryCode,
+		Currency:          first.Currency,
+	}
+
+	approved := 0
+	for _, r := range series {
+		p.TransactionCount += r.TransactionCount
+		approved += r.ApprovedCount
+	}
+	p.ApprovalRate = approvalRate(approved, p.TransactionCount)
+
+	for _, r := range series {
+		p.Bands = append(p.Bands, AmountBand{
+			Band:               r.Band,
+			MinAmount:          r.MinAmount,
+			MaxAmount:          r.MaxAmount,
+			TransactionCount:   r.TransactionCount,
+			ApprovedCount:      r.ApprovedCount,
+			ApprovalRate:       approvalRate(r.ApprovedCount, r.TransactionCount),
+			OthersApprovalRate: approvalRate(approved-r.ApprovedCount, p.TransactionCount-r.TransactionCount),
+			VolumeUSD:          r.VolumeUSD,
+			ApprovedTpvUSD:     r.ApprovedTpvUSD,
+		})
+	}
+
+	split, below, above, ok := amountLimitSplit(p.Bands, int(params.Get("min_band_txns")))
+	if !ok || below-above < params.Get("min_drop_pp") {
+		return p
+	}
+	limit := p.Bands[split-1].MaxAmount
+	p.ProbableLimit = &limit
+	p.ApprovalBelowLimit = below
+	p.ApprovalAboveLimit = above
+	p.LimitDropPP = below - above
+	for _, b := range p.Bands[split:] {
+		p.TxnsAboveLimit += b.TransactionCount
+		p.VolumeAboveLimit += b.VolumeUSD
+	}
+	return p
+}
+
+// amountLimitSplit finds where approval falls most between the bands below
+// and at or above split, with at least minTxns transactions on each side.
+// Rates are pooled over each side.
+func amountLimitSplit(bands []AmountBand, minTxns int) (split int, below, above float64, ok bool) {
+	total, approved := 0, 0
+	for _, b := range bands {
+		total += b.TransactionCount
+		approved += b.ApprovedCount
+	}
+
+	belowTxns, belowApproved := 0, 0
+	for k := 1; k < len(bands); k++ {
+		belowTxns += bands[k-1].TransactionCount
+		belowApproved += bands[k-1].ApprovedCount
+		aboveTxns := total - belowTxns
+		if belowTxns < minTxns || aboveTxns < minTxns {
+			continue
+		}
+		b := approvalRate(belowApproved, belowTxns)
+		a := approvalRate(approved-belowApproved, aboveTxns)
+		if !ok || b-a > below-above {
+			split, below, above, ok = k, b, a, true
+		}
+	}
+	return split, below, above, ok
+}
+
+type ticketSizeMismatchDetector struct {
+	repo *repository.AmountBandRepository
+}
+
+func (d *ticketSizeMismatchDetector) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	now := run.Scope.Now
+	// Bands are cut in SQL, so their count and window come from the global
+	// parameters; scoped rules can still tune the limit thresholds.
+	since := now.AddDate(0, 0, -int(run.Params.Get("lookback_days")))
+	rows, err := d.repo.GetAmountBands(ctx, run.Scope.Filter, since, now, int(run.Params.Get("band_count")))
+	if err != nil {
+		return nil, err
+	}
+
+	var insights []Insight
+	for _, series := range splitAmountBands(rows) {
+		first := series[0]
+		params := run.ParamsFor(first.CountryCode, first.PaymentMethodType, first.PaymentMethodCode)
+		p := profileAmountBands(series, params)
+		if p.ProbableLimit == nil {
+			continue
+		}
+
+		insights = append(insights, Insight{
+			// Bands are cut per currency, so a method-country can have one
+			// limit per currency it is charged in.
+			InsightID:         hashID(InsightTypeTicketSizeMismatch, p.PaymentMethodCode, p.CountryCode, p.Currency),
+			Type:              InsightTypeTicketSizeMismatch,
+			Severity:          run.SeverityFor(params).Map(p.LimitDropPP),
+			PaymentMethodCode: p.PaymentMethodCode,
+			PaymentMethodName: p.PaymentMethodName,
+			CountryCode:       p.CountryCode,
+			TriggeringMetric:  "approval_drop_above_limit_pp",
+			MetricValue:       p.LimitDropPP,
+			Threshold:         params.Get("min_drop_pp"),
+			Description: fmt.Sprintf("%s in %s approves %.1f%% of transactions above %s %.2f against %.1f%% below it; %d transactions were above the probable limit",
+				p.PaymentMethodName, p.CountryCode, p.ApprovalAboveLimit, p.Currency, *p.ProbableLimit,
+				p.ApprovalBelowLimit, p.TxnsAboveLimit),
+			RecommendedAction: "Confirm the provider's per-transaction limit, then show it at checkout or route larger tickets to a method that accepts them.",
+			SupportingData: map[string]interface{}{
+				"probable_limit":            *p.ProbableLimit,
+				"currency":                  p.Currency,
+				"approval_rate_below_limit": p.ApprovalBelowLimit,
+				"approval_rate_above_limit": p.ApprovalAboveLimit,
+				"transactions_above_limit":  p.TxnsAboveLimit,
+				"bands":                     p.Bands,
+				"payment_method_type":       p.PaymentMethodType,
+			},
+			EstimatedImpactUSD: ticketSizeImpact(p, run.Params.Get("lookback_days")),
+			Thresholds:         params,
+			GeneratedAt:        now,
+		})
+	}
+	return insights, nil
+}
+
+// EvidenceScope covers the transactions that were split into bands.
+func (d *ticketSizeMismatchDetector) EvidenceScope(in Insight) EvidenceScope {
+	return EvidenceScope{
+		From:   in.GeneratedAt.AddDate(0, 0, -int(DetectorParams(in.Thresholds).Get("lookback_days"))),
+		To:     in.GeneratedAt,
+		Metric: EvidenceMetricApprovalRate,
+		Peers:  PeerGroupNone,
+	}
+}
+
+// ticketSizeImpact is the monthly approved TPV lost above the limit: the
+// volume attempted there times the drop in approval.
+func ticketSizeImpact(p AmountBandProfile, lookbackDays float64) float64 {
+	return perMonth(p.VolumeAboveLimit*p.LimitDropPP/100, lookbackDays)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func bandParams() DetectorParams {
+	return DetectorParams{"min_band_txns": 20, "min_drop_pp": 20}
+}
+
+// amountBands builds one band of 50 transactions per approval rate, each
+// covering 1000 units of local currency.
+func amountBands(approvedPct ...int) []repository.AmountBandRow {
+	var out []repository.AmountBandRow
+	for i, pct := range approvedPct {
+		out = append(out, repository.AmountBandRow{
+			PaymentMethodCode: "KUESKI",
+			PaymentMethodName: "Kueski Pay BNPL",
+			PaymentMethodType: "BNPL",
+			CountryCode:       "MX",
+			Currency:          "MXN",
+			Band:              i + 1,
+			MinAmount:         float64(i*1000) + 1,
+			MaxAmount:         float64((i + 1) * 1000),
+			TransactionCount:  50,
+			ApprovedCount:     pct / 2,
+		})
+	}
+	return out
+}
+
+func TestProfileAmountBandsFindsLimit(t *testing.T) {
+	p := profileAmountBands(amountBands(90, 88, 92, 30, 20), bandParams())
+
+	require.NotNil(t, p.ProbableLimit)
+	assert.Equal(t, 3000.0, *p.ProbableLimit, "approval collapses above the third band")
+	assert.InDelta(t, 90, p.ApprovalBelowLimit, 1e-9)
+	assert.InDelta(t, 25, p.ApprovalAboveLimit, 1e-9)
+	assert.InDelta(t, 65, p.LimitDropPP, 1e-9)
+	assert.Equal(t, 100, p.TxnsAboveLimit)
+	assert.Equal(t, 250, p.TransactionCount)
+	assert.InDelta(t, 64, p.ApprovalRate, 1e-9)
+
+	require.Len(t, p.Bands, 5)
+	assert.InDelta(t, 30, p.Bands[3].ApprovalRate, 1e-9)
+	assert.InDelta(t, 72.5, p.Bands[3].OthersApprovalRate, 1e-9)
+}
+
+func TestProfileAmountBandsWithoutLimit(t *testing.T) {
+	p := profileAmountBands(amountBands(90, 85, 88, 80, 82), bandParams())
+	assert.Nil(t, p.ProbableLimit, "gradual decline is not a limit")
+
+	thin := amountBands(90, 90, 90, 90, 0)
+	for i := range thin {
+		thin[i].TransactionCount, thin[i].ApprovedCount = 4, thin[i].ApprovedCount/12
+	}
+	p = profileAmountBands(thin, bandParams())
+	assert.Nil(t, p.ProbableLimit, "too few transactions on each side")
+}
+
+func TestSplitAmountBands(t *testing.T) {
+	rows := append(amountBands(90, 90), amountBands(80)...)
+	rows[2].Currency = "USD"
+	groups := splitAmountBands(rows)
+	require.Len(t, groups, 2)
+	assert.Len(t, groups[0], 2)
+	assert.Equal(t, "USD", groups[1][0].Currency)
+}
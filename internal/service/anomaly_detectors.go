@@ -0,0 +1,412 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+type approvalAnomalyDetector struct {
+	repo *repository.InsightRepository
+}
+
+func (d *approvalAnomalyDetector) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	now := run.Scope.Now
+	lookback := time.Duration(run.Params.Get("lookback_hours")) * time.Hour
+	points, err := d.repo.GetHourlyApprovalSeries(ctx, run.Scope.Filter, now.Add(-lookback), now)
+	if err != nil {
+		return nil, err
+	}
+
+	var insights []Insight
+	for _, series := range splitHourlySeries(points) {
+		first := series[0]
+		params := run.ParamsFor(first.CountryCode, first.PaymentMethodType, first.PaymentMethodCode)
+		a, ok := approvalAnomaly(series, params, now)
+		if !ok {
+			continue
+		}
+
+		insights = append(insights, Insight{
+			InsightID:         hashID(InsightTypeApprovalAnomaly, first.PaymentMethodCode, first.CountryCode),
+			Type:              InsightTypeApprovalAnomaly,
+			Severity:          run.SeverityFor(params).Map(float64(a.Latest.TransactionCount)),
+			PaymentMethodCode: first.PaymentMethodCode,
+			PaymentMethodName: first.PaymentMethodName,
+			CountryCode:       first.CountryCode,
+			TriggeringMetric:  "hourly_approval_rate",
+			MetricValue:       a.Rate,
+			Threshold:         a.Baseline - params.Get("z_threshold")*a.StdDev,
+			Description: fmt.Sprintf("%s in %s approved %.1f%% of %d transactions in the hour starting %s UTC, against a baseline of %.1f%% (z=%.1f)",
+				first.PaymentMethodName, first.CountryCode, a.Rate, a.Latest.TransactionCount,
+				a.Latest.Hour.UTC().Format("2006-01-02 15:04"), a.Baseline, a.ZScore),
+			RecommendedAction: "Check provider status and recent fraud-rule or routing changes; escalate to the acquirer if declines persist.",
+			SupportingData: map[string]interface{}{
+				"hour":                      a.Latest.Hour,
+				"transaction_count":         a.Latest.TransactionCount,
+				"approved_count":            a.Latest.ApprovedCount,
+				"baseline_approval_rate":    a.Baseline,
+				"baseline_stddev_pp":        a.StdDev,
+				"z_score":                   a.ZScore,
+				"baseline_hours":            a.BaselineHours,
+				"estimated_excess_declines": a.ExcessDeclines,
+				"payment_method_type":       first.PaymentMethodType,
+			},
+			EstimatedImpactUSD: approvalAnomalyImpact(a.Latest.VolumeUSD, a.Baseline, a.Rate),
+			Thresholds:         params,
+			GeneratedAt:        now,
+		})
+	}
+	return insights, nil
+}
+
+// EvidenceScope covers the hours the EWMA baseline was built from.
+func (d *approvalAnomalyDetector) EvidenceScope(in Insight) EvidenceScope {
+	lookback := time.Duration(DetectorParams(in.Thresholds).Get("lookback_hours")) * time.Hour
+	return EvidenceScope{
+		From:   in.GeneratedAt.Add(-lookback),
+		To:     in.GeneratedAt,
+		Metric: EvidenceMetricApprovalRate,
+		Peers:  PeerGroupNone,
+	}
+}
+
+// SuppressionWindow is the anomalous hour.
+func (d *approvalAnomalyDetector) SuppressionWindow(in Insight) (time.Time, time.Time) {
+	hour, ok := supportingTime(in, "hour")
+	if !ok {
+		return in.GeneratedAt, in.GeneratedAt
+	}
+	return hour, hour.Add(time.Hour)
+}
+
+// splitHourlySeries cuts method-country-ordered points into one series each.
+func splitHourlySeries(points []repository.HourlyApprovalPoint) [][]repository.HourlyApprovalPoint {
+	var out [][]repository.HourlyApprovalPoint
+	start := 0
+	for i := 1; i <= len(points); i++ {
+		if i == len(points) ||
+			points[i].PaymentMethodCode != points[start].PaymentMethodCode ||
+			points[i].CountryCode != points[start].CountryCode {
+			if i > start {
+				out = append(out, points[start:i])
+			}
+			start = i
+		}
+	}
+	return out
+}
+
+// ewma is an exponentially weighted mean and variance.
+type ewma struct {
+	alpha    float64
+	mean     float64
+	variance float64
+	n        int
+}
+
+func (e *ewma) add(x float64) {
+	if e.n == 0 {
+		e.mean = x
+		e.n = 1
+		return
+	}
+	diff := x - e.mean
+	incr := e.alpha * diff
+	e.mean += incr
+	e.variance = (1 - e.alpha) * (e.variance + diff*incr)
+	e.n++
+}
+
+// approvalAnomalyResult describes a latest hour that fell below baseline.
+type approvalAnomalyResult struct {
+	Latest         repository.HourlyApprovalPoint
+	Rate           float64
+	Baseline       float64
+	StdDev         float64
+	ZScore         float64
+	BaselineHours  int
+	ExcessDeclines float64
+}
+
+// approvalAnomaly folds every hour with enough traffic except the latest into
+// an EWMA baseline and reports whether the latest hour's approval rate sits
+// more than z_threshold standard deviations below it. Only drops count; the
+// latest hour must be recent and the baseline warmed up.
+func approvalAnomaly(series []repository.HourlyApprovalPoint, params DetectorParams, now time.Time) (approvalAnomalyResult, bool) {
+	minTxns := int(params.Get("min_hourly_txns"))
+	var eligible []repository.HourlyApprovalPoint
+	for _, p := range series {
+		if p.TransactionCount >= minTxns && p.TransactionCount > 0 {
+			eligible = append(eligible, p)
+		}
+	}
+	if len(eligible) < 2 {
+		return approvalAnomalyResult{}, false
+	}
+
+	latest := eligible[len(eligible)-1]
+	maxAge := time.Duration(params.Get("max_age_hours")) * time.Hour
+	if latest.Hour.Before(now.Truncate(time.Hour).Add(-maxAge)) {
+		return approvalAnomalyResult{}, false
+	}
+
+	baseline := ewma{alpha: params.Get("ewma_alpha")}
+	for _, p := range eligible[:len(eligible)-1] {
+		baseline.add(approvalRate(p.ApprovedCount, p.TransactionCount))
+	}
+	if float64(baseline.n) < params.Get("warmup_hours") {
+		return approvalAnomalyResult{}, false
+	}
+
+	stddev := math.Max(math.Sqrt(baseline.variance), params.Get("min_stddev_pp"))
+	rate := approvalRate(latest.ApprovedCount, latest.TransactionCount)
+	z := (baseline.mean - rate) / stddev
+	if z < params.Get("z_threshold") {
+		return approvalAnomalyResult{}, false
+	}
+
+	return approvalAnomalyResult{
+		Latest:         latest,
+		Rate:           rate,
+		Baseline:       baseline.mean,
+		StdDev:         stddev,
+		ZScore:         z,
+		BaselineHours:  baseline.n,
+		ExcessDeclines: float64(latest.TransactionCount) * (baseline.mean - rate) / 100,
+	}, true
+}
+
+func approvalRate(approved, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(approved) / float64(total) * 100
+}
+
+const hoursPerWeek = 7 * 24
+
+type volumeOutageDetector struct {
+	repo *repository.InsightRepository
+}
+
+func (d *volumeOutageDetector) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	now := run.Scope.Now
+	since := now.Add(-time.Duration(run.Params.Get("lookback_weeks")*hoursPerWeek) * time.Hour)
+	// Recent counts are fetched once, so the window comes from the global
+	// parameters; scoped rules can still tune the other parameters.
+	window := time.Duration(run.Params.Get("window_minutes")) * time.Minute
+	recentSince := now.Add(-window)
+
+	volumes, err := d.repo.GetHourOfWeekVolumes(ctx, run.Scope.Filter, since, now)
+	if err != nil {
+		return nil, err
+	}
+	activity, err := d.repo.GetRecentActivity(ctx, run.Scope.Filter, since, recentSince, now)
+	if err != nil {
+		return nil, err
+	}
+	activityByKey := make(map[string]repository.RecentActivity, len(activity))
+	for _, a := range activity {
+		activityByKey[a.PaymentMethodCode+"|"+a.CountryCode] = a
+	}
+
+	var insights []Insight
+	for _, series := range splitVolumeSeries(volumes) {
+		first := series[0]
+		act, ok := activityByKey[first.PaymentMethodCode+"|"+first.CountryCode]
+		if !ok {
+			continue
+		}
+		params := run.ParamsFor(first.CountryCode, first.PaymentMethodType, first.PaymentMethodCode)
+		start := first.SeriesStart
+		if start.Before(since) {
+			start = since
+		}
+		weeks := now.Sub(start).Hours() / hoursPerWeek
+		if weeks < params.Get("min_history_weeks") {
+			continue
+		}
+
+		o, ok := volumeOutage(buildArrivalProfile(series, weeks), act, window, params, now)
+		if !ok {
+			continue
+		}
+
+		gapMinutes := o.Gap.Minutes()
+		insights = append(insights, Insight{
+			InsightID:         hashID(InsightTypeVolumeOutage, first.PaymentMethodCode, first.CountryCode),
+			Type:              InsightTypeVolumeOutage,
+			Severity:          run.SeverityFor(params).Map(o.Expected - float64(o.Observed)),
+			PaymentMethodCode: first.PaymentMethodCode,
+			PaymentMethodName: first.PaymentMethodName,
+			CountryCode:       first.CountryCode,
+			TriggeringMetric:  "transaction_count",
+			MetricValue:       float64(o.Observed),
+			Threshold:         o.Expected,
+			Description: fmt.Sprintf("%s in %s has %d transactions in the last %.0f minutes where %.1f were expected (p=%.2g); last transaction %.0f minutes ago",
+				first.PaymentMethodName, first.CountryCode, o.Observed, o.Window.Minutes(), o.Expected, o.PValue, gapMinutes),
+			RecommendedAction: "Treat as a possible outage: check provider status, credentials and connectivity before the gap grows.",
+			SupportingData: map[string]interface{}{
+				"gap_minutes":            gapMinutes,
+				"last_transaction_at":    act.LastTransactionAt,
+				"window_minutes":         o.Window.Minutes(),
+				"expected_transactions":  o.Expected,
+				"observed_transactions":  o.Observed,
+				"p_value":                o.PValue,
+				"estimated_lost_tpv_usd": o.LostTpvUSD,
+				"history_weeks":          weeks,
+				"payment_method_type":    first.PaymentMethodType,
+			},
+			EstimatedImpactUSD: volumeOutageImpact(o.LostTpvUSD),
+			Thresholds:         params,
+			GeneratedAt:        now,
+		})
+	}
+	return insights, nil
+}
+
+// EvidenceScope covers the weeks the arrival profile was learned from.
+func (d *volumeOutageDetector) EvidenceScope(in Insight) EvidenceScope {
+	lookback := time.Duration(DetectorParams(in.Thresholds).Get("lookback_weeks")*hoursPerWeek) * time.Hour
+	return EvidenceScope{
+		From:   in.GeneratedAt.Add(-lookback),
+		To:     in.GeneratedAt,
+		Metric: EvidenceMetricTransactions,
+		Peers:  PeerGroupNone,
+	}
+}
+
+// SuppressionWindow is the recent window the gap was measured over.
+func (d *volumeOutageDetector) SuppressionWindow(in Insight) (time.Time, time.Time) {
+	window := time.Duration(supportingNumber(in, "window_minutes")) * time.Minute
+	return in.GeneratedAt.Add(-window), in.GeneratedAt
+}
+
+// splitVolumeSeries cuts method-country-ordered volumes into one series each.
+func splitVolumeSeries(volumes []repository.HourOfWeekVolume) [][]repository.HourOfWeekVolume {
+	var out [][]repository.HourOfWeekVolume
+	start := 0
+	for i := 1; i <= len(volumes); i++ {
+		if i == len(volumes) ||
+			volumes[i].PaymentMethodCode != volumes[start].PaymentMethodCode ||
+			volumes[i].CountryCode != volumes[start].CountryCode {
+			if i > start {
+				out = append(out, volumes[start:i])
+			}
+			start = i
+		}
+	}
+	return out
+}
+
+// arrivalProfile is the expected transactions and approved TPV per hour for
+// each UTC hour-of-week slot.
+type arrivalProfile struct {
+	rate [hoursPerWeek]float64
+	tpv  [hoursPerWeek]float64
+}
+
+func buildArrivalProfile(series []repository.HourOfWeekVolume, weeks float64) arrivalProfile {
+	var p arrivalProfile
+	if weeks <= 0 {
+		return p
+	}
+	for _, v := range series {
+		if v.HourOfWeek < 0 || v.HourOfWeek >= hoursPerWeek {
+			continue
+		}
+		p.rate[v.HourOfWeek] = float64(v.TransactionCount) / weeks
+		p.tpv[v.HourOfWeek] = v.ApprovedTpvUSD / weeks
+	}
+	return p
+}
+
+func hourOfWeek(t time.Time) int {
+	t = t.UTC()
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// expected integrates the profile over [from, to), prorating partial hours.
+func (p arrivalProfile) expected(from, to time.Time) (count, tpv float64) {
+	for t := from; t.Before(to); {
+		next := t.Truncate(time.Hour).Add(time.Hour)
+		if next.After(to) {
+			next = to
+		}
+		frac := next.Sub(t).Hours()
+		slot := hourOfWeek(t)
+		count += p.rate[slot] * frac
+		tpv += p.tpv[slot] * frac
+		t = next
+	}
+	return count, tpv
+}
+
+// volumeOutageResult describes a window with improbably little traffic.
+type volumeOutageResult struct {
+	Window     time.Duration
+	Gap        time.Duration
+	Expected   float64
+	Observed   int
+	PValue     float64
+	LostTpvUSD float64
+}
+
+// volumeOutage compares the traffic in the last window, or since the last
+// transaction when that is longer ago, with the profile's expectation and
+// reports whether P(X <= observed) under a Poisson model is below
+// p_threshold. act's recent figures must cover exactly the window.
+func volumeOutage(profile arrivalProfile, act repository.RecentActivity, window time.Duration, params DetectorParams, now time.Time) (volumeOutageResult, bool) {
+	from := now.Add(-window)
+	observed, observedTpv := act.RecentCount, act.RecentTpvUSD
+	if act.LastTransactionAt.Before(from) {
+		from = act.LastTransactionAt
+		observed, observedTpv = 0, 0
+	}
+
+	expected, expectedTpv := profile.expected(from, now)
+	if expected < params.Get("min_expected_txns") {
+		return volumeOutageResult{}, false
+	}
+	p := poissonCDF(observed, expected)
+	if p >= params.Get("p_threshold") {
+		return volumeOutageResult{}, false
+	}
+
+	return volumeOutageResult{
+		Window:     now.Sub(from),
+		Gap:        now.Sub(act.LastTransactionAt),
+		Expected:   expected,
+		Observed:   observed,
+		PValue:     p,
+		LostTpvUSD: math.Max(0, expectedTpv-observedTpv),
+	}, true
+}
+
+// poissonCDF returns P(X <= k) for X ~ Poisson(lambda), summed in log space
+// so large lambdas do not underflow.
+func poissonCDF(k int, lambda float64) float64 {
+	if k < 0 {
+		return 0
+	}
+	if lambda <= 0 {
+		return 1
+	}
+	logLambda := math.Log(lambda)
+	maxLog := math.Inf(-1)
+	logs := make([]float64, k+1)
+	for i := 0; i <= k; i++ {
+		lg, _ := math.Lgamma(float64(i + 1))
+		logs[i] = -lambda + float64(i)*logLambda - lg
+		maxLog = math.Max(maxLog, logs[i])
+	}
+	sum := 0.0
+	for _, l := range logs {
+		sum += math.Exp(l - maxLog)
+	}
+	return math.Min(1, math.Exp(maxLog+math.Log(sum)))
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func anomalyParams() DetectorParams {
+	return DetectorParams{
+		"lookback_hours":  168,
+		"ewma_alpha":      0.1,
+		"z_threshold":     3,
+		"min_hourly_txns": 10,
+		"warmup_hours":    24,
+		"max_age_hours":   2,
+		"min_stddev_pp":   2,
+	}
+}
+
+// hourlySeries builds hours ending at last, approving the given share of 100
+// transactions per hour, alternating +/-1pp of noise.
+func hourlySeries(last time.Time, hours int, approvedPct int) []repository.HourlyApprovalPoint {
+	var out []repository.HourlyApprovalPoint
+	for i := hours - 1; i >= 0; i-- {
+		approved := approvedPct + 1 - 2*(i%2)
+		out = append(out, repository.HourlyApprovalPoint{
+			PaymentMethodCode: "PIX",
+			CountryCode:       "BR",
+			Hour:              last.Add(-time.Duration(i) * time.Hour),
+			TransactionCount:  100,
+			ApprovedCount:     approved,
+		})
+	}
+	return out
+}
+
+func TestApprovalAnomalyFlagsCollapse(t *testing.T) {
+	now := time.Date(2026, 3, 4, 15, 20, 0, 0, time.UTC)
+	series := hourlySeries(now.Truncate(time.Hour).Add(-time.Hour), 48, 90)
+	series = append(series, repository.HourlyApprovalPoint{
+		PaymentMethodCode: "PIX", CountryCode: "BR", Hour: now.Truncate(time.Hour),
+		TransactionCount: 300, ApprovedCount: 150,
+	})
+
+	a, ok := approvalAnomaly(series, anomalyParams(), now)
+	require.True(t, ok)
+	assert.InDelta(t, 50, a.Rate, 0.001)
+	assert.InDelta(t, 90, a.Baseline, 1)
+	assert.Equal(t, 2.0, a.StdDev, "noise below the floor uses min_stddev_pp")
+	assert.Greater(t, a.ZScore, 15.0)
+	assert.InDelta(t, 120, a.ExcessDeclines, 5)
+	assert.Equal(t, 48, a.BaselineHours)
+}
+
+func TestApprovalAnomalyIgnoresNormalAndStaleHours(t *testing.T) {
+	now := time.Date(2026, 3, 4, 15, 20, 0, 0, time.UTC)
+	params := anomalyParams()
+
+	_, ok := approvalAnomaly(hourlySeries(now.Truncate(time.Hour), 48, 90), params, now)
+	assert.False(t, ok, "steady approval rate")
+
+	series := hourlySeries(now.Add(-10*time.Hour), 48, 90)
+	series[len(series)-1].ApprovedCount = 10
+	_, ok = approvalAnomaly(series, params, now)
+	assert.False(t, ok, "latest hour is older than max_age_hours")
+
+	series = hourlySeries(now.Truncate(time.Hour), 10, 90)
+	series[len(series)-1].ApprovedCount = 10
+	_, ok = approvalAnomaly(series, params, now)
+	assert.False(t, ok, "baseline not warmed up")
+
+	series = hourlySeries(now.Truncate(time.Hour), 48, 90)
+	series[len(series)-1].TransactionCount = 5
+	series[len(series)-1].ApprovedCount = 0
+	_, ok = approvalAnomaly(series, params, now)
+	assert.False(t, ok, "hours below min_hourly_txns are skipped, so the previous hour is latest")
+
+	series = hourlySeries(now.Truncate(time.Hour), 48, 60)
+	series[len(series)-1].ApprovedCount = 99
+	_, ok = approvalAnomaly(series, params, now)
+	assert.False(t, ok, "rises are not anomalies")
+}
+
+func TestSplitHourlySeries(t *testing.T) {
+	points := []repository.HourlyApprovalPoint{
+		{PaymentMethodCode: "PIX", CountryCode: "BR"},
+		{PaymentMethodCode: "PIX", CountryCode: "BR"},
+		{PaymentMethodCode: "SPEI", CountryCode: "MX"},
+	}
+	series := splitHourlySeries(points)
+	require.Len(t, series, 2)
+	assert.Len(t, series[0], 2)
+	assert.Equal(t, "SPEI", series[1][0].PaymentMethodCode)
+	assert.Empty(t, splitHourlySeries(nil))
+}
+
+func TestPoissonCDF(t *testing.T) {
+	assert.InDelta(t, math.Exp(-3), poissonCDF(0, 3), 1e-12)
+	assert.InDelta(t, math.Exp(-3)*(1+3+4.5), poissonCDF(2, 3), 1e-12)
+	assert.Equal(t, 0.0, poissonCDF(-1, 3))
+	assert.Equal(t, 1.0, poissonCDF(0, 0))
+	// Large rates must not underflow to 0 when the count is typical.
+	assert.InDelta(t, 0.5, poissonCDF(800, 800), 0.02)
+}
+
+func flatProfile(perHour, tpvPerHour float64) arrivalProfile {
+	var p arrivalProfile
+	for i := range p.rate {
+		p.rate[i] = perHour
+		p.tpv[i] = tpvPerHour
+	}
+	return p
+}
+
+func TestArrivalProfileExpected(t *testing.T) {
+	vols := []repository.HourOfWeekVolume{
+		{HourOfWeek: hourOfWeek(time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)), TransactionCount: 80, ApprovedTpvUSD: 4000},
+		{HourOfWeek: hourOfWeek(time.Date(2026, 3, 4, 11, 0, 0, 0, time.UTC)), TransactionCount: 40, ApprovedTpvUSD: 2000},
+	}
+	p := buildArrivalProfile(vols, 4)
+
+	count, tpv := p.expected(time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC), time.Date(2026, 3, 4, 11, 30, 0, 0, time.UTC))
+	assert.InDelta(t, 10+5, count, 1e-9)
+	assert.InDelta(t, 500+250, tpv, 1e-9)
+
+	// The same slot a week later has the same expectation.
+	count, _ = p.expected(time.Date(2026, 3, 11, 10, 0, 0, 0, time.UTC), time.Date(2026, 3, 11, 11, 0, 0, 0, time.UTC))
+	assert.InDelta(t, 20, count, 1e-9)
+}
+
+func TestVolumeOutage(t *testing.T) {
+	now := time.Date(2026, 3, 4, 15, 0, 0, 0, time.UTC)
+	params := DetectorParams{"min_expected_txns": 5, "p_threshold": 0.001}
+	window := 30 * time.Minute
+	busy := flatProfile(60, 3000) // one transaction a minute
+
+	act := repository.RecentActivity{LastTransactionAt: now.Add(-90 * time.Minute)}
+	o, ok := volumeOutage(busy, act, window, params, now)
+	require.True(t, ok, "90 silent minutes where 90 transactions were expected")
+	assert.Equal(t, 90*time.Minute, o.Gap)
+	assert.Equal(t, 90*time.Minute, o.Window)
+	assert.InDelta(t, 90, o.Expected, 1e-9)
+	assert.Equal(t, 0, o.Observed)
+	assert.InDelta(t, 4500, o.LostTpvUSD, 1e-6)
+
+	act = repository.RecentActivity{LastTransactionAt: now.Add(-time.Minute), RecentCount: 5, RecentTpvUSD: 250}
+	o, ok = volumeOutage(busy, act, window, params, now)
+	require.True(t, ok, "5 transactions where 30 were expected")
+	assert.Equal(t, window, o.Window)
+	assert.InDelta(t, 1250, o.LostTpvUSD, 1e-6)
+
+	act = repository.RecentActivity{LastTransactionAt: now.Add(-time.Minute), RecentCount: 27}
+	_, ok = volumeOutage(busy, act, window, params, now)
+	assert.False(t, ok, "normal traffic")
+
+	quiet := flatProfile(2, 100)
+	act = repository.RecentActivity{LastTransactionAt: now.Add(-40 * time.Minute)}
+	_, ok = volumeOutage(quiet, act, window, params, now)
+	assert.False(t, ok, "quiet methods are not expected to be busy")
+}
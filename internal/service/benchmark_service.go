@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+type BenchmarkService struct {
+	repo *repository.BenchmarkRepository
+}
+
+func NewBenchmarkService(repo *repository.BenchmarkRepository) *BenchmarkService {
+	return &BenchmarkService{repo: repo}
+}
+
+const (
+	BenchmarkApprovalRate       = "approval_rate"
+	BenchmarkAvgTicket          = "avg_ticket_usd"
+	BenchmarkGrowth             = "growth_pct"
+	BenchmarkCostPerApprovedTxn = "cost_per_approved_txn_usd"
+)
+
+var benchmarkMetrics = []string{BenchmarkApprovalRate, BenchmarkAvgTicket, BenchmarkGrowth, BenchmarkCostPerApprovedTxn}
+
+// PeerPercentiles holds the percentile rank of a value within each peer
+// group: the share of peers with a lower value, ties counting half. A nil rank
+// means the method has no value for the metric (e.g. growth with no prior
+// volume).
+type PeerPercentiles struct {
+	SameTypeSameCountry  *float64 `json:"same_type_same_country"`
+	SameTypeAllCountries *float64 `json:"same_type_all_countries"`
+	AllMethods           *float64 `json:"all_methods"`
+}
+
+type PeerGroupSizes struct {
+	SameTypeSameCountry  int `json:"same_type_same_country"`
+	SameTypeAllCountries int `json:"same_type_all_countries"`
+	AllMethods           int `json:"all_methods"`
+}
+
+type BenchmarkResult struct {
+	PaymentMethodCode string                     `json:"payment_method_code"`
+	PaymentMethodName string                     `json:"payment_method_name"`
+	PaymentMethodType string                     `json:"payment_method_type"`
+	CountryCode       string                     `json:"country_code"`
+	Values            map[string]*float64        `json:"values"`
+	Percentiles       map[string]PeerPercentiles `json:"percentiles"`
+	PeerGroupSizes    PeerGroupSizes             `json:"peer_group_sizes"`
+}
+
+func (s *BenchmarkService) GetBenchmarks(ctx context.Context, f model.AnalyticsFilter, growthDays int) ([]BenchmarkResult, error) {
+	if growthDays < 1 {
+		growthDays = 30
+	}
+
+	rows, err := s.repo.GetBenchmarkData(ctx, f.DateFrom, f.DateTo, growthDays)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]map[string]*float64, len(rows))
+	for i, r := range rows {
+		values[i] = benchmarkValues(r)
+	}
+
+	// Peer groups are built from every row so that the filter lists
+	// narrows the output without shrinking the comparison set.
+	type countryTypeKey struct{ cc, pmType string }
+	countryType := make(map[countryTypeKey][]int)
+	byType := make(map[string][]int)
+	all := make([]int, len(rows))
+	for i, r := range rows {
+		k := countryTypeKey{r.CountryCode, r.PaymentMethodType}
+		countryType[k] = append(countryType[k], i)
+		byType[r.PaymentMethodType] = append(byType[r.PaymentMethodType], i)
+		all[i] = i
+	}
+
+	var results []BenchmarkResult
+	for i, r := range rows {
+		if !f.Matches(r.CountryCode, r.PaymentMethodCode, r.PaymentMethodType) {
+			continue
+		}
+
+		sameCountry := countryType[countryTypeKey{r.CountryCode, r.PaymentMethodType}]
+		sameType := byType[r.PaymentMethodType]
+
+		percentiles := make(map[string]PeerPercentiles, len(benchmarkMetrics))
+		for _, metric := range benchmarkMetrics {
+			v := values[i][metric]
+			percentiles[metric] = PeerPercentiles{
+				SameTypeSameCountry:  percentileRank(v, peerValues(values, sameCountry, metric)),
+				SameTypeAllCountries: percentileRank(v, peerValues(values, sameType, metric)),
+				AllMethods:           percentileRank(v, peerValues(values, all, metric)),
+			}
+		}
+
+		results = append(results, BenchmarkResult{
+			PaymentMethodCode: r.PaymentMethodCode,
+			PaymentMethodName: r.PaymentMethodName,
+			PaymentMethodType: r.PaymentMethodType,
+			CountryCode:       r.CountryCode,
+			Values:            values[i],
+			Percentiles:       percentiles,
+			PeerGroupSizes: PeerGroupSizes{
+				SameTypeSameCountry:  len(sameCountry),
+				SameTypeAllCountries: len(sameType),
+				AllMethods:           len(all),
+			},
+		})
+	}
+
+	return results, nil
+}
+
+func benchmarkValues(r repository.BenchmarkRow) map[string]*float64 {
+	round := func(v float64) *float64 {
+		v = math.Round(v*100) / 100
+		return &v
+	}
+
+	vals := map[string]*float64{
+		BenchmarkApprovalRate:       nil,
+		BenchmarkAvgTicket:          round(r.AvgTicketUSD),
+		BenchmarkGrowth:             nil,
+		BenchmarkCostPerApprovedTxn: nil,
+	}
+	if r.TotalCount > 0 {
+		vals[BenchmarkApprovalRate] = round(float64(r.ApprovedCount) / float64(r.TotalCount) * 100)
+	}
+	if r.PriorTPV > 0 {
+		vals[BenchmarkGrowth] = round((r.RecentTPV - r.PriorTPV) / r.PriorTPV * 100)
+	}
+	if r.ApprovedCount > 0 {
+		// Same cost model as ROIService.
+		totalCost := (r.MonthsInRange * r.MonthlyFixedCost) +
+			(float64(r.TotalCount) * r.PerTransactionCost) +
+			(r.ApprovedTPV * r.PercentageFee)
+		vals[BenchmarkCostPerApprovedTxn] = round(totalCost / float64(r.ApprovedCount))
+	}
+	return vals
+}
+
+func peerValues(values []map[string]*float64, members []int, metric string) []float64 {
+	var out []float64
+	for _, idx := range members {
+		if v := values[idx][metric]; v != nil {
+			out = append(out, *v)
+		}
+	}
+	return out
+}
+
+func percentileRank(v *float64, peers []float64) *float64 {
+	if v == nil || len(peers) == 0 {
+		return nil
+	}
+	var below, equal float64
+	for _, p := range peers {
+		switch {
+		case p < *v:
+			below++
+		case p == *v:
+			equal++
+		}
+	}
+	rank := math.Round((below+0.5*equal)/float64(len(peers))*1000) / 10
+	return &rank
+}
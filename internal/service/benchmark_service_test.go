@@ -0,0 +1,71 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func TestPercentileRank(t *testing.T) {
+	peers := []float64{10, 20, 20, 30, 40}
+	tests := []struct {
+		name  string
+		v     *float64
+		peers []float64
+		want  *float64
+	}{
+		{name: "below every peer", v: floatPtr(5), peers: peers, want: floatPtr(0)},
+		{name: "above every peer", v: floatPtr(50), peers: peers, want: floatPtr(100)},
+		{name: "ties count half", v: floatPtr(20), peers: peers, want: floatPtr(40)},
+		{name: "rounds to one decimal", v: floatPtr(20), peers: []float64{10, 20, 30}, want: floatPtr(50)},
+		{name: "thirds", v: floatPtr(15), peers: []float64{10, 20, 30}, want: floatPtr(33.3)},
+		{name: "missing value", v: nil, peers: peers, want: nil},
+		{name: "no peers", v: floatPtr(20), peers: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, percentileRank(tt.v, tt.peers))
+		})
+	}
+}
+
+func TestBenchmarkValues(t *testing.T) {
+	tests := []struct {
+		name string
+		row  repository.BenchmarkRow
+		want map[string]*float64
+	}{
+		{
+			name: "full row",
+			row: repository.BenchmarkRow{
+				TotalCount: 200, ApprovedCount: 170, ApprovedTPV: 17000, AvgTicketUSD: 100.004,
+				MonthsInRange: 2, RecentTPV: 9000, PriorTPV: 8000,
+				MonthlyFixedCost: 100, PerTransactionCost: 0.5, PercentageFee: 0.01,
+			},
+			want: map[string]*float64{
+				BenchmarkApprovalRate: floatPtr(85),
+				BenchmarkAvgTicket:    floatPtr(100),
+				BenchmarkGrowth:       floatPtr(12.5),
+				// 2*100 + 200*0.5 + 17000*0.01 = 470 over 170 approvals.
+				BenchmarkCostPerApprovedTxn: floatPtr(2.76),
+			},
+		},
+		{
+			name: "no transactions, prior TPV or approvals",
+			row:  repository.BenchmarkRow{RecentTPV: 500},
+			want: map[string]*float64{
+				BenchmarkApprovalRate:       nil,
+				BenchmarkAvgTicket:          floatPtr(0),
+				BenchmarkGrowth:             nil,
+				BenchmarkCostPerApprovedTxn: nil,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, benchmarkValues(tt.row))
+		})
+	}
+}
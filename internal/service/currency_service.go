@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+// CurrencyLocal requests each transaction's original currency, read from the
+// amount column rather than converted from USD.
+const CurrencyLocal = "LOCAL"
+
+var (
+	ErrUnknownCurrency      = errors.New("currency is not configured")
+	ErrLocalCurrencyCountry = errors.New("local currency requires a single country")
+)
+
+type CurrencyService struct {
+	repo *repository.FxRepository
+}
+
+func NewCurrencyService(repo *repository.FxRepository) *CurrencyService {
+	return &CurrencyService{repo: repo}
+}
+
+// FxConverter turns USD aggregates into another currency using the rate in
+// effect at a given time. A nil converter is the USD identity.
+type FxConverter struct {
+	Currency string
+	rates    []repository.FxRate
+}
+
+// NormalizeCurrency upper-cases a currency param; "" means USD.
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return "USD"
+	}
+	return currency
+}
+
+// Converter loads the rate history of currency. USD yields a nil converter.
+func (s *CurrencyService) Converter(ctx context.Context, currency string) (*FxConverter, error) {
+	currency = NormalizeCurrency(currency)
+	if currency == "USD" {
+		return nil, nil
+	}
+
+	rates, err := s.repo.ListRates(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCurrency, currency)
+	}
+	return &FxConverter{Currency: currency, rates: rates}, nil
+}
+
+// LocalConverter resolves the currency of a single country.
+func (s *CurrencyService) LocalConverter(ctx context.Context, country string) (*FxConverter, error) {
+	if country == "" {
+		return nil, ErrLocalCurrencyCountry
+	}
+	currency, err := s.repo.GetCountryCurrency(ctx, country)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: unknown country %s", ErrUnknownCurrency, country)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get country currency: %w", err)
+	}
+	return s.Converter(ctx, currency)
+}
+
+func (c *FxConverter) Code() string {
+	if c == nil {
+		return "USD"
+	}
+	return c.Currency
+}
+
+// FromUSD converts at the rate effective at `at`; before the first recorded
+// rate the earliest one applies.
+func (c *FxConverter) FromUSD(usd float64, at time.Time) float64 {
+	if c == nil {
+		return usd
+	}
+	rate := c.rates[0].RateToUSD
+	for _, r := range c.rates {
+		if r.EffectiveFrom.After(at) {
+			break
+		}
+		rate = r.RateToUSD
+	}
+	return round2(usd / rate)
+}
+
+// windowEnd is the instant whose rate applies to a whole date window.
+func windowEnd(dateTo string) time.Time {
+	if dateTo != "" {
+		if t, err := time.Parse(time.RFC3339, dateTo); err == nil {
+			return t
+		}
+		if t, err := time.Parse("2006-01-02", dateTo); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// ConvertMetrics rewrites the monetary fields of metric rows and the summary
+// into the converter's currency. In local mode TPV and ticket come from the
+// original amounts and only the USD-denominated cost is converted.
+func ConvertMetrics(results []MetricResult, summary *MetricsSummary, conv *FxConverter, local bool, dateTo string) {
+	if conv == nil {
+		return
+	}
+	at := windowEnd(dateTo)
+
+	summary.TotalTPVUSD = 0
+	for i := range results {
+		m := &results[i]
+		if local {
+			m.TpvUSD = m.tpvLocal
+			m.AvgTransactionValue = m.avgTransactionValueLocal
+		} else {
+			m.TpvUSD = conv.FromUSD(m.TpvUSD, at)
+			m.AvgTransactionValue = conv.FromUSD(m.AvgTransactionValue, at)
+		}
+		m.MonthlyCostUSD = conv.FromUSD(m.MonthlyCostUSD, at)
+		summary.TotalTPVUSD += m.TpvUSD
+	}
+	summary.TotalTPVUSD = round2(summary.TotalTPVUSD)
+}
+
+func ConvertROI(results []ROIResult, conv *FxConverter, dateTo string) {
+	if conv == nil {
+		return
+	}
+	at := windowEnd(dateTo)
+
+	for i := range results {
+		r := &results[i]
+		r.ApprovedTPVUSD = conv.FromUSD(r.ApprovedTPVUSD, at)
+		r.TotalCostUSD = conv.FromUSD(r.TotalCostUSD, at)
+		r.CostPerApprovedTxn = conv.FromUSD(r.CostPerApprovedTxn, at)
+	}
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func TestFxConverterFromUSD(t *testing.T) {
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	conv := &FxConverter{Currency: "BRL", rates: []repository.FxRate{
+		{Currency: "BRL", RateToUSD: 0.2, EffectiveFrom: jan},
+		{Currency: "BRL", RateToUSD: 0.25, EffectiveFrom: feb},
+	}}
+
+	tests := []struct {
+		name string
+		conv *FxConverter
+		usd  float64
+		at   time.Time
+		want float64
+	}{
+		{name: "nil converter keeps USD", conv: nil, usd: 12.345, at: feb, want: 12.345},
+		{name: "before the first rate uses the earliest", conv: conv, usd: 100, at: jan.AddDate(0, -3, 0), want: 500},
+		{name: "first rate", conv: conv, usd: 100, at: jan.AddDate(0, 0, 15), want: 500},
+		{name: "rate effective from its start", conv: conv, usd: 100, at: feb, want: 400},
+		{name: "latest rate after the last change", conv: conv, usd: 100, at: feb.AddDate(1, 0, 0), want: 400},
+		{name: "rounds to cents", conv: &FxConverter{Currency: "MXN", rates: []repository.FxRate{
+			{Currency: "MXN", RateToUSD: 0.058, EffectiveFrom: jan},
+		}}, usd: 10, at: feb, want: 172.41},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.conv.FromUSD(tt.usd, tt.at))
+		})
+	}
+	assert.Equal(t, "USD", (*FxConverter)(nil).Code())
+	assert.Equal(t, "BRL", conv.Code())
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/filterexpr"
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+const (
+	InsightTypeCustomRule = "custom_rule"
+
+	defaultCustomRuleLookbackDays = 30
+	maxCustomRuleLookbackDays     = 730
+)
+
+var ErrInvalidCustomRule = errors.New("invalid custom insight rule")
+
+// customRuleFields are the metric row fields a custom rule can read, named
+// like the metrics filter fields, with type and country as short aliases.
+var customRuleFields = map[string]filterexpr.Kind{
+	"payment_method_code":       filterexpr.String,
+	"payment_method_type":       filterexpr.String,
+	"type":                      filterexpr.String,
+	"country_code":              filterexpr.String,
+	"country":                   filterexpr.String,
+	"activity_status":           filterexpr.String,
+	"transaction_count":         filterexpr.Number,
+	"approved_count":            filterexpr.Number,
+	"declined_count":            filterexpr.Number,
+	"refunded_count":            filterexpr.Number,
+	"tpv_usd":                   filterexpr.Number,
+	"approval_rate":             filterexpr.Number,
+	"avg_transaction_value_usd": filterexpr.Number,
+	"revenue_contribution_pct":  filterexpr.Number,
+	"monthly_cost_usd":          filterexpr.Number,
+	"cost_efficiency_ratio":     filterexpr.Number,
+}
+
+func metricRowValues(m repository.MetricRow) filterexpr.Row {
+	return filterexpr.Row{
+		"payment_method_code":       filterexpr.Str(m.PaymentMethodCode),
+		"payment_method_type":       filterexpr.Str(m.PaymentMethodType),
+		"type":                      filterexpr.Str(m.PaymentMethodType),
+		"country_code":              filterexpr.Str(m.CountryCode),
+		"country":                   filterexpr.Str(m.CountryCode),
+		"activity_status":           filterexpr.Str(m.ActivityStatus),
+		"transaction_count":         filterexpr.Num(float64(m.TransactionCount)),
+		"approved_count":            filterexpr.Num(float64(m.ApprovedCount)),
+		"declined_count":            filterexpr.Num(float64(m.DeclinedCount)),
+		"refunded_count":            filterexpr.Num(float64(m.RefundedCount)),
+		"tpv_usd":                   filterexpr.Num(m.TpvUSD),
+		"approval_rate":             filterexpr.Num(m.ApprovalRate),
+		"avg_transaction_value_usd": filterexpr.Num(m.AvgTransactionValue),
+		"revenue_contribution_pct":  filterexpr.Num(m.RevenueContribution),
+		"monthly_cost_usd":          filterexpr.Num(m.MonthlyCostUSD),
+		"cost_efficiency_ratio":     filterexpr.Num(m.CostEfficiencyRatio),
+	}
+}
+
+// templateData is what description templates render: every rule field by
+// name plus the method name and the rule name.
+func templateData(rule model.CustomInsightRule, m repository.MetricRow, values filterexpr.Row) map[string]any {
+	data := map[string]any{
+		"payment_method_name": m.PaymentMethodName,
+		"rule_name":           rule.Name,
+	}
+	for name, v := range values {
+		if v.IsString {
+			data[name] = v.Str
+		} else {
+			data[name] = v.Num
+		}
+	}
+	return data
+}
+
+// compiledRule is a stored rule ready to evaluate.
+type compiledRule struct {
+	rule        model.CustomInsightRule
+	program     *filterexpr.Program
+	severity    SeverityMapping
+	description *template.Template
+}
+
+// compileCustomRule checks the expression, score field, severities and
+// description template. Errors wrap ErrInvalidCustomRule.
+func compileCustomRule(rule model.CustomInsightRule) (*compiledRule, error) {
+	program, err := filterexpr.NewProgram(rule.Expression, customRuleFields)
+	if err != nil {
+		return nil, fmt.Errorf("%w: expression: %v", ErrInvalidCustomRule, err)
+	}
+	if kind, ok := customRuleFields[rule.ScoreField]; !ok || kind != filterexpr.Number {
+		return nil, fmt.Errorf("%w: score_field %q must be a numeric metric field", ErrInvalidCustomRule, rule.ScoreField)
+	}
+	if rule.LookbackDays < 1 || rule.LookbackDays > maxCustomRuleLookbackDays {
+		return nil, fmt.Errorf("%w: lookback_days must be between 1 and %d", ErrInvalidCustomRule, maxCustomRuleLookbackDays)
+	}
+
+	severity := SeverityMapping{Inverted: rule.Inverted, Default: rule.DefaultSeverity}
+	if !validSeverity(rule.DefaultSeverity) {
+		return nil, fmt.Errorf("%w: default_severity must be HIGH, MEDIUM or LOW", ErrInvalidCustomRule)
+	}
+	for _, t := range rule.SeverityTiers {
+		if !validSeverity(t.Severity) {
+			return nil, fmt.Errorf("%w: severity tier %q must be HIGH, MEDIUM or LOW", ErrInvalidCustomRule, t.Severity)
+		}
+		severity.Tiers = append(severity.Tiers, SeverityTier{Severity: t.Severity, Threshold: t.Threshold})
+	}
+
+	cr := &compiledRule{rule: rule, program: program, severity: severity}
+	if rule.DescriptionTemplate != "" {
+		cr.description, err = parseRestrictedTemplate("description", rule.DescriptionTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: description_template: %v", ErrInvalidCustomRule, err)
+		}
+		// Render a sample so unknown fields fail here rather than at
+		// detection time.
+		if _, err := cr.describe(repository.MetricRow{}, metricRowValues(repository.MetricRow{})); err != nil {
+			return nil, fmt.Errorf("%w: description_template: %v", ErrInvalidCustomRule, err)
+		}
+	}
+	return cr, nil
+}
+
+func validSeverity(s string) bool {
+	return s == SeverityHigh || s == SeverityMedium || s == SeverityLow
+}
+
+func (cr *compiledRule) describe(m repository.MetricRow, values filterexpr.Row) (string, error) {
+	if cr.description == nil {
+		return fmt.Sprintf("%s in %s matches rule %q (%s = %.2f)", m.PaymentMethodName, m.CountryCode,
+			cr.rule.Name, cr.rule.ScoreField, values[cr.rule.ScoreField].Num), nil
+	}
+	var sb strings.Builder
+	if err := cr.description.Execute(&sb, templateData(cr.rule, m, values)); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// evaluate returns one insight per row that satisfies the rule.
+func (cr *compiledRule) evaluate(rows []repository.MetricRow, now time.Time) ([]Insight, error) {
+	var insights []Insight
+	for _, m := range rows {
+		values := metricRowValues(m)
+		if !cr.program.Eval(values) {
+			continue
+		}
+
+		score := values[cr.rule.ScoreField].Num
+		severity := cr.severity.Map(score)
+		threshold := 0.0
+		for _, t := range cr.severity.Tiers {
+			if t.Severity == severity {
+				threshold = t.Threshold
+				break
+			}
+		}
+		description, err := cr.describe(m, values)
+		if err != nil {
+			return nil, fmt.Errorf("render description of custom rule %s: %w", cr.rule.Name, err)
+		}
+		action := cr.rule.RecommendedAction
+		if action == "" {
+			action = fmt.Sprintf("Review %s in %s against the %q rule.", m.PaymentMethodName, m.CountryCode, cr.rule.Name)
+		}
+
+		insights = append(insights, Insight{
+			InsightID:         hashID(InsightTypeCustomRule, cr.rule.ID, m.PaymentMethodCode, m.CountryCode),
+			Type:              InsightTypeCustomRule,
+			Severity:          severity,
+			PaymentMethodCode: m.PaymentMethodCode,
+			PaymentMethodName: m.PaymentMethodName,
+			CountryCode:       m.CountryCode,
+			TriggeringMetric:  cr.rule.ScoreField,
+			MetricValue:       score,
+			Threshold:         threshold,
+			Description:       description,
+			RecommendedAction: action,
+			SupportingData: map[string]interface{}{
+				"rule_id":             cr.rule.ID,
+				"rule_name":           cr.rule.Name,
+				"expression":          cr.rule.Expression,
+				"payment_method_type": m.PaymentMethodType,
+				"transaction_count":   m.TransactionCount,
+				"approval_rate":       m.ApprovalRate,
+				"tpv_usd":             m.TpvUSD,
+			},
+			EstimatedImpactUSD: customRuleImpact(m.TpvUSD, cr.rule.LookbackDays),
+			Thresholds:         map[string]float64{"lookback_days": float64(cr.rule.LookbackDays)},
+			GeneratedAt:        now,
+		})
+	}
+	return insights, nil
+}
+
+// CustomRuleService stores analyst-defined insight rules. The custom_rule
+// detector evaluates the enabled ones on every detection run.
+type CustomRuleService struct {
+	repo *repository.CustomRuleRepository
+}
+
+func NewCustomRuleService(repo *repository.CustomRuleRepository) *CustomRuleService {
+	return &CustomRuleService{repo: repo}
+}
+
+// normalizeCustomRule fills defaults and validates the rule.
+func normalizeCustomRule(rule model.CustomInsightRule) (model.CustomInsightRule, error) {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		return rule, fmt.Errorf("%w: name is required", ErrInvalidCustomRule)
+	}
+	if rule.DefaultSeverity == "" {
+		rule.DefaultSeverity = SeverityMedium
+	}
+	if rule.LookbackDays == 0 {
+		rule.LookbackDays = defaultCustomRuleLookbackDays
+	}
+	if rule.SeverityTiers == nil {
+		rule.SeverityTiers = []model.CustomRuleTier{}
+	}
+	if _, err := compileCustomRule(rule); err != nil {
+		return rule, err
+	}
+	return rule, nil
+}
+
+func (s *CustomRuleService) CreateRule(ctx context.Context, rule model.CustomInsightRule) (*model.CustomInsightRule, error) {
+	rule, err := normalizeCustomRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.CreateRule(ctx, rule)
+}
+
+// UpdateRule returns pgx.ErrNoRows when the rule does not exist.
+func (s *CustomRuleService) UpdateRule(ctx context.Context, rule model.CustomInsightRule) (*model.CustomInsightRule, error) {
+	rule, err := normalizeCustomRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.UpdateRule(ctx, rule)
+}
+
+func (s *CustomRuleService) ListRules(ctx context.Context) ([]model.CustomInsightRule, error) {
+	return s.repo.ListRules(ctx, false)
+}
+
+func (s *CustomRuleService) GetRule(ctx context.Context, id string) (*model.CustomInsightRule, error) {
+	return s.repo.GetRule(ctx, id)
+}
+
+func (s *CustomRuleService) DeleteRule(ctx context.Context, id string) error {
+	return s.repo.DeleteRule(ctx, id)
+}
+
+// customRuleDetector evaluates every enabled custom rule over the metrics of
+// its lookback window, ending at the scope's evaluation time.
+type customRuleDetector struct {
+	rules   *repository.CustomRuleRepository
+	metrics *repository.MetricsRepository
+}
+
+func (d *customRuleDetector) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	rules, err := d.rules.ListRules(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rules sharing a lookback window share one metrics query. A stored rule
+	// that no longer compiles or renders is skipped, so one bad rule does
+	// not fail the whole detection run.
+	rowsByLookback := make(map[int][]repository.MetricRow)
+	var insights []Insight
+	for _, rule := range rules {
+		cr, err := compileCustomRule(rule)
+		if err != nil {
+			log.Warn().Err(err).Str("rule_id", rule.ID).Str("rule", rule.Name).Msg("skipping invalid custom insight rule")
+			continue
+		}
+		rows, ok := rowsByLookback[rule.LookbackDays]
+		if !ok {
+			f := run.Scope.Filter
+			f.Expr = ""
+			f.DateFrom = run.Scope.Now.AddDate(0, 0, -rule.LookbackDays).Format(time.RFC3339)
+			f.DateTo = run.Scope.Now.Format(time.RFC3339)
+			rows, err = d.metrics.ListMetrics(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+			rowsByLookback[rule.LookbackDays] = rows
+		}
+		found, err := cr.evaluate(rows, run.Scope.Now)
+		if err != nil {
+			log.Warn().Err(err).Str("rule_id", rule.ID).Str("rule", rule.Name).Msg("skipping custom insight rule")
+			continue
+		}
+		insights = append(insights, found...)
+	}
+	return insights, nil
+}
+
+// EvidenceScope covers the rule's lookback window, following the score field
+// when it has a weekly series.
+func (d *customRuleDetector) EvidenceScope(in Insight) EvidenceScope {
+	days := int(in.Thresholds["lookback_days"])
+	if days <= 0 {
+		days = defaultCustomRuleLookbackDays
+	}
+	metric := EvidenceMetricApprovalRate
+	switch in.TriggeringMetric {
+	case "transaction_count":
+		metric = EvidenceMetricTransactions
+	case "tpv_usd":
+		metric = EvidenceMetricTPV
+	}
+	return EvidenceScope{
+		From:   in.GeneratedAt.AddDate(0, 0, -days),
+		To:     in.GeneratedAt,
+		Metric: metric,
+		Peers:  PeerGroupType,
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func walletRule() model.CustomInsightRule {
+	return model.CustomInsightRule{
+		ID:         "rule-1",
+		Name:       "weak wallets",
+		Expression: `type == "WALLET" && approval_rate < 85 && tpv_usd > 10000`,
+		ScoreField: "approval_rate",
+		Inverted:   true,
+		SeverityTiers: []model.CustomRuleTier{
+			{Severity: SeverityHigh, Threshold: 70},
+			{Severity: SeverityMedium, Threshold: 80},
+		},
+		DefaultSeverity:     SeverityLow,
+		LookbackDays:        30,
+		DescriptionTemplate: `{{.payment_method_name}} in {{.country}} approves {{printf "%.1f" .approval_rate}}% ({{.rule_name}})`,
+	}
+}
+
+func TestCustomRuleEvaluate(t *testing.T) {
+	cr, err := compileCustomRule(walletRule())
+	require.NoError(t, err)
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	rows := []repository.MetricRow{
+		{PaymentMethodCode: "MERCADOPAGO", PaymentMethodName: "Mercado Pago", PaymentMethodType: "WALLET", CountryCode: "AR", ApprovalRate: 65, TpvUSD: 50000},
+		{PaymentMethodCode: "NEQUI", PaymentMethodName: "Nequi", PaymentMethodType: "WALLET", CountryCode: "CO", ApprovalRate: 82, TpvUSD: 12000},
+		{PaymentMethodCode: "YAPE", PaymentMethodName: "Yape", PaymentMethodType: "WALLET", CountryCode: "PE", ApprovalRate: 60, TpvUSD: 900},
+		{PaymentMethodCode: "VISA", PaymentMethodName: "Visa", PaymentMethodType: "CARD", CountryCode: "AR", ApprovalRate: 60, TpvUSD: 90000},
+	}
+	insights, err := cr.evaluate(rows, now)
+	require.NoError(t, err)
+	require.Len(t, insights, 2)
+
+	in := insights[0]
+	assert.Equal(t, InsightTypeCustomRule, in.Type)
+	assert.Equal(t, "MERCADOPAGO", in.PaymentMethodCode)
+	assert.Equal(t, SeverityHigh, in.Severity)
+	assert.Equal(t, "approval_rate", in.TriggeringMetric)
+	assert.Equal(t, 65.0, in.MetricValue)
+	assert.Equal(t, 70.0, in.Threshold)
+	assert.Equal(t, "Mercado Pago in AR approves 65.0% (weak wallets)", in.Description)
+	assert.Equal(t, "rule-1", in.SupportingData["rule_id"])
+	assert.Equal(t, 30.0, in.Thresholds["lookback_days"])
+	assert.Equal(t, now, in.GeneratedAt)
+
+	assert.Equal(t, SeverityLow, insights[1].Severity, "82% clears both tiers")
+	assert.Equal(t, 0.0, insights[1].Threshold)
+
+	// Insight ids are stable per rule and method-country.
+	other := walletRule()
+	other.ID = "rule-2"
+	cr2, err := compileCustomRule(other)
+	require.NoError(t, err)
+	again, err := cr2.evaluate(rows[:1], now)
+	require.NoError(t, err)
+	assert.NotEqual(t, in.InsightID, again[0].InsightID)
+}
+
+func TestCustomRuleDefaultDescription(t *testing.T) {
+	rule := walletRule()
+	rule.DescriptionTemplate = ""
+	cr, err := compileCustomRule(rule)
+	require.NoError(t, err)
+
+	insights, err := cr.evaluate([]repository.MetricRow{
+		{PaymentMethodCode: "NEQUI", PaymentMethodName: "Nequi", PaymentMethodType: "WALLET", CountryCode: "CO", ApprovalRate: 82, TpvUSD: 12000},
+	}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, insights, 1)
+	assert.Equal(t, `Nequi in CO matches rule "weak wallets" (approval_rate = 82.00)`, insights[0].Description)
+	assert.Contains(t, insights[0].RecommendedAction, "weak wallets")
+}
+
+func TestNormalizeCustomRule(t *testing.T) {
+	rule, err := normalizeCustomRule(model.CustomInsightRule{
+		Name:       " wallets ",
+		Expression: "type = 'WALLET'",
+		ScoreField: "tpv_usd",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "wallets", rule.Name)
+	assert.Equal(t, SeverityMedium, rule.DefaultSeverity)
+	assert.Equal(t, defaultCustomRuleLookbackDays, rule.LookbackDays)
+	assert.NotNil(t, rule.SeverityTiers)
+
+	invalid := map[string]func(*model.CustomInsightRule){
+		"blank name":         func(r *model.CustomInsightRule) { r.Name = " " },
+		"unknown field":      func(r *model.CustomInsightRule) { r.Expression = "merchant_id = 'x'" },
+		"sql":                func(r *model.CustomInsightRule) { r.Expression = "approval_rate < 80; DROP TABLE transactions" },
+		"text score field":   func(r *model.CustomInsightRule) { r.ScoreField = "country_code" },
+		"unknown score":      func(r *model.CustomInsightRule) { r.ScoreField = "profit" },
+		"bad tier":           func(r *model.CustomInsightRule) { r.SeverityTiers[0].Severity = "CRITICAL" },
+		"bad default":        func(r *model.CustomInsightRule) { r.DefaultSeverity = "URGENT" },
+		"long lookback":      func(r *model.CustomInsightRule) { r.LookbackDays = 1000 },
+		"template syntax":    func(r *model.CustomInsightRule) { r.DescriptionTemplate = "{{.approval_rate" },
+		"template bad field": func(r *model.CustomInsightRule) { r.DescriptionTemplate = "{{.merchant_id}}" },
+		"template range": func(r *model.CustomInsightRule) {
+			r.DescriptionTemplate = "{{range 1000000}}{{range 1000000}}x{{end}}{{end}}"
+		},
+		"template with":     func(r *model.CustomInsightRule) { r.DescriptionTemplate = "{{with .country}}{{.}}{{end}}" },
+		"template define":   func(r *model.CustomInsightRule) { r.DescriptionTemplate = `{{define "a"}}x{{end}}{{template "a"}}` },
+		"template variable": func(r *model.CustomInsightRule) { r.DescriptionTemplate = "{{$x := .country}}{{$x}}" },
+		"template function": func(r *model.CustomInsightRule) { r.DescriptionTemplate = "{{len .country}}" },
+		"template pipeline": func(r *model.CustomInsightRule) { r.DescriptionTemplate = `{{.country | printf "%s"}}` },
+		"printf width": func(r *model.CustomInsightRule) {
+			r.DescriptionTemplate = `{{printf "%999999999d" .transaction_count}}`
+		},
+		"template length": func(r *model.CustomInsightRule) {
+			r.DescriptionTemplate = strings.Repeat("x", maxTemplateLen+1)
+		},
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			rule := walletRule()
+			mutate(&rule)
+			_, err := normalizeCustomRule(rule)
+			assert.True(t, errors.Is(err, ErrInvalidCustomRule), "got %v", err)
+		})
+	}
+}
+
+func TestCustomRuleEvidenceScope(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	scope := (&customRuleDetector{}).EvidenceScope(Insight{
+		TriggeringMetric: "tpv_usd",
+		Thresholds:       map[string]float64{"lookback_days": 14},
+		GeneratedAt:      now,
+	})
+	assert.Equal(t, now.AddDate(0, 0, -14), scope.From)
+	assert.Equal(t, EvidenceMetricTPV, scope.Metric)
+	assert.Equal(t, PeerGroupType, scope.Peers)
+}
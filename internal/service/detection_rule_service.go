@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+// ActivityRuleSet holds the 90-day transaction thresholds behind the metrics
+// activity_status. They are read in SQL through detection_param().
+const ActivityRuleSet = "activity_status"
+
+var activityParams = []ParamSpec{
+	{Name: "active_min_txns", Description: "Minimum 90-day transactions for ACTIVE", Default: repository.DefaultActiveMinTxns, Min: bound(0)},
+	{Name: "low_activity_min_txns", Description: "Minimum 90-day transactions for LOW_ACTIVITY", Default: repository.DefaultLowActivityMinTxns, Min: bound(0)},
+}
+
+var ErrInvalidDetectionRule = errors.New("invalid detection rule")
+
+// paymentMethodTypes are the types a rule may be scoped to, as allowed by
+// the detection_rules check constraint.
+var paymentMethodTypes = map[string]bool{"CARD": true, "CASH": true, "BANK_TRANSFER": true, "WALLET": true, "BNPL": true}
+
+type DetectionRuleService struct {
+	repo     *repository.DetectionRuleRepository
+	pmRepo   *repository.PaymentMethodRepository
+	registry *DetectorRegistry
+}
+
+func NewDetectionRuleService(repo *repository.DetectionRuleRepository, pmRepo *repository.PaymentMethodRepository, registry *DetectorRegistry) *DetectionRuleService {
+	return &DetectionRuleService{repo: repo, pmRepo: pmRepo, registry: registry}
+}
+
+// EffectiveParams is the merged parameter set for one scope, with the id of
+// the rule each value came from ("default" for registration defaults).
+type EffectiveParams struct {
+	RuleSet string            `json:"rule_set"`
+	Params  DetectorParams    `json:"params"`
+	Sources map[string]string `json:"sources"`
+}
+
+// RuleResolver merges detection rules over parameter defaults.
+type RuleResolver struct {
+	rules map[string][]model.DetectionRule
+}
+
+// NewRuleResolver groups rules by set, ordered from least to most specific.
+func NewRuleResolver(rules []model.DetectionRule) *RuleResolver {
+	r := &RuleResolver{rules: make(map[string][]model.DetectionRule)}
+	for _, rule := range rules {
+		r.rules[rule.RuleSet] = append(r.rules[rule.RuleSet], rule)
+	}
+	for _, set := range r.rules {
+		sort.SliceStable(set, func(i, j int) bool {
+			return set[i].Specificity() < set[j].Specificity()
+		})
+	}
+	return r
+}
+
+// Resolve applies every matching rule over defaults key by key, so the most
+// specific rule that sets a parameter wins.
+func (r *RuleResolver) Resolve(ruleSet string, defaults DetectorParams, country, pmType, paymentMethod string) EffectiveParams {
+	out := EffectiveParams{
+		RuleSet: ruleSet,
+		Params:  make(DetectorParams, len(defaults)),
+		Sources: make(map[string]string, len(defaults)),
+	}
+	for k, v := range defaults {
+		out.Params[k] = v
+		out.Sources[k] = "default"
+	}
+	if r == nil {
+		return out
+	}
+	for _, rule := range r.rules[ruleSet] {
+		if !rule.Matches(country, pmType, paymentMethod) {
+			continue
+		}
+		for k, v := range rule.Params {
+			out.Params[k] = v
+			out.Sources[k] = rule.ID
+		}
+	}
+	return out
+}
+
+// Resolver loads every rule once for a detection run.
+func (s *DetectionRuleService) Resolver(ctx context.Context) (*RuleResolver, error) {
+	rules, err := s.repo.ListRules(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return NewRuleResolver(rules), nil
+}
+
+// ProposedResolver loads every rule with proposed in place of the stored rule
+// for its scope, as SaveRule would leave them, without saving it.
+func (s *DetectionRuleService) ProposedResolver(ctx context.Context, proposed model.DetectionRule) (*RuleResolver, error) {
+	if err := s.validate(ctx, proposed); err != nil {
+		return nil, err
+	}
+	rules, err := s.repo.ListRules(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return NewRuleResolver(replaceRule(rules, proposed)), nil
+}
+
+// replaceRule swaps the rule with proposed's set and scope for proposed, or
+// adds it.
+func replaceRule(rules []model.DetectionRule, proposed model.DetectionRule) []model.DetectionRule {
+	out := make([]model.DetectionRule, 0, len(rules)+1)
+	for _, r := range rules {
+		if r.RuleSet == proposed.RuleSet && r.CountryCode == proposed.CountryCode &&
+			r.PaymentMethodType == proposed.PaymentMethodType && r.PaymentMethodCode == proposed.PaymentMethodCode {
+			continue
+		}
+		out = append(out, r)
+	}
+	return append(out, proposed)
+}
+
+func (s *DetectionRuleService) ListRules(ctx context.Context, ruleSet string) ([]model.DetectionRule, error) {
+	return s.repo.ListRules(ctx, ruleSet)
+}
+
+// GetRule returns the rule with its version history, newest first.
+func (s *DetectionRuleService) GetRule(ctx context.Context, id string) (*model.DetectionRule, []model.DetectionRuleVersion, error) {
+	rule, err := s.repo.GetRule(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	versions, err := s.repo.ListVersions(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rule, versions, nil
+}
+
+// Effective resolves the parameters a detector would use for one scope.
+func (s *DetectionRuleService) Effective(ctx context.Context, ruleSet, country, pmType, paymentMethod string) (EffectiveParams, error) {
+	specs, _, err := s.ruleSetSpec(ruleSet)
+	if err != nil {
+		return EffectiveParams{}, err
+	}
+	resolver, err := s.Resolver(ctx)
+	if err != nil {
+		return EffectiveParams{}, err
+	}
+	return resolver.Resolve(ruleSet, defaultParams(specs), country, pmType, paymentMethod), nil
+}
+
+// ParamsFunc loads the rules once and returns a resolver for the parameters
+// of ruleSet per row.
+func (s *DetectionRuleService) ParamsFunc(ctx context.Context, ruleSet string) (func(country, pmType, paymentMethod string) DetectorParams, error) {
+	specs, _, err := s.ruleSetSpec(ruleSet)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := s.Resolver(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defaults := defaultParams(specs)
+	return func(country, pmType, paymentMethod string) DetectorParams {
+		return resolver.Resolve(ruleSet, defaults, country, pmType, paymentMethod).Params
+	}, nil
+}
+
+// SaveRule creates or replaces the rule for the scope of rule, bumping its
+// version.
+func (s *DetectionRuleService) SaveRule(ctx context.Context, rule model.DetectionRule, note string) (*model.DetectionRule, error) {
+	if err := s.validate(ctx, rule); err != nil {
+		return nil, err
+	}
+	return s.repo.UpsertRule(ctx, rule, note)
+}
+
+func (s *DetectionRuleService) validate(ctx context.Context, rule model.DetectionRule) error {
+	specs, severity, err := s.ruleSetSpec(rule.RuleSet)
+	if err != nil {
+		return err
+	}
+	if len(rule.Params) == 0 {
+		return fmt.Errorf("%w: params must not be empty", ErrInvalidDetectionRule)
+	}
+
+	allowed := make(map[string]ParamSpec, len(specs))
+	for _, p := range specs {
+		allowed[p.Name] = p
+	}
+	for _, t := range severity.Tiers {
+		name := severityParam(t.Severity)
+		allowed[name] = ParamSpec{Name: name}
+	}
+	scoped := rule.CountryCode != "" || rule.PaymentMethodType != "" || rule.PaymentMethodCode != ""
+	for k, v := range rule.Params {
+		spec, ok := allowed[k]
+		if !ok {
+			return fmt.Errorf("%w: unknown parameter %q for %s", ErrInvalidDetectionRule, k, rule.RuleSet)
+		}
+		if spec.GlobalOnly && scoped {
+			return fmt.Errorf("%w: %s can only be set in the global rule", ErrInvalidDetectionRule, k)
+		}
+		if err := spec.check(v); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidDetectionRule, err)
+		}
+	}
+
+	if rule.PaymentMethodType != "" && !paymentMethodTypes[rule.PaymentMethodType] {
+		return fmt.Errorf("%w: unknown payment method type %s", ErrInvalidDetectionRule, rule.PaymentMethodType)
+	}
+
+	if rule.CountryCode != "" {
+		exists, err := s.pmRepo.CountryExists(ctx, rule.CountryCode)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: unknown country %s", ErrInvalidDetectionRule, rule.CountryCode)
+		}
+	}
+	if rule.PaymentMethodCode != "" {
+		exists, err := s.pmRepo.Exists(ctx, rule.PaymentMethodCode)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: unknown payment method %s", ErrInvalidDetectionRule, rule.PaymentMethodCode)
+		}
+	}
+	return nil
+}
+
+// ruleSetSpec returns the parameters and severity mapping of a rule set: a
+// registered detector type or ActivityRuleSet.
+func (s *DetectionRuleService) ruleSetSpec(ruleSet string) ([]ParamSpec, SeverityMapping, error) {
+	if ruleSet == ActivityRuleSet {
+		return activityParams, SeverityMapping{}, nil
+	}
+	reg, ok := s.registry.Get(ruleSet)
+	if !ok {
+		return nil, SeverityMapping{}, fmt.Errorf("%w: unknown rule set %q", ErrInvalidDetectionRule, ruleSet)
+	}
+	return reg.Params, reg.Severity, nil
+}
+
+func defaultParams(specs []ParamSpec) DetectorParams {
+	params := make(DetectorParams, len(specs))
+	for _, p := range specs {
+		params[p.Name] = p.Default
+	}
+	return params
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+func TestRuleResolverMostSpecificRuleWins(t *testing.T) {
+	resolver := NewRuleResolver([]model.DetectionRule{
+		{ID: "method", RuleSet: "zombie", PaymentMethodCode: "PIX", Params: map[string]float64{"baseline_pct": 5}},
+		{ID: "global", RuleSet: "zombie", Params: map[string]float64{"baseline_pct": 10, "baseline_floor": 10}},
+		{ID: "country", RuleSet: "zombie", CountryCode: "BR", Params: map[string]float64{"baseline_floor": 20, "baseline_pct": 8}},
+		{ID: "other-set", RuleSet: "hidden_gem", Params: map[string]float64{"baseline_floor": 99}},
+	})
+	defaults := DetectorParams{"baseline_floor": 1, "baseline_pct": 1, "new_method_months": 3}
+
+	got := resolver.Resolve("zombie", defaults, "BR", "WALLET", "PIX")
+	assert.Equal(t, DetectorParams{"baseline_floor": 20, "baseline_pct": 5, "new_method_months": 3}, got.Params)
+	assert.Equal(t, map[string]string{"baseline_floor": "country", "baseline_pct": "method", "new_method_months": "default"}, got.Sources)
+
+	got = resolver.Resolve("zombie", defaults, "MX", "WALLET", "OXXO")
+	assert.Equal(t, DetectorParams{"baseline_floor": 10, "baseline_pct": 10, "new_method_months": 3}, got.Params)
+
+	assert.Equal(t, defaults, (*RuleResolver)(nil).Resolve("zombie", defaults, "BR", "", "").Params)
+	assert.Equal(t, 1.0, defaults.Get("baseline_floor"), "defaults must not be mutated")
+}
+
+func TestDetectorRunParamsFor(t *testing.T) {
+	run := DetectorRun{
+		Params:   DetectorParams{"min_gap_pp": 10},
+		Severity: SeverityMapping{Tiers: []SeverityTier{{Severity: SeverityHigh, Threshold: 15}}, Default: SeverityMedium},
+	}
+	assert.Equal(t, run.Params, run.ParamsFor("BR", "CARD", "VISA_CREDIT"))
+
+	run.resolve = func(country, pmType, paymentMethod string) DetectorParams {
+		return DetectorParams{"min_gap_pp": 5, "severity_high": 8}
+	}
+	params := run.ParamsFor("BR", "CARD", "VISA_CREDIT")
+	assert.Equal(t, 5.0, params.Get("min_gap_pp"))
+	assert.Equal(t, SeverityHigh, run.SeverityFor(params).Map(9))
+	assert.Equal(t, SeverityMedium, run.Severity.Map(9))
+}
+
+func TestReplaceRuleSwapsSameScope(t *testing.T) {
+	rules := []model.DetectionRule{
+		{ID: "global", RuleSet: "zombie", Params: map[string]float64{"baseline_pct": 10}},
+		{ID: "br", RuleSet: "zombie", CountryCode: "BR", Params: map[string]float64{"baseline_pct": 8}},
+		{ID: "gem", RuleSet: "hidden_gem", CountryCode: "BR", Params: map[string]float64{"min_approval_rate": 90}},
+	}
+	proposed := model.DetectionRule{RuleSet: "zombie", CountryCode: "BR", Params: map[string]float64{"baseline_floor": 5}}
+
+	got := replaceRule(rules, proposed)
+	assert.Equal(t, []model.DetectionRule{rules[0], rules[2], proposed}, got)
+	assert.Len(t, rules, 3, "input must not be modified")
+
+	params := NewRuleResolver(got).Resolve("zombie", DetectorParams{"baseline_pct": 1, "baseline_floor": 1}, "BR", "", "").Params
+	assert.Equal(t, DetectorParams{"baseline_pct": 10, "baseline_floor": 5}, params)
+}
+
+func TestDetectionRuleValidateBounds(t *testing.T) {
+	registry := NewDetectorRegistry()
+	require.NoError(t, RegisterBuiltinDetectors(registry, nil, nil, nil, nil, nil, nil))
+	svc := NewDetectionRuleService(nil, nil, registry)
+
+	tests := []struct {
+		name    string
+		ruleSet string
+		params  map[string]float64
+		wantErr string
+	}{
+		{"defaults", "approval_anomaly", map[string]float64{"ewma_alpha": 0.1, "lookback_hours": 168}, ""},
+		{"severity tier", "approval_anomaly", map[string]float64{"severity_high": -5}, ""},
+		{"unknown", "approval_anomaly", map[string]float64{"alpha": 0.1}, `unknown parameter "alpha"`},
+		{"alpha above 1", "approval_anomaly", map[string]float64{"ewma_alpha": 1.5}, "ewma_alpha must be at most 1"},
+		{"negative window", "approval_anomaly", map[string]float64{"lookback_hours": -24}, "lookback_hours must be at least 1"},
+		{"zero bands", "ticket_size_mismatch", map[string]float64{"band_count": 0}, "band_count must be at least 2"},
+		{"zero lookback", "ticket_size_mismatch", map[string]float64{"lookback_days": 0}, "lookback_days must be at least 1"},
+		{"zero momentum", "hidden_gem", map[string]float64{"momentum_days": 0}, "momentum_days must be at least 1"},
+		{"negative activity", ActivityRuleSet, map[string]float64{"active_min_txns": -1}, "active_min_txns must be at least 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.validate(context.Background(), model.DetectionRule{RuleSet: tt.ruleSet, Params: tt.params})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidDetectionRule))
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestDetectionRuleValidateScope(t *testing.T) {
+	registry := NewDetectorRegistry()
+	require.NoError(t, RegisterBuiltinDetectors(registry, nil, nil, nil, nil, nil, nil))
+	svc := NewDetectionRuleService(nil, nil, registry)
+
+	tests := []struct {
+		name    string
+		rule    model.DetectionRule
+		wantErr string
+	}{
+		{"global window", model.DetectionRule{RuleSet: "hidden_gem", Params: map[string]float64{"momentum_days": 60}}, ""},
+		{"scoped threshold", model.DetectionRule{RuleSet: "hidden_gem", PaymentMethodType: "WALLET", Params: map[string]float64{"min_approval_rate": 0.9}}, ""},
+		{"scoped window", model.DetectionRule{RuleSet: "hidden_gem", PaymentMethodType: "WALLET", Params: map[string]float64{"momentum_days": 60}}, "momentum_days can only be set in the global rule"},
+		{"scoped bands", model.DetectionRule{RuleSet: "ticket_size_mismatch", PaymentMethodType: "CARD", Params: map[string]float64{"band_count": 4}}, "band_count can only be set in the global rule"},
+		{"unknown type", model.DetectionRule{RuleSet: "hidden_gem", PaymentMethodType: "CRYPTO", Params: map[string]float64{"min_approval_rate": 0.9}}, "unknown payment method type CRYPTO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.validate(context.Background(), tt.rule)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidDetectionRule))
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
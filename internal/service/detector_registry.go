@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+const (
+	SeverityHigh   = "HIGH"
+	SeverityMedium = "MEDIUM"
+	SeverityLow    = "LOW"
+)
+
+var ErrUnknownInsightType = errors.New("unknown insight type")
+
+// DetectionScope is what a detection run looks at.
+type DetectionScope struct {
+	Filter model.AnalyticsFilter
+	Now    time.Time
+}
+
+// DetectorParams holds a detector's resolved numeric parameters by name.
+type DetectorParams map[string]float64
+
+func (p DetectorParams) Get(name string) float64 {
+	return p[name]
+}
+
+// DetectorRun is passed to every Detect call: the scope, the global
+// parameters (registration defaults merged with global detection rules), and
+// the severity mapping with any severity_<level> parameter overrides applied.
+// Detectors that emit per-row insights should use ParamsFor and SeverityFor
+// so country, type and method scoped rules apply.
+type DetectorRun struct {
+	Scope    DetectionScope
+	Params   DetectorParams
+	Severity SeverityMapping
+
+	resolve func(country, pmType, paymentMethod string) DetectorParams
+}
+
+// ParamsFor returns the parameters effective for one row.
+func (r DetectorRun) ParamsFor(country, pmType, paymentMethod string) DetectorParams {
+	if r.resolve == nil {
+		return r.Params
+	}
+	return r.resolve(country, pmType, paymentMethod)
+}
+
+// SeverityFor applies severity_<level> overrides from row parameters.
+func (r DetectorRun) SeverityFor(params DetectorParams) SeverityMapping {
+	return r.Severity.withParams(params)
+}
+
+// Detector produces insights of a single type.
+type Detector interface {
+	Detect(ctx context.Context, run DetectorRun) ([]Insight, error)
+}
+
+// DetectorFunc adapts a plain function to Detector.
+type DetectorFunc func(ctx context.Context, run DetectorRun) ([]Insight, error)
+
+func (f DetectorFunc) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	return f(ctx, run)
+}
+
+// ParamSpec documents one tunable parameter of a detector. Stricter is +1
+// when raising the parameter makes the detector fire less often and -1 when
+// lowering it does; adaptive threshold suggestions only move parameters
+// with a direction. Min and Max, when set, bound the values a detection rule
+// may store. GlobalOnly parameters shape the whole run, such as its time
+// window, and are read from the global rule only, so scoped rules may not
+// set them.
+type ParamSpec struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Default     float64  `json:"default"`
+	Stricter    int      `json:"stricter,omitempty"`
+	Min         *float64 `json:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+	GlobalOnly  bool     `json:"global_only,omitempty"`
+}
+
+// check reports why v is outside the parameter's bounds, or nil.
+func (p ParamSpec) check(v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("%s must be a finite number", p.Name)
+	}
+	if p.Min != nil && v < *p.Min {
+		return fmt.Errorf("%s must be at least %g", p.Name, *p.Min)
+	}
+	if p.Max != nil && v > *p.Max {
+		return fmt.Errorf("%s must be at most %g", p.Name, *p.Max)
+	}
+	return nil
+}
+
+// bound returns a pointer for ParamSpec.Min and Max.
+func bound(v float64) *float64 {
+	return &v
+}
+
+type SeverityTier struct {
+	Severity  string  `json:"severity"`
+	Threshold float64 `json:"threshold"`
+}
+
+// SeverityMapping turns a detector's severity score into a level: the first
+// tier whose threshold the score exceeds (or falls below, when Inverted) wins,
+// otherwise Default.
+type SeverityMapping struct {
+	Inverted bool           `json:"inverted"`
+	Tiers    []SeverityTier `json:"tiers"`
+	Default  string         `json:"default"`
+}
+
+func (m SeverityMapping) Map(score float64) string {
+	for _, t := range m.Tiers {
+		if (!m.Inverted && score > t.Threshold) || (m.Inverted && score < t.Threshold) {
+			return t.Severity
+		}
+	}
+	return m.Default
+}
+
+// withParams overrides tier thresholds from severity_high, severity_medium
+// and severity_low parameters.
+func (m SeverityMapping) withParams(params DetectorParams) SeverityMapping {
+	out := m
+	out.Tiers = make([]SeverityTier, len(m.Tiers))
+	for i, t := range m.Tiers {
+		if v, ok := params[severityParam(t.Severity)]; ok {
+			t.Threshold = v
+		}
+		out.Tiers[i] = t
+	}
+	return out
+}
+
+func severityParam(severity string) string {
+	return "severity_" + strings.ToLower(severity)
+}
+
+// DetectorRegistration describes a detector to the registry.
+type DetectorRegistration struct {
+	Type        string          `json:"type"`
+	Description string          `json:"description"`
+	Params      []ParamSpec     `json:"params"`
+	Severity    SeverityMapping `json:"severity"`
+	Detector    Detector        `json:"-"`
+	Enabled     bool            `json:"enabled"`
+}
+
+// DefaultParams returns the declared parameter defaults.
+func (r DetectorRegistration) DefaultParams() DetectorParams {
+	return defaultParams(r.Params)
+}
+
+// DetectorRegistry holds the insight detectors in registration order.
+type DetectorRegistry struct {
+	mu      sync.RWMutex
+	entries []DetectorRegistration
+}
+
+func NewDetectorRegistry() *DetectorRegistry {
+	return &DetectorRegistry{}
+}
+
+// Register adds a detector. Registrations are enabled unless SetEnabled says
+// otherwise; the Enabled field of reg is ignored.
+func (r *DetectorRegistry) Register(reg DetectorRegistration) error {
+	if reg.Type == "" {
+		return errors.New("detector type is required")
+	}
+	if reg.Detector == nil {
+		return fmt.Errorf("detector %q has no implementation", reg.Type)
+	}
+	seen := make(map[string]bool, len(reg.Params))
+	for _, p := range reg.Params {
+		if p.Name == "" || seen[p.Name] {
+			return fmt.Errorf("detector %q: parameter names must be unique and non-empty", reg.Type)
+		}
+		if err := p.check(p.Default); err != nil {
+			return fmt.Errorf("detector %q: default %w", reg.Type, err)
+		}
+		seen[p.Name] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.Type == reg.Type {
+			return fmt.Errorf("detector %q already registered", reg.Type)
+		}
+	}
+	reg.Enabled = true
+	r.entries = append(r.entries, reg)
+	return nil
+}
+
+func (r *DetectorRegistry) SetEnabled(insightType string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.entries {
+		if r.entries[i].Type == insightType {
+			r.entries[i].Enabled = enabled
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownInsightType, insightType)
+}
+
+func (r *DetectorRegistry) Get(insightType string) (DetectorRegistration, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, e := range r.entries {
+		if e.Type == insightType {
+			return e, true
+		}
+	}
+	return DetectorRegistration{}, false
+}
+
+// List returns every registration, enabled or not, in registration order.
+func (r *DetectorRegistry) List() []DetectorRegistration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]DetectorRegistration(nil), r.entries...)
+}
+
+func (r *DetectorRegistry) enabled() []DetectorRegistration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []DetectorRegistration
+	for _, e := range r.entries {
+		if e.Enabled {
+			out = append(out, e)
+		}
+	}
+	return out
+}
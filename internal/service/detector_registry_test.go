@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+var emptyFilter model.AnalyticsFilter
+
+func stubDetector(insights ...Insight) Detector {
+	return DetectorFunc(func(ctx context.Context, run DetectorRun) ([]Insight, error) {
+		return insights, nil
+	})
+}
+
+func TestDetectorRegistryRegister(t *testing.T) {
+	r := NewDetectorRegistry()
+	require.NoError(t, r.Register(DetectorRegistration{Type: "a", Detector: stubDetector()}))
+
+	assert.Error(t, r.Register(DetectorRegistration{Type: "a", Detector: stubDetector()}), "duplicate type")
+	assert.Error(t, r.Register(DetectorRegistration{Detector: stubDetector()}), "missing type")
+	assert.Error(t, r.Register(DetectorRegistration{Type: "b"}), "missing detector")
+	assert.Error(t, r.Register(DetectorRegistration{
+		Type:     "c",
+		Params:   []ParamSpec{{Name: "x"}, {Name: "x"}},
+		Detector: stubDetector(),
+	}), "duplicate param")
+	assert.Error(t, r.Register(DetectorRegistration{
+		Type:     "d",
+		Params:   []ParamSpec{{Name: "x", Default: -1, Min: bound(0)}},
+		Detector: stubDetector(),
+	}), "default out of bounds")
+
+	reg, ok := r.Get("a")
+	require.True(t, ok)
+	assert.True(t, reg.Enabled)
+
+	require.NoError(t, r.SetEnabled("a", false))
+	assert.True(t, errors.Is(r.SetEnabled("missing", false), ErrUnknownInsightType))
+	assert.Empty(t, r.enabled())
+}
+
+func TestDetectInsightsRunsEnabledDetectorsInOrder(t *testing.T) {
+	r := NewDetectorRegistry()
+	require.NoError(t, r.Register(DetectorRegistration{Type: "first", Detector: stubDetector(Insight{InsightID: "1", Severity: SeverityHigh})}))
+	require.NoError(t, r.Register(DetectorRegistration{Type: "off", Detector: stubDetector(Insight{InsightID: "x"})}))
+	require.NoError(t, r.Register(DetectorRegistration{Type: "second", Detector: stubDetector(Insight{InsightID: "2", Severity: SeverityLow})}))
+	require.NoError(t, r.SetEnabled("off", false))
+
+	svc := NewInsightService(r, nil, nil)
+	ctx := context.Background()
+
+	all, err := svc.DetectInsights(ctx, emptyFilter, "", "")
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "1", all[0].InsightID)
+	assert.Equal(t, "2", all[1].InsightID)
+
+	high, err := svc.DetectInsights(ctx, emptyFilter, "", SeverityHigh)
+	require.NoError(t, err)
+	require.Len(t, high, 1)
+
+	_, err = svc.DetectInsights(ctx, emptyFilter, "nope", "")
+	assert.True(t, errors.Is(err, ErrUnknownInsightType))
+}
+
+func TestDetectorParamsAndSeverityOverrides(t *testing.T) {
+	var got DetectorRun
+	r := NewDetectorRegistry()
+	require.NoError(t, r.Register(DetectorRegistration{
+		Type: "gap",
+		Params: []ParamSpec{
+			{Name: "min_gap", Default: 10},
+			{Name: "severity_high", Default: 20},
+		},
+		Severity: SeverityMapping{
+			Tiers:   []SeverityTier{{Severity: SeverityHigh, Threshold: 15}},
+			Default: SeverityMedium,
+		},
+		Detector: DetectorFunc(func(ctx context.Context, run DetectorRun) ([]Insight, error) {
+			got = run
+			return nil, nil
+		}),
+	}))
+
+	_, err := NewInsightService(r, nil, nil).DetectInsights(context.Background(), emptyFilter, "gap", "")
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, got.Params.Get("min_gap"))
+	assert.Equal(t, SeverityMedium, got.Severity.Map(18))
+	assert.Equal(t, SeverityHigh, got.Severity.Map(21))
+}
+
+func TestSeverityMappingInverted(t *testing.T) {
+	m := SeverityMapping{
+		Inverted: true,
+		Tiers: []SeverityTier{
+			{Severity: SeverityHigh, Threshold: 0.1},
+			{Severity: SeverityMedium, Threshold: 0.3},
+		},
+		Default: SeverityLow,
+	}
+	assert.Equal(t, SeverityHigh, m.Map(0))
+	assert.Equal(t, SeverityMedium, m.Map(0.2))
+	assert.Equal(t, SeverityLow, m.Map(0.3))
+}
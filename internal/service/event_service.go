@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+var ErrInvalidEvent = errors.New("invalid event")
+
+// EventService manages the events timeline. Maintenance events suppress
+// insights (see insight_suppression.go); every kind annotates trends and the
+// health report.
+type EventService struct {
+	repo   *repository.EventRepository
+	pmRepo *repository.PaymentMethodRepository
+}
+
+func NewEventService(repo *repository.EventRepository, pmRepo *repository.PaymentMethodRepository) *EventService {
+	return &EventService{repo: repo, pmRepo: pmRepo}
+}
+
+// CreateEvent validates the event's kind, range and scope and stores it.
+func (s *EventService) CreateEvent(ctx context.Context, e model.Event) (*model.Event, error) {
+	e.Title = strings.TrimSpace(e.Title)
+	switch e.Kind {
+	case model.EventKindMaintenance, model.EventKindOutage, model.EventKindPricingChange,
+		model.EventKindLaunch, model.EventKindCampaign:
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidEvent, e.Kind)
+	}
+	if e.Title == "" {
+		return nil, fmt.Errorf("%w: title is required", ErrInvalidEvent)
+	}
+	if !e.EndsAt.After(e.StartsAt) {
+		return nil, fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidEvent)
+	}
+
+	if e.CountryCode != "" {
+		exists, err := s.pmRepo.CountryExists(ctx, e.CountryCode)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: unknown country %s", ErrInvalidEvent, e.CountryCode)
+		}
+	}
+	if e.PaymentMethodCode != "" || e.Provider != "" {
+		providers, err := s.pmRepo.Providers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if e.PaymentMethodCode != "" {
+			provider, ok := providers[e.PaymentMethodCode]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown payment method %s", ErrInvalidEvent, e.PaymentMethodCode)
+			}
+			if e.Provider != "" && provider != e.Provider {
+				return nil, fmt.Errorf("%w: payment method %s is not provided by %s", ErrInvalidEvent, e.PaymentMethodCode, e.Provider)
+			}
+		} else if !hasProvider(providers, e.Provider) {
+			return nil, fmt.Errorf("%w: unknown provider %s", ErrInvalidEvent, e.Provider)
+		}
+	}
+
+	return s.repo.CreateEvent(ctx, e)
+}
+
+func hasProvider(providers map[string]string, provider string) bool {
+	for _, p := range providers {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *EventService) ListEvents(ctx context.Context, q repository.EventQuery) ([]model.Event, error) {
+	return s.repo.ListEvents(ctx, q)
+}
+
+// DeleteEvent returns pgx.ErrNoRows when no event has the given id.
+func (s *EventService) DeleteEvent(ctx context.Context, id string) error {
+	return s.repo.DeleteEvent(ctx, id)
+}
+
+// scopedEvents loads the events of the given kinds overlapping [from, to]
+// in the filter's scope, along with each payment method's provider so
+// callers can match provider-scoped events.
+func (s *EventService) scopedEvents(ctx context.Context, f model.AnalyticsFilter, kinds []string, from, to time.Time) ([]model.Event, map[string]string, error) {
+	events, err := s.repo.ListEvents(ctx, repository.EventQuery{
+		Kinds:          kinds,
+		Countries:      f.Countries,
+		PaymentMethods: f.PaymentMethods,
+		From:           from,
+		To:             to,
+	})
+	if err != nil || len(events) == 0 {
+		return nil, nil, err
+	}
+	providers, err := s.pmRepo.Providers(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return events, providers, nil
+}
+
+// TrendAnnotation marks an event on the first trend period it overlaps.
+type TrendAnnotation struct {
+	Period   string    `json:"period"`
+	EventID  string    `json:"event_id"`
+	Kind     string    `json:"kind"`
+	Title    string    `json:"title"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// AnnotateTrends attaches the events overlapping each series' periods. period
+// is WOW or MOM, as passed to GetTrends.
+func (s *EventService) AnnotateTrends(ctx context.Context, f model.AnalyticsFilter, trends []TrendSummary, period string) error {
+	var from, to time.Time
+	for _, t := range trends {
+		if len(t.Points) == 0 {
+			continue
+		}
+		first := periodStart(t.Points[0].Period)
+		last := periodEnd(periodStart(t.Points[len(t.Points)-1].Period), period)
+		if from.IsZero() || first.Before(from) {
+			from = first
+		}
+		if last.After(to) {
+			to = last
+		}
+	}
+	if from.IsZero() {
+		return nil
+	}
+
+	events, providers, err := s.scopedEvents(ctx, f, nil, from, to)
+	if err != nil {
+		return err
+	}
+	for i := range trends {
+		trends[i].Annotations = annotateTrend(trends[i], events, providers[trends[i].PaymentMethodCode], period)
+	}
+	return nil
+}
+
+// annotateTrend places each matching event on the first of the series'
+// periods it overlaps. The event's end is exclusive of the next period.
+func annotateTrend(t TrendSummary, events []model.Event, provider, period string) []TrendAnnotation {
+	var out []TrendAnnotation
+	for _, e := range events {
+		if !e.Matches(t.CountryCode, t.PaymentMethodCode, provider) {
+			continue
+		}
+		for _, p := range t.Points {
+			start := periodStart(p.Period)
+			if e.StartsAt.Before(periodEnd(start, period)) && e.EndsAt.After(start) {
+				out = append(out, TrendAnnotation{
+					Period:   p.Period,
+					EventID:  e.ID,
+					Kind:     e.Kind,
+					Title:    e.Title,
+					StartsAt: e.StartsAt,
+					EndsAt:   e.EndsAt,
+				})
+				break
+			}
+		}
+	}
+	return out
+}
+
+func periodEnd(start time.Time, period string) time.Time {
+	if period == "WOW" {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 1, 0)
+}
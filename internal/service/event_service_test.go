@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+func TestSuppressInsights(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2026, 3, 1, h, 0, 0, 0, time.UTC) }
+	events := []model.Event{
+		{ID: "e1", Kind: model.EventKindMaintenance, Provider: "MercadoLibre", StartsAt: at(2), EndsAt: at(4)},
+		{ID: "e2", Kind: model.EventKindMaintenance, CountryCode: "CO", StartsAt: at(10), EndsAt: at(11)},
+	}
+	providers := map[string]string{"MERCADOPAGO": "MercadoLibre", "NEQUI": "Bancolombia", "PIX": "BCB"}
+	insights := []Insight{
+		{InsightID: "a", PaymentMethodCode: "MERCADOPAGO", CountryCode: "AR"},
+		{InsightID: "b", PaymentMethodCode: "NEQUI", CountryCode: "CO"},
+		{InsightID: "c", PaymentMethodCode: "NEQUI", CountryCode: "CO"},
+		{InsightID: "d", PaymentMethodCode: "PIX", CountryCode: "BR"},
+	}
+	windows := [][2]time.Time{
+		{at(3), at(4)},   // inside the provider maintenance
+		{at(11), at(12)}, // touches the country maintenance
+		{at(12), at(13)}, // after it
+		{at(3), at(3)},   // different provider and country
+	}
+
+	kept, held := suppressInsights(insights, windows, events, providers)
+	assert.Equal(t, []string{"a", "b"}, held)
+	require.Len(t, kept, 2)
+	assert.Equal(t, "c", kept[0].InsightID)
+	assert.Equal(t, "d", kept[1].InsightID)
+}
+
+func TestSuppressionWindows(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	hour := now.Add(-3 * time.Hour)
+
+	from, to := (&approvalAnomalyDetector{}).SuppressionWindow(Insight{
+		GeneratedAt:    now,
+		SupportingData: map[string]interface{}{"hour": hour.Format(time.RFC3339)},
+	})
+	assert.Equal(t, hour, from)
+	assert.Equal(t, hour.Add(time.Hour), to)
+
+	from, to = (&volumeOutageDetector{}).SuppressionWindow(Insight{
+		GeneratedAt:    now,
+		SupportingData: map[string]interface{}{"window_minutes": 90.0},
+	})
+	assert.Equal(t, now.Add(-90*time.Minute), from)
+	assert.Equal(t, now, to)
+
+	svc := &InsightService{registry: NewDetectorRegistry()}
+	from, to = svc.suppressionWindow(Insight{Type: "unregistered", GeneratedAt: now})
+	assert.Equal(t, now, from)
+	assert.Equal(t, now, to)
+}
+
+func TestAnnotateTrend(t *testing.T) {
+	day := func(m, d int) time.Time { return time.Date(2026, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	trend := TrendSummary{
+		PaymentMethodCode: "PIX",
+		CountryCode:       "BR",
+		Points: []TrendPoint{
+			{Period: "2026-01-01 00:00:00+00"},
+			{Period: "2026-02-01 00:00:00+00"},
+			{Period: "2026-03-01 00:00:00+00"},
+		},
+	}
+	events := []model.Event{
+		{ID: "before", Kind: model.EventKindLaunch, StartsAt: day(12, 1).AddDate(-1, 0, 0), EndsAt: day(12, 2).AddDate(-1, 0, 0)},
+		{ID: "spans", Kind: model.EventKindCampaign, CountryCode: "BR", StartsAt: day(1, 20), EndsAt: day(2, 10)},
+		{ID: "boundary", Kind: model.EventKindPricingChange, PaymentMethodCode: "PIX", StartsAt: day(2, 20), EndsAt: day(3, 1)},
+		{ID: "march", Kind: model.EventKindOutage, Provider: "BCB", Title: "SPI down", StartsAt: day(3, 5), EndsAt: day(3, 6)},
+		{ID: "other", Kind: model.EventKindOutage, CountryCode: "MX", StartsAt: day(3, 5), EndsAt: day(3, 6)},
+	}
+
+	got := annotateTrend(trend, events, "BCB", "MOM")
+	require.Len(t, got, 3)
+	assert.Equal(t, "spans", got[0].EventID)
+	assert.Equal(t, "2026-01-01 00:00:00+00", got[0].Period, "placed on the first period it overlaps")
+	assert.Equal(t, "boundary", got[1].EventID)
+	assert.Equal(t, "2026-02-01 00:00:00+00", got[1].Period, "an end at the period start stays in the earlier period")
+	assert.Equal(t, "march", got[2].EventID)
+	assert.Equal(t, "SPI down", got[2].Title)
+
+	assert.Empty(t, annotateTrend(trend, events[3:4], "Other", "MOM"))
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+const (
+	HealthComponentApproval = "approval_rate"
+	HealthComponentTrend    = "volume_trend"
+	HealthComponentROI      = "roi"
+	HealthComponentActivity = "activity"
+	HealthComponentRefunds  = "refund_rate"
+)
+
+// HealthWeights sets the relative weight of each score component. Weights do
+// not need to sum to 1; the score is normalized by their total.
+type HealthWeights struct {
+	Approval float64
+	Trend    float64
+	ROI      float64
+	Activity float64
+	Refunds  float64
+}
+
+var DefaultHealthWeights = HealthWeights{
+	Approval: 0.30,
+	Trend:    0.20,
+	ROI:      0.20,
+	Activity: 0.15,
+	Refunds:  0.15,
+}
+
+// ParseHealthWeights reads "approval=0.3,trend=0.2,..." overrides on top of
+// DefaultHealthWeights. An empty string yields the defaults.
+func ParseHealthWeights(s string) (HealthWeights, error) {
+	w := DefaultHealthWeights
+	if strings.TrimSpace(s) == "" {
+		return w, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		name, raw, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return w, fmt.Errorf("invalid weight %q, expected name=value", pair)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil || v < 0 {
+			return w, fmt.Errorf("invalid weight value for %s: %q", name, raw)
+		}
+		switch strings.TrimSpace(name) {
+		case "approval":
+			w.Approval = v
+		case "trend":
+			w.Trend = v
+		case "roi":
+			w.ROI = v
+		case "activity":
+			w.Activity = v
+		case "refunds":
+			w.Refunds = v
+		default:
+			return w, fmt.Errorf("unknown weight %q", name)
+		}
+	}
+
+	if w.Approval+w.Trend+w.ROI+w.Activity+w.Refunds == 0 {
+		return w, fmt.Errorf("at least one weight must be positive")
+	}
+	return w, nil
+}
+
+type HealthScoreComponent struct {
+	Input        interface{} `json:"input"`
+	Score        float64     `json:"score"`
+	Weight       float64     `json:"weight"`
+	Contribution float64     `json:"contribution"`
+}
+
+type HealthScore struct {
+	Score      float64                         `json:"score"`
+	Components map[string]HealthScoreComponent `json:"components"`
+}
+
+type HealthScorePoint struct {
+	Period string `json:"period"`
+	HealthScore
+}
+
+type HealthScoreSeries struct {
+	PaymentMethodCode string             `json:"payment_method_code"`
+	PaymentMethodName string             `json:"payment_method_name"`
+	CountryCode       string             `json:"country_code"`
+	Points            []HealthScorePoint `json:"points"`
+}
+
+type HealthScoreService struct {
+	repo     *repository.HealthScoreRepository
+	trendSvc *TrendService
+	roiSvc   *ROIService
+	weights  HealthWeights
+}
+
+func NewHealthScoreService(repo *repository.HealthScoreRepository, trendSvc *TrendService, roiSvc *ROIService, weights HealthWeights) *HealthScoreService {
+	return &HealthScoreService{repo: repo, trendSvc: trendSvc, roiSvc: roiSvc, weights: weights}
+}
+
+// healthInputs are the raw signals behind one score. TrendSlope is relative:
+// the regression slope divided by the series mean, per period.
+type healthInputs struct {
+	ApprovalRate   float64
+	TrendSlope     float64
+	HasTrend       bool
+	ROIPct         *float64
+	TpvUSD         float64
+	ActivityStatus string
+	RefundRate     float64
+}
+
+// Attach computes the current health score of every metric row, using the
+// last six months of TPV for the trend and the metrics window for ROI.
+func (s *HealthScoreService) Attach(ctx context.Context, results []MetricResult, f model.AnalyticsFilter) error {
+	if len(results) == 0 {
+		return nil
+	}
+	// The expression is written against metric columns; the rows it selected
+	// are already in results.
+	f.Expr = ""
+
+	var trends []TrendSummary
+	var rois []ROIResult
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		trends, err = s.trendSvc.GetTrends(gctx, f, "MOM", "tpv_usd", 6)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		rois, err = s.roiSvc.GetROI(gctx, f)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	type key struct{ pm, cc string }
+	trendByKey := make(map[key]TrendSummary, len(trends))
+	for _, t := range trends {
+		trendByKey[key{t.PaymentMethodCode, t.CountryCode}] = t
+	}
+	roiByKey := make(map[key]ROIResult, len(rois))
+	for _, r := range rois {
+		roiByKey[key{r.PaymentMethodCode, r.CountryCode}] = r
+	}
+
+	for i := range results {
+		m := &results[i]
+		k := key{m.PaymentMethodCode, m.CountryCode}
+
+		in := healthInputs{
+			ApprovalRate:   m.ApprovalRate,
+			TpvUSD:         m.TpvUSD,
+			ActivityStatus: m.ActivityStatus,
+		}
+		if m.TransactionCount > 0 {
+			in.RefundRate = float64(m.RefundedCount) / float64(m.TransactionCount) * 100
+		}
+		if t, ok := trendByKey[k]; ok && len(t.Points) >= 2 {
+			values := make([]float64, len(t.Points))
+			for j, p := range t.Points {
+				values[j] = p.Value
+			}
+			in.TrendSlope, in.HasTrend = relativeSlope(values)
+		}
+		if r, ok := roiByKey[k]; ok {
+			in.ROIPct = r.ROIPct
+		}
+
+		score := s.weights.score(in)
+		m.HealthScore = &score
+	}
+	return nil
+}
+
+// GetHistory scores each period bucket independently; the trend component of
+// a bucket only looks at the buckets up to and including it.
+func (s *HealthScoreService) GetHistory(ctx context.Context, f model.AnalyticsFilter, period string, periodsBack int) ([]HealthScoreSeries, error) {
+	if periodsBack < 1 {
+		periodsBack = 6
+	}
+
+	buckets, err := s.repo.GetScoreInputs(ctx, f, period, periodsBack)
+	if err != nil {
+		return nil, err
+	}
+
+	monthsPerBucket := 1.0
+	if period == "WOW" {
+		monthsPerBucket = 7.0 / 30.0
+	}
+
+	var results []HealthScoreSeries
+	var tpvs []float64
+	for i, b := range buckets {
+		if i == 0 || b.PaymentMethodCode != buckets[i-1].PaymentMethodCode || b.CountryCode != buckets[i-1].CountryCode {
+			results = append(results, HealthScoreSeries{
+				PaymentMethodCode: b.PaymentMethodCode,
+				PaymentMethodName: b.PaymentMethodName,
+				CountryCode:       b.CountryCode,
+			})
+			tpvs = tpvs[:0]
+		}
+		tpvs = append(tpvs, b.TpvUSD)
+
+		in := healthInputs{
+			TpvUSD:         b.TpvUSD,
+			ActivityStatus: activityStatusFor(b.TxnCount90d, b.ActiveMinTxns, b.LowActivityMinTxns),
+		}
+		if b.TotalCount > 0 {
+			in.ApprovalRate = float64(b.ApprovedCount) / float64(b.TotalCount) * 100
+			in.RefundRate = float64(b.RefundedCount) / float64(b.TotalCount) * 100
+		}
+		in.TrendSlope, in.HasTrend = relativeSlope(tpvs)
+
+		// Same cost model as ROIService, scoped to one bucket.
+		totalCost := monthsPerBucket*b.MonthlyFixedCost +
+			float64(b.TotalCount)*b.PerTransactionCost +
+			b.TpvUSD*b.PercentageFee
+		if totalCost > 0 {
+			roi := (b.TpvUSD - totalCost) / totalCost * 100
+			in.ROIPct = &roi
+		}
+
+		series := &results[len(results)-1]
+		series.Points = append(series.Points, HealthScorePoint{
+			Period:      b.Period,
+			HealthScore: s.weights.score(in),
+		})
+	}
+
+	return results, nil
+}
+
+func (w HealthWeights) score(in healthInputs) HealthScore {
+	trendScore := 50.0
+	var trendInput interface{}
+	if in.HasTrend {
+		// +/-20% per period saturates the component.
+		trendScore = clampScore(50 + in.TrendSlope*250)
+		trendInput = round2(in.TrendSlope * 100)
+	}
+
+	roiScore := 0.0
+	var roiInput interface{}
+	switch {
+	case in.ROIPct != nil:
+		// 500% ROI (HIGHLY_PROFITABLE) saturates the component.
+		roiScore = clampScore(*in.ROIPct / 5)
+		roiInput = round2(*in.ROIPct)
+	case in.TpvUSD > 0:
+		// No integration cost on record: nothing is being wasted.
+		roiScore = 100
+	}
+
+	activityScore := 0.0
+	switch in.ActivityStatus {
+	case "ACTIVE":
+		activityScore = 100
+	case "LOW_ACTIVITY":
+		activityScore = 40
+	}
+
+	parts := []struct {
+		name   string
+		input  interface{}
+		score  float64
+		weight float64
+	}{
+		{HealthComponentApproval, round2(in.ApprovalRate), clampScore(in.ApprovalRate), w.Approval},
+		{HealthComponentTrend, trendInput, trendScore, w.Trend},
+		{HealthComponentROI, roiInput, roiScore, w.ROI},
+		{HealthComponentActivity, in.ActivityStatus, activityScore, w.Activity},
+		// 10% refunds zeroes the component.
+		{HealthComponentRefunds, round2(in.RefundRate), clampScore(100 - in.RefundRate*10), w.Refunds},
+	}
+
+	var totalWeight float64
+	for _, p := range parts {
+		totalWeight += p.weight
+	}
+
+	hs := HealthScore{Components: make(map[string]HealthScoreComponent, len(parts))}
+	for _, p := range parts {
+		weight := 0.0
+		if totalWeight > 0 {
+			weight = p.weight / totalWeight
+		}
+		contribution := p.score * weight
+		hs.Score += contribution
+		hs.Components[p.name] = HealthScoreComponent{
+			Input:        p.input,
+			Score:        round2(p.score),
+			Weight:       round2(weight),
+			Contribution: round2(contribution),
+		}
+	}
+	hs.Score = math.Round(hs.Score*10) / 10
+	return hs
+}
+
+func relativeSlope(values []float64) (float64, bool) {
+	if len(values) < 2 {
+		return 0, false
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return 0, false
+	}
+	slope, _ := linearRegression(values)
+	return slope / mean, true
+}
+
+func activityStatusFor(txnCount90d int, activeMin, lowActivityMin float64) string {
+	switch {
+	case float64(txnCount90d) >= activeMin:
+		return "ACTIVE"
+	case float64(txnCount90d) >= lowActivityMin:
+		return "LOW_ACTIVITY"
+	default:
+		return "INACTIVE"
+	}
+}
+
+func clampScore(v float64) float64 {
+	return math.Max(0, math.Min(100, v))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHealthWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    HealthWeights
+		wantErr bool
+	}{
+		{name: "empty keeps defaults", in: "  ", want: DefaultHealthWeights},
+		{
+			name: "overrides on top of defaults",
+			in:   "approval=0.5, refunds = 0",
+			want: HealthWeights{Approval: 0.5, Trend: 0.2, ROI: 0.2, Activity: 0.15, Refunds: 0},
+		},
+		{name: "missing value", in: "approval", wantErr: true},
+		{name: "not a number", in: "trend=high", wantErr: true},
+		{name: "negative", in: "roi=-1", wantErr: true},
+		{name: "unknown component", in: "latency=0.2", wantErr: true},
+		{name: "all zero", in: "approval=0,trend=0,roi=0,activity=0,refunds=0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHealthWeights(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHealthScore(t *testing.T) {
+	roi := 250.0
+	tests := []struct {
+		name       string
+		weights    HealthWeights
+		in         healthInputs
+		want       float64
+		components map[string]float64
+	}{
+		{
+			name:    "every component",
+			weights: DefaultHealthWeights,
+			in: healthInputs{ApprovalRate: 90, HasTrend: true, TrendSlope: 0.1, ROIPct: &roi,
+				TpvUSD: 1000, ActivityStatus: "ACTIVE", RefundRate: 2},
+			// 0.3*90 + 0.2*75 + 0.2*50 + 0.15*100 + 0.15*80
+			want: 79,
+			components: map[string]float64{
+				HealthComponentApproval: 90, HealthComponentTrend: 75, HealthComponentROI: 50,
+				HealthComponentActivity: 100, HealthComponentRefunds: 80,
+			},
+		},
+		{
+			name:    "no trend is neutral and no cost on record scores full ROI",
+			weights: HealthWeights{Trend: 1, ROI: 1},
+			in:      healthInputs{TpvUSD: 10, ActivityStatus: "LOW_ACTIVITY"},
+			want:    75,
+			components: map[string]float64{
+				HealthComponentTrend: 50, HealthComponentROI: 100, HealthComponentActivity: 40,
+			},
+		},
+		{
+			name:    "components saturate",
+			weights: HealthWeights{Trend: 1, Refunds: 1},
+			in:      healthInputs{HasTrend: true, TrendSlope: -0.5, RefundRate: 15, ActivityStatus: "INACTIVE"},
+			want:    0,
+			components: map[string]float64{
+				HealthComponentTrend: 0, HealthComponentROI: 0, HealthComponentActivity: 0, HealthComponentRefunds: 0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := tt.weights.score(tt.in)
+			assert.Equal(t, tt.want, hs.Score)
+			for name, score := range tt.components {
+				assert.Equal(t, score, hs.Components[name].Score, name)
+			}
+			var weights float64
+			for _, c := range hs.Components {
+				weights += c.Weight
+			}
+			assert.InDelta(t, 1, weights, 0.01, "weights are normalized")
+		})
+	}
+}
+
+func TestRelativeSlope(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+		ok     bool
+	}{
+		{name: "growing 10 per period around a mean of 100", values: []float64{90, 100, 110}, want: 0.1, ok: true},
+		{name: "flat", values: []float64{50, 50, 50, 50}, want: 0, ok: true},
+		{name: "declining", values: []float64{120, 100, 80}, want: -0.2, ok: true},
+		{name: "one period", values: []float64{100}},
+		{name: "zero mean", values: []float64{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := relativeSlope(tt.values)
+			assert.Equal(t, tt.ok, ok)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
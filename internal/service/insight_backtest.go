@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+// MaxBacktestWeeks bounds the evaluations of one backtest.
+const MaxBacktestWeeks = 104
+
+var ErrInvalidBacktest = errors.New("invalid backtest")
+
+// BacktestRequest replays one detector weekly from From to To. A non-nil
+// Proposed rule replaces the stored rule for its scope, so a threshold change
+// can be tried before it is saved.
+type BacktestRequest struct {
+	RuleSet  string
+	From     time.Time
+	To       time.Time
+	Filter   model.AnalyticsFilter
+	Proposed *model.DetectionRule
+}
+
+// BacktestEpisode is one stretch of consecutive weekly evaluations in which
+// an insight fired. ResolvedAt is the first evaluation it no longer fired at,
+// nil when it was still firing at the end of the range.
+type BacktestEpisode struct {
+	FiredAt      time.Time  `json:"fired_at"`
+	ResolvedAt   *time.Time `json:"resolved_at"`
+	Evaluations  int        `json:"evaluations"`
+	PeakSeverity string     `json:"peak_severity"`
+}
+
+type BacktestInsight struct {
+	InsightID         string            `json:"insight_id"`
+	PaymentMethodCode string            `json:"payment_method_code"`
+	PaymentMethodName string            `json:"payment_method_name"`
+	CountryCode       string            `json:"country_code"`
+	Episodes          []BacktestEpisode `json:"episodes"`
+}
+
+// BacktestEvaluation counts the insights at one weekly evaluation: firing,
+// newly fired and resolved since the previous one.
+type BacktestEvaluation struct {
+	AsOf     time.Time `json:"as_of"`
+	Firing   int       `json:"firing"`
+	Fired    int       `json:"fired"`
+	Resolved int       `json:"resolved"`
+}
+
+type BacktestResult struct {
+	RuleSet     string               `json:"rule_set"`
+	From        time.Time            `json:"from"`
+	To          time.Time            `json:"to"`
+	Proposed    *model.DetectionRule `json:"proposed_rule,omitempty"`
+	TotalAlerts int                  `json:"total_alerts"`
+	Evaluations []BacktestEvaluation `json:"evaluations"`
+	Insights    []BacktestInsight    `json:"insights"`
+}
+
+// Backtest evaluates the detector as of each week in the request's range.
+// Nothing is persisted or notified, and lifecycle states such as snoozes are
+// not replayed.
+func (s *InsightService) Backtest(ctx context.Context, req BacktestRequest) (*BacktestResult, error) {
+	reg, ok := s.registry.Get(req.RuleSet)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownInsightType, req.RuleSet)
+	}
+	if req.To.After(time.Now()) {
+		return nil, fmt.Errorf("%w: date_to is in the future", ErrInvalidBacktest)
+	}
+	asOfs, err := backtestWeeks(req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+
+	var resolver *RuleResolver
+	switch {
+	case req.Proposed != nil && s.rules != nil:
+		resolver, err = s.rules.ProposedResolver(ctx, *req.Proposed)
+	case req.Proposed != nil:
+		resolver = NewRuleResolver([]model.DetectionRule{*req.Proposed})
+	case s.rules != nil:
+		resolver, err = s.rules.Resolver(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([][]Insight, len(asOfs))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(4)
+	for i, asOf := range asOfs {
+		g.Go(func() error {
+			insights, err := runDetector(gctx, reg, resolver, DetectionScope{Filter: req.Filter, Now: asOf})
+			runs[i] = insights
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	result := &BacktestResult{RuleSet: req.RuleSet, From: req.From, To: req.To, Proposed: req.Proposed}
+	result.Evaluations, result.Insights = backtestTimeline(asOfs, runs)
+	for _, in := range result.Insights {
+		result.TotalAlerts += len(in.Episodes)
+	}
+	return result, nil
+}
+
+// backtestWeeks returns from and every seventh day after it up to to.
+func backtestWeeks(from, to time.Time) ([]time.Time, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: date_to is before date_from", ErrInvalidBacktest)
+	}
+	var weeks []time.Time
+	for t := from; !t.After(to); t = t.AddDate(0, 0, 7) {
+		if len(weeks) == MaxBacktestWeeks {
+			return nil, fmt.Errorf("%w: at most %d weekly evaluations", ErrInvalidBacktest, MaxBacktestWeeks)
+		}
+		weeks = append(weeks, t)
+	}
+	return weeks, nil
+}
+
+// backtestTimeline turns the insights found at each evaluation into
+// per-evaluation counts and per-insight episodes, ordered by first firing.
+func backtestTimeline(asOfs []time.Time, runs [][]Insight) ([]BacktestEvaluation, []BacktestInsight) {
+	evaluations := make([]BacktestEvaluation, len(asOfs))
+	index := map[string]int{}
+	var insights []BacktestInsight
+	firing := map[string]bool{}
+
+	for i, asOf := range asOfs {
+		evaluations[i] = BacktestEvaluation{AsOf: asOf, Firing: len(runs[i])}
+		now := map[string]bool{}
+		for _, in := range runs[i] {
+			now[in.InsightID] = true
+			k, ok := index[in.InsightID]
+			if !ok {
+				k = len(insights)
+				index[in.InsightID] = k
+				insights = append(insights, BacktestInsight{
+					InsightID:         in.InsightID,
+					PaymentMethodCode: in.PaymentMethodCode,
+					PaymentMethodName: in.PaymentMethodName,
+					CountryCode:       in.CountryCode,
+				})
+			}
+			bt := &insights[k]
+			if !firing[in.InsightID] {
+				evaluations[i].Fired++
+				bt.Episodes = append(bt.Episodes, BacktestEpisode{FiredAt: asOf, PeakSeverity: in.Severity})
+			}
+			ep := &bt.Episodes[len(bt.Episodes)-1]
+			ep.Evaluations++
+			if severityRank(in.Severity) > severityRank(ep.PeakSeverity) {
+				ep.PeakSeverity = in.Severity
+			}
+		}
+		for id := range firing {
+			if !now[id] {
+				evaluations[i].Resolved++
+				bt := &insights[index[id]]
+				at := asOf
+				bt.Episodes[len(bt.Episodes)-1].ResolvedAt = &at
+			}
+		}
+		firing = now
+	}
+
+	sort.SliceStable(insights, func(i, j int) bool {
+		return insights[i].Episodes[0].FiredAt.Before(insights[j].Episodes[0].FiredAt)
+	})
+	return evaluations, insights
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	case SeverityLow:
+		return 1
+	}
+	return 0
+}
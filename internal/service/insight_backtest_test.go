@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+func TestBacktestWeeks(t *testing.T) {
+	from := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	weeks, err := backtestWeeks(from, from.AddDate(0, 0, 20))
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{from, from.AddDate(0, 0, 7), from.AddDate(0, 0, 14)}, weeks)
+
+	_, err = backtestWeeks(from, from.AddDate(0, 0, -1))
+	assert.True(t, errors.Is(err, ErrInvalidBacktest))
+	_, err = backtestWeeks(from, from.AddDate(0, 0, 7*MaxBacktestWeeks))
+	assert.True(t, errors.Is(err, ErrInvalidBacktest))
+}
+
+func TestBacktestTimeline(t *testing.T) {
+	from := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	asOfs := []time.Time{from, from.AddDate(0, 0, 7), from.AddDate(0, 0, 14), from.AddDate(0, 0, 21)}
+	a := func(severity string) Insight { return Insight{InsightID: "a", Severity: severity} }
+	b := Insight{InsightID: "b", Severity: SeverityLow}
+
+	evaluations, insights := backtestTimeline(asOfs, [][]Insight{
+		{a(SeverityLow)},
+		{a(SeverityHigh), b},
+		{b},
+		{a(SeverityMedium), b},
+	})
+
+	assert.Equal(t, []BacktestEvaluation{
+		{AsOf: asOfs[0], Firing: 1, Fired: 1},
+		{AsOf: asOfs[1], Firing: 2, Fired: 1},
+		{AsOf: asOfs[2], Firing: 1, Resolved: 1},
+		{AsOf: asOfs[3], Firing: 2, Fired: 1},
+	}, evaluations)
+
+	require.Len(t, insights, 2)
+	assert.Equal(t, "a", insights[0].InsightID)
+	require.Len(t, insights[0].Episodes, 2)
+	first := insights[0].Episodes[0]
+	assert.Equal(t, asOfs[0], first.FiredAt)
+	require.NotNil(t, first.ResolvedAt)
+	assert.Equal(t, asOfs[2], *first.ResolvedAt)
+	assert.Equal(t, 2, first.Evaluations)
+	assert.Equal(t, SeverityHigh, first.PeakSeverity)
+	assert.Nil(t, insights[0].Episodes[1].ResolvedAt)
+
+	assert.Equal(t, "b", insights[1].InsightID)
+	require.Len(t, insights[1].Episodes, 1)
+	assert.Equal(t, 3, insights[1].Episodes[0].Evaluations)
+}
+
+func TestBacktestUsesProposedRuleAndEvaluationDate(t *testing.T) {
+	cutoff := time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC)
+	var (
+		mu   sync.Mutex
+		seen []float64
+	)
+	r := NewDetectorRegistry()
+	require.NoError(t, r.Register(DetectorRegistration{
+		Type:   "gap",
+		Params: []ParamSpec{{Name: "min_gap", Default: 10}},
+		Detector: DetectorFunc(func(ctx context.Context, run DetectorRun) ([]Insight, error) {
+			mu.Lock()
+			seen = append(seen, run.ParamsFor("MX", "CARD", "VISA").Get("min_gap"))
+			mu.Unlock()
+			if run.Scope.Now.Before(cutoff) {
+				return nil, nil
+			}
+			return []Insight{{InsightID: "x", Severity: SeverityLow}}, nil
+		}),
+	}))
+	svc := NewInsightService(r, nil, nil)
+	from := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	result, err := svc.Backtest(context.Background(), BacktestRequest{
+		RuleSet:  "gap",
+		From:     from,
+		To:       from.AddDate(0, 0, 14),
+		Proposed: &model.DetectionRule{RuleSet: "gap", CountryCode: "MX", Params: map[string]float64{"min_gap": 4}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{4, 4, 4}, seen)
+	assert.Equal(t, 1, result.TotalAlerts)
+	require.Len(t, result.Insights, 1)
+	assert.Equal(t, cutoff, result.Insights[0].Episodes[0].FiredAt)
+
+	_, err = svc.Backtest(context.Background(), BacktestRequest{RuleSet: "nope", From: from, To: from})
+	assert.True(t, errors.Is(err, ErrUnknownInsightType))
+	_, err = svc.Backtest(context.Background(), BacktestRequest{RuleSet: "gap", From: from, To: time.Now().AddDate(0, 0, 1)})
+	assert.True(t, errors.Is(err, ErrInvalidBacktest))
+}
@@ -0,0 +1,547 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+const (
+	InsightTypeZombie             = "zombie"
+	InsightTypeHiddenGem          = "hidden_gem"
+	InsightTypePerformanceAlert   = "performance_alert"
+	InsightTypeApprovalAnomaly    = "approval_anomaly"
+	InsightTypeVolumeOutage       = "volume_outage"
+	InsightTypeConcentrationRisk  = "concentration_risk"
+	InsightTypeCannibalization    = "cannibalization"
+	InsightTypeStuckPayments      = "stuck_payments"
+	InsightTypeTicketSizeMismatch = "ticket_size_mismatch"
+	InsightTypeSustainedDecline   = "sustained_decline"
+	InsightTypeBreakoutGrowth     = "breakout_growth"
+)
+
+// trendParams are shared by the sustained_decline and breakout_growth
+// detectors.
+var trendParams = []ParamSpec{
+	{Name: "periods", Description: "Complete months fitted, excluding the current one", Default: 6, Min: bound(2), GlobalOnly: true},
+	{Name: "min_r_squared", Description: "Minimum R² of the linear fit (0-1)", Default: 0.7, Min: bound(0), Max: bound(1)},
+	{Name: "min_relative_slope_pct", Description: "Minimum monthly change as a share of average monthly TPV (%)", Default: 10, Stricter: 1, Min: bound(0)},
+	{Name: "min_transactions", Description: "Minimum transactions across the fitted months", Default: 30, Min: bound(0)},
+}
+
+// RegisterBuiltinDetectors registers the detectors that ship with the service.
+func RegisterBuiltinDetectors(registry *DetectorRegistry, repo *repository.InsightRepository, pendingRepo *repository.PendingRepository, bandRepo *repository.AmountBandRepository, trends *TrendService, customRules *repository.CustomRuleRepository, metrics *repository.MetricsRepository) error {
+	regs := []DetectorRegistration{
+		{
+			Type:        InsightTypeZombie,
+			Description: "Methods with an active integration cost but little or no recent volume",
+			Params: []ParamSpec{
+				{Name: "new_method_months", Description: "Methods active for fewer months use the absolute minimum", Default: 3, Min: bound(0)},
+				{Name: "new_method_min_txns", Description: "Minimum 90-day transactions for a new method", Default: 5, Min: bound(0)},
+				{Name: "baseline_floor", Description: "Lowest 90-day baseline for an established method", Default: 10, Min: bound(0)},
+				{Name: "baseline_pct", Description: "Baseline as % of the historical 90-day volume", Default: 10, Stricter: -1, Min: bound(0), Max: bound(100)},
+			},
+			// Scored by 90-day volume as a share of the historical 90-day volume.
+			Severity: SeverityMapping{
+				Inverted: true,
+				Tiers: []SeverityTier{
+					{Severity: SeverityHigh, Threshold: 0.1},
+					{Severity: SeverityMedium, Threshold: 0.3},
+				},
+				Default: SeverityLow,
+			},
+			Detector: &zombieDetector{repo: repo},
+		},
+		{
+			Type:        InsightTypeHiddenGem,
+			Description: "High-approval, profitable, growing methods earning more of their country's revenue than their volume share suggests",
+			Params: []ParamSpec{
+				{Name: "min_approval_rate", Description: "Minimum approval rate (%)", Default: 90, Min: bound(0), Max: bound(100)},
+				{Name: "min_revenue_contribution", Description: "Minimum revenue contribution within the country, or type with within_type (%)", Default: 2, Min: bound(0), Max: bound(100)},
+				{Name: "max_volume_to_revenue", Description: "Volume share must stay below this multiple of revenue share", Default: 0.75, Stricter: -1, Min: bound(0)},
+				{Name: "within_type", Description: "1 computes shares within the method's type in the country instead of the whole country", Default: 0, Min: bound(0), Max: bound(1)},
+				{Name: "momentum_days", Description: "Length of the recent and prior windows compared for growth", Default: 90, Min: bound(1), GlobalOnly: true},
+				{Name: "min_growth_pct", Description: "Minimum change in approved TPV from the prior to the recent window (%)", Default: 0, Min: bound(-100)},
+				{Name: "min_margin_pct", Description: "Minimum margin after integration costs, as a share of approved TPV (%); methods without a cost row are skipped", Default: 0, Max: bound(100)},
+			},
+			// Scored by revenue contribution (%).
+			Severity: SeverityMapping{
+				Tiers: []SeverityTier{
+					{Severity: SeverityHigh, Threshold: 20},
+					{Severity: SeverityMedium, Threshold: 10},
+				},
+				Default: SeverityLow,
+			},
+			Detector: &hiddenGemDetector{repo: repo},
+		},
+		{
+			Type:        InsightTypePerformanceAlert,
+			Description: "Methods whose approval rate is significantly below the other methods of their type in the country",
+			Params: []ParamSpec{
+				{Name: "min_transactions", Description: "Minimum transactions to evaluate a method", Default: 20, Min: bound(0)},
+				{Name: "min_gap_pp", Description: "Minimum gap below the peer rate, however significant (percentage points)", Default: 2, Min: bound(0), Max: bound(100)},
+				{Name: "max_p_value", Description: "One-sided two-proportion test p-value the gap must fall below", Default: 0.01, Stricter: -1, Min: bound(0), Max: bound(1)},
+			},
+			// Scored by the gap in percentage points.
+			Severity: SeverityMapping{
+				Tiers: []SeverityTier{
+					{Severity: SeverityHigh, Threshold: 15},
+				},
+				Default: SeverityMedium,
+			},
+			Detector: &performanceAlertDetector{repo: repo},
+		},
+		{
+			Type:        InsightTypeApprovalAnomaly,
+			Description: "Hourly approval rates falling sharply below their EWMA baseline",
+			Params: []ParamSpec{
+				{Name: "lookback_hours", Description: "Hours of history used to build the baseline", Default: 168, Min: bound(1), GlobalOnly: true},
+				{Name: "ewma_alpha", Description: "Weight of each new hour in the baseline (0-1)", Default: 0.1, Min: bound(0), Max: bound(1)},
+				{Name: "z_threshold", Description: "Standard deviations below baseline that raise an insight", Default: 3, Stricter: 1, Min: bound(0)},
+				{Name: "min_hourly_txns", Description: "Hours with fewer transactions are ignored", Default: 10, Min: bound(0)},
+				{Name: "warmup_hours", Description: "Minimum baseline hours before evaluating", Default: 24, Min: bound(0)},
+				{Name: "max_age_hours", Description: "The latest hour must be at most this old", Default: 2, Min: bound(0)},
+				{Name: "min_stddev_pp", Description: "Floor for the baseline standard deviation (percentage points)", Default: 2, Min: bound(0)},
+			},
+			// Scored by transactions in the anomalous hour.
+			Severity: SeverityMapping{
+				Tiers: []SeverityTier{
+					{Severity: SeverityHigh, Threshold: 200},
+					{Severity: SeverityMedium, Threshold: 50},
+				},
+				Default: SeverityLow,
+			},
+			Detector: &approvalAnomalyDetector{repo: repo},
+		},
+		{
+			Type:        InsightTypeVolumeOutage,
+			Description: "Normally busy methods with improbably few recent transactions",
+			Params: []ParamSpec{
+				{Name: "lookback_weeks", Description: "Weeks of history used to learn hour-of-week arrival rates", Default: 8, Min: bound(1), GlobalOnly: true},
+				{Name: "min_history_weeks", Description: "Minimum weeks of history before evaluating", Default: 2, Min: bound(1)},
+				{Name: "window_minutes", Description: "Recent window compared with the expected arrivals", Default: 30, Min: bound(1), GlobalOnly: true},
+				{Name: "min_expected_txns", Description: "Minimum expected transactions in the window", Default: 5, Min: bound(0)},
+				{Name: "p_threshold", Description: "Raise when P(count <= observed) is below this", Default: 0.001, Stricter: -1, Min: bound(0), Max: bound(1)},
+			},
+			// Scored by expected minus observed transactions.
+			Severity: SeverityMapping{
+				Tiers: []SeverityTier{
+					{Severity: SeverityHigh, Threshold: 100},
+					{Severity: SeverityMedium, Threshold: 20},
+				},
+				Default: SeverityLow,
+			},
+			Detector: &volumeOutageDetector{repo: repo},
+		},
+		{
+			Type:        InsightTypeConcentrationRisk,
+			Description: "Countries where one method carries too much TPV or a method type has no active fallback",
+			Params: []ParamSpec{
+				{Name: "lookback_days", Description: "Days of approved TPV used for shares", Default: 90, Min: bound(1), GlobalOnly: true},
+				{Name: "max_method_share_pct", Description: "Maximum share of country TPV for a single method (%)", Default: 60, Stricter: 1, Min: bound(0), Max: bound(100)},
+				{Name: "min_type_share_pct", Description: "Types carrying at least this share of country TPV need a fallback (%)", Default: 20, Min: bound(0), Max: bound(100)},
+				{Name: "min_country_tpv_usd", Description: "Countries with less TPV are skipped", Default: 1000, Min: bound(0)},
+			},
+			// Scored by the method's share of country TPV (%).
+			Severity: SeverityMapping{
+				Tiers: []SeverityTier{
+					{Severity: SeverityHigh, Threshold: 75},
+					{Severity: SeverityMedium, Threshold: 50},
+				},
+				Default: SeverityLow,
+			},
+			Detector: &concentrationRiskDetector{repo: repo},
+		},
+		{
+			Type:        InsightTypeCannibalization,
+			Description: "Recently launched methods classified as incremental, cannibalizing or mixed",
+			Params: []ParamSpec{
+				{Name: "window_weeks", Description: "Weeks compared before and after launch", Default: 8, Min: bound(1)},
+				{Name: "min_post_weeks", Description: "Full weeks since launch before classifying", Default: 4, Min: bound(1)},
+				{Name: "max_launch_age_days", Description: "Launches older than this are no longer watched", Default: 180, Min: bound(1)},
+				{Name: "min_post_tpv_usd", Description: "Minimum approved TPV of the new method since launch", Default: 1000, Min: bound(0)},
+				{Name: "min_overlap_customers", Description: "Identified adopters needed to use customer overlap", Default: 20, Min: bound(0)},
+				{Name: "cannibalizing_score", Description: "Scores at or above this are cannibalizing (0-1)", Default: 0.6, Min: bound(0), Max: bound(1)},
+				{Name: "incremental_score", Description: "Scores at or below this are incremental (0-1)", Default: 0.3, Min: bound(0), Max: bound(1)},
+			},
+			// Scored by the cannibalization score (0-100).
+			Severity: SeverityMapping{
+				Tiers: []SeverityTier{
+					{Severity: SeverityHigh, Threshold: 60},
+					{Severity: SeverityMedium, Threshold: 30},
+				},
+				Default: SeverityLow,
+			},
+			Detector: &cannibalizationDetector{repo: repo},
+		},
+		{
+			Type:        InsightTypeStuckPayments,
+			Description: "Methods with too many PENDING transactions older than their settlement window",
+			Params: []ParamSpec{
+				{Name: "settlement_window_hours", Description: "Hours a PENDING transaction may take to settle", Default: 24, Min: bound(1)},
+				{Name: "max_overdue_share_pct", Description: "Maximum share of PENDING transactions past the window (%)", Default: 20, Stricter: 1, Min: bound(0), Max: bound(100)},
+				{Name: "min_pending_txns", Description: "Minimum PENDING transactions to evaluate a method", Default: 5, Min: bound(0)},
+			},
+			// Scored by the overdue share of PENDING transactions (%).
+			Severity: SeverityMapping{
+				Tiers: []SeverityTier{
+					{Severity: SeverityHigh, Threshold: 50},
+					{Severity: SeverityMedium, Threshold: 35},
+				},
+				Default: SeverityLow,
+			},
+			Detector: &stuckPaymentsDetector{repo: pendingRepo},
+		},
+		{
+			Type:        InsightTypeTicketSizeMismatch,
+			Description: "Methods whose approval rate collapses above a probable ticket-size limit",
+			Params: []ParamSpec{
+				{Name: "lookback_days", Description: "Days of transactions split into bands", Default: 90, Min: bound(1), GlobalOnly: true},
+				{Name: "band_count", Description: "Equal-count amount bands per method-country", Default: 5, Min: bound(2), GlobalOnly: true},
+				{Name: "min_band_txns", Description: "Minimum transactions on each side of the limit", Default: 20, Min: bound(0)},
+				{Name: "min_drop_pp", Description: "Minimum approval drop above the limit (percentage points)", Default: 20, Stricter: 1, Min: bound(0), Max: bound(100)},
+			},
+			// Scored by the approval drop above the limit in percentage points.
+			Severity: SeverityMapping{
+				Tiers: []SeverityTier{
+					{Severity: SeverityHigh, Threshold: 40},
+					{Severity: SeverityMedium, Threshold: 25},
+				},
+				Default: SeverityLow,
+			},
+			Detector: &ticketSizeMismatchDetector{repo: bandRepo},
+		},
+		{
+			Type:        InsightTypeSustainedDecline,
+			Description: "Methods whose monthly approved TPV has fallen steadily",
+			Params:      trendParams,
+			// Scored by the monthly decline as a share of average TPV (%).
+			Severity: SeverityMapping{
+				Tiers: []SeverityTier{
+					{Severity: SeverityHigh, Threshold: 25},
+					{Severity: SeverityMedium, Threshold: 15},
+				},
+				Default: SeverityLow,
+			},
+			Detector: &trendDetector{trends: trends, insightType: InsightTypeSustainedDecline, sign: -1},
+		},
+		{
+			Type:        InsightTypeBreakoutGrowth,
+			Description: "Methods whose monthly approved TPV has grown steadily",
+			Params:      trendParams,
+			// Scored by the monthly growth as a share of average TPV (%).
+			Severity: SeverityMapping{
+				Tiers: []SeverityTier{
+					{Severity: SeverityHigh, Threshold: 50},
+					{Severity: SeverityMedium, Threshold: 25},
+				},
+				Default: SeverityLow,
+			},
+			Detector: &trendDetector{trends: trends, insightType: InsightTypeBreakoutGrowth, sign: 1},
+		},
+		{
+			Type:        InsightTypeCustomRule,
+			Description: "Methods matching an analyst-defined rule from /admin/insight-rules",
+			// Each rule scores its own score_field against its own tiers.
+			Severity: SeverityMapping{Default: SeverityMedium},
+			Detector: &customRuleDetector{rules: customRules, metrics: metrics},
+		},
+	}
+
+	for _, reg := range regs {
+		if err := registry.Register(reg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type zombieDetector struct {
+	repo *repository.InsightRepository
+}
+
+func (d *zombieDetector) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	candidates, err := d.repo.GetZombieCandidates(ctx, run.Scope.Filter, run.Scope.Now)
+	if err != nil {
+		return nil, err
+	}
+
+	var insights []Insight
+
+	for _, c := range candidates {
+		params := run.ParamsFor(c.CountryCode, c.PaymentMethodType, c.PaymentMethodCode)
+		var isZombie bool
+		var threshold float64
+
+		if float64(c.MonthsActive) < params.Get("new_method_months") {
+			// New method: absolute threshold
+			threshold = params.Get("new_method_min_txns")
+			isZombie = float64(c.TxnCount90d) < threshold
+		} else {
+			// Established: relative to baseline
+			baseline := math.Max(params.Get("baseline_floor"), c.HistoricalMonthlyAvg*3*params.Get("baseline_pct")/100)
+			threshold = baseline
+			isZombie = float64(c.TxnCount90d) < baseline
+		}
+
+		if !isZombie {
+			continue
+		}
+
+		ratio := 1.0
+		if c.TxnCount90d == 0 {
+			ratio = 0
+		} else if c.HistoricalMonthlyAvg > 0 {
+			ratio = float64(c.TxnCount90d) / (c.HistoricalMonthlyAvg * 3)
+		}
+
+		insights = append(insights, Insight{
+			InsightID:         hashID(InsightTypeZombie, c.PaymentMethodCode, c.CountryCode),
+			Type:              InsightTypeZombie,
+			Severity:          run.SeverityFor(params).Map(ratio),
+			PaymentMethodCode: c.PaymentMethodCode,
+			PaymentMethodName: c.PaymentMethodName,
+			CountryCode:       c.CountryCode,
+			TriggeringMetric:  "txn_count_90d",
+			MetricValue:       float64(c.TxnCount90d),
+			Threshold:         threshold,
+			Description:       fmt.Sprintf("%s in %s has only %d transactions in 90 days with active integration costing $%.2f/month", c.PaymentMethodName, c.CountryCode, c.TxnCount90d, c.MonthlyCostUSD),
+			RecommendedAction: "Review integration cost vs. value. Consider deactivating or renegotiating terms.",
+			SupportingData: map[string]interface{}{
+				"monthly_cost_usd":       c.MonthlyCostUSD,
+				"historical_monthly_avg": c.HistoricalMonthlyAvg,
+				"months_active":          c.MonthsActive,
+				"payment_method_type":    c.PaymentMethodType,
+			},
+			EstimatedImpactUSD: zombieImpact(c.MonthlyCostUSD),
+			Thresholds:         params,
+			GeneratedAt:        run.Scope.Now,
+		})
+	}
+
+	return insights, nil
+}
+
+// EvidenceScope covers the 90 days of volume compared with the method's own
+// history.
+func (d *zombieDetector) EvidenceScope(in Insight) EvidenceScope {
+	return EvidenceScope{
+		From:   in.GeneratedAt.AddDate(0, 0, -90),
+		To:     in.GeneratedAt,
+		Metric: EvidenceMetricTransactions,
+		Peers:  PeerGroupNone,
+	}
+}
+
+type hiddenGemDetector struct {
+	repo *repository.InsightRepository
+}
+
+func (d *hiddenGemDetector) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	candidates, err := d.repo.GetHiddenGemCandidates(ctx, run.Scope.Filter, run.Scope.Now, int(run.Params.Get("momentum_days")))
+	if err != nil {
+		return nil, err
+	}
+
+	var insights []Insight
+
+	for _, c := range candidates {
+		params := run.ParamsFor(c.CountryCode, c.PaymentMethodType, c.PaymentMethodCode)
+		g, ok := hiddenGemSignal(c, params)
+		if !ok {
+			continue
+		}
+
+		market := c.CountryCode
+		if g.Peers == PeerGroupType {
+			market = fmt.Sprintf("%s %s methods", c.CountryCode, c.PaymentMethodType)
+		}
+		growth := "no approved TPV in the prior window"
+		supporting := map[string]interface{}{
+			"approval_rate":     c.ApprovalRate,
+			"share_scope":       g.Peers,
+			"volume_share_pct":  g.VolumeShare,
+			"tpv_usd":           c.TpvUSD,
+			"transaction_count": c.TransactionCount,
+			"active_months":     c.ActiveMonths,
+			"recent_tpv_usd":    c.RecentTpvUSD,
+			"prior_tpv_usd":     c.PriorTpvUSD,
+			"margin_pct":        *g.MarginPct,
+		}
+		if g.GrowthPct != nil {
+			growth = fmt.Sprintf("%+.0f%% approved TPV growth", *g.GrowthPct)
+			supporting["growth_pct"] = *g.GrowthPct
+		}
+
+		insights = append(insights, Insight{
+			InsightID:         hashID(InsightTypeHiddenGem, c.PaymentMethodCode, c.CountryCode),
+			Type:              InsightTypeHiddenGem,
+			Severity:          run.SeverityFor(params).Map(g.RevenueContribution),
+			PaymentMethodCode: c.PaymentMethodCode,
+			PaymentMethodName: c.PaymentMethodName,
+			CountryCode:       c.CountryCode,
+			TriggeringMetric:  "revenue_contribution_pct",
+			MetricValue:       g.RevenueContribution,
+			Threshold:         params.Get("min_revenue_contribution"),
+			Description: fmt.Sprintf("%s in %s has %.1f%% approval rate and %.1f%% of %s revenue but only %.1f%% of its volume, with a %.0f%% margin and %s",
+				c.PaymentMethodName, c.CountryCode, c.ApprovalRate, g.RevenueContribution, market, g.VolumeShare, *g.MarginPct, growth),
+			RecommendedAction:  "Increase merchant adoption and volume for this high-performing method.",
+			SupportingData:     supporting,
+			EstimatedImpactUSD: hiddenGemImpact(c.TpvUSD, c.ActiveMonths, g.RevenueContribution, g.VolumeShare),
+			Thresholds:         params,
+			GeneratedAt:        run.Scope.Now,
+		})
+	}
+
+	return insights, nil
+}
+
+type hiddenGemResult struct {
+	Peers               string
+	RevenueContribution float64
+	VolumeShare         float64
+	// MarginPct is nil when the method has no integration cost row, so its
+	// profitability is unknown.
+	MarginPct *float64
+	// GrowthPct is nil when the method had no approved TPV in the prior
+	// window, so there is nothing to grow from. Such a method passes the
+	// growth check only if it has approved TPV in the recent window.
+	GrowthPct *float64
+}
+
+// hiddenGemSignal picks the country or type shares and reports whether the
+// method is high-approval, under-used relative to its revenue, profitable
+// after integration costs and not losing momentum. A method without a cost
+// row cannot show a margin and is not a gem.
+func hiddenGemSignal(c repository.HiddenGemCandidate, params DetectorParams) (hiddenGemResult, bool) {
+	g := hiddenGemResult{Peers: PeerGroupCountry, RevenueContribution: c.RevenueContribution, VolumeShare: c.VolumeShare}
+	if params.Get("within_type") > 0 {
+		g.Peers, g.RevenueContribution, g.VolumeShare = PeerGroupType, c.TypeRevenueContribution, c.TypeVolumeShare
+	}
+	if c.TpvUSD > 0 && c.HasCost {
+		cost := c.ActiveMonths*c.MonthlyFixedCostUSD + float64(c.TransactionCount)*c.PerTransactionCostUSD + c.TpvUSD*c.PercentageFee
+		margin := (c.TpvUSD - cost) / c.TpvUSD * 100
+		g.MarginPct = &margin
+	}
+	if c.PriorTpvUSD > 0 {
+		growth := (c.RecentTpvUSD/c.PriorTpvUSD - 1) * 100
+		g.GrowthPct = &growth
+	}
+
+	growing := c.RecentTpvUSD > 0
+	if g.GrowthPct != nil {
+		growing = *g.GrowthPct >= params.Get("min_growth_pct")
+	}
+
+	ok := c.ApprovalRate >= params.Get("min_approval_rate") &&
+		g.RevenueContribution >= params.Get("min_revenue_contribution") &&
+		g.VolumeShare < g.RevenueContribution*params.Get("max_volume_to_revenue") &&
+		g.MarginPct != nil && *g.MarginPct >= params.Get("min_margin_pct") &&
+		growing
+	return g, ok
+}
+
+// EvidenceScope covers all history; revenue is shared with the country, or
+// with the method's type when the insight was detected within_type.
+func (d *hiddenGemDetector) EvidenceScope(in Insight) EvidenceScope {
+	peers := PeerGroupCountry
+	if DetectorParams(in.Thresholds).Get("within_type") > 0 {
+		peers = PeerGroupType
+	}
+	return EvidenceScope{To: in.GeneratedAt, Metric: EvidenceMetricTPVShare, Peers: peers}
+}
+
+type performanceAlertDetector struct {
+	repo *repository.InsightRepository
+}
+
+func (d *performanceAlertDetector) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	candidates, err := d.repo.GetPerformanceAlertCandidates(ctx, run.Scope.Filter, run.Scope.Now)
+	if err != nil {
+		return nil, err
+	}
+
+	var insights []Insight
+
+	for _, c := range candidates {
+		params := run.ParamsFor(c.CountryCode, c.PaymentMethodType, c.PaymentMethodCode)
+		if float64(c.TransactionCount) < params.Get("min_transactions") || c.PeerTransactionCount == 0 {
+			continue
+		}
+
+		minGap := params.Get("min_gap_pp")
+
+		gap := c.PeerApprovalRate - c.ApprovalRate
+		if gap < minGap {
+			continue
+		}
+		test := twoProportionTest(c.ApprovedCount, c.TransactionCount, c.PeerApprovedCount, c.PeerTransactionCount)
+		if test.PValue >= params.Get("max_p_value") {
+			continue
+		}
+
+		insights = append(insights, Insight{
+			InsightID:         hashID(InsightTypePerformanceAlert, c.PaymentMethodCode, c.CountryCode),
+			Type:              InsightTypePerformanceAlert,
+			Severity:          run.SeverityFor(params).Map(gap),
+			PaymentMethodCode: c.PaymentMethodCode,
+			PaymentMethodName: c.PaymentMethodName,
+			CountryCode:       c.CountryCode,
+			TriggeringMetric:  "approval_rate",
+			MetricValue:       c.ApprovalRate,
+			Threshold:         c.PeerApprovalRate - minGap,
+			Description: fmt.Sprintf("%s in %s has %.1f%% approval rate, %.1fpp below the %.1f%% of other %s methods (p=%.2g)",
+				c.PaymentMethodName, c.CountryCode, c.ApprovalRate, gap, c.PeerApprovalRate, c.PaymentMethodType, test.PValue),
+			RecommendedAction: "Investigate decline reasons. Check provider configuration and fraud rules.",
+			SupportingData: map[string]interface{}{
+				"peer_approval_rate":     c.PeerApprovalRate,
+				"peer_transaction_count": c.PeerTransactionCount,
+				"peer_methods":           c.PeerMethods,
+				"gap_pp":                 gap,
+				"z_score":                test.ZScore,
+				"p_value":                test.PValue,
+				"effect_size_h":          test.EffectSize,
+				"payment_method_type":    c.PaymentMethodType,
+				"transaction_count":      c.TransactionCount,
+				"volume_usd":             c.VolumeUSD,
+				"active_months":          c.ActiveMonths,
+			},
+			EstimatedImpactUSD: performanceAlertImpact(c.VolumeUSD, c.ActiveMonths, gap),
+			Thresholds:         params,
+			GeneratedAt:        run.Scope.Now,
+		})
+	}
+
+	return insights, nil
+}
+
+// EvidenceScope covers all history against the method's type in the country.
+func (d *performanceAlertDetector) EvidenceScope(in Insight) EvidenceScope {
+	return EvidenceScope{To: in.GeneratedAt, Metric: EvidenceMetricApprovalRate, Peers: PeerGroupType}
+}
+
+// proportionTest is a one-sided two-proportion z-test of whether a method
+// approves less often than its peers. EffectSize is Cohen's h, positive when
+// the method trails.
+type proportionTest struct {
+	ZScore     float64
+	PValue     float64
+	EffectSize float64
+}
+
+func twoProportionTest(approved, total, peerApproved, peerTotal int) proportionTest {
+	p1 := float64(approved) / float64(total)
+	p2 := float64(peerApproved) / float64(peerTotal)
+	h := 2*math.Asin(math.Sqrt(p2)) - 2*math.Asin(math.Sqrt(p1))
+
+	pooled := float64(approved+peerApproved) / float64(total+peerTotal)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(total) + 1/float64(peerTotal)))
+	if se == 0 {
+		// Everything approved or everything declined on both sides: no gap.
+		return proportionTest{PValue: 1, EffectSize: h}
+	}
+	z := (p2 - p1) / se
+	return proportionTest{ZScore: z, PValue: 0.5 * math.Erfc(z/math.Sqrt2), EffectSize: h}
+}
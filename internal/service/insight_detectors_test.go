@@ -0,0 +1,117 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func TestTwoProportionTest(t *testing.T) {
+	// 60% of 100 against peers approving 850 of 1000.
+	test := twoProportionTest(60, 100, 850, 1000)
+	assert.InDelta(t, 6.3058, test.ZScore, 1e-4)
+	assert.InDelta(t, 1.434e-10, test.PValue, 1e-12)
+	assert.InDelta(t, 0.5740, test.EffectSize, 1e-4)
+
+	// A 15pp gap on 20 transactions is not significant at 1%.
+	small := twoProportionTest(14, 20, 850, 1000)
+	assert.InDelta(t, 0.0325, small.PValue, 1e-4)
+
+	// On large volumes a 1.5pp gap is significant but a small effect; the
+	// min_gap_pp floor keeps it quiet.
+	large := twoProportionTest(9000, 10000, 91500, 100000)
+	assert.Less(t, large.PValue, 1e-6)
+	assert.InDelta(t, 0.0518, large.EffectSize, 1e-4)
+
+	ahead := twoProportionTest(95, 100, 800, 1000)
+	assert.Greater(t, ahead.PValue, 0.99, "one-sided: leading peers is never an alert")
+	assert.Negative(t, ahead.EffectSize)
+
+	flat := twoProportionTest(20, 20, 300, 300)
+	assert.Equal(t, 1.0, flat.PValue)
+	assert.Zero(t, flat.EffectSize)
+}
+
+func TestHiddenGemSignal(t *testing.T) {
+	params := DetectorParams{
+		"min_approval_rate":        90,
+		"min_revenue_contribution": 2,
+		"max_volume_to_revenue":    0.75,
+		"within_type":              0,
+		"min_growth_pct":           0,
+		"min_margin_pct":           0,
+	}
+	gem := repository.HiddenGemCandidate{
+		ApprovalRate:            95,
+		RevenueContribution:     25,
+		VolumeShare:             10,
+		TypeRevenueContribution: 90,
+		TypeVolumeShare:         80,
+		TpvUSD:                  3000,
+		TransactionCount:        20,
+		ActiveMonths:            6,
+		RecentTpvUSD:            1200,
+		PriorTpvUSD:             1000,
+		HasCost:                 true,
+		MonthlyFixedCostUSD:     70,
+		PerTransactionCostUSD:   0.5,
+		PercentageFee:           0.01,
+	}
+
+	g, ok := hiddenGemSignal(gem, params)
+	require.True(t, ok)
+	assert.Equal(t, PeerGroupCountry, g.Peers)
+	// 3000 - (6*70 + 20*0.5 + 3000*0.01) = 2540.
+	require.NotNil(t, g.MarginPct)
+	assert.InDelta(t, 84.6667, *g.MarginPct, 1e-4)
+	require.NotNil(t, g.GrowthPct)
+	assert.InDelta(t, 20, *g.GrowthPct, 1e-9)
+
+	withinType := DetectorParams{"within_type": 1}
+	for k, v := range params {
+		if k != "within_type" {
+			withinType[k] = v
+		}
+	}
+	g, ok = hiddenGemSignal(gem, withinType)
+	assert.False(t, ok, "80%% of the type's volume against 90%% of its revenue is not under-used")
+	assert.Equal(t, PeerGroupType, g.Peers)
+	assert.Equal(t, 90.0, g.RevenueContribution)
+
+	fading := gem
+	fading.RecentTpvUSD = 950
+	_, ok = hiddenGemSignal(fading, params)
+	assert.False(t, ok, "a 5%% decline is below min_growth_pct")
+
+	fresh := gem
+	fresh.PriorTpvUSD = 0
+	g, ok = hiddenGemSignal(fresh, params)
+	assert.True(t, ok, "recent TPV with none before is new growth")
+	assert.Nil(t, g.GrowthPct)
+
+	dormant := gem
+	dormant.PriorTpvUSD, dormant.RecentTpvUSD = 0, 0
+	g, ok = hiddenGemSignal(dormant, params)
+	assert.False(t, ok, "no TPV in either window is not growth")
+	assert.Nil(t, g.GrowthPct)
+
+	uncosted := gem
+	uncosted.HasCost = false
+	uncosted.MonthlyFixedCostUSD, uncosted.PerTransactionCostUSD, uncosted.PercentageFee = 0, 0, 0
+	g, ok = hiddenGemSignal(uncosted, params)
+	assert.False(t, ok, "no cost row leaves the margin unknown, not 100%%")
+	assert.Nil(t, g.MarginPct)
+
+	costly := gem
+	costly.MonthlyFixedCostUSD = 600
+	_, ok = hiddenGemSignal(costly, params)
+	assert.False(t, ok, "fixed costs above the TPV make a negative margin")
+
+	declined := gem
+	declined.TpvUSD = 0
+	_, ok = hiddenGemSignal(declined, params)
+	assert.False(t, ok, "no approved TPV has no margin to earn")
+}
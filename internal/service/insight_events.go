@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+// InsightEvent is emitted when a tracked insight opens, resolves, changes
+// state or changes severity.
+type InsightEvent struct {
+	Type             string                   `json:"event"`
+	Insight          model.TrackedInsight     `json:"insight"`
+	Transition       *model.InsightTransition `json:"transition,omitempty"`
+	PreviousSeverity string                   `json:"previous_severity,omitempty"`
+	OccurredAt       time.Time                `json:"occurred_at"`
+}
+
+// InsightNotifier receives the events of every detection run and manual
+// transition. It is called inside the transaction that records them and must
+// write through tx, so an event is queued exactly when its change commits.
+type InsightNotifier interface {
+	NotifyInsightEvents(ctx context.Context, tx pgx.Tx, events []InsightEvent) error
+}
+
+// AddNotifier registers a notifier; call it before serving requests.
+func (s *InsightService) AddNotifier(n InsightNotifier) {
+	s.notifiers = append(s.notifiers, n)
+}
+
+func (s *InsightService) notify(ctx context.Context, tx pgx.Tx, events []InsightEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	for _, n := range s.notifiers {
+		if err := n.NotifyInsightEvents(ctx, tx, events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventForTransition names the event a state change produces.
+func eventForTransition(tr model.InsightTransition) string {
+	switch {
+	case tr.ToState == model.InsightStateResolved || tr.ToState == model.InsightStateAutoResolved:
+		return model.InsightEventResolved
+	case tr.ToState == model.InsightStateOpen &&
+		(tr.FromState == "" || tr.FromState == model.InsightStateResolved || tr.FromState == model.InsightStateAutoResolved):
+		return model.InsightEventOpened
+	default:
+		return model.InsightEventStateChanged
+	}
+}
+
+func transitionEvent(t model.TrackedInsight, tr model.InsightTransition) InsightEvent {
+	return InsightEvent{
+		Type:       eventForTransition(tr),
+		Insight:    t,
+		Transition: &tr,
+		OccurredAt: tr.CreatedAt,
+	}
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+// Metrics an evidence series can follow.
+const (
+	EvidenceMetricTransactions = "transaction_count"
+	EvidenceMetricApprovalRate = "approval_rate"
+	EvidenceMetricTPV          = "tpv_usd"
+	EvidenceMetricTPVShare     = "tpv_share_pct"
+)
+
+// Peer groups an insight can be compared against.
+const (
+	PeerGroupNone    = "none"
+	PeerGroupType    = "type_in_country"
+	PeerGroupCountry = "country"
+)
+
+// EvidenceScope is the data an insight was computed from. A zero From means
+// the detector reads all history up to To.
+type EvidenceScope struct {
+	From     time.Time
+	To       time.Time
+	Metric   string
+	Peers    string
+	Statuses []string
+}
+
+// EvidenceScoper is implemented by detectors that know the window, metric
+// and peers behind their insights. Other detectors get defaultEvidenceScope.
+type EvidenceScoper interface {
+	EvidenceScope(in Insight) EvidenceScope
+}
+
+// defaultEvidenceScope covers the 90 days before detection against the
+// method's type in the country.
+func defaultEvidenceScope(in Insight) EvidenceScope {
+	return EvidenceScope{
+		From:   in.GeneratedAt.AddDate(0, 0, -90),
+		To:     in.GeneratedAt,
+		Metric: EvidenceMetricApprovalRate,
+		Peers:  PeerGroupType,
+	}
+}
+
+type EvidenceWindow struct {
+	From *time.Time `json:"from"`
+	To   time.Time  `json:"to"`
+}
+
+type EvidencePeerGroup struct {
+	Kind              string   `json:"kind"`
+	Description       string   `json:"description"`
+	CountryCode       string   `json:"country_code,omitempty"`
+	PaymentMethodType string   `json:"payment_method_type,omitempty"`
+	PaymentMethods    []string `json:"payment_methods"`
+}
+
+// EvidencePoint is one week of the method and its peers. Value is the
+// evidence metric for the method; PeerValue is the same metric per peer
+// method, when there are peers.
+type EvidencePoint struct {
+	Week                 time.Time `json:"week"`
+	Value                float64   `json:"value"`
+	PeerValue            *float64  `json:"peer_value,omitempty"`
+	TransactionCount     int       `json:"transaction_count"`
+	ApprovedCount        int       `json:"approved_count"`
+	ApprovalRate         float64   `json:"approval_rate"`
+	TpvUSD               float64   `json:"tpv_usd"`
+	PeerTransactionCount int       `json:"peer_transaction_count"`
+	PeerApprovalRate     float64   `json:"peer_approval_rate"`
+	PeerTpvUSD           float64   `json:"peer_tpv_usd"`
+}
+
+type InsightEvidence struct {
+	Insight      Insight             `json:"insight"`
+	Window       EvidenceWindow      `json:"window"`
+	Metric       string              `json:"metric"`
+	PeerGroup    EvidencePeerGroup   `json:"peer_group"`
+	WeeklySeries []EvidencePoint     `json:"weekly_series"`
+	Sample       []model.Transaction `json:"sample"`
+}
+
+type EvidenceService struct {
+	registry *DetectorRegistry
+	states   *repository.InsightStateRepository
+	pmRepo   *repository.PaymentMethodRepository
+	repo     *repository.EvidenceRepository
+}
+
+func NewEvidenceService(registry *DetectorRegistry, states *repository.InsightStateRepository, pmRepo *repository.PaymentMethodRepository, repo *repository.EvidenceRepository) *EvidenceService {
+	return &EvidenceService{registry: registry, states: states, pmRepo: pmRepo, repo: repo}
+}
+
+// GetEvidence rebuilds the data behind a tracked insight's latest snapshot.
+// It returns pgx.ErrNoRows for insights that were never detected.
+func (s *EvidenceService) GetEvidence(ctx context.Context, id string, sampleSize int) (*InsightEvidence, error) {
+	tracked, err := s.states.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var in Insight
+	if err := json.Unmarshal(tracked.Snapshot, &in); err != nil {
+		return nil, fmt.Errorf("decode insight snapshot: %w", err)
+	}
+	pm, err := s.pmRepo.FindByCode(ctx, in.PaymentMethodCode)
+	if err != nil {
+		return nil, fmt.Errorf("find payment method %s: %w", in.PaymentMethodCode, err)
+	}
+
+	scope := defaultEvidenceScope(in)
+	if reg, ok := s.registry.Get(in.Type); ok {
+		if scoper, ok := reg.Detector.(EvidenceScoper); ok {
+			scope = scoper.EvidenceScope(in)
+		}
+	}
+
+	group := EvidencePeerGroup{Kind: scope.Peers, PaymentMethods: []string{}}
+	switch scope.Peers {
+	case PeerGroupType:
+		group.CountryCode, group.PaymentMethodType = in.CountryCode, pm.Type
+		group.Description = fmt.Sprintf("Other %s methods with transactions in %s during the window", pm.Type, in.CountryCode)
+	case PeerGroupCountry:
+		group.CountryCode = in.CountryCode
+		group.Description = fmt.Sprintf("Other methods with transactions in %s during the window", in.CountryCode)
+	default:
+		group.Kind = PeerGroupNone
+		group.Description = "None: the method is compared with its own history"
+	}
+	if group.Kind != PeerGroupNone {
+		group.PaymentMethods, err = s.repo.GetPeerMethods(ctx, in.PaymentMethodCode, in.CountryCode,
+			group.PaymentMethodType, scope.From, scope.To)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	weeks, err := s.repo.GetWeeklyEvidence(ctx, in.PaymentMethodCode, in.CountryCode, group.PaymentMethods, scope.From, scope.To)
+	if err != nil {
+		return nil, err
+	}
+	sample, err := s.repo.SampleTransactions(ctx, in.PaymentMethodCode, in.CountryCode, scope.Statuses,
+		scope.From, scope.To, sampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	ev := &InsightEvidence{
+		Insight:      in,
+		Window:       EvidenceWindow{To: scope.To},
+		Metric:       scope.Metric,
+		PeerGroup:    group,
+		WeeklySeries: evidencePoints(weeks, scope.Metric, len(group.PaymentMethods)),
+		Sample:       sample,
+	}
+	if !scope.From.IsZero() {
+		ev.Window.From = &scope.From
+	}
+	return ev, nil
+}
+
+// evidencePoints derives rates and the metric value for each week. Peer
+// counts and TPV are averaged over the peer methods.
+func evidencePoints(weeks []repository.EvidenceWeek, metric string, peers int) []EvidencePoint {
+	points := make([]EvidencePoint, 0, len(weeks))
+	for _, w := range weeks {
+		p := EvidencePoint{
+			Week:                 w.Week,
+			TransactionCount:     w.TransactionCount,
+			ApprovedCount:        w.ApprovedCount,
+			ApprovalRate:         approvalRate(w.ApprovedCount, w.TransactionCount),
+			TpvUSD:               w.TpvUSD,
+			PeerTransactionCount: w.PeerTransactionCount,
+			PeerApprovalRate:     approvalRate(w.PeerApprovedCount, w.PeerTransactionCount),
+			PeerTpvUSD:           w.PeerTpvUSD,
+		}
+		var peerValue float64
+		hasPeers := peers > 0
+		switch metric {
+		case EvidenceMetricTransactions:
+			p.Value = float64(w.TransactionCount)
+			peerValue = float64(w.PeerTransactionCount) / float64(max(peers, 1))
+		case EvidenceMetricTPV:
+			p.Value = w.TpvUSD
+			peerValue = w.PeerTpvUSD / float64(max(peers, 1))
+		case EvidenceMetricTPVShare:
+			if total := w.TpvUSD + w.PeerTpvUSD; total > 0 {
+				p.Value = w.TpvUSD / total * 100
+			}
+			hasPeers = false // the share already includes the peers
+		default:
+			p.Value = p.ApprovalRate
+			peerValue = p.PeerApprovalRate
+		}
+		if hasPeers {
+			p.PeerValue = &peerValue
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// supportingTime reads a timestamp from supporting data, which holds
+// time.Time values when fresh and RFC 3339 strings when decoded from a
+// snapshot.
+func supportingTime(in Insight, key string) (time.Time, bool) {
+	switch v := in.SupportingData[key].(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// supportingNumber reads a number from supporting data, which holds Go
+// numbers when fresh and float64 when decoded from a snapshot.
+func supportingNumber(in Insight, key string) float64 {
+	switch v := in.SupportingData[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func TestEvidencePoints(t *testing.T) {
+	week := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	weeks := []repository.EvidenceWeek{{
+		Week:                 week,
+		TransactionCount:     10,
+		ApprovedCount:        8,
+		TpvUSD:               300,
+		PeerTransactionCount: 40,
+		PeerApprovedCount:    36,
+		PeerTpvUSD:           900,
+	}}
+
+	t.Run("approval rate against peers", func(t *testing.T) {
+		points := evidencePoints(weeks, EvidenceMetricApprovalRate, 2)
+		require.Len(t, points, 1)
+		assert.Equal(t, week, points[0].Week)
+		assert.InDelta(t, 80, points[0].Value, 1e-9)
+		require.NotNil(t, points[0].PeerValue)
+		assert.InDelta(t, 90, *points[0].PeerValue, 1e-9)
+	})
+
+	t.Run("volume is averaged per peer", func(t *testing.T) {
+		points := evidencePoints(weeks, EvidenceMetricTransactions, 2)
+		assert.InDelta(t, 10, points[0].Value, 1e-9)
+		require.NotNil(t, points[0].PeerValue)
+		assert.InDelta(t, 20, *points[0].PeerValue, 1e-9)
+
+		points = evidencePoints(weeks, EvidenceMetricTPV, 3)
+		assert.InDelta(t, 300, points[0].Value, 1e-9)
+		assert.InDelta(t, 300, *points[0].PeerValue, 1e-9)
+	})
+
+	t.Run("share has no peer value", func(t *testing.T) {
+		points := evidencePoints(weeks, EvidenceMetricTPVShare, 2)
+		assert.InDelta(t, 25, points[0].Value, 1e-9)
+		assert.Nil(t, points[0].PeerValue)
+	})
+
+	t.Run("no peers", func(t *testing.T) {
+		points := evidencePoints([]repository.EvidenceWeek{{Week: week, TransactionCount: 5, ApprovedCount: 5}},
+			EvidenceMetricApprovalRate, 0)
+		assert.InDelta(t, 100, points[0].Value, 1e-9)
+		assert.Nil(t, points[0].PeerValue)
+	})
+}
+
+func TestEvidenceScopeFromSnapshot(t *testing.T) {
+	launched := time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC)
+	fresh := Insight{
+		Type:        InsightTypeCannibalization,
+		GeneratedAt: launched.AddDate(0, 3, 0),
+		SupportingData: map[string]interface{}{
+			"launched_at": launched,
+			"pre_weeks":   8,
+			"post_weeks":  6,
+		},
+	}
+	raw, err := json.Marshal(fresh)
+	require.NoError(t, err)
+	var decoded Insight
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+
+	d := &cannibalizationDetector{}
+	for _, in := range []Insight{fresh, decoded} {
+		scope := d.EvidenceScope(in)
+		assert.True(t, launched.AddDate(0, 0, -56).Equal(scope.From))
+		assert.True(t, launched.AddDate(0, 0, 42).Equal(scope.To))
+		assert.Equal(t, PeerGroupCountry, scope.Peers)
+	}
+
+	delete(decoded.SupportingData, "launched_at")
+	assert.Equal(t, defaultEvidenceScope(decoded), d.EvidenceScope(decoded))
+}
+
+func TestTrendEvidenceScope(t *testing.T) {
+	in := Insight{
+		GeneratedAt: time.Date(2026, 7, 15, 10, 0, 0, 0, time.UTC),
+		Thresholds:  DetectorParams{"periods": 6},
+	}
+	scope := (&trendDetector{}).EvidenceScope(in)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), scope.From)
+	assert.Equal(t, time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), scope.To)
+	assert.Equal(t, EvidenceMetricTPV, scope.Metric)
+}
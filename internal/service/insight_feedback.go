@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+var (
+	ErrInvalidFeedback            = errors.New("invalid insight feedback")
+	ErrAdaptiveThresholdsDisabled = errors.New("adaptive threshold mode is disabled")
+	ErrUnknownSuggestion          = errors.New("unknown threshold suggestion")
+)
+
+// Adaptive mode proposes a stricter setting once at least adaptiveMinLabels
+// insights detected with the current global value were labelled useful or
+// false positive and their precision is below adaptiveTargetPrecision. Each
+// proposal moves the parameter by a factor of adaptiveStep.
+const (
+	adaptiveMinLabels       = 10
+	adaptiveTargetPrecision = 0.7
+	adaptiveStep            = 1.2
+)
+
+// InsightFeedbackService stores analyst verdicts on insights, reports
+// detector precision and, in adaptive mode, suggests threshold changes that
+// an admin can accept as global detection rules.
+type InsightFeedbackService struct {
+	repo     *repository.InsightFeedbackRepository
+	rules    *DetectionRuleService
+	registry *DetectorRegistry
+	adaptive bool
+}
+
+func NewInsightFeedbackService(repo *repository.InsightFeedbackRepository, rules *DetectionRuleService, registry *DetectorRegistry, adaptive bool) *InsightFeedbackService {
+	return &InsightFeedbackService{repo: repo, rules: rules, registry: registry, adaptive: adaptive}
+}
+
+// AddFeedback records a verdict on a tracked insight. False positives and
+// duplicates need a reason. It returns pgx.ErrNoRows for unknown insights.
+func (s *InsightFeedbackService) AddFeedback(ctx context.Context, f model.InsightFeedback) (*model.InsightFeedback, error) {
+	f.Reason = strings.TrimSpace(f.Reason)
+	switch f.Verdict {
+	case model.FeedbackUseful:
+	case model.FeedbackFalsePositive, model.FeedbackDuplicate:
+		if f.Reason == "" {
+			return nil, fmt.Errorf("%w: a reason is required for %s", ErrInvalidFeedback, f.Verdict)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown verdict %q", ErrInvalidFeedback, f.Verdict)
+	}
+	return s.repo.AddFeedback(ctx, f)
+}
+
+func (s *InsightFeedbackService) ListFeedback(ctx context.Context, insightID string) ([]model.InsightFeedback, error) {
+	return s.repo.ListFeedback(ctx, insightID)
+}
+
+// FeedbackCounts tallies the latest verdict on each insight. Precision is
+// useful / (useful + false positive); duplicates count toward neither, and
+// it stays nil until an insight is labelled either way, so "no labels" is
+// not reported as 0%.
+type FeedbackCounts struct {
+	Useful        int      `json:"useful"`
+	FalsePositive int      `json:"false_positive"`
+	Duplicate     int      `json:"duplicate"`
+	Precision     *float64 `json:"precision"`
+}
+
+func (c *FeedbackCounts) add(verdict string) {
+	switch verdict {
+	case model.FeedbackUseful:
+		c.Useful++
+	case model.FeedbackFalsePositive:
+		c.FalsePositive++
+	case model.FeedbackDuplicate:
+		c.Duplicate++
+	}
+	if n := c.labelled(); n > 0 {
+		p := math.Round(float64(c.Useful)/float64(n)*10000) / 10000
+		c.Precision = &p
+	}
+}
+
+func (c FeedbackCounts) labelled() int {
+	return c.Useful + c.FalsePositive
+}
+
+// ThresholdSettingPrecision is the precision of the insights detected with
+// one set of parameters.
+type ThresholdSettingPrecision struct {
+	Thresholds DetectorParams `json:"thresholds"`
+	FeedbackCounts
+}
+
+type DetectorPrecision struct {
+	InsightType string `json:"insight_type"`
+	FeedbackCounts
+	Settings []ThresholdSettingPrecision `json:"settings"`
+}
+
+// PrecisionStats reports precision per detector and per threshold setting,
+// for the given insight types or all of them.
+func (s *InsightFeedbackService) PrecisionStats(ctx context.Context, types []string) ([]DetectorPrecision, error) {
+	feedback, err := s.repo.LatestVerdicts(ctx, types)
+	if err != nil {
+		return nil, err
+	}
+	return precisionStats(feedback), nil
+}
+
+// precisionStats groups latest verdicts by insight type, then by thresholds.
+// Both levels are ordered by key.
+func precisionStats(feedback []model.InsightFeedback) []DetectorPrecision {
+	byType := make(map[string]*DetectorPrecision)
+	settings := make(map[string]map[string]*ThresholdSettingPrecision)
+	for _, f := range feedback {
+		d, ok := byType[f.InsightType]
+		if !ok {
+			d = &DetectorPrecision{InsightType: f.InsightType}
+			byType[f.InsightType] = d
+			settings[f.InsightType] = make(map[string]*ThresholdSettingPrecision)
+		}
+		d.add(f.Verdict)
+
+		key := thresholdsKey(f.Thresholds)
+		st, ok := settings[f.InsightType][key]
+		if !ok {
+			st = &ThresholdSettingPrecision{Thresholds: DetectorParams(f.Thresholds)}
+			settings[f.InsightType][key] = st
+		}
+		st.add(f.Verdict)
+	}
+
+	out := make([]DetectorPrecision, 0, len(byType))
+	for t, d := range byType {
+		keys := make([]string, 0, len(settings[t]))
+		for k := range settings[t] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			d.Settings = append(d.Settings, *settings[t][k])
+		}
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].InsightType < out[j].InsightType })
+	return out
+}
+
+// thresholdsKey identifies a parameter set independently of map order.
+func thresholdsKey(thresholds map[string]float64) string {
+	names := make([]string, 0, len(thresholds))
+	for k := range thresholds {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, k := range names {
+		parts[i] = fmt.Sprintf("%s=%g", k, thresholds[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// ThresholdSuggestion proposes a new global value for one detector
+// parameter. Its id is stable while the value and proposal stay the same.
+type ThresholdSuggestion struct {
+	ID            string  `json:"id"`
+	RuleSet       string  `json:"rule_set"`
+	Param         string  `json:"param"`
+	CurrentValue  float64 `json:"current_value"`
+	ProposedValue float64 `json:"proposed_value"`
+	Precision     float64 `json:"precision"`
+	Labelled      int     `json:"labelled"`
+	Rationale     string  `json:"rationale"`
+}
+
+// Suggestions proposes threshold changes from the feedback history. They are
+// computed on demand, so accepting one (or labelling more insights) changes
+// what is suggested next.
+func (s *InsightFeedbackService) Suggestions(ctx context.Context) ([]ThresholdSuggestion, error) {
+	if !s.adaptive {
+		return nil, ErrAdaptiveThresholdsDisabled
+	}
+	var resolver *RuleResolver
+	if s.rules != nil {
+		var err error
+		if resolver, err = s.rules.Resolver(ctx); err != nil {
+			return nil, fmt.Errorf("load detection rules: %w", err)
+		}
+	}
+	feedback, err := s.repo.LatestVerdicts(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return suggestThresholds(s.registry.enabled(), resolver, feedback), nil
+}
+
+// suggestThresholds checks the first directed parameter of each detector, its
+// tuning parameter, at its current global value. Other parameters are never
+// suggested, and proposals stay within the parameter's bounds.
+func suggestThresholds(regs []DetectorRegistration, resolver *RuleResolver, feedback []model.InsightFeedback) []ThresholdSuggestion {
+	var out []ThresholdSuggestion
+	for _, reg := range regs {
+		var spec *ParamSpec
+		for i := range reg.Params {
+			if reg.Params[i].Stricter != 0 {
+				spec = &reg.Params[i]
+				break
+			}
+		}
+		if spec == nil {
+			continue
+		}
+
+		current := resolver.Resolve(reg.Type, reg.DefaultParams(), "", "", "").Params.Get(spec.Name)
+		var counts FeedbackCounts
+		for _, f := range feedback {
+			if v, ok := f.Thresholds[spec.Name]; ok && f.InsightType == reg.Type && v == current {
+				counts.add(f.Verdict)
+			}
+		}
+		if counts.labelled() < adaptiveMinLabels || *counts.Precision >= adaptiveTargetPrecision {
+			continue
+		}
+
+		proposed := roundSignificant(current*math.Pow(adaptiveStep, float64(spec.Stricter)), 3)
+		if spec.Max != nil {
+			proposed = math.Min(proposed, *spec.Max)
+		}
+		if spec.Min != nil {
+			proposed = math.Max(proposed, *spec.Min)
+		}
+		if proposed == current {
+			continue
+		}
+		direction := "raise"
+		if spec.Stricter < 0 {
+			direction = "lower"
+		}
+		out = append(out, ThresholdSuggestion{
+			ID:            hashID("threshold_suggestion", reg.Type, spec.Name, fmt.Sprint(current), fmt.Sprint(proposed)),
+			RuleSet:       reg.Type,
+			Param:         spec.Name,
+			CurrentValue:  current,
+			ProposedValue: proposed,
+			Precision:     *counts.Precision,
+			Labelled:      counts.labelled(),
+			Rationale: fmt.Sprintf("%d of %d labelled %s insights at %s=%g were useful (precision %.0f%%, target %.0f%%); %s %s to %g so it fires less often",
+				counts.Useful, counts.labelled(), reg.Type, spec.Name, current, *counts.Precision*100, adaptiveTargetPrecision*100,
+				direction, spec.Name, proposed),
+		})
+	}
+	return out
+}
+
+func roundSignificant(v float64, digits int) float64 {
+	if v == 0 {
+		return 0
+	}
+	scale := math.Pow(10, float64(digits)-math.Ceil(math.Log10(math.Abs(v))))
+	return math.Round(v*scale) / scale
+}
+
+// AcceptSuggestion writes a current suggestion into the global detection rule
+// of its detector, keeping the rule's other parameters.
+func (s *InsightFeedbackService) AcceptSuggestion(ctx context.Context, id, actor string) (*model.DetectionRule, error) {
+	suggestions, err := s.Suggestions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var sug *ThresholdSuggestion
+	for i := range suggestions {
+		if suggestions[i].ID == id {
+			sug = &suggestions[i]
+		}
+	}
+	if sug == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSuggestion, id)
+	}
+
+	rules, err := s.rules.ListRules(ctx, sug.RuleSet)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]float64{}
+	for _, r := range rules {
+		if r.CountryCode == "" && r.PaymentMethodType == "" && r.PaymentMethodCode == "" {
+			for k, v := range r.Params {
+				params[k] = v
+			}
+		}
+	}
+	params[sug.Param] = sug.ProposedValue
+	return s.rules.SaveRule(ctx, model.DetectionRule{
+		RuleSet:   sug.RuleSet,
+		Params:    params,
+		UpdatedBy: actor,
+	}, "accepted threshold suggestion: "+sug.Rationale)
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+func labels(insightType string, thresholds map[string]float64, useful, falsePositive, duplicate int) []model.InsightFeedback {
+	var out []model.InsightFeedback
+	add := func(verdict string, n int) {
+		for range n {
+			out = append(out, model.InsightFeedback{InsightType: insightType, Thresholds: thresholds, Verdict: verdict})
+		}
+	}
+	add(model.FeedbackUseful, useful)
+	add(model.FeedbackFalsePositive, falsePositive)
+	add(model.FeedbackDuplicate, duplicate)
+	return out
+}
+
+func TestPrecisionStats(t *testing.T) {
+	var feedback []model.InsightFeedback
+	feedback = append(feedback, labels(InsightTypeZombie, map[string]float64{"baseline_pct": 10, "baseline_floor": 10}, 3, 1, 0)...)
+	feedback = append(feedback, labels(InsightTypeZombie, map[string]float64{"baseline_floor": 10, "baseline_pct": 10}, 1, 1, 2)...)
+	feedback = append(feedback, labels(InsightTypeZombie, map[string]float64{"baseline_floor": 10, "baseline_pct": 5}, 0, 0, 1)...)
+	feedback = append(feedback, labels(InsightTypeApprovalAnomaly, map[string]float64{"z_threshold": 3}, 1, 0, 0)...)
+	feedback = append(feedback, labels(InsightTypeVolumeOutage, map[string]float64{"p_threshold": 0.001}, 0, 2, 0)...)
+
+	stats := precisionStats(feedback)
+	require.Len(t, stats, 3)
+	assert.Equal(t, InsightTypeApprovalAnomaly, stats[0].InsightType)
+	assert.Equal(t, floatPtr(1), stats[0].Precision)
+	assert.Equal(t, floatPtr(0), stats[1].Precision, "all false positives is 0%, not unknown")
+
+	zombie := stats[2]
+	assert.Equal(t, FeedbackCounts{Useful: 4, FalsePositive: 2, Duplicate: 3, Precision: floatPtr(0.6667)}, zombie.FeedbackCounts)
+	require.Len(t, zombie.Settings, 2, "map order does not split a setting")
+	assert.Equal(t, 10.0, zombie.Settings[0].Thresholds.Get("baseline_pct"))
+	assert.Equal(t, floatPtr(0.6667), zombie.Settings[0].Precision)
+	assert.Equal(t, 5.0, zombie.Settings[1].Thresholds.Get("baseline_pct"))
+	assert.Equal(t, FeedbackCounts{Duplicate: 1}, zombie.Settings[1].FeedbackCounts, "duplicates alone leave precision unknown")
+}
+
+func TestSuggestThresholds(t *testing.T) {
+	regs := []DetectorRegistration{
+		{Type: InsightTypePerformanceAlert, Params: []ParamSpec{
+			{Name: "min_transactions", Default: 20},
+			{Name: "min_gap_pp", Default: 10, Stricter: 1},
+		}},
+		{Type: InsightTypeVolumeOutage, Params: []ParamSpec{{Name: "p_threshold", Default: 0.001, Stricter: -1}}},
+		{Type: InsightTypeZombie, Params: []ParamSpec{{Name: "baseline_pct", Default: 10, Stricter: -1}}},
+		{Type: InsightTypeCustomRule},
+	}
+	var feedback []model.InsightFeedback
+	feedback = append(feedback, labels(InsightTypePerformanceAlert, map[string]float64{"min_gap_pp": 10}, 4, 8, 5)...)
+	// Labels from an older setting do not count against the current one.
+	feedback = append(feedback, labels(InsightTypePerformanceAlert, map[string]float64{"min_gap_pp": 5}, 0, 20, 0)...)
+	feedback = append(feedback, labels(InsightTypeVolumeOutage, map[string]float64{"p_threshold": 0.002}, 3, 9, 0)...)
+	feedback = append(feedback, labels(InsightTypeZombie, map[string]float64{"baseline_pct": 10}, 8, 2, 0)...)
+
+	resolver := NewRuleResolver([]model.DetectionRule{
+		{ID: "r1", RuleSet: InsightTypeVolumeOutage, Params: map[string]float64{"p_threshold": 0.002}},
+		{ID: "r2", RuleSet: InsightTypeVolumeOutage, CountryCode: "BR", Params: map[string]float64{"p_threshold": 0.01}},
+	})
+	got := suggestThresholds(regs, resolver, feedback)
+	require.Len(t, got, 2, "zombies are precise enough")
+
+	assert.Equal(t, InsightTypePerformanceAlert, got[0].RuleSet)
+	assert.Equal(t, "min_gap_pp", got[0].Param)
+	assert.Equal(t, 10.0, got[0].CurrentValue)
+	assert.Equal(t, 12.0, got[0].ProposedValue)
+	assert.Equal(t, 12, got[0].Labelled, "duplicates are not labelled either way")
+	assert.Equal(t, 0.3333, got[0].Precision)
+	assert.Contains(t, got[0].Rationale, "raise min_gap_pp to 12")
+
+	assert.Equal(t, 0.002, got[1].CurrentValue, "the global rule sets the current value")
+	assert.Equal(t, 0.00167, got[1].ProposedValue)
+	assert.Contains(t, got[1].Rationale, "lower p_threshold")
+
+	again := suggestThresholds(regs, resolver, feedback)
+	assert.Equal(t, got[0].ID, again[0].ID)
+	assert.NotEqual(t, got[0].ID, got[1].ID)
+
+	few := labels(InsightTypePerformanceAlert, map[string]float64{"min_gap_pp": 10}, 2, 7, 5)
+	assert.Empty(t, suggestThresholds(regs, nil, few), "below the minimum number of labels")
+}
+
+func TestSuggestThresholdsStaysWithinBounds(t *testing.T) {
+	regs := []DetectorRegistration{{Type: InsightTypeStuckPayments, Params: []ParamSpec{
+		{Name: "max_overdue_share_pct", Default: 90, Stricter: 1, Min: bound(0), Max: bound(100)},
+	}}}
+	feedback := labels(InsightTypeStuckPayments, map[string]float64{"max_overdue_share_pct": 90}, 2, 10, 0)
+
+	got := suggestThresholds(regs, nil, feedback)
+	require.Len(t, got, 1)
+	assert.Equal(t, 100.0, got[0].ProposedValue, "capped at the maximum")
+
+	feedback = labels(InsightTypeStuckPayments, map[string]float64{"max_overdue_share_pct": 100}, 2, 10, 0)
+	rule := model.DetectionRule{RuleSet: InsightTypeStuckPayments, Params: map[string]float64{"max_overdue_share_pct": 100}}
+	assert.Empty(t, suggestThresholds(regs, NewRuleResolver([]model.DetectionRule{rule}), feedback), "already at the maximum")
+}
+
+func TestAddFeedbackValidation(t *testing.T) {
+	svc := &InsightFeedbackService{}
+	for name, f := range map[string]model.InsightFeedback{
+		"unknown verdict":          {Verdict: "MEH", Reason: "x"},
+		"false positive no reason": {Verdict: model.FeedbackFalsePositive, Reason: "  "},
+		"duplicate no reason":      {Verdict: model.FeedbackDuplicate},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.AddFeedback(context.Background(), f)
+			assert.True(t, errors.Is(err, ErrInvalidFeedback), "got %v", err)
+		})
+	}
+
+	_, err := svc.Suggestions(context.Background())
+	assert.ErrorIs(t, err, ErrAdaptiveThresholdsDisabled)
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"math"
+	"sort"
+)
+
+// Dollar impact estimates put every detector's insights on one scale: USD
+// per month while the problem lasts. Amounts that built up over a longer
+// span, such as an overdue balance, are converted with perMonth over that
+// span. Incidents measured over a short window, an outage or an approval
+// drop, report the approved TPV lost in that window instead: scaling a
+// 30-minute gap up to a month would assume it never ends. Zero means the
+// detector has no dollar model.
+
+// daysPerMonth matches the month length of the repositories' active_months.
+const daysPerMonth = 30.4375
+
+// perMonth scales an amount observed over days to a month.
+func perMonth(amount, days float64) float64 {
+	if days <= 0 {
+		return 0
+	}
+	return amount / days * daysPerMonth
+}
+
+// zombieImpact is the fixed cost still being paid each month.
+func zombieImpact(monthlyCostUSD float64) float64 {
+	return monthlyCostUSD
+}
+
+// hiddenGemImpact is the extra monthly approved TPV if the method's volume
+// share grew to match its revenue share at today's ticket size and approval
+// rate.
+func hiddenGemImpact(tpvUSD, months, revenueSharePct, volumeSharePct float64) float64 {
+	if volumeSharePct <= 0 || months <= 0 || revenueSharePct <= volumeSharePct {
+		return 0
+	}
+	return tpvUSD / months * (revenueSharePct/volumeSharePct - 1)
+}
+
+// performanceAlertImpact is the monthly approved TPV lost to declines above
+// the peer rate: attempted volume times the gap.
+func performanceAlertImpact(volumeUSD, months, gapPP float64) float64 {
+	if months <= 0 || gapPP <= 0 {
+		return 0
+	}
+	return volumeUSD / months * gapPP / 100
+}
+
+// trendImpact is the change in monthly TPV across the fitted months: the
+// slope times the months it spans.
+func trendImpact(slope float64, months int) float64 {
+	if months < 2 {
+		return 0
+	}
+	return math.Abs(slope) * float64(months-1)
+}
+
+// volumeOutageImpact is the approved TPV missing over the outage window.
+func volumeOutageImpact(lostTpvUSD float64) float64 {
+	return lostTpvUSD
+}
+
+// approvalAnomalyImpact is the approved TPV lost in the anomalous hour: its
+// volume times the drop from the baseline approval rate.
+func approvalAnomalyImpact(volumeUSD, baselinePct, ratePct float64) float64 {
+	if baselinePct <= ratePct {
+		return 0
+	}
+	return volumeUSD * (baselinePct - ratePct) / 100
+}
+
+// concentrationRiskImpact is the monthly approved TPV at risk if the method
+// fails without a fallback, from its TPV over the lookback.
+func concentrationRiskImpact(tpvUSD, lookbackDays float64) float64 {
+	return perMonth(tpvUSD, lookbackDays)
+}
+
+// customRuleImpact is the monthly approved TPV of the flagged method-country,
+// the volume the rule puts in question, from its TPV over the lookback.
+func customRuleImpact(tpvUSD float64, lookbackDays int) float64 {
+	return perMonth(tpvUSD, float64(lookbackDays))
+}
+
+// stuckPaymentsImpact is the overdue amount as a monthly rate over the age
+// of the oldest PENDING transaction, the span it built up in.
+func stuckPaymentsImpact(overdueUSD float64, oldestAgeHours int) float64 {
+	return perMonth(overdueUSD, float64(oldestAgeHours)/24)
+}
+
+// SortByImpact orders insights by estimated_impact_usd, largest first, and
+// keeps detector order among equal estimates.
+func SortByImpact(insights []Insight) {
+	sort.SliceStable(insights, func(i, j int) bool {
+		return insights[i].EstimatedImpactUSD > insights[j].EstimatedImpactUSD
+	})
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHiddenGemImpact(t *testing.T) {
+	// 10% of revenue on 5% of volume: doubling volume doubles TPV.
+	assert.InDelta(t, 5000, hiddenGemImpact(24000, 4.8, 10, 5), 1e-9)
+	assert.Zero(t, hiddenGemImpact(24000, 4.8, 10, 0))
+	assert.Zero(t, hiddenGemImpact(24000, 4.8, 5, 10))
+}
+
+func TestPerformanceAlertImpact(t *testing.T) {
+	// $60k attempted over 3 months, 12pp below peers.
+	assert.InDelta(t, 2400, performanceAlertImpact(60000, 3, 12), 1e-9)
+	assert.Zero(t, performanceAlertImpact(60000, 3, -2))
+	assert.Zero(t, performanceAlertImpact(60000, 0, 12))
+}
+
+func TestTicketSizeImpact(t *testing.T) {
+	limit := 500.0
+	p := AmountBandProfile{ProbableLimit: &limit, LimitDropPP: 40, VolumeAboveLimit: 9000}
+	// $3,600 lost over 90 days.
+	assert.InDelta(t, 3600/90.0*daysPerMonth, ticketSizeImpact(p, 90), 1e-9)
+	assert.Zero(t, ticketSizeImpact(AmountBandProfile{}, 90))
+}
+
+func TestTrendImpact(t *testing.T) {
+	// Losing $100 a month for six months is $500 a month below the start.
+	assert.InDelta(t, 500, trendImpact(-100, 6), 1e-9)
+	assert.InDelta(t, 500, trendImpact(100, 6), 1e-9)
+	assert.Zero(t, trendImpact(-100, 1))
+}
+
+func TestVolumeOutageImpact(t *testing.T) {
+	// $50 missing in 30 minutes stays $50, not a month of outage.
+	assert.Equal(t, 50.0, volumeOutageImpact(50))
+}
+
+func TestApprovalAnomalyImpact(t *testing.T) {
+	// $2,000 attempted in the hour at 70% against a 90% baseline.
+	assert.InDelta(t, 400, approvalAnomalyImpact(2000, 90, 70), 1e-9)
+	assert.Zero(t, approvalAnomalyImpact(2000, 70, 90))
+}
+
+func TestConcentrationRiskImpact(t *testing.T) {
+	// $90,000 approved over 90 days.
+	assert.InDelta(t, 1000*daysPerMonth, concentrationRiskImpact(90000, 90), 1e-9)
+	assert.Zero(t, concentrationRiskImpact(90000, 0))
+}
+
+func TestCustomRuleImpact(t *testing.T) {
+	// $3,000 approved over 30 days.
+	assert.InDelta(t, 100*daysPerMonth, customRuleImpact(3000, 30), 1e-9)
+}
+
+func TestStuckPaymentsImpact(t *testing.T) {
+	// $1,000 overdue, the oldest created 10 days ago.
+	assert.InDelta(t, 100*daysPerMonth, stuckPaymentsImpact(1000, 240), 1e-9)
+	assert.Zero(t, stuckPaymentsImpact(1000, 0))
+}
+
+func TestSortByImpact(t *testing.T) {
+	insights := []Insight{
+		{InsightID: "a", EstimatedImpactUSD: 10},
+		{InsightID: "b", EstimatedImpactUSD: 500},
+		{InsightID: "c"},
+		{InsightID: "d", EstimatedImpactUSD: 500},
+	}
+	SortByImpact(insights)
+
+	var ids []string
+	for _, in := range insights {
+		ids = append(ids, in.InsightID)
+	}
+	assert.Equal(t, []string{"b", "d", "a", "c"}, ids)
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+var ErrInvalidInsightTransition = errors.New("invalid insight transition")
+
+// InsightLifecycle is the persisted state attached to a detected insight.
+type InsightLifecycle struct {
+	State        string     `json:"state"`
+	FirstSeen    time.Time  `json:"first_seen"`
+	LastSeen     time.Time  `json:"last_seen"`
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	Assignee     string     `json:"assignee,omitempty"`
+}
+
+// InsightDetail is a tracked insight with its history.
+type InsightDetail struct {
+	model.TrackedInsight
+	Transitions []model.InsightTransition `json:"transitions"`
+	Notes       []model.InsightNote       `json:"notes"`
+}
+
+// manualTransitions lists the states a user may move an insight to from each
+// state. AUTO_RESOLVED is only ever set by detection runs.
+var manualTransitions = map[string][]string{
+	model.InsightStateOpen:         {model.InsightStateAcknowledged, model.InsightStateSnoozed, model.InsightStateResolved},
+	model.InsightStateAcknowledged: {model.InsightStateOpen, model.InsightStateSnoozed, model.InsightStateResolved},
+	model.InsightStateSnoozed:      {model.InsightStateOpen, model.InsightStateAcknowledged, model.InsightStateSnoozed, model.InsightStateResolved},
+	model.InsightStateResolved:     {model.InsightStateOpen},
+	model.InsightStateAutoResolved: {model.InsightStateOpen, model.InsightStateResolved},
+}
+
+func canTransition(from, to string) bool {
+	for _, s := range manualTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// trackDetected folds a fresh detection into the stored lifecycle. Resolved
+// insights that fire again reopen with a new first_seen, and expired snoozes
+// reopen; acknowledged and still-snoozed insights keep their state.
+func trackDetected(existing *model.TrackedInsight, in Insight, snapshot json.RawMessage, now time.Time) (model.TrackedInsight, *model.InsightTransition) {
+	var t model.TrackedInsight
+	var tr *model.InsightTransition
+
+	switch {
+	case existing == nil:
+		t = model.TrackedInsight{
+			InsightID:         in.InsightID,
+			Type:              in.Type,
+			PaymentMethodCode: in.PaymentMethodCode,
+			CountryCode:       in.CountryCode,
+			State:             model.InsightStateOpen,
+			FirstSeen:         now,
+		}
+		tr = &model.InsightTransition{ToState: model.InsightStateOpen, Note: "first detected"}
+	case existing.State == model.InsightStateResolved || existing.State == model.InsightStateAutoResolved:
+		t = *existing
+		t.State = model.InsightStateOpen
+		t.FirstSeen = now
+		t.ResolvedAt = nil
+		tr = &model.InsightTransition{FromState: existing.State, ToState: model.InsightStateOpen, Note: "detector fired again"}
+	case existing.State == model.InsightStateSnoozed && (existing.SnoozedUntil == nil || !existing.SnoozedUntil.After(now)):
+		t = *existing
+		t.State = model.InsightStateOpen
+		t.SnoozedUntil = nil
+		tr = &model.InsightTransition{FromState: existing.State, ToState: model.InsightStateOpen, Note: "snooze expired"}
+	default:
+		t = *existing
+	}
+
+	t.Severity = in.Severity
+	t.LastSeen = now
+	t.Snapshot = snapshot
+	if tr != nil {
+		tr.InsightID = t.InsightID
+		tr.Actor = "system"
+		tr.CreatedAt = now
+	}
+	return t, tr
+}
+
+// syncInsights persists a detection run, auto-resolving within resolveScope
+// when it is not nil, attaches each insight's lifecycle and queues the
+// resulting events in the same transaction. Held insights, suppressed by maintenance, keep their
+// current state.
+func (s *InsightService) syncInsights(ctx context.Context, insights []Insight, resolveScope *repository.InsightScope, held []string, now time.Time) error {
+	ids := make([]string, len(insights))
+	snapshots := make([]json.RawMessage, len(insights))
+	for i, in := range insights {
+		ids[i] = in.InsightID
+		snapshot, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshal insight %s: %w", in.InsightID, err)
+		}
+		snapshots[i] = snapshot
+	}
+
+	var events []InsightEvent
+	plan := func(existing map[string]model.TrackedInsight) ([]model.TrackedInsight, []model.InsightTransition, error) {
+		records := make([]model.TrackedInsight, 0, len(insights))
+		var transitions []model.InsightTransition
+		events = nil
+		for i := range insights {
+			var prev *model.TrackedInsight
+			if e, ok := existing[insights[i].InsightID]; ok {
+				prev = &e
+			}
+			t, tr := trackDetected(prev, insights[i], snapshots[i], now)
+			records = append(records, t)
+			if tr != nil {
+				transitions = append(transitions, *tr)
+				events = append(events, transitionEvent(t, *tr))
+			} else if prev != nil && prev.Severity != t.Severity {
+				events = append(events, InsightEvent{
+					Type:             model.InsightEventSeverityChanged,
+					Insight:          t,
+					PreviousSeverity: prev.Severity,
+					OccurredAt:       now,
+				})
+			}
+			insights[i].Lifecycle = &InsightLifecycle{
+				State:        t.State,
+				FirstSeen:    t.FirstSeen,
+				LastSeen:     t.LastSeen,
+				SnoozedUntil: t.SnoozedUntil,
+				Assignee:     t.Assignee,
+			}
+		}
+		return records, transitions, nil
+	}
+
+	return s.states.Sync(ctx, ids, plan, resolveScope, held, now, func(tx pgx.Tx, resolved []repository.AutoResolved) error {
+		for _, r := range resolved {
+			events = append(events, transitionEvent(r.Insight, r.Transition))
+		}
+		if err := s.notify(ctx, tx, events); err != nil {
+			return fmt.Errorf("notify insight events: %w", err)
+		}
+		return nil
+	})
+}
+
+// FilterByState keeps insights whose lifecycle state is one of states; an
+// empty list keeps everything.
+func FilterByState(insights []Insight, states []string) []Insight {
+	if len(states) == 0 {
+		return insights
+	}
+	var out []Insight
+	for _, in := range insights {
+		if in.Lifecycle == nil {
+			continue
+		}
+		for _, st := range states {
+			if in.Lifecycle.State == st {
+				out = append(out, in)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (s *InsightService) ListTracked(ctx context.Context, scope repository.InsightScope) ([]model.TrackedInsight, error) {
+	return s.states.List(ctx, scope)
+}
+
+// GetTracked returns pgx.ErrNoRows for insights that were never detected.
+func (s *InsightService) GetTracked(ctx context.Context, id string) (*InsightDetail, error) {
+	t, err := s.states.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := s.states.ListTransitions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := s.states.ListNotes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if notes == nil {
+		notes = []model.InsightNote{}
+	}
+	return &InsightDetail{TrackedInsight: *t, Transitions: transitions, Notes: notes}, nil
+}
+
+// TransitionInsight applies a manual state change. SNOOZED needs a future
+// snoozedUntil.
+func (s *InsightService) TransitionInsight(ctx context.Context, id, to string, snoozedUntil *time.Time, actor, note string) (*model.TrackedInsight, error) {
+	current, err := s.states.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canTransition(current.State, to) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidInsightTransition, current.State, to)
+	}
+
+	now := time.Now()
+	if to == model.InsightStateSnoozed {
+		if snoozedUntil == nil || !snoozedUntil.After(now) {
+			return nil, fmt.Errorf("%w: snoozed_until must be in the future", ErrInvalidInsightTransition)
+		}
+	} else {
+		snoozedUntil = nil
+	}
+
+	tr := model.InsightTransition{
+		InsightID: id,
+		FromState: current.State,
+		ToState:   to,
+		Actor:     actor,
+		Note:      note,
+		CreatedAt: now,
+	}
+	t, err := s.states.SetState(ctx, tr, snoozedUntil, func(tx pgx.Tx, t *model.TrackedInsight) error {
+		if err := s.notify(ctx, tx, []InsightEvent{transitionEvent(*t, tr)}); err != nil {
+			return fmt.Errorf("notify insight transition: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: state changed concurrently", ErrInvalidInsightTransition)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *InsightService) AssignInsight(ctx context.Context, id, assignee string) (*model.TrackedInsight, error) {
+	return s.states.SetAssignee(ctx, id, assignee)
+}
+
+func (s *InsightService) AddInsightNote(ctx context.Context, id, author, note string) (*model.InsightNote, error) {
+	return s.states.AddNote(ctx, id, model.InsightNote{Author: author, Note: note})
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+func TestTrackDetected(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-21 * 24 * time.Hour)
+	in := Insight{InsightID: "abc", Type: InsightTypeZombie, Severity: SeverityHigh, CountryCode: "AR", PaymentMethodCode: "RAPIPAGO"}
+
+	t.Run("new insight opens", func(t *testing.T) {
+		got, tr := trackDetected(nil, in, nil, now)
+		assert.Equal(t, model.InsightStateOpen, got.State)
+		assert.Equal(t, now, got.FirstSeen)
+		require.NotNil(t, tr)
+		assert.Equal(t, "", tr.FromState)
+	})
+
+	t.Run("acknowledged keeps state and first_seen", func(t *testing.T) {
+		existing := &model.TrackedInsight{InsightID: "abc", State: model.InsightStateAcknowledged, FirstSeen: earlier, Severity: SeverityLow}
+		got, tr := trackDetected(existing, in, nil, now)
+		assert.Nil(t, tr)
+		assert.Equal(t, model.InsightStateAcknowledged, got.State)
+		assert.Equal(t, earlier, got.FirstSeen)
+		assert.Equal(t, now, got.LastSeen)
+		assert.Equal(t, SeverityHigh, got.Severity)
+	})
+
+	t.Run("resolved reopens", func(t *testing.T) {
+		existing := &model.TrackedInsight{InsightID: "abc", State: model.InsightStateAutoResolved, FirstSeen: earlier, ResolvedAt: &earlier}
+		got, tr := trackDetected(existing, in, nil, now)
+		require.NotNil(t, tr)
+		assert.Equal(t, model.InsightStateOpen, got.State)
+		assert.Equal(t, now, got.FirstSeen)
+		assert.Nil(t, got.ResolvedAt)
+	})
+
+	t.Run("snooze holds until it expires", func(t *testing.T) {
+		later := now.Add(time.Hour)
+		existing := &model.TrackedInsight{InsightID: "abc", State: model.InsightStateSnoozed, SnoozedUntil: &later}
+		got, tr := trackDetected(existing, in, nil, now)
+		assert.Nil(t, tr)
+		assert.Equal(t, model.InsightStateSnoozed, got.State)
+
+		existing.SnoozedUntil = &earlier
+		got, tr = trackDetected(existing, in, nil, now)
+		require.NotNil(t, tr)
+		assert.Equal(t, model.InsightStateOpen, got.State)
+		assert.Nil(t, got.SnoozedUntil)
+	})
+}
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, canTransition(model.InsightStateOpen, model.InsightStateAcknowledged))
+	assert.True(t, canTransition(model.InsightStateResolved, model.InsightStateOpen))
+	assert.False(t, canTransition(model.InsightStateResolved, model.InsightStateAcknowledged))
+	assert.False(t, canTransition(model.InsightStateOpen, model.InsightStateAutoResolved))
+	assert.False(t, canTransition(model.InsightStateOpen, model.InsightStateOpen))
+}
+
+func TestFilterByState(t *testing.T) {
+	insights := []Insight{
+		{InsightID: "1", Lifecycle: &InsightLifecycle{State: model.InsightStateOpen}},
+		{InsightID: "2", Lifecycle: &InsightLifecycle{State: model.InsightStateSnoozed}},
+		{InsightID: "3"},
+	}
+	assert.Len(t, FilterByState(insights, nil), 3)
+	got := FilterByState(insights, []string{model.InsightStateOpen})
+	require.Len(t, got, 1)
+	assert.Equal(t, "1", got[0].InsightID)
+}
+
+func TestAutoResolveScope(t *testing.T) {
+	regs := []DetectorRegistration{{Type: InsightTypeZombie}, {Type: InsightTypeHiddenGem}}
+
+	scope := autoResolveScope(model.AnalyticsFilter{Countries: []string{"CO"}, Types: []string{"WALLET"}}, regs)
+	require.NotNil(t, scope)
+	assert.Equal(t, []string{InsightTypeZombie, InsightTypeHiddenGem}, scope.Types)
+	assert.Equal(t, []string{"CO"}, scope.Countries)
+	assert.Equal(t, []string{"WALLET"}, scope.PaymentMethodTypes)
+
+	// A windowed or expression-filtered GET did not look at every insight in
+	// its scope, so open insights it did not produce stay open.
+	for name, f := range map[string]model.AnalyticsFilter{
+		"date_from": {DateFrom: "2026-01-01"},
+		"date_to":   {DateTo: "2026-02-01"},
+		"filter":    {Countries: []string{"CO"}, Expr: "approval_rate < 80"},
+	} {
+		assert.Nil(t, autoResolveScope(f, regs), name)
+	}
+}
@@ -4,20 +4,28 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
-	"math"
 	"time"
 
 	"golang.org/x/sync/errgroup"
 
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
 )
 
 type InsightService struct {
-	repo *repository.InsightRepository
+	registry *DetectorRegistry
+	rules    *DetectionRuleService
+	states   *repository.InsightStateRepository
+
+	notifiers []InsightNotifier
+	events    *EventService
 }
 
-func NewInsightService(repo *repository.InsightRepository) *InsightService {
-	return &InsightService{repo: repo}
+// NewInsightService runs the registry's detectors. With a nil rules service
+// they use their registration defaults only; with a nil state repository
+// insights are not persisted.
+func NewInsightService(registry *DetectorRegistry, rules *DetectionRuleService, states *repository.InsightStateRepository) *InsightService {
+	return &InsightService{registry: registry, rules: rules, states: states}
 }
 
 type Insight struct {
@@ -33,46 +41,69 @@ type Insight struct {
 	Description       string                 `json:"description"`
 	RecommendedAction string                 `json:"recommended_action"`
 	SupportingData    map[string]interface{} `json:"supporting_data"`
-	GeneratedAt       time.Time              `json:"generated_at"`
+	Thresholds        map[string]float64     `json:"thresholds"`
+	// EstimatedImpactUSD ranks insights across detectors; see insight_impact.go.
+	EstimatedImpactUSD float64           `json:"estimated_impact_usd"`
+	Lifecycle          *InsightLifecycle `json:"lifecycle,omitempty"`
+	GeneratedAt        time.Time         `json:"generated_at"`
 }
 
-func (s *InsightService) DetectInsights(ctx context.Context, country, insightType, severity string) ([]Insight, error) {
-	g, gctx := errgroup.WithContext(ctx)
-
-	var zombies, gems, alerts []Insight
-
-	if insightType == "" || insightType == "zombie" {
-		g.Go(func() error {
-			var err error
-			zombies, err = s.detectZombies(gctx, country)
-			return err
-		})
+// DetectInsights runs every enabled detector (or only insightType) concurrently
+// and returns their insights in registration order, less those suppressed by
+// maintenance events. Each run is persisted: new insights open, and
+// unresolved insights of the detectors that ran, in the filter's scope,
+// auto-resolve when they no longer fire.
+func (s *InsightService) DetectInsights(ctx context.Context, f model.AnalyticsFilter, insightType, severity string) ([]Insight, error) {
+	var regs []DetectorRegistration
+	if insightType == "" {
+		regs = s.registry.enabled()
+	} else {
+		reg, ok := s.registry.Get(insightType)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownInsightType, insightType)
+		}
+		if reg.Enabled {
+			regs = []DetectorRegistration{reg}
+		}
 	}
 
-	if insightType == "" || insightType == "hidden_gem" {
-		g.Go(func() error {
-			var err error
-			gems, err = s.detectHiddenGems(gctx, country)
-			return err
-		})
+	var resolver *RuleResolver
+	if s.rules != nil && len(regs) > 0 {
+		var err error
+		if resolver, err = s.rules.Resolver(ctx); err != nil {
+			return nil, fmt.Errorf("load detection rules: %w", err)
+		}
 	}
 
-	if insightType == "" || insightType == "performance_alert" {
+	scope := DetectionScope{Filter: f, Now: time.Now()}
+	found := make([][]Insight, len(regs))
+
+	g, gctx := errgroup.WithContext(ctx)
+	for i, reg := range regs {
 		g.Go(func() error {
-			var err error
-			alerts, err = s.detectPerformanceAlerts(gctx, country)
+			insights, err := runDetector(gctx, reg, resolver, scope)
+			found[i] = insights
 			return err
 		})
 	}
-
 	if err := g.Wait(); err != nil {
 		return nil, err
 	}
 
 	var all []Insight
-	all = append(all, zombies...)
-	all = append(all, gems...)
-	all = append(all, alerts...)
+	for _, insights := range found {
+		all = append(all, insights...)
+	}
+	all, held, err := s.suppressMaintenance(ctx, f, all)
+	if err != nil {
+		return nil, fmt.Errorf("load maintenance events: %w", err)
+	}
+
+	if s.states != nil && len(regs) > 0 {
+		if err := s.syncInsights(ctx, all, autoResolveScope(f, regs), held, scope.Now); err != nil {
+			return nil, fmt.Errorf("sync insights: %w", err)
+		}
+	}
 
 	if severity != "" {
 		var filtered []Insight
@@ -87,168 +118,53 @@ func (s *InsightService) DetectInsights(ctx context.Context, country, insightTyp
 	return all, nil
 }
 
-func (s *InsightService) detectZombies(ctx context.Context, country string) ([]Insight, error) {
-	candidates, err := s.repo.GetZombieCandidates(ctx, country)
-	if err != nil {
-		return nil, err
+// autoResolveScope is the set of tracked insights a run over f with regs
+// can resolve by not producing them. Runs windowed by date or narrowed by a
+// filter expression did not look at everything in their scope, so they
+// resolve nothing.
+func autoResolveScope(f model.AnalyticsFilter, regs []DetectorRegistration) *repository.InsightScope {
+	if f.DateFrom != "" || f.DateTo != "" || f.Expr != "" {
+		return nil
 	}
-
-	now := time.Now()
-	var insights []Insight
-
-	for _, c := range candidates {
-		var isZombie bool
-		var sev string
-		var threshold float64
-
-		if c.MonthsActive < 3 {
-			// New method: absolute threshold
-			threshold = 5
-			isZombie = c.TxnCount90d < 5
-		} else {
-			// Established: relative to baseline
-			baseline := math.Max(10, c.HistoricalMonthlyAvg*3*0.1)
-			threshold = baseline
-			isZombie = float64(c.TxnCount90d) < baseline
-		}
-
-		if !isZombie {
-			continue
-		}
-
-		if c.TxnCount90d == 0 || (c.HistoricalMonthlyAvg > 0 && float64(c.TxnCount90d)/(c.HistoricalMonthlyAvg*3) < 0.1) {
-			sev = "HIGH"
-		} else if c.HistoricalMonthlyAvg > 0 && float64(c.TxnCount90d)/(c.HistoricalMonthlyAvg*3) < 0.3 {
-			sev = "MEDIUM"
-		} else {
-			sev = "LOW"
-		}
-
-		insights = append(insights, Insight{
-			InsightID:         hashID("zombie", c.PaymentMethodCode, c.CountryCode),
-			Type:              "zombie",
-			Severity:          sev,
-			PaymentMethodCode: c.PaymentMethodCode,
-			PaymentMethodName: c.PaymentMethodName,
-			CountryCode:       c.CountryCode,
-			TriggeringMetric:  "txn_count_90d",
-			MetricValue:       float64(c.TxnCount90d),
-			Threshold:         threshold,
-			Description:       fmt.Sprintf("%s in %s has only %d transactions in 90 days with active integration costing $%.2f/month", c.PaymentMethodName, c.CountryCode, c.TxnCount90d, c.MonthlyCostUSD),
-			RecommendedAction: "Review integration cost vs. value. Consider deactivating or renegotiating terms.",
-			SupportingData: map[string]interface{}{
-				"monthly_cost_usd":       c.MonthlyCostUSD,
-				"historical_monthly_avg": c.HistoricalMonthlyAvg,
-				"months_active":          c.MonthsActive,
-				"payment_method_type":    c.PaymentMethodType,
-			},
-			GeneratedAt: now,
-		})
+	types := make([]string, len(regs))
+	for i, reg := range regs {
+		types[i] = reg.Type
+	}
+	return &repository.InsightScope{
+		Types:              types,
+		Countries:          f.Countries,
+		PaymentMethods:     f.PaymentMethods,
+		PaymentMethodTypes: f.Types,
 	}
-
-	return insights, nil
 }
 
-func (s *InsightService) detectHiddenGems(ctx context.Context, country string) ([]Insight, error) {
-	candidates, err := s.repo.GetHiddenGemCandidates(ctx, country)
+// runDetector runs one detector with parameters resolved from resolver, which
+// may be nil for registration defaults.
+func runDetector(ctx context.Context, reg DetectorRegistration, resolver *RuleResolver, scope DetectionScope) ([]Insight, error) {
+	defaults := reg.DefaultParams()
+	params := resolver.Resolve(reg.Type, defaults, "", "", "").Params
+	insights, err := reg.Detector.Detect(ctx, DetectorRun{
+		Scope:    scope,
+		Params:   params,
+		Severity: reg.Severity.withParams(params),
+		resolve: func(country, pmType, paymentMethod string) DetectorParams {
+			return resolver.Resolve(reg.Type, defaults, country, pmType, paymentMethod).Params
+		},
+	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("detector %s: %w", reg.Type, err)
 	}
-
-	now := time.Now()
-	var insights []Insight
-
-	for _, c := range candidates {
-		if c.ApprovalRate < 90 || c.RevenueContribution < 2 {
-			continue
-		}
-		if c.VolumeShare >= c.RevenueContribution*0.75 {
-			continue
-		}
-
-		var sev string
-		if c.RevenueContribution > 8 {
-			sev = "HIGH"
-		} else if c.RevenueContribution > 4 {
-			sev = "MEDIUM"
-		} else {
-			sev = "LOW"
+	for j := range insights {
+		if insights[j].Thresholds == nil {
+			insights[j].Thresholds = params
 		}
-
-		insights = append(insights, Insight{
-			InsightID:         hashID("hidden_gem", c.PaymentMethodCode, c.CountryCode),
-			Type:              "hidden_gem",
-			Severity:          sev,
-			PaymentMethodCode: c.PaymentMethodCode,
-			PaymentMethodName: c.PaymentMethodName,
-			CountryCode:       c.CountryCode,
-			TriggeringMetric:  "revenue_contribution_pct",
-			MetricValue:       c.RevenueContribution,
-			Threshold:         2.0,
-			Description:       fmt.Sprintf("%s in %s has %.1f%% approval rate and %.1f%% revenue contribution but only %.1f%% volume share", c.PaymentMethodName, c.CountryCode, c.ApprovalRate, c.RevenueContribution, c.VolumeShare),
-			RecommendedAction: "Increase merchant adoption and volume for this high-performing method.",
-			SupportingData: map[string]interface{}{
-				"approval_rate":        c.ApprovalRate,
-				"volume_share_pct":     c.VolumeShare,
-				"tpv_usd":             c.TpvUSD,
-				"transaction_count":    c.TransactionCount,
-			},
-			GeneratedAt: now,
-		})
 	}
-
 	return insights, nil
 }
 
-func (s *InsightService) detectPerformanceAlerts(ctx context.Context, country string) ([]Insight, error) {
-	candidates, err := s.repo.GetPerformanceAlertCandidates(ctx, country)
-	if err != nil {
-		return nil, err
-	}
-
-	now := time.Now()
-	var insights []Insight
-
-	for _, c := range candidates {
-		if c.TransactionCount < 20 {
-			continue
-		}
-
-		gap := c.CountryTypeAvgApproval - c.ApprovalRate
-		if gap <= 10 {
-			continue
-		}
-
-		var sev string
-		if gap > 15 {
-			sev = "HIGH"
-		} else {
-			sev = "MEDIUM"
-		}
-
-		insights = append(insights, Insight{
-			InsightID:         hashID("performance_alert", c.PaymentMethodCode, c.CountryCode),
-			Type:              "performance_alert",
-			Severity:          sev,
-			PaymentMethodCode: c.PaymentMethodCode,
-			PaymentMethodName: c.PaymentMethodName,
-			CountryCode:       c.CountryCode,
-			TriggeringMetric:  "approval_rate",
-			MetricValue:       c.ApprovalRate,
-			Threshold:         c.CountryTypeAvgApproval - 10,
-			Description:       fmt.Sprintf("%s in %s has %.1f%% approval rate, %.1fpp below %s average of %.1f%%", c.PaymentMethodName, c.CountryCode, c.ApprovalRate, gap, c.PaymentMethodType, c.CountryTypeAvgApproval),
-			RecommendedAction: "Investigate decline reasons. Check provider configuration and fraud rules.",
-			SupportingData: map[string]interface{}{
-				"country_type_avg_approval": c.CountryTypeAvgApproval,
-				"gap_pp":                    gap,
-				"payment_method_type":       c.PaymentMethodType,
-				"transaction_count":         c.TransactionCount,
-			},
-			GeneratedAt: now,
-		})
-	}
-
-	return insights, nil
+// Detectors lists the registered detectors.
+func (s *InsightService) Detectors() []DetectorRegistration {
+	return s.registry.List()
 }
 
 func hashID(parts ...string) string {
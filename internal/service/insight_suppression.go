@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+// SuppressionWindower is implemented by detectors whose insights describe a
+// span of time rather than the moment of detection. Other detectors'
+// insights are suppressed by maintenance covering their GeneratedAt.
+type SuppressionWindower interface {
+	SuppressionWindow(in Insight) (from, to time.Time)
+}
+
+// SetEventService enables maintenance suppression; call it before serving
+// requests.
+func (s *InsightService) SetEventService(events *EventService) {
+	s.events = events
+}
+
+func (s *InsightService) suppressionWindow(in Insight) (time.Time, time.Time) {
+	if reg, ok := s.registry.Get(in.Type); ok {
+		if w, ok := reg.Detector.(SuppressionWindower); ok {
+			return w.SuppressionWindow(in)
+		}
+	}
+	return in.GeneratedAt, in.GeneratedAt
+}
+
+// suppressMaintenance drops the insights whose window overlaps a maintenance
+// event in their scope. It returns the kept insights and the ids of the
+// suppressed ones, which sync leaves untouched rather than auto-resolving.
+func (s *InsightService) suppressMaintenance(ctx context.Context, f model.AnalyticsFilter, insights []Insight) ([]Insight, []string, error) {
+	if s.events == nil || len(insights) == 0 {
+		return insights, nil, nil
+	}
+
+	windows := make([][2]time.Time, len(insights))
+	var from, to time.Time
+	for i, in := range insights {
+		lo, hi := s.suppressionWindow(in)
+		windows[i] = [2]time.Time{lo, hi}
+		if from.IsZero() || lo.Before(from) {
+			from = lo
+		}
+		if hi.After(to) {
+			to = hi
+		}
+	}
+
+	events, providers, err := s.events.scopedEvents(ctx, f, []string{model.EventKindMaintenance}, from, to)
+	if err != nil || len(events) == 0 {
+		return insights, nil, err
+	}
+	kept, held := suppressInsights(insights, windows, events, providers)
+	return kept, held, nil
+}
+
+func suppressInsights(insights []Insight, windows [][2]time.Time, events []model.Event, providers map[string]string) ([]Insight, []string) {
+	var kept []Insight
+	var held []string
+	for i, in := range insights {
+		if coveredBy(events, in, windows[i], providers[in.PaymentMethodCode]) {
+			held = append(held, in.InsightID)
+			continue
+		}
+		kept = append(kept, in)
+	}
+	return kept, held
+}
+
+func coveredBy(events []model.Event, in Insight, window [2]time.Time, provider string) bool {
+	for _, e := range events {
+		if e.Matches(in.CountryCode, in.PaymentMethodCode, provider) && e.Overlaps(window[0], window[1]) {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"math"
 
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
 )
 
@@ -32,13 +33,13 @@ type CoverageResult struct {
 	GapCount      int     `json:"gap_count"`
 }
 
-func (s *MarketGapService) GetMarketGaps(ctx context.Context, country string, onlyEssential bool) ([]GapResult, []CoverageResult, error) {
-	gaps, err := s.repo.GetGaps(ctx, country, onlyEssential)
+func (s *MarketGapService) GetMarketGaps(ctx context.Context, f model.AnalyticsFilter, onlyEssential bool) ([]GapResult, []CoverageResult, error) {
+	gaps, err := s.repo.GetGaps(ctx, f, onlyEssential)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	coverage, err := s.repo.GetCoverage(ctx, country)
+	coverage, err := s.repo.GetCoverage(ctx, f)
 	if err != nil {
 		return nil, nil, err
 	}
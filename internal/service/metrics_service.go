@@ -2,16 +2,24 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
 
+	"golang.org/x/sync/errgroup"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
 )
 
 type MetricsService struct {
-	repo *repository.MetricsRepository
+	repo      *repository.MetricsRepository
+	healthSvc *HealthScoreService
 }
 
-func NewMetricsService(repo *repository.MetricsRepository) *MetricsService {
-	return &MetricsService{repo: repo}
+func NewMetricsService(repo *repository.MetricsRepository, healthSvc *HealthScoreService) *MetricsService {
+	return &MetricsService{repo: repo, healthSvc: healthSvc}
 }
 
 type MetricResult struct {
@@ -22,6 +30,7 @@ type MetricResult struct {
 	TransactionCount    int     `json:"transaction_count"`
 	ApprovedCount       int     `json:"approved_count"`
 	DeclinedCount       int     `json:"declined_count"`
+	RefundedCount       int     `json:"refunded_count"`
 	TpvUSD              float64 `json:"tpv_usd"`
 	ApprovalRate        float64 `json:"approval_rate"`
 	AvgTransactionValue float64 `json:"avg_transaction_value_usd"`
@@ -29,6 +38,11 @@ type MetricResult struct {
 	MonthlyCostUSD      float64 `json:"monthly_cost_usd"`
 	CostEfficiencyRatio float64 `json:"cost_efficiency_ratio"`
 	ActivityStatus      string  `json:"activity_status"`
+
+	HealthScore *HealthScore `json:"health_score,omitempty"`
+
+	tpvLocal                 float64
+	avgTransactionValueLocal float64
 }
 
 type MetricsSummary struct {
@@ -41,8 +55,10 @@ type MetricsSummary struct {
 	InactiveCount     int     `json:"inactive_methods"`
 }
 
-func (s *MetricsService) GetMetrics(ctx context.Context, country, pmType, dateFrom, dateTo, sortBy, order string, limit, offset int) ([]MetricResult, MetricsSummary, int, error) {
-	rows, totalItems, err := s.repo.GetMetrics(ctx, country, pmType, dateFrom, dateTo, sortBy, order, limit, offset)
+// GetMetrics returns one page of metrics; withHealth attaches each row's
+// health score, which costs a second, heavier query.
+func (s *MetricsService) GetMetrics(ctx context.Context, f model.AnalyticsFilter, sortBy, order string, limit, offset int, withHealth bool) ([]MetricResult, MetricsSummary, int, error) {
+	rows, totalItems, err := s.repo.GetMetrics(ctx, f, sortBy, order, limit, offset)
 	if err != nil {
 		return nil, MetricsSummary{}, 0, err
 	}
@@ -51,22 +67,7 @@ func (s *MetricsService) GetMetrics(ctx context.Context, country, pmType, dateFr
 	var summary MetricsSummary
 
 	for i, row := range rows {
-		results[i] = MetricResult{
-			PaymentMethodCode:   row.PaymentMethodCode,
-			PaymentMethodName:   row.PaymentMethodName,
-			PaymentMethodType:   row.PaymentMethodType,
-			CountryCode:         row.CountryCode,
-			TransactionCount:    row.TransactionCount,
-			ApprovedCount:       row.ApprovedCount,
-			DeclinedCount:       row.DeclinedCount,
-			TpvUSD:              row.TpvUSD,
-			ApprovalRate:        row.ApprovalRate,
-			AvgTransactionValue: row.AvgTransactionValue,
-			RevenueContribution: row.RevenueContribution,
-			MonthlyCostUSD:      row.MonthlyCostUSD,
-			CostEfficiencyRatio: row.CostEfficiencyRatio,
-			ActivityStatus:      row.ActivityStatus,
-		}
+		results[i] = toMetricResult(row)
 
 		summary.TotalTransactions += row.TransactionCount
 		summary.TotalApproved += row.ApprovedCount
@@ -87,5 +88,207 @@ func (s *MetricsService) GetMetrics(ctx context.Context, country, pmType, dateFr
 		summary.OverallApproval = float64(int(summary.OverallApproval*100)) / 100
 	}
 
+	if withHealth {
+		if err := s.healthSvc.Attach(ctx, results, f); err != nil {
+			return nil, MetricsSummary{}, 0, err
+		}
+	}
+
 	return results, summary, totalItems, nil
 }
+
+func toMetricResult(row repository.MetricRow) MetricResult {
+	return MetricResult{
+		PaymentMethodCode:   row.PaymentMethodCode,
+		PaymentMethodName:   row.PaymentMethodName,
+		PaymentMethodType:   row.PaymentMethodType,
+		CountryCode:         row.CountryCode,
+		TransactionCount:    row.TransactionCount,
+		ApprovedCount:       row.ApprovedCount,
+		DeclinedCount:       row.DeclinedCount,
+		RefundedCount:       row.RefundedCount,
+		TpvUSD:              row.TpvUSD,
+		ApprovalRate:        row.ApprovalRate,
+		AvgTransactionValue: row.AvgTransactionValue,
+		RevenueContribution: row.RevenueContribution,
+		MonthlyCostUSD:      row.MonthlyCostUSD,
+		CostEfficiencyRatio: row.CostEfficiencyRatio,
+		ActivityStatus:      row.ActivityStatus,
+
+		tpvLocal:                 row.TpvLocal,
+		avgTransactionValueLocal: row.AvgTransactionValueLocal,
+	}
+}
+
+const (
+	ComparePresetPreviousPeriod     = "previous_period"
+	ComparePresetSamePeriodLastYear = "same_period_last_year"
+)
+
+// DateWindow is the half-open range [From, To).
+type DateWindow struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type MetricDelta struct {
+	Current         float64  `json:"current"`
+	Previous        float64  `json:"previous"`
+	AbsoluteDelta   float64  `json:"absolute_delta"`
+	PercentageDelta *float64 `json:"percentage_delta"`
+}
+
+// MetricComparison diffs one method-country. ActivityStatus and
+// PreviousActivityStatus are as of the end of each window, empty when the
+// method had no transactions in it.
+type MetricComparison struct {
+	PaymentMethodCode      string                 `json:"payment_method_code"`
+	PaymentMethodName      string                 `json:"payment_method_name"`
+	PaymentMethodType      string                 `json:"payment_method_type"`
+	CountryCode            string                 `json:"country_code"`
+	ActivityStatus         string                 `json:"activity_status"`
+	PreviousActivityStatus string                 `json:"previous_activity_status"`
+	Metrics                map[string]MetricDelta `json:"metrics"`
+}
+
+// ComparableMetrics lists the numeric MetricResult fields, keyed by their JSON
+// names, that CompareMetrics diffs between two windows.
+var ComparableMetrics = map[string]func(MetricResult) float64{
+	"transaction_count":         func(m MetricResult) float64 { return float64(m.TransactionCount) },
+	"approved_count":            func(m MetricResult) float64 { return float64(m.ApprovedCount) },
+	"declined_count":            func(m MetricResult) float64 { return float64(m.DeclinedCount) },
+	"refunded_count":            func(m MetricResult) float64 { return float64(m.RefundedCount) },
+	"tpv_usd":                   func(m MetricResult) float64 { return m.TpvUSD },
+	"approval_rate":             func(m MetricResult) float64 { return m.ApprovalRate },
+	"avg_transaction_value_usd": func(m MetricResult) float64 { return m.AvgTransactionValue },
+	"revenue_contribution_pct":  func(m MetricResult) float64 { return m.RevenueContribution },
+	"monthly_cost_usd":          func(m MetricResult) float64 { return m.MonthlyCostUSD },
+	"cost_efficiency_ratio":     func(m MetricResult) float64 { return m.CostEfficiencyRatio },
+}
+
+// PreviousWindow derives the comparison window for a preset. previous_period is
+// the window of equal length ending where current starts.
+func PreviousWindow(current DateWindow, preset string) (DateWindow, error) {
+	switch preset {
+	case ComparePresetPreviousPeriod:
+		length := current.To.Sub(current.From)
+		return DateWindow{From: current.From.Add(-length), To: current.From}, nil
+	case ComparePresetSamePeriodLastYear:
+		return DateWindow{From: current.From.AddDate(-1, 0, 0), To: current.To.AddDate(-1, 0, 0)}, nil
+	default:
+		return DateWindow{}, fmt.Errorf("unknown preset %q", preset)
+	}
+}
+
+// CompareMetrics diffs two windows. A filter expression is evaluated on the
+// current window only, and then just the rows it kept are compared. Each
+// window's activity_status is as of its end.
+func (s *MetricsService) CompareMetrics(ctx context.Context, f model.AnalyticsFilter, current, previous DateWindow, sortBy, rankBy, order string) ([]MetricComparison, error) {
+	var currentRows, previousRows []repository.MetricRow
+
+	previousFilter := f
+	previousFilter.Expr = ""
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		currentRows, err = s.repo.ListMetricsInWindow(gctx, f, current.From, current.To)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		previousRows, err = s.repo.ListMetricsInWindow(gctx, previousFilter, previous.From, previous.To)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	type key struct{ pm, cc string }
+	currentByKey := make(map[key]MetricResult, len(currentRows))
+	previousByKey := make(map[key]MetricResult, len(previousRows))
+	var keys []key
+	for _, row := range currentRows {
+		k := key{row.PaymentMethodCode, row.CountryCode}
+		currentByKey[k] = toMetricResult(row)
+		keys = append(keys, k)
+	}
+	for _, row := range previousRows {
+		k := key{row.PaymentMethodCode, row.CountryCode}
+		previousByKey[k] = toMetricResult(row)
+		if _, ok := currentByKey[k]; !ok && f.Expr == "" {
+			keys = append(keys, k)
+		}
+	}
+
+	results := make([]MetricComparison, 0, len(keys))
+	for _, k := range keys {
+		cur, hasCur := currentByKey[k]
+		prev := previousByKey[k]
+		ref := cur
+		if !hasCur {
+			ref = prev
+		}
+
+		metrics := make(map[string]MetricDelta, len(ComparableMetrics))
+		for name, value := range ComparableMetrics {
+			metrics[name] = newMetricDelta(value(cur), value(prev))
+		}
+
+		results = append(results, MetricComparison{
+			PaymentMethodCode:      ref.PaymentMethodCode,
+			PaymentMethodName:      ref.PaymentMethodName,
+			PaymentMethodType:      ref.PaymentMethodType,
+			CountryCode:            ref.CountryCode,
+			ActivityStatus:         cur.ActivityStatus,
+			PreviousActivityStatus: prev.ActivityStatus,
+			Metrics:                metrics,
+		})
+	}
+
+	if _, ok := ComparableMetrics[sortBy]; !ok {
+		sortBy = "tpv_usd"
+	}
+	// Movers without a percentage (previous value of zero) rank last.
+	magnitude := func(d MetricDelta) (float64, bool) {
+		if rankBy == "pct_delta" {
+			if d.PercentageDelta == nil {
+				return 0, false
+			}
+			return math.Abs(*d.PercentageDelta), true
+		}
+		return math.Abs(d.AbsoluteDelta), true
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		mi, okI := magnitude(results[i].Metrics[sortBy])
+		mj, okJ := magnitude(results[j].Metrics[sortBy])
+		if okI != okJ {
+			return okI
+		}
+		if mi != mj {
+			if order == "asc" {
+				return mi < mj
+			}
+			return mi > mj
+		}
+		if results[i].PaymentMethodCode != results[j].PaymentMethodCode {
+			return results[i].PaymentMethodCode < results[j].PaymentMethodCode
+		}
+		return results[i].CountryCode < results[j].CountryCode
+	})
+
+	return results, nil
+}
+
+func newMetricDelta(current, previous float64) MetricDelta {
+	d := MetricDelta{
+		Current:       current,
+		Previous:      previous,
+		AbsoluteDelta: math.Round((current-previous)*100) / 100,
+	}
+	if previous != 0 {
+		pct := math.Round((current-previous)/previous*10000) / 100
+		d.PercentageDelta = &pct
+	}
+	return d
+}
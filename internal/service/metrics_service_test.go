@@ -0,0 +1,80 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviousWindow(t *testing.T) {
+	current := DateWindow{
+		From: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name    string
+		preset  string
+		want    DateWindow
+		wantErr bool
+	}{
+		{
+			name:   "previous period ends where the current window starts",
+			preset: ComparePresetPreviousPeriod,
+			want: DateWindow{
+				From: time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:   "same period last year",
+			preset: ComparePresetSamePeriodLastYear,
+			want: DateWindow{
+				From: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{name: "unknown preset", preset: "last_week", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PreviousWindow(current, tt.preset)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.From.Equal(got.From), "from %s", got.From)
+			assert.True(t, tt.want.To.Equal(got.To), "to %s", got.To)
+			assert.Equal(t, current.To.Sub(current.From), got.To.Sub(got.From))
+		})
+	}
+}
+
+func TestNewMetricDelta(t *testing.T) {
+	tests := []struct {
+		name              string
+		current, previous float64
+		absolute          float64
+		pct               *float64
+	}{
+		{name: "growth", current: 150, previous: 120, absolute: 30, pct: floatPtr(25.0)},
+		{name: "decline rounds to cents", current: 80.456, previous: 100, absolute: -19.54, pct: floatPtr(-19.54)},
+		{name: "no previous value has no percentage", current: 10, previous: 0, absolute: 10},
+		{name: "unchanged", current: 42, previous: 42, absolute: 0, pct: floatPtr(0.0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newMetricDelta(tt.current, tt.previous)
+			assert.Equal(t, tt.current, d.Current)
+			assert.Equal(t, tt.previous, d.Previous)
+			assert.Equal(t, tt.absolute, d.AbsoluteDelta)
+			assert.Equal(t, tt.pct, d.PercentageDelta)
+		})
+	}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
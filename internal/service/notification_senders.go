@@ -0,0 +1,151 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+// NotificationMessage is a rendered notification for one channel.
+type NotificationMessage struct {
+	Subject string
+	Body    string
+}
+
+// ChannelSender delivers rendered messages for one channel kind.
+type ChannelSender interface {
+	Send(ctx context.Context, ch model.NotificationChannel, msg NotificationMessage) error
+}
+
+// SMTPSender sends EMAIL channels as plain-text mail. Auth is only used when a
+// username is set.
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send follows smtp.SendMail, but dials with ctx and bounds the whole
+// conversation by its deadline.
+func (s SMTPSender) Send(ctx context.Context, ch model.NotificationChannel, msg NotificationMessage) error {
+	if s.Host == "" {
+		return fmt.Errorf("SMTP_HOST is not configured")
+	}
+	if len(ch.Recipients) == 0 {
+		return fmt.Errorf("channel %s has no recipients", ch.Name)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, s.Port))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	for _, rcpt := range ch.Recipients {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildEmail(s.From, ch.Recipients, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func buildEmail(from string, to []string, msg NotificationMessage) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// ChatWebhookSender posts {"text": ...} to CHAT_WEBHOOK channels, the payload
+// Slack, Mattermost and most chat incoming webhooks accept.
+type ChatWebhookSender struct {
+	Client *http.Client
+}
+
+func (s ChatWebhookSender) Send(ctx context.Context, ch model.NotificationChannel, msg NotificationMessage) error {
+	text := msg.Body
+	if msg.Subject != "" {
+		text = "*" + msg.Subject + "*\n" + msg.Body
+	}
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ch.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("chat webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// LogSender writes LOG channel messages to the application log.
+type LogSender struct{}
+
+func (LogSender) Send(_ context.Context, ch model.NotificationChannel, msg NotificationMessage) error {
+	log.Info().Str("channel", ch.Name).Str("subject", msg.Subject).Msg(msg.Body)
+	return nil
+}
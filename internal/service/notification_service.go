@@ -0,0 +1,418 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+const (
+	notificationBatchSize   = 100
+	notificationSendTimeout = 10 * time.Second
+	// notificationLease outlasts a whole batch of sends at
+	// notificationSendTimeout each; digests only add items to a message, not
+	// messages to a batch.
+	notificationLease       = notificationBatchSize*notificationSendTimeout + time.Minute
+	notificationMaxAttempts = 5
+	notificationLogLimit    = 1000
+	// rateLimitDelay is how long items wait when their channel is over its
+	// hourly limit.
+	rateLimitDelay = 5 * time.Minute
+
+	// Immediate messages carry a single item, so ranging over Items picks it.
+	defaultSubjectTemplate = `{{if .Digest}}{{.Count}} payment method insights{{else}}{{range .Items}}[{{.Severity}}] {{.Type}}: {{.PaymentMethod}} in {{.Country}}{{end}}{{end}}`
+	defaultBodyTemplate    = `{{range .Items}}[{{.Severity}}] {{.Event}} {{.Type}}: {{.PaymentMethod}} in {{.Country}} ({{.State}})
+{{if .Description}}{{.Description}}
+{{end}}{{if .RecommendedAction}}Action: {{.RecommendedAction}}
+{{end}}
+{{end}}`
+)
+
+var ErrInvalidNotificationConfig = errors.New("invalid notification config")
+
+// NotificationService routes insight events to channels, queues them for
+// immediate or digest delivery and sends due messages.
+type NotificationService struct {
+	repo    *repository.NotificationRepository
+	senders map[string]ChannelSender
+}
+
+// NewNotificationService takes one sender per channel kind.
+func NewNotificationService(repo *repository.NotificationRepository, senders map[string]ChannelSender) *NotificationService {
+	return &NotificationService{repo: repo, senders: senders}
+}
+
+// CreateChannel validates the channel's kind-specific settings and templates
+// before storing it.
+func (s *NotificationService) CreateChannel(ctx context.Context, ch model.NotificationChannel) (*model.NotificationChannel, error) {
+	switch ch.Kind {
+	case model.ChannelKindEmail:
+		if len(ch.Recipients) == 0 {
+			return nil, fmt.Errorf("%w: EMAIL channels need recipients", ErrInvalidNotificationConfig)
+		}
+	case model.ChannelKindChatWebhook:
+		if err := checkCallbackURL(ch.WebhookURL); err != nil {
+			return nil, fmt.Errorf("%w: webhook_url %v", ErrInvalidNotificationConfig, err)
+		}
+	case model.ChannelKindLog:
+	default:
+		return nil, fmt.Errorf("%w: unknown channel kind %q", ErrInvalidNotificationConfig, ch.Kind)
+	}
+	// Render a sample so templates referencing unknown fields fail here
+	// rather than at send time.
+	sample := notificationView{Channel: ch.Name, Count: 1, Items: []notificationItem{{Event: model.InsightEventOpened}}}
+	if _, err := renderView(ch, sample); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNotificationConfig, err)
+	}
+	ch.Active = true
+	return s.repo.CreateChannel(ctx, ch)
+}
+
+func (s *NotificationService) ListChannels(ctx context.Context) ([]model.NotificationChannel, error) {
+	return s.repo.ListChannels(ctx)
+}
+
+func (s *NotificationService) DeleteChannel(ctx context.Context, id string) error {
+	return s.repo.DeleteChannel(ctx, id)
+}
+
+func (s *NotificationService) CreateRoute(ctx context.Context, rt model.NotificationRoute) (*model.NotificationRoute, error) {
+	for _, e := range rt.EventTypes {
+		switch e {
+		case model.InsightEventOpened, model.InsightEventResolved, model.InsightEventStateChanged, model.InsightEventSeverityChanged:
+		default:
+			return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidNotificationConfig, e)
+		}
+	}
+	if rt.Delivery == "" {
+		rt.Delivery = model.RouteDeliveryImmediate
+	}
+	exists, err := s.repo.ChannelExists(ctx, rt.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: channel %s does not exist", ErrInvalidNotificationConfig, rt.ChannelID)
+	}
+	rt.Active = true
+	return s.repo.CreateRoute(ctx, rt)
+}
+
+func (s *NotificationService) ListRoutes(ctx context.Context) ([]model.NotificationRoute, error) {
+	return s.repo.ListRoutes(ctx)
+}
+
+func (s *NotificationService) DeleteRoute(ctx context.Context, id string) error {
+	return s.repo.DeleteRoute(ctx, id)
+}
+
+func (s *NotificationService) ListLog(ctx context.Context, channelID string) ([]model.NotificationLogEntry, error) {
+	return s.repo.ListLog(ctx, channelID, notificationLogLimit)
+}
+
+// NotifyInsightEvents implements InsightNotifier by queueing each event for
+// every route it matches.
+func (s *NotificationService) NotifyInsightEvents(ctx context.Context, tx pgx.Tx, events []InsightEvent) error {
+	routes, err := s.repo.ListRoutes(ctx)
+	if err != nil {
+		return err
+	}
+
+	var items []repository.QueuedNotification
+	for _, e := range events {
+		matched := matchRoutes(routes, e)
+		if len(matched) == 0 {
+			continue
+		}
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("marshal notification event: %w", err)
+		}
+		for _, rt := range matched {
+			items = append(items, repository.QueuedNotification{
+				RouteID:   rt.ID,
+				ChannelID: rt.ChannelID,
+				InsightID: e.Insight.InsightID,
+				Event:     payload,
+				Digest:    rt.Delivery != model.RouteDeliveryImmediate,
+				DueAt:     nextDigestAt(rt.Delivery, e.OccurredAt),
+			})
+		}
+	}
+	return s.repo.Enqueue(ctx, tx, items)
+}
+
+// matchRoutes returns the active routes an event matches, in priority order,
+// stopping after the first matching route with StopProcessing set. routes
+// must already be sorted by priority.
+func matchRoutes(routes []model.NotificationRoute, e InsightEvent) []model.NotificationRoute {
+	var out []model.NotificationRoute
+	for _, rt := range routes {
+		if !rt.Active || !routeMatches(rt, e) {
+			continue
+		}
+		out = append(out, rt)
+		if rt.StopProcessing {
+			break
+		}
+	}
+	return out
+}
+
+// routeMatches reports whether every non-empty filter of rt contains the
+// event's value.
+func routeMatches(rt model.NotificationRoute, e InsightEvent) bool {
+	return matchesAny(rt.EventTypes, e.Type) &&
+		matchesAny(rt.InsightTypes, e.Insight.Type) &&
+		matchesAny(rt.Severities, e.Insight.Severity) &&
+		matchesAny(rt.Countries, e.Insight.CountryCode)
+}
+
+func matchesAny(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// nextDigestAt is when an item routed at t is sent: right away for immediate
+// routes, the next midnight UTC for daily digests and the next Monday 08:00
+// UTC for weekly digests.
+func nextDigestAt(delivery string, t time.Time) time.Time {
+	t = t.UTC()
+	switch delivery {
+	case model.RouteDeliveryDailyDigest:
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+	case model.RouteDeliveryWeeklyDigest:
+		days := (int(time.Monday) - int(t.Weekday()) + 7) % 7
+		next := time.Date(t.Year(), t.Month(), t.Day()+days, 8, 0, 0, 0, time.UTC)
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	default:
+		return t
+	}
+}
+
+// DeliverDue sends one batch of due queue items and returns how many it
+// claimed. Immediate items are sent one message each; all due digest items
+// of a route are claimed together and sent as one message.
+func (s *NotificationService) DeliverDue(ctx context.Context) (int, error) {
+	due, err := s.repo.ClaimDue(ctx, notificationBatchSize, notificationLease)
+	if err != nil || len(due) == 0 {
+		return len(due), err
+	}
+
+	channels, err := s.repo.ListChannels(ctx)
+	if err != nil {
+		return len(due), err
+	}
+	routes, err := s.repo.ListRoutes(ctx)
+	if err != nil {
+		return len(due), err
+	}
+	channelByID := make(map[string]model.NotificationChannel, len(channels))
+	for _, ch := range channels {
+		channelByID[ch.ID] = ch
+	}
+	routeByID := make(map[string]model.NotificationRoute, len(routes))
+	for _, rt := range routes {
+		routeByID[rt.ID] = rt
+	}
+
+	sentThisRun := map[string]int{}
+	for _, group := range groupNotifications(due) {
+		ch := channelByID[group[0].ChannelID]
+		rt := routeByID[group[0].RouteID]
+		if err := s.deliverGroup(ctx, ch, rt, group, sentThisRun); err != nil {
+			return len(due), err
+		}
+	}
+	return len(due), nil
+}
+
+// groupNotifications keeps claim order; digest items share a group per route.
+func groupNotifications(items []repository.QueuedNotification) [][]repository.QueuedNotification {
+	var groups [][]repository.QueuedNotification
+	digestGroup := map[string]int{}
+	for _, it := range items {
+		if !it.Digest {
+			groups = append(groups, []repository.QueuedNotification{it})
+			continue
+		}
+		if i, ok := digestGroup[it.RouteID]; ok {
+			groups[i] = append(groups[i], it)
+			continue
+		}
+		digestGroup[it.RouteID] = len(groups)
+		groups = append(groups, []repository.QueuedNotification{it})
+	}
+	return groups
+}
+
+func (s *NotificationService) deliverGroup(ctx context.Context, ch model.NotificationChannel, rt model.NotificationRoute,
+	group []repository.QueuedNotification, sentThisRun map[string]int) error {
+	ids := make([]string, len(group))
+	attempts := 0
+	for i, it := range group {
+		ids[i] = it.ID
+		if it.Attempts > attempts {
+			attempts = it.Attempts
+		}
+	}
+	now := time.Now()
+
+	if ch.RateLimitPerHour > 0 {
+		sent, err := s.repo.CountSent(ctx, ch.ID, now.Add(-time.Hour))
+		if err != nil {
+			return err
+		}
+		if sent+sentThisRun[ch.ID] >= ch.RateLimitPerHour {
+			return s.repo.Defer(ctx, ids, now.Add(rateLimitDelay))
+		}
+	}
+
+	entry := model.NotificationLogEntry{ChannelID: ch.ID, RouteID: rt.ID, ItemCount: len(group)}
+	msg, sendErr := renderNotification(ch, rt, group)
+	entry.Subject = msg.Subject
+	if sendErr == nil {
+		sendErr = s.send(ctx, ch, msg)
+	}
+	if sendErr == nil {
+		sentThisRun[ch.ID]++
+		entry.Status = "SENT"
+		return s.repo.MarkSent(ctx, ids, entry)
+	}
+
+	entry.Status = "FAILED"
+	entry.Error = sendErr.Error()
+	var retryAt *time.Time
+	if attempts+1 < notificationMaxAttempts {
+		next := now.Add(retryBackoff(attempts + 1))
+		retryAt = &next
+	}
+	return s.repo.MarkFailed(ctx, ids, entry, retryAt)
+}
+
+func (s *NotificationService) send(ctx context.Context, ch model.NotificationChannel, msg NotificationMessage) error {
+	if !ch.Active {
+		return fmt.Errorf("channel %s is inactive", ch.Name)
+	}
+	sender, ok := s.senders[ch.Kind]
+	if !ok {
+		return fmt.Errorf("no sender for channel kind %s", ch.Kind)
+	}
+	ctx, cancel := context.WithTimeout(ctx, notificationSendTimeout)
+	defer cancel()
+	return sender.Send(ctx, ch, msg)
+}
+
+// notificationView is the data channel templates render.
+type notificationView struct {
+	Channel string
+	Route   string
+	Digest  bool
+	Count   int
+	Items   []notificationItem
+}
+
+type notificationItem struct {
+	Event             string
+	InsightID         string
+	Type              string
+	Severity          string
+	PreviousSeverity  string
+	Country           string
+	PaymentMethod     string
+	State             string
+	Description       string
+	RecommendedAction string
+	OccurredAt        time.Time
+}
+
+func renderNotification(ch model.NotificationChannel, rt model.NotificationRoute, group []repository.QueuedNotification) (NotificationMessage, error) {
+	view := notificationView{Channel: ch.Name, Route: rt.Name, Digest: group[0].Digest, Count: len(group)}
+	for _, it := range group {
+		var e InsightEvent
+		if err := json.Unmarshal(it.Event, &e); err != nil {
+			return NotificationMessage{}, fmt.Errorf("decode notification event: %w", err)
+		}
+		view.Items = append(view.Items, notificationItemFor(e))
+	}
+	return renderView(ch, view)
+}
+
+func renderView(ch model.NotificationChannel, view notificationView) (NotificationMessage, error) {
+	subjectTmpl, bodyTmpl, err := parseNotificationTemplates(ch)
+	if err != nil {
+		return NotificationMessage{}, err
+	}
+
+	var subject, body strings.Builder
+	if err := subjectTmpl.Execute(&subject, view); err != nil {
+		return NotificationMessage{}, fmt.Errorf("render subject: %w", err)
+	}
+	if err := bodyTmpl.Execute(&body, view); err != nil {
+		return NotificationMessage{}, fmt.Errorf("render body: %w", err)
+	}
+	return NotificationMessage{Subject: strings.TrimSpace(subject.String()), Body: body.String()}, nil
+}
+
+func notificationItemFor(e InsightEvent) notificationItem {
+	item := notificationItem{
+		Event:            e.Type,
+		InsightID:        e.Insight.InsightID,
+		Type:             e.Insight.Type,
+		Severity:         e.Insight.Severity,
+		PreviousSeverity: e.PreviousSeverity,
+		Country:          e.Insight.CountryCode,
+		PaymentMethod:    e.Insight.PaymentMethodCode,
+		State:            e.Insight.State,
+		OccurredAt:       e.OccurredAt,
+	}
+	var snap Insight
+	if len(e.Insight.Snapshot) > 0 && json.Unmarshal(e.Insight.Snapshot, &snap) == nil {
+		item.Description = snap.Description
+		item.RecommendedAction = snap.RecommendedAction
+		if snap.PaymentMethodName != "" {
+			item.PaymentMethod = snap.PaymentMethodName
+		}
+	}
+	return item
+}
+
+// parseNotificationTemplates falls back to the default templates when a
+// channel leaves them empty. Both follow the admin template policy.
+func parseNotificationTemplates(ch model.NotificationChannel) (*template.Template, *template.Template, error) {
+	subjectSrc, bodySrc := ch.SubjectTemplate, ch.BodyTemplate
+	if subjectSrc == "" {
+		subjectSrc = defaultSubjectTemplate
+	}
+	if bodySrc == "" {
+		bodySrc = defaultBodyTemplate
+	}
+	subject, err := parseRestrictedTemplate("subject", subjectSrc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("subject_template: %w", err)
+	}
+	body, err := parseRestrictedTemplate("body", bodySrc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("body_template: %w", err)
+	}
+	return subject, body, nil
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func testEvent(eventType, insightType, severity, country string) InsightEvent {
+	return InsightEvent{
+		Type: eventType,
+		Insight: model.TrackedInsight{
+			InsightID:         "id-" + insightType + "-" + country,
+			Type:              insightType,
+			PaymentMethodCode: "OXXO",
+			CountryCode:       country,
+			Severity:          severity,
+			State:             model.InsightStateOpen,
+		},
+		OccurredAt: time.Date(2026, 3, 4, 15, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestMatchRoutes(t *testing.T) {
+	routes := []model.NotificationRoute{
+		{ID: "mx-pager", Priority: 10, InsightTypes: []string{"performance_alert"}, Severities: []string{"HIGH"},
+			Countries: []string{"MX"}, StopProcessing: true, Active: true},
+		{ID: "gems-weekly", Priority: 20, InsightTypes: []string{"hidden_gem"}, Severities: []string{"LOW"}, Active: true},
+		{ID: "disabled", Priority: 30, Active: false},
+		{ID: "catch-all", Priority: 100, Active: true},
+	}
+	ids := func(rs []model.NotificationRoute) []string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"mx-pager"},
+		ids(matchRoutes(routes, testEvent(model.InsightEventOpened, "performance_alert", "HIGH", "MX"))))
+	assert.Equal(t, []string{"catch-all"},
+		ids(matchRoutes(routes, testEvent(model.InsightEventOpened, "performance_alert", "HIGH", "BR"))))
+	assert.Equal(t, []string{"gems-weekly", "catch-all"},
+		ids(matchRoutes(routes, testEvent(model.InsightEventOpened, "hidden_gem", "LOW", "CO"))))
+
+	resolvedOnly := model.NotificationRoute{EventTypes: []string{model.InsightEventResolved}, Active: true}
+	assert.False(t, routeMatches(resolvedOnly, testEvent(model.InsightEventOpened, "zombie", "LOW", "MX")))
+	assert.True(t, routeMatches(resolvedOnly, testEvent(model.InsightEventResolved, "zombie", "LOW", "MX")))
+}
+
+func TestNextDigestAt(t *testing.T) {
+	wed := time.Date(2026, 3, 4, 15, 30, 0, 0, time.UTC) // a Wednesday
+	assert.Equal(t, wed, nextDigestAt(model.RouteDeliveryImmediate, wed))
+	assert.Equal(t, time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC), nextDigestAt(model.RouteDeliveryDailyDigest, wed))
+	assert.Equal(t, time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC), nextDigestAt(model.RouteDeliveryWeeklyDigest, wed))
+
+	mondayEarly := time.Date(2026, 3, 9, 7, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC), nextDigestAt(model.RouteDeliveryWeeklyDigest, mondayEarly))
+	mondayLate := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 16, 8, 0, 0, 0, time.UTC), nextDigestAt(model.RouteDeliveryWeeklyDigest, mondayLate))
+}
+
+func TestGroupNotifications(t *testing.T) {
+	groups := groupNotifications([]repository.QueuedNotification{
+		{ID: "1", RouteID: "weekly", Digest: true},
+		{ID: "2", RouteID: "pager"},
+		{ID: "3", RouteID: "weekly", Digest: true},
+		{ID: "4", RouteID: "pager"},
+	})
+	require.Len(t, groups, 3)
+	assert.Len(t, groups[0], 2)
+	assert.Equal(t, "2", groups[1][0].ID)
+	assert.Equal(t, "4", groups[2][0].ID)
+}
+
+func queued(t *testing.T, e InsightEvent, digest bool) repository.QueuedNotification {
+	payload, err := json.Marshal(e)
+	require.NoError(t, err)
+	return repository.QueuedNotification{Event: payload, Digest: digest}
+}
+
+func TestRenderNotification(t *testing.T) {
+	e := testEvent(model.InsightEventOpened, "performance_alert", "HIGH", "MX")
+	e.Insight.Snapshot, _ = json.Marshal(Insight{PaymentMethodName: "OXXO Pay", Description: "Approval rate fell to 61%"})
+
+	msg, err := renderNotification(model.NotificationChannel{Name: "mx-team"}, model.NotificationRoute{},
+		[]repository.QueuedNotification{queued(t, e, false)})
+	require.NoError(t, err)
+	assert.Equal(t, "[HIGH] performance_alert: OXXO Pay in MX", msg.Subject)
+	assert.Contains(t, msg.Body, "Approval rate fell to 61%")
+
+	ch := model.NotificationChannel{
+		SubjectTemplate: "{{.Route}}: {{.Count}} new",
+		BodyTemplate:    "{{range .Items}}{{.Country}}/{{.Severity}} {{end}}",
+	}
+	msg, err = renderNotification(ch, model.NotificationRoute{Name: "gems"}, []repository.QueuedNotification{
+		queued(t, testEvent(model.InsightEventOpened, "hidden_gem", "LOW", "CO"), true),
+		queued(t, testEvent(model.InsightEventOpened, "hidden_gem", "LOW", "PE"), true),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "gems: 2 new", msg.Subject)
+	assert.Equal(t, "CO/LOW PE/LOW ", msg.Body)
+}
+
+func TestCreateChannelRejectsBadTemplates(t *testing.T) {
+	svc := NewNotificationService(nil, nil)
+	_, err := svc.CreateChannel(context.Background(), model.NotificationChannel{
+		Kind: model.ChannelKindLog, BodyTemplate: "{{.Items",
+	})
+	assert.ErrorIs(t, err, ErrInvalidNotificationConfig)
+
+	_, err = svc.CreateChannel(context.Background(), model.NotificationChannel{
+		Kind: model.ChannelKindLog, SubjectTemplate: "{{.NoSuchField}}",
+	})
+	assert.ErrorIs(t, err, ErrInvalidNotificationConfig)
+
+	_, err = svc.CreateChannel(context.Background(), model.NotificationChannel{Kind: model.ChannelKindEmail})
+	assert.ErrorIs(t, err, ErrInvalidNotificationConfig)
+
+	for _, src := range []string{
+		"{{len .Items}}",
+		"{{with .Items}}x{{end}}",
+		"{{$n := .Count}}{{$n}}",
+		`{{template "subject"}}`,
+		strings.Repeat("x", maxTemplateLen+1),
+	} {
+		_, err = svc.CreateChannel(context.Background(), model.NotificationChannel{
+			Kind: model.ChannelKindLog, BodyTemplate: src,
+		})
+		assert.ErrorIs(t, err, ErrInvalidNotificationConfig, src)
+	}
+
+	_, _, err = parseNotificationTemplates(model.NotificationChannel{})
+	assert.NoError(t, err, "default templates follow the policy")
+}
+
+func TestCreateChannelRejectsBadWebhookURLs(t *testing.T) {
+	svc := NewNotificationService(nil, nil)
+	for _, u := range []string{
+		"ftp://example.com/hook",
+		"/relative",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://metadata.google.internal/computeMetadata/v1/",
+		"http://[fe80::1]/",
+		"http://[fd00:ec2::254]/",
+	} {
+		_, err := svc.CreateChannel(context.Background(), model.NotificationChannel{
+			Kind: model.ChannelKindChatWebhook, WebhookURL: u,
+		})
+		assert.ErrorIs(t, err, ErrInvalidNotificationConfig, u)
+	}
+}
+
+func TestChatWebhookSender(t *testing.T) {
+	var got map[string]string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	sender := ChatWebhookSender{Client: receiver.Client()}
+	err := sender.Send(context.Background(), model.NotificationChannel{WebhookURL: receiver.URL},
+		NotificationMessage{Subject: "[HIGH] alert", Body: "details"})
+	require.NoError(t, err)
+	assert.Equal(t, "*[HIGH] alert*\ndetails", got["text"])
+}
+
+// fakeSMTP accepts one message and returns what it received.
+func fakeSMTP(t *testing.T) (host, port string, received <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		var transcript strings.Builder
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM"), strings.HasPrefix(cmd, "RCPT TO"):
+				transcript.WriteString(line)
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					transcript.WriteString(l)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				out <- transcript.String()
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	host, port, err = net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+	return host, port, out
+}
+
+func TestSMTPSender(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+	sender := SMTPSender{Host: host, Port: port, From: "pmhm@example.com"}
+
+	err := sender.Send(context.Background(), model.NotificationChannel{
+		Name: "mx-team", Recipients: []string{"payments-mx@example.com", "oncall@example.com"},
+	}, NotificationMessage{Subject: "[HIGH] performance_alert", Body: "Approval rate fell\nCheck the acquirer"})
+	require.NoError(t, err)
+
+	select {
+	case msg := <-received:
+		assert.Contains(t, msg, "MAIL FROM:<pmhm@example.com>")
+		assert.Contains(t, msg, "RCPT TO:<payments-mx@example.com>")
+		assert.Contains(t, msg, "RCPT TO:<oncall@example.com>")
+		assert.Contains(t, msg, "Subject: [HIGH] performance_alert\r\n")
+		assert.Contains(t, msg, "Approval rate fell\r\nCheck the acquirer")
+	case <-time.After(5 * time.Second):
+		t.Fatal("fake SMTP server received nothing")
+	}
+}
+
+func TestSMTPSenderRequiresHost(t *testing.T) {
+	err := SMTPSender{}.Send(context.Background(), model.NotificationChannel{Recipients: []string{"a@example.com"}},
+		NotificationMessage{})
+	assert.Error(t, err)
+}
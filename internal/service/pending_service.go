@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+// pendingAgeBuckets are the report's age bands in hours. To is exclusive and
+// zero for the open-ended last band.
+var pendingAgeBuckets = []struct {
+	Label    string
+	From, To int
+}{
+	{"<1h", 0, 1},
+	{"1-6h", 1, 6},
+	{"6-24h", 6, 24},
+	{"1-3d", 24, 72},
+	{"3-7d", 72, 168},
+	{">7d", 168, 0},
+}
+
+type PendingAgeBucket struct {
+	Label            string  `json:"label"`
+	MinHours         int     `json:"min_hours"`
+	MaxHours         int     `json:"max_hours,omitempty"`
+	TransactionCount int     `json:"transaction_count"`
+	AmountUSD        float64 `json:"amount_usd"`
+}
+
+// PendingAging summarizes how long a method-country's PENDING transactions
+// have been waiting against its settlement window.
+type PendingAging struct {
+	PaymentMethodCode     string             `json:"payment_method_code"`
+	PaymentMethodName     string             `json:"payment_method_name"`
+	PaymentMethodType     string             `json:"payment_method_type"`
+	CountryCode           string             `json:"country_code"`
+	PendingCount          int                `json:"pending_count"`
+	PendingAmountUSD      float64            `json:"pending_amount_usd"`
+	OldestAgeHours        int                `json:"oldest_age_hours"`
+	SettlementWindowHours float64            `json:"settlement_window_hours"`
+	OverdueCount          int                `json:"overdue_count"`
+	OverdueAmountUSD      float64            `json:"overdue_amount_usd"`
+	OverdueSharePct       float64            `json:"overdue_share_pct"`
+	Buckets               []PendingAgeBucket `json:"buckets"`
+}
+
+type PendingService struct {
+	repo  *repository.PendingRepository
+	rules *DetectionRuleService
+}
+
+func NewPendingService(repo *repository.PendingRepository, rules *DetectionRuleService) *PendingService {
+	return &PendingService{repo: repo, rules: rules}
+}
+
+// GetAging reports PENDING transactions by age per method-country, most
+// overdue first. Settlement windows come from the stuck_payments rules.
+func (s *PendingService) GetAging(ctx context.Context, f model.AnalyticsFilter) ([]PendingAging, error) {
+	ages, err := s.repo.GetPendingAges(ctx, f, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	paramsFor, err := s.rules.ParamsFunc(ctx, InsightTypeStuckPayments)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []PendingAging{}
+	for _, series := range splitPendingAges(ages) {
+		first := series[0]
+		params := paramsFor(first.CountryCode, first.PaymentMethodType, first.PaymentMethodCode)
+		results = append(results, summarizePending(series, params.Get("settlement_window_hours")))
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].OverdueSharePct != results[j].OverdueSharePct {
+			return results[i].OverdueSharePct > results[j].OverdueSharePct
+		}
+		return results[i].PendingCount > results[j].PendingCount
+	})
+	return results, nil
+}
+
+// splitPendingAges groups rows ordered by method and country.
+func splitPendingAges(rows []repository.PendingAge) [][]repository.PendingAge {
+	var out [][]repository.PendingAge
+	start := 0
+	for i := 1; i <= len(rows); i++ {
+		if i == len(rows) ||
+			rows[i].PaymentMethodCode != rows[start].PaymentMethodCode ||
+			rows[i].CountryCode != rows[start].CountryCode {
+			if i > start {
+				out = append(out, rows[start:i])
+			}
+			start = i
+		}
+	}
+	return out
+}
+
+// summarizePending buckets one method-country's pending ages. A transaction
+// is overdue once its whole hours of age reach windowHours.
+func summarizePending(series []repository.PendingAge, windowHours float64) PendingAging {
+	first := series[0]
+	a := PendingAging{
+		PaymentMethodCode:     first.PaymentMethodCode,
+		PaymentMethodName:     first.PaymentMethodName,
+		PaymentMethodType:     first.PaymentMethodType,
+		CountryCode:           first.CountryCode,
+		SettlementWindowHours: windowHours,
+		Buckets:               make([]PendingAgeBucket, len(pendingAgeBuckets)),
+	}
+	for i, b := range pendingAgeBuckets {
+		a.Buckets[i] = PendingAgeBucket{Label: b.Label, MinHours: b.From, MaxHours: b.To}
+	}
+
+	for _, p := range series {
+		a.PendingCount += p.TransactionCount
+		a.PendingAmountUSD += p.AmountUSD
+		if p.AgeHours > a.OldestAgeHours {
+			a.OldestAgeHours = p.AgeHours
+		}
+		if float64(p.AgeHours) >= windowHours {
+			a.OverdueCount += p.TransactionCount
+			a.OverdueAmountUSD += p.AmountUSD
+		}
+		for i, b := range pendingAgeBuckets {
+			if p.AgeHours >= b.From && (b.To == 0 || p.AgeHours < b.To) {
+				a.Buckets[i].TransactionCount += p.TransactionCount
+				a.Buckets[i].AmountUSD += p.AmountUSD
+				break
+			}
+		}
+	}
+	if a.PendingCount > 0 {
+		a.OverdueSharePct = float64(a.OverdueCount) / float64(a.PendingCount) * 100
+	}
+	return a
+}
+
+type stuckPaymentsDetector struct {
+	repo *repository.PendingRepository
+}
+
+func (d *stuckPaymentsDetector) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	ages, err := d.repo.GetPendingAges(ctx, run.Scope.Filter, run.Scope.Now)
+	if err != nil {
+		return nil, err
+	}
+
+	var insights []Insight
+	for _, series := range splitPendingAges(ages) {
+		first := series[0]
+		params := run.ParamsFor(first.CountryCode, first.PaymentMethodType, first.PaymentMethodCode)
+		a := summarizePending(series, params.Get("settlement_window_hours"))
+		if float64(a.PendingCount) < params.Get("min_pending_txns") ||
+			a.OverdueSharePct <= params.Get("max_overdue_share_pct") {
+			continue
+		}
+
+		insights = append(insights, Insight{
+			InsightID:         hashID(InsightTypeStuckPayments, a.PaymentMethodCode, a.CountryCode),
+			Type:              InsightTypeStuckPayments,
+			Severity:          run.SeverityFor(params).Map(a.OverdueSharePct),
+			PaymentMethodCode: a.PaymentMethodCode,
+			PaymentMethodName: a.PaymentMethodName,
+			CountryCode:       a.CountryCode,
+			TriggeringMetric:  "overdue_pending_share_pct",
+			MetricValue:       a.OverdueSharePct,
+			Threshold:         params.Get("max_overdue_share_pct"),
+			Description: fmt.Sprintf("%d of %d PENDING %s transactions in %s (%.1f%%, $%.2f) are past the %gh settlement window; the oldest has waited %dh",
+				a.OverdueCount, a.PendingCount, a.PaymentMethodName, a.CountryCode, a.OverdueSharePct,
+				a.OverdueAmountUSD, a.SettlementWindowHours, a.OldestAgeHours),
+			RecommendedAction: "Reconcile against the provider's settlement reports and check that payment confirmations are still arriving; expire vouchers that can no longer be paid.",
+			SupportingData: map[string]interface{}{
+				"pending_count":           a.PendingCount,
+				"pending_amount_usd":      a.PendingAmountUSD,
+				"overdue_count":           a.OverdueCount,
+				"overdue_amount_usd":      a.OverdueAmountUSD,
+				"oldest_age_hours":        a.OldestAgeHours,
+				"settlement_window_hours": a.SettlementWindowHours,
+				"payment_method_type":     a.PaymentMethodType,
+			},
+			EstimatedImpactUSD: stuckPaymentsImpact(a.OverdueAmountUSD, a.OldestAgeHours),
+			Thresholds:         params,
+			GeneratedAt:        run.Scope.Now,
+		})
+	}
+	return insights, nil
+}
+
+// EvidenceScope samples the PENDING transactions of any age.
+func (d *stuckPaymentsDetector) EvidenceScope(in Insight) EvidenceScope {
+	return EvidenceScope{
+		To:       in.GeneratedAt,
+		Metric:   EvidenceMetricTransactions,
+		Peers:    PeerGroupNone,
+		Statuses: []string{"PENDING"},
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func pendingAge(method, country string, ageHours, count int, amount float64) repository.PendingAge {
+	return repository.PendingAge{
+		PaymentMethodCode: method,
+		PaymentMethodName: method,
+		PaymentMethodType: "CASH",
+		CountryCode:       country,
+		AgeHours:          ageHours,
+		TransactionCount:  count,
+		AmountUSD:         amount,
+	}
+}
+
+func TestSummarizePending(t *testing.T) {
+	series := []repository.PendingAge{
+		pendingAge("OXXO", "MX", 0, 10, 100),
+		pendingAge("OXXO", "MX", 5, 4, 40),
+		pendingAge("OXXO", "MX", 71, 3, 30),
+		pendingAge("OXXO", "MX", 72, 2, 20),
+		pendingAge("OXXO", "MX", 200, 1, 10),
+	}
+	a := summarizePending(series, 72)
+
+	assert.Equal(t, 20, a.PendingCount)
+	assert.InDelta(t, 200, a.PendingAmountUSD, 1e-9)
+	assert.Equal(t, 200, a.OldestAgeHours)
+	assert.Equal(t, 3, a.OverdueCount, "ages of 72h and more are past a 72h window")
+	assert.InDelta(t, 30, a.OverdueAmountUSD, 1e-9)
+	assert.InDelta(t, 15, a.OverdueSharePct, 1e-9)
+
+	require.Len(t, a.Buckets, 6)
+	counts := make([]int, len(a.Buckets))
+	for i, b := range a.Buckets {
+		counts[i] = b.TransactionCount
+	}
+	assert.Equal(t, []int{10, 4, 0, 3, 2, 1}, counts)
+	assert.Equal(t, ">7d", a.Buckets[5].Label)
+	assert.Equal(t, 0, a.Buckets[5].MaxHours)
+}
+
+func TestSplitPendingAges(t *testing.T) {
+	groups := splitPendingAges([]repository.PendingAge{
+		pendingAge("BOLETO", "BR", 1, 1, 1),
+		pendingAge("BOLETO", "BR", 30, 1, 1),
+		pendingAge("OXXO", "MX", 2, 1, 1),
+	})
+	require.Len(t, groups, 2)
+	assert.Len(t, groups[0], 2)
+	assert.Equal(t, "OXXO", groups[1][0].PaymentMethodCode)
+	assert.Empty(t, splitPendingAges(nil))
+}
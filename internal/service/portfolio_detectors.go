@@ -0,0 +1,439 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+type concentrationRiskDetector struct {
+	repo *repository.InsightRepository
+}
+
+func (d *concentrationRiskDetector) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	since := run.Scope.Now.AddDate(0, 0, -int(run.Params.Get("lookback_days")))
+	tpv, err := d.repo.GetMethodTPV(ctx, run.Scope.Filter, since, run.Scope.Now)
+	if err != nil {
+		return nil, err
+	}
+	alternatives, err := d.repo.GetCatalogAlternatives(ctx, run.Scope.Filter, since, run.Scope.Now)
+	if err != nil {
+		return nil, err
+	}
+	altByCountry := map[string][]repository.CatalogAlternative{}
+	for _, a := range alternatives {
+		altByCountry[a.CountryCode] = append(altByCountry[a.CountryCode], a)
+	}
+
+	var insights []Insight
+	for _, country := range splitByCountry(tpv) {
+		for _, r := range concentrationRisks(country, run.ParamsFor) {
+			m := r.Method
+			if !run.Scope.Filter.Matches(m.CountryCode, m.PaymentMethodCode, m.PaymentMethodType) {
+				continue
+			}
+			params := run.ParamsFor(m.CountryCode, m.PaymentMethodType, m.PaymentMethodCode)
+			suggested := suggestAlternatives(altByCountry[m.CountryCode], m.PaymentMethodType, 3)
+
+			action := "Integrate a second method of the same type so traffic can fail over."
+			if len(suggested) > 0 {
+				action = fmt.Sprintf("Integrate %s from the %s catalog to add failover capacity.", strings.Join(suggested, ", "), m.CountryCode)
+			}
+
+			insights = append(insights, Insight{
+				InsightID:         hashID(InsightTypeConcentrationRisk, m.PaymentMethodCode, m.CountryCode),
+				Type:              InsightTypeConcentrationRisk,
+				Severity:          run.SeverityFor(params).Map(r.SharePct),
+				PaymentMethodCode: m.PaymentMethodCode,
+				PaymentMethodName: m.PaymentMethodName,
+				CountryCode:       m.CountryCode,
+				TriggeringMetric:  "tpv_share_pct",
+				MetricValue:       r.SharePct,
+				Threshold:         params.Get("max_method_share_pct"),
+				Description: fmt.Sprintf("%s carries %.1f%% of approved TPV in %s (HHI %.0f): %s",
+					m.PaymentMethodName, r.SharePct, m.CountryCode, r.CountryHHI, strings.Join(r.Reasons, "; ")),
+				RecommendedAction: action,
+				SupportingData: map[string]interface{}{
+					"country_hhi":            r.CountryHHI,
+					"type_hhi":               r.TypeHHI,
+					"type_share_pct":         r.TypeSharePct,
+					"active_methods_of_type": r.MethodsOfType,
+					"tpv_usd":                m.TpvUSD,
+					"country_tpv_usd":        r.CountryTPV,
+					"suggested_methods":      suggested,
+					"payment_method_type":    m.PaymentMethodType,
+				},
+				EstimatedImpactUSD: concentrationRiskImpact(m.TpvUSD, run.Params.Get("lookback_days")),
+				Thresholds:         params,
+				GeneratedAt:        run.Scope.Now,
+			})
+		}
+	}
+	return insights, nil
+}
+
+// EvidenceScope covers the TPV window shared with the whole country.
+func (d *concentrationRiskDetector) EvidenceScope(in Insight) EvidenceScope {
+	return EvidenceScope{
+		From:   in.GeneratedAt.AddDate(0, 0, -int(DetectorParams(in.Thresholds).Get("lookback_days"))),
+		To:     in.GeneratedAt,
+		Metric: EvidenceMetricTPVShare,
+		Peers:  PeerGroupCountry,
+	}
+}
+
+// splitByCountry groups country-ordered rows.
+func splitByCountry(rows []repository.MethodTPV) [][]repository.MethodTPV {
+	var out [][]repository.MethodTPV
+	start := 0
+	for i := 1; i <= len(rows); i++ {
+		if i == len(rows) || rows[i].CountryCode != rows[start].CountryCode {
+			if i > start {
+				out = append(out, rows[start:i])
+			}
+			start = i
+		}
+	}
+	return out
+}
+
+// concentrationRisk is a method whose loss would take out a large part of a
+// country's TPV.
+type concentrationRisk struct {
+	Method        repository.MethodTPV
+	SharePct      float64
+	CountryHHI    float64
+	TypeHHI       float64
+	TypeSharePct  float64
+	MethodsOfType int
+	CountryTPV    float64
+	Reasons       []string
+}
+
+// concentrationRisks flags methods of one country that exceed
+// max_method_share_pct of TPV, or that are the only active method of a type
+// carrying at least min_type_share_pct. HHIs are on the 0-10000 scale.
+func concentrationRisks(country []repository.MethodTPV, paramsFor func(country, pmType, paymentMethod string) DetectorParams) []concentrationRisk {
+	var total float64
+	typeTPV := map[string]float64{}
+	typeCount := map[string]int{}
+	for _, m := range country {
+		total += m.TpvUSD
+		typeTPV[m.PaymentMethodType] += m.TpvUSD
+		typeCount[m.PaymentMethodType]++
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	countryHHI := 0.0
+	typeHHI := map[string]float64{}
+	for _, m := range country {
+		s := m.TpvUSD / total * 100
+		countryHHI += s * s
+		if t := typeTPV[m.PaymentMethodType]; t > 0 {
+			ts := m.TpvUSD / t * 100
+			typeHHI[m.PaymentMethodType] += ts * ts
+		}
+	}
+
+	var out []concentrationRisk
+	for _, m := range country {
+		params := paramsFor(m.CountryCode, m.PaymentMethodType, m.PaymentMethodCode)
+		if total < params.Get("min_country_tpv_usd") {
+			continue
+		}
+		share := m.TpvUSD / total * 100
+		typeShare := typeTPV[m.PaymentMethodType] / total * 100
+
+		var reasons []string
+		if share > params.Get("max_method_share_pct") {
+			reasons = append(reasons, fmt.Sprintf("above the %.0f%% single-method limit", params.Get("max_method_share_pct")))
+		}
+		if typeCount[m.PaymentMethodType] == 1 && typeShare >= params.Get("min_type_share_pct") {
+			reasons = append(reasons, fmt.Sprintf("no active %s fallback for %.1f%% of TPV", m.PaymentMethodType, typeShare))
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+
+		out = append(out, concentrationRisk{
+			Method:        m,
+			SharePct:      share,
+			CountryHHI:    countryHHI,
+			TypeHHI:       typeHHI[m.PaymentMethodType],
+			TypeSharePct:  typeShare,
+			MethodsOfType: typeCount[m.PaymentMethodType],
+			CountryTPV:    total,
+			Reasons:       reasons,
+		})
+	}
+	return out
+}
+
+// suggestAlternatives picks up to n inactive catalog methods, preferring the
+// same type, then essential methods, then the rest, each by market share.
+func suggestAlternatives(alts []repository.CatalogAlternative, pmType string, n int) []string {
+	var sameType, essential, rest []string
+	for _, a := range alts {
+		switch {
+		case a.PaymentMethodType == pmType:
+			sameType = append(sameType, a.PaymentMethodCode)
+		case a.IsEssential:
+			essential = append(essential, a.PaymentMethodCode)
+		default:
+			rest = append(rest, a.PaymentMethodCode)
+		}
+	}
+	out := append(append(sameType, essential...), rest...)
+	if out == nil {
+		out = []string{}
+	}
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+const (
+	LaunchIncremental   = "incremental"
+	LaunchCannibalizing = "cannibalizing"
+	LaunchMixed         = "mixed"
+)
+
+type cannibalizationDetector struct {
+	repo *repository.InsightRepository
+}
+
+func (d *cannibalizationDetector) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	now := run.Scope.Now
+	week := 7 * 24 * time.Hour
+	minPost := time.Duration(run.Params.Get("min_post_weeks")) * week
+	launches, err := d.repo.GetLaunchesBetween(ctx, run.Scope.Filter,
+		now.AddDate(0, 0, -int(run.Params.Get("max_launch_age_days"))), now.Add(-minPost))
+	if err != nil {
+		return nil, err
+	}
+
+	var insights []Insight
+	for _, l := range launches {
+		params := run.ParamsFor(l.CountryCode, l.PaymentMethodType, l.PaymentMethodCode)
+		preWeeks := int(params.Get("window_weeks"))
+		postWeeks := preWeeks
+		if elapsed := int(now.Sub(l.LaunchedAt) / week); elapsed < postWeeks {
+			postWeeks = elapsed
+		}
+		if postWeeks < int(params.Get("min_post_weeks")) || preWeeks < 2 {
+			continue
+		}
+
+		weekly, err := d.repo.GetWeeklyTPVAround(ctx, l.CountryCode, l.LaunchedAt, preWeeks, postWeeks)
+		if err != nil {
+			return nil, err
+		}
+		overlap, err := d.repo.GetCustomerOverlap(ctx, l.PaymentMethodCode, l.CountryCode,
+			l.LaunchedAt.Add(-time.Duration(preWeeks)*week), l.LaunchedAt, l.LaunchedAt.Add(time.Duration(postWeeks)*week))
+		if err != nil {
+			return nil, err
+		}
+
+		impact, ok := assessLaunch(l.PaymentMethodCode, weekly, preWeeks, postWeeks, overlap, params)
+		if !ok {
+			continue
+		}
+
+		insights = append(insights, Insight{
+			InsightID:         hashID(InsightTypeCannibalization, l.PaymentMethodCode, l.CountryCode),
+			Type:              InsightTypeCannibalization,
+			Severity:          run.SeverityFor(params).Map(impact.Score * 100),
+			PaymentMethodCode: l.PaymentMethodCode,
+			PaymentMethodName: l.PaymentMethodName,
+			CountryCode:       l.CountryCode,
+			TriggeringMetric:  "cannibalization_score",
+			MetricValue:       impact.Score,
+			Threshold:         params.Get("cannibalizing_score"),
+			Description: fmt.Sprintf("%s launch in %s on %s looks %s: it processed $%.0f in %d weeks while other methods came in $%.0f below their pre-launch trend",
+				l.PaymentMethodName, l.CountryCode, l.LaunchedAt.Format("2006-01-02"), impact.Classification,
+				impact.NewMethodTPV, postWeeks, impact.ShortfallTPV),
+			RecommendedAction: launchAction(impact.Classification),
+			SupportingData: map[string]interface{}{
+				"classification":        impact.Classification,
+				"launched_at":           l.LaunchedAt,
+				"pre_weeks":             preWeeks,
+				"post_weeks":            postWeeks,
+				"new_method_tpv_usd":    impact.NewMethodTPV,
+				"others_expected_usd":   impact.ExpectedOthersTPV,
+				"others_actual_usd":     impact.ActualOthersTPV,
+				"trajectory_share":      impact.TrajectoryShare,
+				"customer_overlap":      impact.OverlapShare,
+				"customers_identified":  overlap.Customers,
+				"customers_overlapping": overlap.Overlapping,
+				"overlap_used":          impact.OverlapUsed,
+				"donors":                impact.Donors,
+				"payment_method_type":   l.PaymentMethodType,
+			},
+			EstimatedImpactUSD: perMonth(impact.ShortfallTPV, float64(postWeeks*7)),
+			Thresholds:         params,
+			GeneratedAt:        now,
+		})
+	}
+	return insights, nil
+}
+
+// EvidenceScope covers the weeks around launch against the other methods in
+// the country.
+func (d *cannibalizationDetector) EvidenceScope(in Insight) EvidenceScope {
+	launched, ok := supportingTime(in, "launched_at")
+	if !ok {
+		return defaultEvidenceScope(in)
+	}
+	return EvidenceScope{
+		From:   launched.Add(-time.Duration(supportingNumber(in, "pre_weeks")*hoursPerWeek) * time.Hour),
+		To:     launched.Add(time.Duration(supportingNumber(in, "post_weeks")*hoursPerWeek) * time.Hour),
+		Metric: EvidenceMetricTPV,
+		Peers:  PeerGroupCountry,
+	}
+}
+
+func launchAction(classification string) string {
+	switch classification {
+	case LaunchCannibalizing:
+		return "The launch mostly moved existing volume; weigh its cost against the methods it displaced before investing further."
+	case LaunchIncremental:
+		return "The launch brought new volume; consider promoting it more widely."
+	default:
+		return "The launch both added and moved volume; keep monitoring before deciding on further investment."
+	}
+}
+
+// LaunchDonor is an existing method that fell below its pre-launch trend.
+type LaunchDonor struct {
+	PaymentMethodCode string  `json:"payment_method_code"`
+	PaymentMethodType string  `json:"payment_method_type"`
+	ShortfallUSD      float64 `json:"shortfall_usd"`
+}
+
+// launchImpact compares other methods' post-launch TPV with their projected
+// pre-launch trend.
+type launchImpact struct {
+	NewMethodTPV      float64
+	ExpectedOthersTPV float64
+	ActualOthersTPV   float64
+	ShortfallTPV      float64
+	TrajectoryShare   float64
+	OverlapShare      float64
+	OverlapUsed       bool
+	Score             float64
+	Classification    string
+	Donors            []LaunchDonor
+}
+
+// assessLaunch projects each other method's pre-launch weekly TPV linearly
+// into the post-launch weeks. The share of the new method's TPV explained by
+// the others' shortfall is the trajectory signal; the share of identified
+// adopters who paid with other methods before launch is the overlap signal.
+// With enough identified customers the score averages both, otherwise it is
+// the trajectory share alone.
+func assessLaunch(launched string, weekly []repository.WeeklyMethodTPV, preWeeks, postWeeks int, overlap repository.CustomerOverlap, params DetectorParams) (launchImpact, bool) {
+	var impact launchImpact
+	series := map[string][]float64{}
+	types := map[string]string{}
+	for _, w := range weekly {
+		idx := w.Week + preWeeks
+		if idx < 0 || idx >= preWeeks+postWeeks {
+			continue
+		}
+		if w.PaymentMethodCode == launched {
+			if w.Week >= 0 {
+				impact.NewMethodTPV += w.TpvUSD
+			}
+			continue
+		}
+		if series[w.PaymentMethodCode] == nil {
+			series[w.PaymentMethodCode] = make([]float64, preWeeks+postWeeks)
+		}
+		series[w.PaymentMethodCode][idx] += w.TpvUSD
+		types[w.PaymentMethodCode] = w.PaymentMethodType
+	}
+	if impact.NewMethodTPV < params.Get("min_post_tpv_usd") {
+		return impact, false
+	}
+
+	for code, s := range series {
+		projected := projectLinear(s[:preWeeks], postWeeks)
+		var expected, actual float64
+		for i := 0; i < postWeeks; i++ {
+			expected += projected[i]
+			actual += s[preWeeks+i]
+		}
+		impact.ExpectedOthersTPV += expected
+		impact.ActualOthersTPV += actual
+		if expected > actual {
+			impact.Donors = append(impact.Donors, LaunchDonor{
+				PaymentMethodCode: code,
+				PaymentMethodType: types[code],
+				ShortfallUSD:      expected - actual,
+			})
+		}
+	}
+	sort.Slice(impact.Donors, func(i, j int) bool {
+		return impact.Donors[i].ShortfallUSD > impact.Donors[j].ShortfallUSD
+	})
+	if len(impact.Donors) > 3 {
+		impact.Donors = impact.Donors[:3]
+	}
+	if impact.Donors == nil {
+		impact.Donors = []LaunchDonor{}
+	}
+
+	impact.ShortfallTPV = math.Max(0, impact.ExpectedOthersTPV-impact.ActualOthersTPV)
+	impact.TrajectoryShare = math.Min(1, impact.ShortfallTPV/impact.NewMethodTPV)
+	impact.Score = impact.TrajectoryShare
+	if overlap.Customers > 0 {
+		impact.OverlapShare = float64(overlap.Overlapping) / float64(overlap.Customers)
+	}
+	if float64(overlap.Customers) >= params.Get("min_overlap_customers") {
+		impact.OverlapUsed = true
+		impact.Score = (impact.TrajectoryShare + impact.OverlapShare) / 2
+	}
+
+	switch {
+	case impact.Score >= params.Get("cannibalizing_score"):
+		impact.Classification = LaunchCannibalizing
+	case impact.Score <= params.Get("incremental_score"):
+		impact.Classification = LaunchIncremental
+	default:
+		impact.Classification = LaunchMixed
+	}
+	return impact, true
+}
+
+// projectLinear fits a least-squares line to pre and extends it n points,
+// never below zero.
+func projectLinear(pre []float64, n int) []float64 {
+	k := float64(len(pre))
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range pre {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	slope := 0.0
+	if den := k*sumXX - sumX*sumX; den != 0 {
+		slope = (k*sumXY - sumX*sumY) / den
+	}
+	intercept := (sumY - slope*sumX) / k
+
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.Max(0, intercept+slope*float64(len(pre)+i))
+	}
+	return out
+}
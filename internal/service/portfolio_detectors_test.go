@@ -0,0 +1,131 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func concentrationParams(country, pmType, paymentMethod string) DetectorParams {
+	return DetectorParams{"max_method_share_pct": 60, "min_type_share_pct": 20, "min_country_tpv_usd": 1000}
+}
+
+func TestConcentrationRisks(t *testing.T) {
+	br := []repository.MethodTPV{
+		{PaymentMethodCode: "PIX", PaymentMethodType: "BANK_TRANSFER", CountryCode: "BR", TpvUSD: 70000},
+		{PaymentMethodCode: "VISA", PaymentMethodType: "CARD", CountryCode: "BR", TpvUSD: 20000},
+		{PaymentMethodCode: "MASTERCARD", PaymentMethodType: "CARD", CountryCode: "BR", TpvUSD: 8000},
+		{PaymentMethodCode: "MERCADOPAGO", PaymentMethodType: "WALLET", CountryCode: "BR", TpvUSD: 2000},
+	}
+
+	risks := concentrationRisks(br, concentrationParams)
+	require.Len(t, risks, 1)
+	r := risks[0]
+	assert.Equal(t, "PIX", r.Method.PaymentMethodCode)
+	assert.InDelta(t, 70, r.SharePct, 1e-9)
+	assert.InDelta(t, 70*70+20*20+8*8+2*2, r.CountryHHI, 1e-6)
+	assert.InDelta(t, 10000, r.TypeHHI, 1e-6)
+	assert.Len(t, r.Reasons, 2, "dominant and no bank-transfer fallback")
+
+	// A single card method carrying a large share needs a fallback even
+	// below the single-method limit.
+	mx := []repository.MethodTPV{
+		{PaymentMethodCode: "SPEI", PaymentMethodType: "BANK_TRANSFER", CountryCode: "MX", TpvUSD: 5000},
+		{PaymentMethodCode: "OXXO", PaymentMethodType: "CASH", CountryCode: "MX", TpvUSD: 500},
+		{PaymentMethodCode: "CODI", PaymentMethodType: "BANK_TRANSFER", CountryCode: "MX", TpvUSD: 1000},
+		{PaymentMethodCode: "VISA", PaymentMethodType: "CARD", CountryCode: "MX", TpvUSD: 3500},
+	}
+	risks = concentrationRisks(mx, concentrationParams)
+	require.Len(t, risks, 1)
+	assert.Equal(t, "VISA", risks[0].Method.PaymentMethodCode)
+	assert.Equal(t, []string{"no active CARD fallback for 35.0% of TPV"}, risks[0].Reasons)
+
+	tiny := []repository.MethodTPV{{PaymentMethodCode: "YAPE", PaymentMethodType: "WALLET", CountryCode: "PE", TpvUSD: 500}}
+	assert.Empty(t, concentrationRisks(tiny, concentrationParams))
+}
+
+func TestSuggestAlternatives(t *testing.T) {
+	alts := []repository.CatalogAlternative{
+		{PaymentMethodCode: "BOLETO", PaymentMethodType: "CASH", MarketSharePct: 15},
+		{PaymentMethodCode: "ELO", PaymentMethodType: "CARD", MarketSharePct: 12, IsEssential: true},
+		{PaymentMethodCode: "TED", PaymentMethodType: "BANK_TRANSFER", MarketSharePct: 5},
+		{PaymentMethodCode: "PICPAY", MarketSharePct: 3},
+	}
+	assert.Equal(t, []string{"TED", "ELO", "BOLETO"}, suggestAlternatives(alts, "BANK_TRANSFER", 3))
+	assert.Equal(t, []string{"ELO"}, suggestAlternatives(alts, "CARD", 1))
+	assert.Equal(t, []string{}, suggestAlternatives(nil, "CARD", 3))
+}
+
+func launchParams() DetectorParams {
+	return DetectorParams{
+		"min_post_tpv_usd":      1000,
+		"min_overlap_customers": 20,
+		"cannibalizing_score":   0.6,
+		"incremental_score":     0.3,
+	}
+}
+
+// weeklyFlat emits one row per week from -pre to post-1 with tpv(week).
+func weeklyFlat(code, pmType string, pre, post int, tpv func(week int) float64) []repository.WeeklyMethodTPV {
+	var out []repository.WeeklyMethodTPV
+	for w := -pre; w < post; w++ {
+		out = append(out, repository.WeeklyMethodTPV{PaymentMethodCode: code, PaymentMethodType: pmType, Week: w, TpvUSD: tpv(w)})
+	}
+	return out
+}
+
+func TestProjectLinear(t *testing.T) {
+	assert.Equal(t, []float64{50, 60}, projectLinear([]float64{10, 20, 30, 40}, 2))
+	assert.Equal(t, []float64{7, 7}, projectLinear([]float64{7, 7, 7}, 2))
+	assert.Equal(t, []float64{0, 0}, projectLinear([]float64{30, 20, 10}, 2), "never projects below zero")
+}
+
+func TestAssessLaunch(t *testing.T) {
+	newWallet := weeklyFlat("WALLET_X", "WALLET", 0, 4, func(int) float64 { return 1000 })
+
+	// Cards drop by exactly the wallet's volume: cannibalizing.
+	weekly := append(weeklyFlat("VISA", "CARD", 4, 4, func(w int) float64 {
+		if w >= 0 {
+			return 4000
+		}
+		return 5000
+	}), newWallet...)
+	impact, ok := assessLaunch("WALLET_X", weekly, 4, 4, repository.CustomerOverlap{}, launchParams())
+	require.True(t, ok)
+	assert.InDelta(t, 4000, impact.NewMethodTPV, 1e-9)
+	assert.InDelta(t, 4000, impact.ShortfallTPV, 1e-6)
+	assert.InDelta(t, 1, impact.TrajectoryShare, 1e-9)
+	assert.Equal(t, LaunchCannibalizing, impact.Classification)
+	require.Len(t, impact.Donors, 1)
+	assert.Equal(t, "VISA", impact.Donors[0].PaymentMethodCode)
+
+	// Cards keep growing on trend: incremental.
+	weekly = append(weeklyFlat("VISA", "CARD", 4, 4, func(w int) float64 { return 5000 + 100*float64(w) }), newWallet...)
+	impact, ok = assessLaunch("WALLET_X", weekly, 4, 4, repository.CustomerOverlap{}, launchParams())
+	require.True(t, ok)
+	assert.InDelta(t, 0, impact.ShortfallTPV, 1e-6)
+	assert.Equal(t, LaunchIncremental, impact.Classification)
+	assert.Empty(t, impact.Donors)
+
+	// Enough identified adopters: a trend-neutral launch whose adopters all
+	// came from other methods is mixed.
+	impact, ok = assessLaunch("WALLET_X", weekly, 4, 4, repository.CustomerOverlap{Customers: 50, Overlapping: 50}, launchParams())
+	require.True(t, ok)
+	assert.True(t, impact.OverlapUsed)
+	assert.InDelta(t, 0.5, impact.Score, 1e-9)
+	assert.Equal(t, LaunchMixed, impact.Classification)
+
+	// Too few adopters to trust overlap.
+	impact, _ = assessLaunch("WALLET_X", weekly, 4, 4, repository.CustomerOverlap{Customers: 5, Overlapping: 5}, launchParams())
+	assert.False(t, impact.OverlapUsed)
+	assert.Equal(t, LaunchIncremental, impact.Classification)
+
+	// Negligible post-launch volume is not classified.
+	tiny := append(weeklyFlat("VISA", "CARD", 4, 4, func(int) float64 { return 5000 }),
+		weeklyFlat("WALLET_X", "WALLET", 0, 4, func(int) float64 { return 10 })...)
+	_, ok = assessLaunch("WALLET_X", tiny, 4, 4, repository.CustomerOverlap{}, launchParams())
+	assert.False(t, ok)
+}
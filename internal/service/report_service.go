@@ -3,51 +3,111 @@ package service
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"html/template"
 	"strings"
 	"time"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 )
 
 type ReportService struct {
 	metricsSvc *MetricsService
 	insightSvc *InsightService
+	eventSvc   *EventService
 }
 
-func NewReportService(metricsSvc *MetricsService, insightSvc *InsightService) *ReportService {
-	return &ReportService{metricsSvc: metricsSvc, insightSvc: insightSvc}
+// NewReportService builds reports; with a nil eventSvc they carry no events
+// timeline.
+func NewReportService(metricsSvc *MetricsService, insightSvc *InsightService, eventSvc *EventService) *ReportService {
+	return &ReportService{metricsSvc: metricsSvc, insightSvc: insightSvc, eventSvc: eventSvc}
 }
 
+// reportEventDays is the events window when the filter has no date_from.
+const reportEventDays = 90
+
 type ReportData struct {
 	GeneratedAt string
+	Currency    string
 	Summary     MetricsSummary
 	Metrics     []MetricResult
 	Insights    []Insight
+	// Events are the timeline events in the report window; InsightEvents
+	// holds, per insight id, those in the insight's method-country scope.
+	Events        []model.Event
+	InsightEvents map[string][]model.Event
 }
 
-func (s *ReportService) GenerateReport(ctx context.Context, country, dateFrom, dateTo string) (*ReportData, error) {
-	metrics, summary, _, err := s.metricsSvc.GetMetrics(ctx, country, "", dateFrom, dateTo, "tpv_usd", "desc", 100, 0)
+// GenerateReport builds the report data. conv selects the reporting currency
+// (nil means USD); local reports the selected country in its own currency.
+// Insights keep detector order unless byImpact is set.
+func (s *ReportService) GenerateReport(ctx context.Context, f model.AnalyticsFilter, conv *FxConverter, local, byImpact bool) (*ReportData, error) {
+	metrics, summary, _, err := s.metricsSvc.GetMetrics(ctx, f, "tpv_usd", "desc", 100, 0, true)
 	if err != nil {
 		return nil, err
 	}
+	ConvertMetrics(metrics, &summary, conv, local, f.DateTo)
 
-	insights, err := s.insightSvc.DetectInsights(ctx, country, "", "")
+	insights, err := s.insightSvc.DetectInsights(ctx, f, "", "")
 	if err != nil {
 		return nil, err
 	}
+	if byImpact {
+		SortByImpact(insights)
+	}
 
-	return &ReportData{
+	data := &ReportData{
 		GeneratedAt: time.Now().Format("2006-01-02 15:04:05 MST"),
+		Currency:    conv.Code(),
 		Summary:     summary,
 		Metrics:     metrics,
 		Insights:    insights,
-	}, nil
+	}
+	if s.eventSvc != nil {
+		to := windowEnd(f.DateTo)
+		from := to.AddDate(0, 0, -reportEventDays)
+		if f.DateFrom != "" {
+			from = windowStart(f.DateFrom)
+		}
+		events, providers, err := s.eventSvc.scopedEvents(ctx, f, nil, from, to)
+		if err != nil {
+			return nil, err
+		}
+		data.Events = events
+		data.InsightEvents = insightEvents(insights, events, providers)
+	}
+	return data, nil
+}
+
+// windowStart parses an RFC3339 or YYYY-MM-DD date_from.
+func windowStart(dateFrom string) time.Time {
+	if t, err := time.Parse(time.RFC3339, dateFrom); err == nil {
+		return t
+	}
+	t, _ := time.Parse("2006-01-02", dateFrom)
+	return t
+}
+
+// insightEvents groups the events that apply to each insight's method and
+// country by insight id.
+func insightEvents(insights []Insight, events []model.Event, providers map[string]string) map[string][]model.Event {
+	out := make(map[string][]model.Event)
+	for _, in := range insights {
+		for _, e := range events {
+			if e.Matches(in.CountryCode, in.PaymentMethodCode, providers[in.PaymentMethodCode]) {
+				out[in.InsightID] = append(out[in.InsightID], e)
+			}
+		}
+	}
+	return out
 }
 
 var ReportTemplate string // Set from main via embed
 
 func (s *ReportService) RenderHTML(data *ReportData) (string, error) {
 	funcMap := template.FuncMap{
-		"toLower": strings.ToLower,
+		"toLower":   strings.ToLower,
+		"sparkline": sparkline,
 	}
 
 	tmpl, err := template.New("report").Funcs(funcMap).Parse(ReportTemplate)
@@ -62,3 +122,33 @@ func (s *ReportService) RenderHTML(data *ReportData) (string, error) {
 
 	return buf.String(), nil
 }
+
+// sparkline draws trend points from an insight's supporting data as an inline
+// SVG polyline. Anything else renders nothing.
+func sparkline(v interface{}) template.HTML {
+	points, ok := v.([]TrendPoint)
+	if !ok || len(points) < 2 {
+		return ""
+	}
+	const width, height, pad = 160.0, 40.0, 2.0
+
+	lo, hi := points[0].Value, points[0].Value
+	for _, p := range points {
+		lo = min(lo, p.Value)
+		hi = max(hi, p.Value)
+	}
+	span := hi - lo
+	if span == 0 {
+		span = 1
+	}
+
+	coords := make([]string, len(points))
+	for i, p := range points {
+		x := pad + float64(i)/float64(len(points)-1)*(width-2*pad)
+		y := height - pad - (p.Value-lo)/span*(height-2*pad)
+		coords[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	return template.HTML(fmt.Sprintf(
+		`<svg class="sparkline" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f"><polyline fill="none" stroke="currentColor" stroke-width="2" points="%s"/></svg>`,
+		width, height, width, height, strings.Join(coords, " ")))
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+)
+
+func TestRenderHTMLDrawsTrendPoints(t *testing.T) {
+	tmpl, err := os.ReadFile("../templates/report.html")
+	require.NoError(t, err)
+	prev := ReportTemplate
+	ReportTemplate = string(tmpl)
+	t.Cleanup(func() { ReportTemplate = prev })
+
+	points := []TrendPoint{{Period: "2025-12-01", Value: 300}, {Period: "2026-01-01", Value: 200}, {Period: "2026-02-01", Value: 100}}
+	html, err := (&ReportService{}).RenderHTML(&ReportData{
+		Insights: []Insight{
+			{Type: InsightTypeSustainedDecline, Severity: SeverityMedium,
+				SupportingData: map[string]interface{}{"points": points}},
+			{Type: InsightTypeZombie, Severity: SeverityLow},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(html, `<svg class="sparkline"`))
+	assert.Contains(t, html, `points="2.0,2.0 80.0,20.0 158.0,38.0"`)
+}
+
+func TestRenderHTMLShowsImpact(t *testing.T) {
+	tmpl, err := os.ReadFile("../templates/report.html")
+	require.NoError(t, err)
+	prev := ReportTemplate
+	ReportTemplate = string(tmpl)
+	t.Cleanup(func() { ReportTemplate = prev })
+
+	html, err := (&ReportService{}).RenderHTML(&ReportData{
+		Insights: []Insight{
+			{Type: InsightTypeZombie, Severity: SeverityLow, EstimatedImpactUSD: 1234.4},
+			{Type: InsightTypeApprovalAnomaly, Severity: SeverityHigh},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(html, `class="insight-impact"`))
+	assert.Contains(t, html, "~$1234 USD impact")
+}
+
+func TestRenderHTMLShowsEvents(t *testing.T) {
+	tmpl, err := os.ReadFile("../templates/report.html")
+	require.NoError(t, err)
+	prev := ReportTemplate
+	ReportTemplate = string(tmpl)
+	t.Cleanup(func() { ReportTemplate = prev })
+
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	campaign := model.Event{Kind: model.EventKindCampaign, Title: "Hot Sale", CountryCode: "MX",
+		StartsAt: start, EndsAt: start.Add(72 * time.Hour)}
+	insights := []Insight{
+		{InsightID: "mx", Type: InsightTypeZombie, Severity: SeverityLow, PaymentMethodCode: "OXXO", CountryCode: "MX"},
+		{InsightID: "br", Type: InsightTypeZombie, Severity: SeverityLow, PaymentMethodCode: "PIX", CountryCode: "BR"},
+	}
+	events := []model.Event{campaign, {Kind: model.EventKindLaunch, Title: "Global launch", StartsAt: start, EndsAt: start.Add(time.Hour)}}
+
+	html, err := (&ReportService{}).RenderHTML(&ReportData{
+		Insights:      insights,
+		Events:        events,
+		InsightEvents: insightEvents(insights, events, nil),
+	})
+	require.NoError(t, err)
+	assert.Contains(t, html, `<h2 class="section-title">Events</h2>`)
+	assert.Contains(t, html, "<td>All</td>")
+	assert.Equal(t, 2, strings.Count(html, `class="insight-events"`))
+	assert.Contains(t, html, "CAMPAIGN Hot Sale (2026-03-02 – 2026-03-05); LAUNCH Global launch")
+	assert.Equal(t, 1, strings.Count(html, "CAMPAIGN Hot Sale ("), "only the MX insight is annotated with the campaign")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"math"
 
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
 )
 
@@ -28,8 +29,8 @@ type ROIResult struct {
 	Recommendation       string   `json:"recommendation"`
 }
 
-func (s *ROIService) GetROI(ctx context.Context, country, dateFrom, dateTo string) ([]ROIResult, error) {
-	rows, err := s.repo.GetROIData(ctx, country, dateFrom, dateTo)
+func (s *ROIService) GetROI(ctx context.Context, f model.AnalyticsFilter) ([]ROIResult, error) {
+	rows, err := s.repo.GetROIData(ctx, f)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Admin-written templates, custom rule descriptions and notification
+// subjects and bodies, all follow one policy: text, fields
+// ({{.approval_rate}}), printf of fields with a constant format, and if or
+// range over a single field with bodies under the same rules. Variables,
+// other functions and nested templates that could run away at render time
+// are rejected.
+
+// maxTemplateLen caps the source of an admin-written template.
+const maxTemplateLen = 1000
+
+// printfVerb matches the verbs templates may pass to printf: no width, a
+// single-digit precision at most.
+var printfVerb = regexp.MustCompile(`%(\.[0-9])?[dfgsv%]`)
+
+const templatePolicy = "only fields, printf, and if or range over a field are allowed"
+
+// parseRestrictedTemplate parses src and checks it against the template
+// policy. Unknown map keys fail at render time.
+func parseRestrictedTemplate(name, src string) (*template.Template, error) {
+	if len(src) > maxTemplateLen {
+		return nil, fmt.Errorf("must be at most %d characters", maxTemplateLen)
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTemplateList(tmpl.Tree.Root); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func checkTemplateList(list *parse.ListNode) error {
+	if list == nil {
+		return nil
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+		case *parse.ActionNode:
+			if len(n.Pipe.Decl) > 0 || len(n.Pipe.Cmds) != 1 {
+				return fmt.Errorf("%s: %s", n, templatePolicy)
+			}
+			if err := checkTemplateCommand(n.Pipe.Cmds[0]); err != nil {
+				return fmt.Errorf("%s: %v", n, err)
+			}
+		case *parse.IfNode:
+			if err := checkTemplateBranch(&n.BranchNode); err != nil {
+				return err
+			}
+		case *parse.RangeNode:
+			if err := checkTemplateBranch(&n.BranchNode); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: %s", node, templatePolicy)
+		}
+	}
+	return nil
+}
+
+// checkTemplateBranch allows an if or range whose pipeline is one field.
+func checkTemplateBranch(b *parse.BranchNode) error {
+	if len(b.Pipe.Decl) > 0 || len(b.Pipe.Cmds) != 1 || len(b.Pipe.Cmds[0].Args) != 1 || !isTemplateField(b.Pipe.Cmds[0].Args[0]) {
+		return fmt.Errorf("%s: %s", b, templatePolicy)
+	}
+	if err := checkTemplateList(b.List); err != nil {
+		return err
+	}
+	return checkTemplateList(b.ElseList)
+}
+
+func checkTemplateCommand(cmd *parse.CommandNode) error {
+	args := cmd.Args
+	if id, ok := args[0].(*parse.IdentifierNode); ok && id.Ident == "printf" {
+		if len(args) < 2 {
+			return errors.New("printf needs a format")
+		}
+		format, ok := args[1].(*parse.StringNode)
+		if !ok || strings.Contains(printfVerb.ReplaceAllString(format.Text, ""), "%") {
+			return errors.New(`printf format must be a constant using verbs like %s, %d or %.1f`)
+		}
+		args = args[2:]
+	} else if len(args) != 1 {
+		return errors.New(templatePolicy)
+	}
+	for _, arg := range args {
+		if !isTemplateField(arg) {
+			return errors.New(templatePolicy)
+		}
+	}
+	return nil
+}
+
+func isTemplateField(node parse.Node) bool {
+	f, ok := node.(*parse.FieldNode)
+	return ok && len(f.Ident) == 1
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+// trendDetector raises insights for method-countries whose monthly approved
+// TPV follows a steady linear trend in one direction: sign -1 for declines,
+// +1 for growth.
+type trendDetector struct {
+	trends      *TrendService
+	insightType string
+	sign        float64
+}
+
+func (d *trendDetector) Detect(ctx context.Context, run DetectorRun) ([]Insight, error) {
+	now := run.Scope.Now
+	// Months are fetched once, so their number comes from the global
+	// parameters; scoped rules can still tune the thresholds.
+	periods := int(run.Params.Get("periods"))
+	f := run.Scope.Filter
+	f.Expr = ""
+	buckets, err := d.trends.repo.GetTrends(ctx, f, "MOM", periods, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var insights []Insight
+	for _, series := range monthlySeries(buckets, now, periods) {
+		first := series[0]
+		params := run.ParamsFor(first.CountryCode, first.PaymentMethodType, first.PaymentMethodCode)
+		t, ok := trendSignal(series, params, d.sign)
+		if !ok {
+			continue
+		}
+		last := t.Summary.Points[len(t.Summary.Points)-1]
+
+		var description, action string
+		if d.sign < 0 {
+			description = fmt.Sprintf("%s in %s has lost %.1f%% of its average monthly approved TPV each month for %d months (R²=%.2f), from $%.2f to $%.2f",
+				first.PaymentMethodName, first.CountryCode, -t.RelativeSlopePct, len(series), t.Summary.RSquared,
+				t.Summary.Points[0].Value, last.Value)
+			action = "Compare with peers in the country to tell a market shift from a method problem, then review provider performance, pricing and checkout placement."
+		} else {
+			description = fmt.Sprintf("%s in %s has grown %.1f%% of its average monthly approved TPV each month for %d months (R²=%.2f), from $%.2f to $%.2f",
+				first.PaymentMethodName, first.CountryCode, t.RelativeSlopePct, len(series), t.Summary.RSquared,
+				t.Summary.Points[0].Value, last.Value)
+			action = "Confirm provider capacity and limits ahead of demand, and consider featuring the method at checkout."
+		}
+
+		insights = append(insights, Insight{
+			InsightID:         hashID(d.insightType, first.PaymentMethodCode, first.CountryCode),
+			Type:              d.insightType,
+			Severity:          run.SeverityFor(params).Map(math.Abs(t.RelativeSlopePct)),
+			PaymentMethodCode: first.PaymentMethodCode,
+			PaymentMethodName: first.PaymentMethodName,
+			CountryCode:       first.CountryCode,
+			TriggeringMetric:  "tpv_relative_slope_pct",
+			MetricValue:       t.RelativeSlopePct,
+			Threshold:         d.sign * params.Get("min_relative_slope_pct"),
+			Description:       description,
+			RecommendedAction: action,
+			SupportingData: map[string]interface{}{
+				"metric":              "tpv_usd",
+				"period":              "MOM",
+				"points":              t.Summary.Points,
+				"slope":               t.Summary.Slope,
+				"r_squared":           t.Summary.RSquared,
+				"relative_slope_pct":  t.RelativeSlopePct,
+				"transaction_count":   t.TransactionCount,
+				"payment_method_type": first.PaymentMethodType,
+			},
+			EstimatedImpactUSD: trendImpact(t.Summary.Slope, len(t.Summary.Points)),
+			Thresholds:         params,
+			GeneratedAt:        now,
+		})
+	}
+	return insights, nil
+}
+
+// EvidenceScope covers the complete months that were fitted.
+func (d *trendDetector) EvidenceScope(in Insight) EvidenceScope {
+	at := in.GeneratedAt.UTC()
+	current := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return EvidenceScope{
+		From:   current.AddDate(0, -int(DetectorParams(in.Thresholds).Get("periods")), 0),
+		To:     current,
+		Metric: EvidenceMetricTPV,
+		Peers:  PeerGroupNone,
+	}
+}
+
+type trendResult struct {
+	Summary          TrendSummary
+	RelativeSlopePct float64
+	TransactionCount int
+}
+
+// trendSignal fits the series and reports whether it trends in the sign's
+// direction with at least min_r_squared, min_relative_slope_pct (slope as a
+// share of the series mean) and min_transactions.
+func trendSignal(series []repository.TrendBucket, params DetectorParams, sign float64) (trendResult, bool) {
+	first := series[0]
+	r := trendResult{
+		Summary: summarizeTrend(first.PaymentMethodCode, first.PaymentMethodName, first.CountryCode, "tpv_usd", series, nil),
+	}
+	var sum float64
+	for _, b := range series {
+		r.TransactionCount += b.TransactionCount
+		sum += b.TpvUSD
+	}
+	mean := sum / float64(len(series))
+	if len(series) < 3 || mean <= 0 || float64(r.TransactionCount) < params.Get("min_transactions") {
+		return r, false
+	}
+
+	slope, _ := linearRegression(extractMetricValues(series, "tpv_usd"))
+	r.RelativeSlopePct = slope / mean * 100
+	ok := r.Summary.RSquared >= params.Get("min_r_squared") &&
+		sign*r.RelativeSlopePct >= params.Get("min_relative_slope_pct")
+	return r, ok
+}
+
+// monthlySeries groups trend buckets per method-country over the periods
+// complete months before now. Months without transactions are filled with
+// zeros, except before a method-country's first transaction in the window,
+// so a launch does not read as growth from zero. The current, partial month
+// is left out.
+func monthlySeries(buckets []repository.TrendBucket, now time.Time, periods int) [][]repository.TrendBucket {
+	if periods < 1 {
+		return nil
+	}
+	now = now.UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	first := current.AddDate(0, -periods, 0)
+
+	type key struct{ pm, cc string }
+	index := make(map[key]int)
+	var out [][]repository.TrendBucket
+	for _, b := range buckets {
+		start := periodStart(b.Period)
+		if start.Before(first) || !start.Before(current) {
+			continue
+		}
+		k := key{b.PaymentMethodCode, b.CountryCode}
+		i, ok := index[k]
+		if !ok {
+			i = len(out)
+			index[k] = i
+			series := make([]repository.TrendBucket, periods)
+			for m := range series {
+				series[m] = repository.TrendBucket{
+					Period:            first.AddDate(0, m, 0).Format("2006-01-02"),
+					PaymentMethodCode: b.PaymentMethodCode,
+					PaymentMethodName: b.PaymentMethodName,
+					PaymentMethodType: b.PaymentMethodType,
+					CountryCode:       b.CountryCode,
+				}
+			}
+			out = append(out, series)
+		}
+		m := (start.Year()-first.Year())*12 + int(start.Month()-first.Month())
+		b.Period = out[i][m].Period
+		out[i][m] = b
+	}
+	for i, series := range out {
+		for len(series) > 0 && series[0].TransactionCount == 0 {
+			series = series[1:]
+		}
+		out[i] = series
+	}
+	return out
+}
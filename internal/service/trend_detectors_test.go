@@ -0,0 +1,101 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func trendParamsForTest() DetectorParams {
+	return DetectorParams{"periods": 6, "min_r_squared": 0.7, "min_relative_slope_pct": 10, "min_transactions": 30}
+}
+
+// monthlyTPV builds one bucket of 20 transactions per value, starting in
+// September 2025.
+func monthlyTPV(values ...float64) []repository.TrendBucket {
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	var out []repository.TrendBucket
+	for i, v := range values {
+		out = append(out, repository.TrendBucket{
+			Period:            start.AddDate(0, i, 0).Format("2006-01-02 15:04:05+00"),
+			PaymentMethodCode: "BOLETO",
+			PaymentMethodName: "Boleto Bancário",
+			PaymentMethodType: "CASH",
+			CountryCode:       "BR",
+			TransactionCount:  20,
+			TpvUSD:            v,
+		})
+	}
+	return out
+}
+
+func TestTrendSignal(t *testing.T) {
+	params := trendParamsForTest()
+
+	r, ok := trendSignal(monthlyTPV(1000, 900, 800, 700, 600, 500), params, -1)
+	require.True(t, ok, "steady decline")
+	assert.InDelta(t, -100.0/750*100, r.RelativeSlopePct, 1e-9)
+	assert.Equal(t, 1.0, r.Summary.RSquared)
+	assert.Len(t, r.Summary.Points, 6)
+	assert.Equal(t, 120, r.TransactionCount)
+
+	_, ok = trendSignal(monthlyTPV(1000, 900, 800, 700, 600, 500), params, 1)
+	assert.False(t, ok, "a decline is not growth")
+
+	_, ok = trendSignal(monthlyTPV(500, 600, 700, 800, 900, 1000), params, 1)
+	assert.True(t, ok, "steady growth")
+
+	_, ok = trendSignal(monthlyTPV(1000, 400, 1100, 300, 900, 500), params, -1)
+	assert.False(t, ok, "noisy series fail min_r_squared")
+
+	_, ok = trendSignal(monthlyTPV(1000, 990, 980, 970, 960, 950), params, -1)
+	assert.False(t, ok, "a 1% monthly decline is below min_relative_slope_pct")
+
+	thin := monthlyTPV(1000, 900, 800, 700, 600, 500)
+	for i := range thin {
+		thin[i].TransactionCount = 2
+	}
+	_, ok = trendSignal(thin, params, -1)
+	assert.False(t, ok, "too few transactions")
+}
+
+func TestMonthlySeries(t *testing.T) {
+	now := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	// September to March; March is the current month and October is missing.
+	buckets := monthlyTPV(100, 200, 300, 400, 500, 600, 700)
+	buckets = append(buckets[:1], buckets[2:]...)
+
+	series := monthlySeries(buckets, now, 6)
+	require.Len(t, series, 1)
+	s := series[0]
+	require.Len(t, s, 6)
+	assert.Equal(t, "2025-09-01", s[0].Period)
+	assert.Equal(t, "2026-02-01", s[5].Period)
+	assert.Equal(t, 0.0, s[1].TpvUSD, "missing months are zero")
+	assert.Equal(t, "CASH", s[1].PaymentMethodType)
+	assert.Equal(t, 600.0, s[5].TpvUSD, "the partial current month is dropped")
+
+	assert.Empty(t, monthlySeries(buckets, now, 0))
+}
+
+func TestMonthlySeriesTrimsMonthsBeforeLaunch(t *testing.T) {
+	now := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	// First transactions in November; January is missing.
+	buckets := monthlyTPV(0, 0, 300, 400, 500, 600)[2:]
+	buckets = append(buckets[:2], buckets[3:]...)
+
+	series := monthlySeries(buckets, now, 6)
+	require.Len(t, series, 1)
+	s := series[0]
+	require.Len(t, s, 4)
+	assert.Equal(t, "2025-11-01", s[0].Period)
+	assert.Equal(t, 0.0, s[2].TpvUSD, "gaps after launch stay zero")
+
+	// Zero-filled pre-launch months would have fitted a steep climb.
+	_, ok := trendSignal(monthlySeries(monthlyTPV(0, 0, 0, 0, 500, 600)[4:], now, 6)[0], trendParamsForTest(), 1)
+	assert.False(t, ok, "two months since launch are too few to fit")
+}
@@ -3,7 +3,9 @@ package service
 import (
 	"context"
 	"math"
+	"time"
 
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
 	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
 )
 
@@ -33,14 +35,22 @@ type TrendSummary struct {
 	OverallTrend      string       `json:"overall_trend"`
 	Slope             float64      `json:"slope"`
 	RSquared          float64      `json:"r_squared"`
+	// Annotations are the timeline events over the series; see event_service.go.
+	Annotations []TrendAnnotation `json:"annotations,omitempty"`
 }
 
-func (s *TrendService) GetTrends(ctx context.Context, country, paymentMethod, period, metric string, periodsBack int) ([]TrendSummary, error) {
+func (s *TrendService) GetTrends(ctx context.Context, f model.AnalyticsFilter, period, metric string, periodsBack int) ([]TrendSummary, error) {
+	return s.GetTrendsInCurrency(ctx, f, period, metric, periodsBack, nil)
+}
+
+// GetTrendsInCurrency converts monetary metrics bucket by bucket, each at the
+// rate effective at the start of its period, before computing changes.
+func (s *TrendService) GetTrendsInCurrency(ctx context.Context, f model.AnalyticsFilter, period, metric string, periodsBack int, conv *FxConverter) ([]TrendSummary, error) {
 	if periodsBack < 1 {
 		periodsBack = 6
 	}
 
-	buckets, err := s.repo.GetTrends(ctx, country, paymentMethod, period, periodsBack)
+	buckets, err := s.repo.GetTrends(ctx, f, period, periodsBack, time.Now())
 	if err != nil {
 		return nil, err
 	}
@@ -57,56 +67,77 @@ func (s *TrendService) GetTrends(ctx context.Context, country, paymentMethod, pe
 
 	var results []TrendSummary
 	for k, points := range grouped {
-		values := extractMetricValues(points, metric)
+		results = append(results, summarizeTrend(k.pm, nameMap[k], k.cc, metric, points, conv))
+	}
+
+	return results, nil
+}
+
+// summarizeTrend computes period-over-period changes and the linear trend of
+// one (payment_method, country) series ordered by period.
+func summarizeTrend(pm, name, cc, metric string, points []repository.TrendBucket, conv *FxConverter) TrendSummary {
+	values := extractMetricValues(points, metric)
+	if conv != nil && (metric == "tpv_usd" || metric == "avg_transaction_value") {
+		for i := range values {
+			values[i] = conv.FromUSD(values[i], periodStart(points[i].Period))
+		}
+	}
 
-		trendPoints := make([]TrendPoint, len(values))
-		for i, v := range values {
-			tp := TrendPoint{
-				Period: points[i].Period,
-				Value:  v,
+	trendPoints := make([]TrendPoint, len(values))
+	for i, v := range values {
+		tp := TrendPoint{
+			Period: points[i].Period,
+			Value:  v,
+		}
+		if i > 0 {
+			tp.PreviousValue = values[i-1]
+			tp.AbsoluteChange = v - values[i-1]
+			if values[i-1] != 0 {
+				tp.PercentageChange = math.Round(tp.AbsoluteChange/values[i-1]*10000) / 100
 			}
-			if i > 0 {
-				tp.PreviousValue = values[i-1]
-				tp.AbsoluteChange = v - values[i-1]
-				if values[i-1] != 0 {
-					tp.PercentageChange = math.Round(tp.AbsoluteChange/values[i-1]*10000) / 100
-				}
-				if math.Abs(tp.PercentageChange) < 1 {
-					tp.Direction = "FLAT"
-				} else if tp.AbsoluteChange > 0 {
-					tp.Direction = "UP"
-				} else {
-					tp.Direction = "DOWN"
-				}
+			if math.Abs(tp.PercentageChange) < 1 {
+				tp.Direction = "FLAT"
+			} else if tp.AbsoluteChange > 0 {
+				tp.Direction = "UP"
+			} else {
+				tp.Direction = "DOWN"
 			}
-			trendPoints[i] = tp
 		}
+		trendPoints[i] = tp
+	}
 
-		slope, r2 := linearRegression(values)
-		overallTrend := "VOLATILE"
-		if len(values) >= 2 {
-			if r2 >= 0.5 {
-				if slope > 0 {
-					overallTrend = "GROWING"
-				} else {
-					overallTrend = "DECLINING"
-				}
+	slope, r2 := linearRegression(values)
+	overallTrend := "VOLATILE"
+	if len(values) >= 2 {
+		if r2 >= 0.5 {
+			if slope > 0 {
+				overallTrend = "GROWING"
+			} else {
+				overallTrend = "DECLINING"
 			}
 		}
+	}
 
-		results = append(results, TrendSummary{
-			PaymentMethodCode: k.pm,
-			PaymentMethodName: nameMap[k],
-			CountryCode:       k.cc,
-			Metric:            metric,
-			Points:            trendPoints,
-			OverallTrend:      overallTrend,
-			Slope:             math.Round(slope*100) / 100,
-			RSquared:          math.Round(r2*10000) / 10000,
-		})
+	return TrendSummary{
+		PaymentMethodCode: pm,
+		PaymentMethodName: name,
+		CountryCode:       cc,
+		Metric:            metric,
+		Points:            trendPoints,
+		OverallTrend:      overallTrend,
+		Slope:             math.Round(slope*100) / 100,
+		RSquared:          math.Round(r2*10000) / 10000,
 	}
+}
 
-	return results, nil
+// periodStart parses the date part of a DATE_TRUNC(...)::text period label.
+func periodStart(period string) time.Time {
+	if len(period) >= 10 {
+		if t, err := time.Parse("2006-01-02", period[:10]); err == nil {
+			return t
+		}
+	}
+	return time.Now()
 }
 
 func extractMetricValues(buckets []repository.TrendBucket, metric string) []float64 {
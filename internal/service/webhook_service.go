@@ -0,0 +1,245 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+const (
+	webhookBatchSize   = 20
+	webhookSendTimeout = 10 * time.Second
+	// webhookLease outlasts a whole batch of sends at webhookSendTimeout each,
+	// so no delivery is re-claimed while it is still being sent.
+	webhookLease     = webhookBatchSize*webhookSendTimeout + time.Minute
+	retryBaseBackoff = 30 * time.Second
+	retryMaxBackoff  = 6 * time.Hour
+	webhookListLimit = 1000
+
+	// Headers set on every delivery. The signature is
+	// sha256=hex(HMAC-SHA256(secret, "<timestamp>.<body>")).
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var ErrInvalidWebhook = errors.New("invalid webhook subscription")
+
+// ErrDeliveryPending is returned when redelivering a delivery that is still
+// queued or in flight.
+var ErrDeliveryPending = errors.New("webhook delivery is still pending")
+
+// metadataHosts are cloud instance metadata endpoints reachable by name.
+var metadataHosts = map[string]bool{"metadata": true, "metadata.google.internal": true}
+
+// awsMetadataIPv6 is the instance metadata endpoint on AWS's IPv6 network.
+var awsMetadataIPv6 = net.ParseIP("fd00:ec2::254")
+
+// checkCallbackURL reports why raw is not a URL the service may POST to:
+// it must be absolute http(s) and must not name a link-local address or a
+// metadata host, so a subscription cannot reach instance credentials at
+// 169.254.169.254 and the like.
+func checkCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("must be an absolute http(s) URL")
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if metadataHosts[host] {
+		return errors.New("must not point at a cloud metadata endpoint")
+	}
+	if ip := net.ParseIP(host); ip != nil &&
+		(ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.Equal(awsMetadataIPv6)) {
+		return errors.New("must not point at a link-local or metadata address")
+	}
+	return nil
+}
+
+// WebhookService manages subscriptions, fills the delivery outbox from insight
+// events and delivers due outbox rows.
+type WebhookService struct {
+	repo        *repository.WebhookRepository
+	client      *http.Client
+	maxAttempts int
+}
+
+func NewWebhookService(repo *repository.WebhookRepository, client *http.Client, maxAttempts int) *WebhookService {
+	return &WebhookService{repo: repo, client: client, maxAttempts: maxAttempts}
+}
+
+// CreateSubscription stores a subscription, generating a secret when none is
+// given. The returned subscription is the only place the secret is shown.
+func (s *WebhookService) CreateSubscription(ctx context.Context, sub model.WebhookSubscription) (*model.WebhookSubscription, string, error) {
+	if err := checkCallbackURL(sub.URL); err != nil {
+		return nil, "", fmt.Errorf("%w: url %v", ErrInvalidWebhook, err)
+	}
+	for _, e := range sub.EventTypes {
+		switch e {
+		case model.InsightEventOpened, model.InsightEventResolved, model.InsightEventStateChanged, model.InsightEventSeverityChanged:
+		default:
+			return nil, "", fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, e)
+		}
+	}
+	if sub.Secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", fmt.Errorf("generate webhook secret: %w", err)
+		}
+		sub.Secret = hex.EncodeToString(buf)
+	}
+	sub.Active = true
+
+	saved, err := s.repo.CreateSubscription(ctx, sub)
+	if err != nil {
+		return nil, "", err
+	}
+	return saved, saved.Secret, nil
+}
+
+func (s *WebhookService) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	return s.repo.ListSubscriptions(ctx)
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id string) error {
+	return s.repo.DeleteSubscription(ctx, id)
+}
+
+// ListDeliveries returns the most recent deliveries; status DEAD is the
+// dead-letter view.
+func (s *WebhookService) ListDeliveries(ctx context.Context, status, subscriptionID string) ([]model.WebhookDelivery, error) {
+	return s.repo.ListDeliveries(ctx, status, subscriptionID, webhookListLimit)
+}
+
+// Redeliver re-queues a delivered or failed delivery. It returns
+// ErrDeliveryPending for one that has not finished, so a subscriber never
+// gets it twice.
+func (s *WebhookService) Redeliver(ctx context.Context, id string) error {
+	queued, err := s.repo.Redeliver(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !queued {
+		return ErrDeliveryPending
+	}
+	return nil
+}
+
+// NotifyInsightEvents implements InsightNotifier by writing outbox rows.
+func (s *WebhookService) NotifyInsightEvents(ctx context.Context, tx pgx.Tx, events []InsightEvent) error {
+	out := make([]repository.WebhookEvent, 0, len(events))
+	for _, e := range events {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("marshal webhook payload: %w", err)
+		}
+		out = append(out, repository.WebhookEvent{
+			EventType:   e.Type,
+			InsightID:   e.Insight.InsightID,
+			InsightType: e.Insight.Type,
+			Severity:    e.Insight.Severity,
+			CountryCode: e.Insight.CountryCode,
+			Payload:     payload,
+		})
+	}
+	_, err := s.repo.Enqueue(ctx, tx, out)
+	return err
+}
+
+// DeliverDue sends one batch of due deliveries and returns how many it
+// attempted.
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	due, err := s.repo.ClaimDue(ctx, webhookBatchSize, webhookLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, d := range due {
+		code, sendErr := s.send(ctx, d)
+		attempts := d.Attempts + 1
+		status := model.WebhookDeliveryDelivered
+		lastErr := ""
+		next := time.Now()
+		if sendErr != nil {
+			lastErr = sendErr.Error()
+			status = model.WebhookDeliveryPending
+			next = next.Add(retryBackoff(attempts))
+			if attempts >= s.maxAttempts {
+				status = model.WebhookDeliveryDead
+			}
+		}
+		if err := s.repo.RecordAttempt(ctx, d.ID, status, attempts, code, lastErr, next); err != nil {
+			return len(due), err
+		}
+	}
+	return len(due), nil
+}
+
+// send posts one signed delivery; any non-2xx response is an error.
+func (s *WebhookService) send(ctx context.Context, d repository.DueDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookSendTimeout)
+	defer cancel()
+
+	ts := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, d.EventType)
+	req.Header.Set(WebhookDeliveryHeader, d.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(d.Secret, ts, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>"; receivers
+// recompute it to verify a delivery.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryBackoff doubles from 30s after each failed attempt, capped at 6h. It
+// paces webhook and notification retries.
+func retryBackoff(attempts int) time.Duration {
+	d := retryBaseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= retryMaxBackoff {
+			return retryMaxBackoff
+		}
+	}
+	return d
+}
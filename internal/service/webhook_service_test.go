@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anyulbade/payment-method-health-monitor/internal/model"
+	"github.com/anyulbade/payment-method-health-monitor/internal/repository"
+)
+
+func TestWebhookSendSignsPayload(t *testing.T) {
+	const secret = "0123456789abcdef"
+	payload := []byte(`{"event":"insight.opened"}`)
+
+	var got *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	svc := NewWebhookService(nil, receiver.Client(), 3)
+	d := repository.DueDelivery{URL: receiver.URL, Secret: secret}
+	d.ID = "delivery-1"
+	d.EventType = model.InsightEventOpened
+	d.Payload = payload
+
+	code, err := svc.send(context.Background(), d)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, code)
+	require.NotNil(t, got)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, model.InsightEventOpened, got.Header.Get(WebhookEventHeader))
+	assert.Equal(t, "delivery-1", got.Header.Get(WebhookDeliveryHeader))
+
+	ts, err := strconv.ParseInt(got.Header.Get(WebhookTimestampHeader), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256="+SignWebhook(secret, ts, payload), got.Header.Get(WebhookSignatureHeader))
+	assert.NotEqual(t, SignWebhook("other-secret-value", ts, payload), SignWebhook(secret, ts, payload))
+}
+
+func TestWebhookSendRejectsNon2xx(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	svc := NewWebhookService(nil, receiver.Client(), 3)
+	code, err := svc.send(context.Background(), repository.DueDelivery{URL: receiver.URL, Secret: "s"})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+}
+
+func TestCheckCallbackURL(t *testing.T) {
+	for _, u := range []string{"https://example.com/hook", "http://10.0.0.5:8080/hook"} {
+		assert.NoError(t, checkCallbackURL(u), u)
+	}
+	for _, u := range []string{
+		"ftp://example.com/hook",
+		"example.com/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://METADATA.google.internal./",
+		"http://[fe80::1]/",
+		"http://[::ffff:169.254.169.254]/",
+		"http://[fd00:ec2::254]/",
+	} {
+		assert.Error(t, checkCallbackURL(u), u)
+	}
+
+	svc := NewWebhookService(nil, nil, 0)
+	_, _, err := svc.CreateSubscription(context.Background(), model.WebhookSubscription{URL: "http://169.254.169.254/"})
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, retryBackoff(1))
+	assert.Equal(t, time.Minute, retryBackoff(2))
+	assert.Equal(t, 4*time.Minute, retryBackoff(4))
+	assert.Equal(t, retryMaxBackoff, retryBackoff(20))
+}
+
+func TestEventForTransition(t *testing.T) {
+	cases := []struct {
+		from, to, want string
+	}{
+		{"", model.InsightStateOpen, model.InsightEventOpened},
+		{model.InsightStateAutoResolved, model.InsightStateOpen, model.InsightEventOpened},
+		{model.InsightStateSnoozed, model.InsightStateOpen, model.InsightEventStateChanged},
+		{model.InsightStateOpen, model.InsightStateAcknowledged, model.InsightEventStateChanged},
+		{model.InsightStateOpen, model.InsightStateAutoResolved, model.InsightEventResolved},
+		{model.InsightStateAcknowledged, model.InsightStateResolved, model.InsightEventResolved},
+	}
+	for _, tc := range cases {
+		got := eventForTransition(model.InsightTransition{FromState: tc.from, ToState: tc.to})
+		assert.Equal(t, tc.want, got, "%s -> %s", tc.from, tc.to)
+	}
+}
@@ -0,0 +1,24 @@
+CREATE TABLE fx_rates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    currency VARCHAR(3) NOT NULL,
+    rate_to_usd DECIMAL(18,10) NOT NULL,
+    effective_from DATE NOT NULL,
+    effective_to DATE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_fx_currency CHECK (currency ~ '^[A-Z]{3}$'),
+    CONSTRAINT chk_fx_rate_positive CHECK (rate_to_usd > 0),
+    CONSTRAINT chk_fx_dates CHECK (effective_to IS NULL OR effective_to > effective_from),
+    CONSTRAINT excl_fx_overlap EXCLUDE USING gist (
+        currency WITH =,
+        daterange(effective_from, effective_to, '[)') WITH &&
+    )
+);
+
+INSERT INTO fx_rates (currency, rate_to_usd, effective_from) VALUES ('USD', 1, '2000-01-01');
+
+-- Carry over the single rate per country for databases seeded before rate history existed.
+INSERT INTO fx_rates (currency, rate_to_usd, effective_from)
+SELECT currency, MIN(fx_rate_to_usd), DATE '2000-01-01'
+FROM countries
+WHERE currency <> 'USD'
+GROUP BY currency;
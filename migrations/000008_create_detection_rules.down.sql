@@ -0,0 +1,3 @@
+DROP FUNCTION IF EXISTS detection_param(TEXT, TEXT, TEXT, TEXT, TEXT);
+DROP TABLE IF EXISTS detection_rule_versions;
+DROP TABLE IF EXISTS detection_rules;
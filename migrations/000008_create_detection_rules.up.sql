@@ -0,0 +1,45 @@
+CREATE TABLE detection_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    rule_set VARCHAR(50) NOT NULL,
+    country_code VARCHAR(2) REFERENCES countries(code),
+    payment_method_type VARCHAR(50),
+    payment_method_code VARCHAR(50) REFERENCES payment_methods(code),
+    params JSONB NOT NULL DEFAULT '{}',
+    version INT NOT NULL DEFAULT 1,
+    updated_by VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_rule_params_object CHECK (jsonb_typeof(params) = 'object'),
+    CONSTRAINT chk_rule_pm_type CHECK (payment_method_type IN ('CARD','CASH','BANK_TRANSFER','WALLET','BNPL')),
+    CONSTRAINT uq_detection_rule_scope UNIQUE NULLS NOT DISTINCT
+        (rule_set, country_code, payment_method_type, payment_method_code)
+);
+
+CREATE TABLE detection_rule_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    rule_id UUID NOT NULL REFERENCES detection_rules(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    params JSONB NOT NULL,
+    changed_by VARCHAR(100) NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (rule_id, version)
+);
+
+-- detection_param resolves one parameter for a row: the most specific rule
+-- that sets it wins (method > type > country > global).
+CREATE FUNCTION detection_param(p_rule_set TEXT, p_key TEXT, p_country TEXT, p_type TEXT, p_method TEXT)
+RETURNS NUMERIC
+LANGUAGE sql STABLE AS $$
+    SELECT (dr.params->>p_key)::numeric
+    FROM detection_rules dr
+    WHERE dr.rule_set = p_rule_set
+        AND dr.params ? p_key
+        AND (dr.country_code IS NULL OR dr.country_code = p_country)
+        AND (dr.payment_method_type IS NULL OR dr.payment_method_type = p_type)
+        AND (dr.payment_method_code IS NULL OR dr.payment_method_code = p_method)
+    ORDER BY (dr.payment_method_code IS NOT NULL)::int * 4
+        + (dr.payment_method_type IS NOT NULL)::int * 2
+        + (dr.country_code IS NOT NULL)::int DESC
+    LIMIT 1
+$$;
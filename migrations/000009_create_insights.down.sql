@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS insight_notes;
+DROP TABLE IF EXISTS insight_transitions;
+DROP TABLE IF EXISTS insights;
@@ -0,0 +1,42 @@
+CREATE TABLE insights (
+    insight_id VARCHAR(64) PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    payment_method_code VARCHAR(50) NOT NULL DEFAULT '',
+    country_code VARCHAR(2) NOT NULL DEFAULT '',
+    severity VARCHAR(10) NOT NULL,
+    state VARCHAR(20) NOT NULL DEFAULT 'OPEN',
+    snoozed_until TIMESTAMPTZ,
+    assignee VARCHAR(100) NOT NULL DEFAULT '',
+    first_seen TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ,
+    snapshot JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_insight_state CHECK (state IN ('OPEN','ACKNOWLEDGED','SNOOZED','RESOLVED','AUTO_RESOLVED')),
+    CONSTRAINT chk_insight_snooze CHECK (state <> 'SNOOZED' OR snoozed_until IS NOT NULL)
+);
+
+CREATE INDEX idx_insights_state ON insights (state);
+CREATE INDEX idx_insights_type_country ON insights (type, country_code);
+
+CREATE TABLE insight_transitions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    insight_id VARCHAR(64) NOT NULL REFERENCES insights(insight_id) ON DELETE CASCADE,
+    from_state VARCHAR(20) NOT NULL DEFAULT '',
+    to_state VARCHAR(20) NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_insight_transitions_insight ON insight_transitions (insight_id, created_at);
+
+CREATE TABLE insight_notes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    insight_id VARCHAR(64) NOT NULL REFERENCES insights(insight_id) ON DELETE CASCADE,
+    author VARCHAR(100) NOT NULL,
+    note TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_insight_notes_insight ON insight_notes (insight_id, created_at);
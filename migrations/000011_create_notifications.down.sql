@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS notification_log;
+DROP TABLE IF EXISTS notification_queue;
+DROP TABLE IF EXISTS notification_routes;
+DROP TABLE IF EXISTS notification_channels;
@@ -0,0 +1,64 @@
+CREATE TABLE notification_channels (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL UNIQUE,
+    kind VARCHAR(20) NOT NULL,
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    webhook_url TEXT NOT NULL DEFAULT '',
+    subject_template TEXT NOT NULL DEFAULT '',
+    body_template TEXT NOT NULL DEFAULT '',
+    rate_limit_per_hour INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_channel_kind CHECK (kind IN ('EMAIL','CHAT_WEBHOOK','LOG')),
+    CONSTRAINT chk_channel_rate_limit CHECK (rate_limit_per_hour >= 0)
+);
+
+CREATE TABLE notification_routes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    priority INT NOT NULL DEFAULT 100,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    insight_types TEXT[] NOT NULL DEFAULT '{}',
+    severities TEXT[] NOT NULL DEFAULT '{}',
+    countries TEXT[] NOT NULL DEFAULT '{}',
+    channel_id UUID NOT NULL REFERENCES notification_channels(id) ON DELETE CASCADE,
+    delivery VARCHAR(20) NOT NULL DEFAULT 'IMMEDIATE',
+    stop_processing BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_route_delivery CHECK (delivery IN ('IMMEDIATE','DAILY_DIGEST','WEEKLY_DIGEST'))
+);
+
+-- Routed events waiting to be sent, immediately or with the next digest.
+CREATE TABLE notification_queue (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    route_id UUID NOT NULL REFERENCES notification_routes(id) ON DELETE CASCADE,
+    channel_id UUID NOT NULL REFERENCES notification_channels(id) ON DELETE CASCADE,
+    insight_id VARCHAR(64) NOT NULL,
+    event JSONB NOT NULL,
+    digest BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+    attempts INT NOT NULL DEFAULT 0,
+    due_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ,
+    CONSTRAINT chk_notification_status CHECK (status IN ('PENDING','SENT','FAILED'))
+);
+
+CREATE INDEX idx_notification_queue_due ON notification_queue (due_at) WHERE status = 'PENDING';
+
+-- One row per message sent (or attempted); rate limits count SENT rows.
+CREATE TABLE notification_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    channel_id UUID NOT NULL REFERENCES notification_channels(id) ON DELETE CASCADE,
+    route_id UUID NOT NULL REFERENCES notification_routes(id) ON DELETE CASCADE,
+    item_count INT NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_notification_log_status CHECK (status IN ('SENT','FAILED'))
+);
+
+CREATE INDEX idx_notification_log_channel ON notification_log (channel_id, created_at);
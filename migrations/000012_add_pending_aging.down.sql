@@ -0,0 +1,5 @@
+DELETE FROM detection_rules
+WHERE rule_set = 'stuck_payments' AND payment_method_type = 'CASH'
+    AND country_code IS NULL AND payment_method_code IS NULL;
+
+DROP INDEX IF EXISTS idx_txn_pending;
@@ -0,0 +1,14 @@
+-- Pending transactions are a small slice of the table and are read by age.
+CREATE INDEX idx_txn_pending ON transactions (payment_method_code, country_code, transaction_date)
+    WHERE status = 'PENDING';
+
+-- Cash vouchers settle when the customer pays at a store, so they get a
+-- longer window than the 24h default. Narrow it per method with a method rule.
+INSERT INTO detection_rules (rule_set, payment_method_type, params, updated_by) VALUES
+    ('stuck_payments', 'CASH', '{"settlement_window_hours": 72}', 'migration');
+
+INSERT INTO detection_rule_versions (rule_id, version, params, changed_by, note)
+SELECT id, version, params, updated_by, 'initial cash settlement window'
+FROM detection_rules
+WHERE rule_set = 'stuck_payments' AND payment_method_type = 'CASH'
+    AND country_code IS NULL AND payment_method_code IS NULL;
@@ -0,0 +1,21 @@
+-- Analyst-defined insight rules: an expression over per-method metrics plus
+-- how matching rows are scored and described.
+CREATE TABLE custom_insight_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL UNIQUE,
+    expression TEXT NOT NULL,
+    score_field VARCHAR(50) NOT NULL,
+    severity_tiers JSONB NOT NULL DEFAULT '[]',
+    default_severity VARCHAR(10) NOT NULL DEFAULT 'MEDIUM',
+    inverted BOOLEAN NOT NULL DEFAULT FALSE,
+    lookback_days INT NOT NULL DEFAULT 30,
+    description_template TEXT NOT NULL DEFAULT '',
+    recommended_action TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_custom_rule_tiers_array CHECK (jsonb_typeof(severity_tiers) = 'array'),
+    CONSTRAINT chk_custom_rule_severity CHECK (default_severity IN ('HIGH','MEDIUM','LOW')),
+    CONSTRAINT chk_custom_rule_lookback CHECK (lookback_days BETWEEN 1 AND 730)
+);
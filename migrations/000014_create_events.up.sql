@@ -0,0 +1,19 @@
+-- Operational events: planned maintenance, outages, pricing changes, launches
+-- and campaigns. Empty scope columns match any method, country or provider.
+CREATE TABLE events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind VARCHAR(20) NOT NULL,
+    title VARCHAR(200) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    payment_method_code VARCHAR(50) REFERENCES payment_methods(code),
+    country_code VARCHAR(2) REFERENCES countries(code),
+    provider VARCHAR(100),
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    created_by VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_event_kind CHECK (kind IN ('MAINTENANCE','OUTAGE','PRICING_CHANGE','LAUNCH','CAMPAIGN')),
+    CONSTRAINT chk_event_range CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX idx_events_range ON events (starts_at, ends_at);
@@ -0,0 +1,16 @@
+-- Analyst verdicts on tracked insights. thresholds copies the parameters the
+-- insight was detected with, so precision can be reported per setting.
+CREATE TABLE insight_feedback (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    insight_id VARCHAR(64) NOT NULL REFERENCES insights(insight_id) ON DELETE CASCADE,
+    insight_type VARCHAR(50) NOT NULL,
+    thresholds JSONB NOT NULL DEFAULT '{}',
+    verdict VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    author VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_feedback_verdict CHECK (verdict IN ('USEFUL','FALSE_POSITIVE','DUPLICATE'))
+);
+
+CREATE INDEX idx_insight_feedback_insight ON insight_feedback (insight_id, created_at);
+CREATE INDEX idx_insight_feedback_type ON insight_feedback (insight_type);